@@ -1,19 +1,44 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"backend/internal/audit"
 	"backend/internal/database"
+	"backend/internal/encryption"
+	"backend/internal/grpcserver"
 	"backend/internal/handlers"
+	"backend/internal/idempotency"
+	"backend/internal/middleware"
 	"backend/internal/models"
-	"github.com/gorilla/mux"
+	"backend/internal/openapi"
+	"backend/internal/outbox"
+	"backend/internal/webhooks"
+	"backend/pkg/pb"
+	"backend/pkg/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 var testServer *httptest.Server
@@ -37,9 +62,30 @@ func TestMain(m *testing.M) {
 		os.Exit(1)
 	}
 
-	// Setup test server
-	r := setupRouter()
-	testServer = httptest.NewServer(r)
+	documentsDir, err := os.MkdirTemp("", "documents")
+	if err != nil {
+		fmt.Printf("Failed to create document storage: %v\n", err)
+		os.Exit(1)
+	}
+	defer os.RemoveAll(documentsDir)
+
+	// Setup test server with the same stack as main. Responses are checked
+	// too, so handlers drifting from the OpenAPI document fail the tests with
+	// a 500
+	srv, err := server.New(context.Background(), server.Config{
+		DB:                 database.DB,
+		DatabaseURL:        testDBURL,
+		DocumentStorageDir: documentsDir,
+		ValidateResponses:  true,
+		AdminActors:        []string{"admin"},
+		APIV1DeprecatedAt:  time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC),
+		APIV1SunsetAt:      time.Date(2027, time.April, 18, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		fmt.Printf("Failed to set up the server: %v\n", err)
+		os.Exit(1)
+	}
+	testServer = httptest.NewServer(srv)
 	defer testServer.Close()
 
 	// Run tests
@@ -50,44 +96,17 @@ func TestMain(m *testing.M) {
 	os.Exit(code)
 }
 
-func setupRouter() *mux.Router {
-	r := mux.NewRouter()
-	
-	r.HandleFunc("/health", handlers.HealthCheck).Methods("GET")
-	r.HandleFunc("/api/items", handlers.GetItems).Methods("GET")
-	r.HandleFunc("/api/items", handlers.CreateItem).Methods("POST")
-	r.HandleFunc("/api/items/{id}", handlers.GetItem).Methods("GET")
-	
-	// Client routes
-	r.HandleFunc("/api/clients", handlers.CreateClient).Methods("POST")
-	r.HandleFunc("/api/clients/{id}", handlers.GetClient).Methods("GET")
-	r.HandleFunc("/api/clients/{id}", handlers.UpdateClient).Methods("PUT")
-	r.HandleFunc("/api/clients/{id}", handlers.DeleteClient).Methods("DELETE")
-
-	// Bank routes
-	r.HandleFunc("/api/banks", handlers.GetBanks).Methods("GET")
-	r.HandleFunc("/api/banks", handlers.CreateBank).Methods("POST")
-	r.HandleFunc("/api/banks/{id}", handlers.GetBank).Methods("GET")
-	r.HandleFunc("/api/banks/{id}", handlers.UpdateBank).Methods("PUT")
-	r.HandleFunc("/api/banks/{id}", handlers.DeleteBank).Methods("DELETE")
-
-	// Credit routes
-	r.HandleFunc("/api/credits", handlers.GetCredits).Methods("GET")
-	r.HandleFunc("/api/credits", handlers.CreateCredit).Methods("POST")
-	r.HandleFunc("/api/credits/{id}", handlers.GetCredit).Methods("GET")
-	r.HandleFunc("/api/credits/{id}", handlers.UpdateCredit).Methods("PUT")
-	r.HandleFunc("/api/credits/{id}", handlers.DeleteCredit).Methods("DELETE")
-	r.HandleFunc("/api/clients/{clientId}/credits", handlers.GetCreditsByClient).Methods("GET")
-	r.HandleFunc("/api/banks/{bankId}/credits", handlers.GetCreditsByBank).Methods("GET")
-
-	return r
-}
-
 func cleanupTestData() {
+	database.DB.Exec("DELETE FROM client_documents")
 	database.DB.Exec("DELETE FROM credits")
 	database.DB.Exec("DELETE FROM clients")
 	database.DB.Exec("DELETE FROM banks")
 	database.DB.Exec("DELETE FROM items")
+	database.DB.Exec("DELETE FROM audit_events")
+	database.DB.Exec("DELETE FROM outbox")
+	database.DB.Exec("DELETE FROM webhook_subscriptions")
+	database.DB.Exec("DELETE FROM idempotency_keys")
+	database.DB.Exec("DELETE FROM import_jobs")
 }
 
 func TestIntegrationHealthCheck(t *testing.T) {
@@ -450,6 +469,10 @@ func TestIntegrationCreditsCRUD(t *testing.T) {
 		t.Errorf("Expected status 200, got %d", resp7.StatusCode)
 	}
 
+	// Approval requires verified KYC documents
+	uploadVerifiedDocument(t, createdClient.ID, models.DocumentTypeIDDocument)
+	uploadVerifiedDocument(t, createdClient.ID, models.DocumentTypeProofOfAddress)
+
 	// Test Update Credit
 	updatedCredit := createdCredit
 	updatedCredit.Status = models.CreditStatusApproved
@@ -470,6 +493,31 @@ func TestIntegrationCreditsCRUD(t *testing.T) {
 		t.Errorf("Expected status 200, got %d", resp8.StatusCode)
 	}
 
+	// An approved credit cannot move to a client without documents
+	otherClient := client
+	otherClient.Email = "jane.doe@example.com"
+	jsonData5, _ := json.Marshal(otherClient)
+	resp10, err := http.Post(testServer.URL+"/api/clients", "application/json", bytes.NewBuffer(jsonData5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp10.Body.Close()
+	json.NewDecoder(resp10.Body).Decode(&otherClient)
+
+	updatedCredit.ClientID = otherClient.ID
+	jsonData6, _ := json.Marshal(updatedCredit)
+	req3, _ := http.NewRequest("PUT", fmt.Sprintf("%s/api/credits/%d", testServer.URL, createdCredit.ID), bytes.NewBuffer(jsonData6))
+	req3.Header.Set("Content-Type", "application/json")
+	resp11, err := httpClient.Do(req3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp11.Body.Close()
+
+	if resp11.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 moving an approved credit to a client without documents, got %d", resp11.StatusCode)
+	}
+
 	// Test Delete Credit
 	req2, _ := http.NewRequest("DELETE", fmt.Sprintf("%s/api/credits/%d", testServer.URL, createdCredit.ID), nil)
 	resp9, err := httpClient.Do(req2)
@@ -481,4 +529,1662 @@ func TestIntegrationCreditsCRUD(t *testing.T) {
 	if resp9.StatusCode != http.StatusOK {
 		t.Errorf("Expected status 200, got %d", resp9.StatusCode)
 	}
-}
\ No newline at end of file
+}
+
+func TestIntegrationCreditDebtToIncomeOnUpdate(t *testing.T) {
+	cleanupTestData()
+
+	var client models.Client
+	body, _ := json.Marshal(models.Client{FullName: "Dora Debt", Email: "dora.debt@example.com",
+		BirthDate: time.Date(1985, 1, 1, 0, 0, 0, 0, time.UTC), Country: "USA"})
+	resp, err := http.Post(testServer.URL+"/api/clients", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.NewDecoder(resp.Body).Decode(&client)
+	resp.Body.Close()
+
+	var bank models.Bank
+	body, _ = json.Marshal(models.Bank{Name: "DTI Bank", Type: models.BankTypePrivate})
+	resp, err = http.Post(testServer.URL+"/api/banks", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.NewDecoder(resp.Body).Decode(&bank)
+	resp.Body.Close()
+
+	req, _ := http.NewRequest("PUT", fmt.Sprintf("%s/api/clients/%d/financial-profile", testServer.URL, client.ID),
+		bytes.NewBufferString(`{"monthly_income":1000,"employment_type":"EMPLOYED","external_debts":0}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	credit := models.Credit{ClientID: client.ID, BankID: bank.ID, MinPayment: 100, MaxPayment: 200,
+		TermMonths: 12, CreditType: models.CreditTypeAuto, Status: models.CreditStatusPending}
+	body, _ = json.Marshal(credit)
+	resp, err = http.Post(testServer.URL+"/api/credits", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.NewDecoder(resp.Body).Decode(&credit)
+	resp.Body.Close()
+	if credit.DebtToIncome == nil || *credit.DebtToIncome != 0.2 || credit.DTIFlagged {
+		t.Fatalf("Expected an unflagged ratio of 0.2, got %v %v", credit.DebtToIncome, credit.DTIFlagged)
+	}
+
+	// The credit's own old payment is replaced, not added to
+	for _, tc := range []struct {
+		maxPayment float64
+		ratio      float64
+		flagged    bool
+	}{{600, 0.6, true}, {300, 0.3, false}} {
+		credit.MaxPayment = tc.maxPayment
+		body, _ = json.Marshal(credit)
+		req, _ := http.NewRequest("PUT", fmt.Sprintf("%s/api/credits/%d", testServer.URL, credit.ID), bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err = http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var updated models.Credit
+		json.NewDecoder(resp.Body).Decode(&updated)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || updated.DebtToIncome == nil || *updated.DebtToIncome != tc.ratio || updated.DTIFlagged != tc.flagged {
+			t.Errorf("Expected max payment %v to store ratio %v flagged %v, got %d %v %v",
+				tc.maxPayment, tc.ratio, tc.flagged, resp.StatusCode, updated.DebtToIncome, updated.DTIFlagged)
+		}
+	}
+}
+
+func uploadVerifiedDocument(t *testing.T, clientID int, docType models.DocumentType) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("type", string(docType))
+	writer.WriteField("expiry_date", time.Now().AddDate(1, 0, 0).Format("2006-01-02"))
+	part, _ := writer.CreateFormFile("file", "document.pdf")
+	part.Write([]byte("%PDF-1.4 test document"))
+	writer.Close()
+
+	resp, err := http.Post(fmt.Sprintf("%s/api/clients/%d/documents", testServer.URL, clientID), writer.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status 201 uploading document, got %d", resp.StatusCode)
+	}
+
+	var doc models.ClientDocument
+	json.NewDecoder(resp.Body).Decode(&doc)
+
+	req, _ := http.NewRequest("PUT", fmt.Sprintf("%s/api/clients/%d/documents/%d/status", testServer.URL, clientID, doc.ID),
+		bytes.NewBufferString(`{"status":"VERIFIED"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp2, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp2.Body.Close()
+
+	if resp2.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 verifying document, got %d", resp2.StatusCode)
+	}
+}
+
+func TestIntegrationClientErasure(t *testing.T) {
+	database.DB.Exec("DELETE FROM credits")
+	database.DB.Exec("DELETE FROM clients")
+	database.DB.Exec("DELETE FROM banks")
+
+	client := models.Client{
+		FullName:  "John Doe",
+		Email:     "john.doe@example.com",
+		BirthDate: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+		Country:   "USA",
+	}
+	jsonData, _ := json.Marshal(client)
+	resp, err := http.Post(testServer.URL+"/api/clients", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var createdClient models.Client
+	json.NewDecoder(resp.Body).Decode(&createdClient)
+
+	var bankID int
+	database.DB.QueryRow("INSERT INTO banks (name, type) VALUES ('Test Bank', 'PRIVATE') RETURNING id").Scan(&bankID)
+	database.DB.Exec(`INSERT INTO credits (client_id, bank_id, min_payment, max_payment, term_months, credit_type)
+		VALUES ($1, $2, 100, 1000, 12, 'AUTO')`, createdClient.ID, bankID)
+
+	// Deletion is soft and can be undone
+	req, _ := http.NewRequest("DELETE", fmt.Sprintf("%s/api/clients/%d", testServer.URL, createdClient.ID), nil)
+	resp2, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp2.Body.Close()
+
+	if resp2.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp2.StatusCode)
+	}
+
+	resp2b, err := http.Get(fmt.Sprintf("%s/api/clients/%d", testServer.URL, createdClient.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp2b.Body.Close()
+
+	if resp2b.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status 404 for deleted client, got %d", resp2b.StatusCode)
+	}
+
+	credit := fmt.Sprintf(`{"client_id":%d,"bank_id":%d,"min_payment":100,"max_payment":1000,"term_months":12,"credit_type":"AUTO"}`,
+		createdClient.ID, bankID)
+	resp2d, err := http.Post(testServer.URL+"/api/credits", "application/json", bytes.NewBufferString(credit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp2d.Body.Close()
+
+	if resp2d.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 creating a credit for a deleted client, got %d", resp2d.StatusCode)
+	}
+
+	// Restore is guarded by If-Match like delete; the delete moved the version on
+	staleReq, _ := http.NewRequest("POST", fmt.Sprintf("%s/api/clients/%d/restore", testServer.URL, createdClient.ID), nil)
+	staleReq.Header.Set("If-Match", `"1"`)
+	respStale, err := http.DefaultClient.Do(staleReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	respStale.Body.Close()
+	if respStale.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("Expected a stale If-Match on restore to fail with 412, got %d", respStale.StatusCode)
+	}
+
+	resp2c, err := http.Post(fmt.Sprintf("%s/api/clients/%d/restore", testServer.URL, createdClient.ID), "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp2c.Body.Close()
+
+	if resp2c.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200 restoring client, got %d", resp2c.StatusCode)
+	}
+
+	// A duplicate merged into the client is part of its history
+	var duplicateID int
+	database.DB.QueryRow(`INSERT INTO clients (full_name, email, birth_date, country)
+		VALUES ('John Doe', 'j.doe@example.com', '1990-01-01', 'USA') RETURNING id`).Scan(&duplicateID)
+	respMerge, err := http.Post(fmt.Sprintf("%s/api/clients/%d/merge", testServer.URL, createdClient.ID), "application/json",
+		bytes.NewBufferString(fmt.Sprintf(`{"duplicate_id":%d}`, duplicateID)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	respMerge.Body.Close()
+	if respMerge.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200 merging the duplicate, got %d", respMerge.StatusCode)
+	}
+
+	resp3, err := http.Post(fmt.Sprintf("%s/api/clients/%d/erase", testServer.URL, createdClient.ID), "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp3.Body.Close()
+
+	if resp3.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp3.StatusCode)
+	}
+
+	resp4, err := http.Get(fmt.Sprintf("%s/api/clients/%d/export", testServer.URL, createdClient.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp4.Body.Close()
+
+	var export models.ClientExport
+	json.NewDecoder(resp4.Body).Decode(&export)
+
+	if export.Client.Email == client.Email || export.Client.FullName == client.FullName {
+		t.Errorf("Expected personal data to be anonymized, got %+v", export.Client)
+	}
+	if len(export.Credits) != 1 {
+		t.Errorf("Expected credits to be retained after erasure, got %d", len(export.Credits))
+	}
+	if len(export.Merges) != 1 || export.Merges[0].DuplicateID != duplicateID {
+		t.Errorf("Expected the merge of the duplicate in the export, got %+v", export.Merges)
+	}
+
+	// The audit trail covers the client, the duplicate merged into it and its credits
+	operations := map[string]bool{}
+	for _, event := range export.AuditEvents {
+		operations[fmt.Sprintf("%s/%d/%s", event.ResourceType, event.ResourceID, event.Operation)] = true
+	}
+	for _, key := range []string{
+		fmt.Sprintf("client/%d/%s", createdClient.ID, audit.OpCreate),
+		fmt.Sprintf("client/%d/%s", createdClient.ID, audit.OpDelete),
+		fmt.Sprintf("client/%d/%s", createdClient.ID, audit.OpRestore),
+		fmt.Sprintf("client/%d/%s", createdClient.ID, audit.OpMerge),
+		fmt.Sprintf("client/%d/%s", createdClient.ID, audit.OpErase),
+		fmt.Sprintf("client/%d/%s", duplicateID, audit.OpMerge),
+	} {
+		if !operations[key] {
+			t.Errorf("Expected audit event %s in the export, got %v", key, operations)
+		}
+	}
+	for key := range operations {
+		if !strings.HasPrefix(key, "client/") && !strings.HasPrefix(key, "credit/") {
+			t.Errorf("Expected only client and credit audit events, got %s", key)
+		}
+	}
+}
+
+func TestIntegrationReencryptionSkipsCaseDuplicates(t *testing.T) {
+	cleanupTestData()
+	defer cleanupTestData()
+
+	// Emails differing only in case were allowed before the blind index
+	var first, second int
+	for i, email := range []string{"Dana.Dup@example.com", "dana.dup@example.com"} {
+		id := &first
+		if i == 1 {
+			id = &second
+		}
+		err := database.DB.QueryRow(`
+			INSERT INTO clients (full_name, email, birth_date, country) VALUES ('Dana Dup', $1, '1990-01-01', 'USA')
+			RETURNING id`, email).Scan(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32))
+	keyFile := t.TempDir() + "/keys.json"
+	keys := `{"current_key_id": "k1", "keys": {"k1": "` + key + `"}, "index_key": "` + key + `"}`
+	if err := os.WriteFile(keyFile, []byte(keys), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	provider, err := encryption.NewLocalKeyProvider(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handlers.PIIEncrypter = encryption.NewEncrypter(provider)
+	n, err := handlers.ReencryptClients(context.Background(), 1)
+	handlers.PIIEncrypter = nil
+	if err != nil || n != 1 {
+		t.Fatalf("Expected the run to finish after encrypting one of the duplicates, got %d %v", n, err)
+	}
+
+	var firstEncrypted, secondEncrypted bool
+	database.DB.QueryRow("SELECT email_enc IS NOT NULL FROM clients WHERE id = $1", first).Scan(&firstEncrypted)
+	database.DB.QueryRow("SELECT email_enc IS NOT NULL FROM clients WHERE id = $1", second).Scan(&secondEncrypted)
+	if !firstEncrypted || secondEncrypted {
+		t.Errorf("Expected only the first duplicate to be encrypted, got %v and %v", firstEncrypted, secondEncrypted)
+	}
+
+	// Without the key the encrypted row cannot be searched, which is a
+	// configuration error rather than a free email
+	body, _ := json.Marshal(models.Client{FullName: "Dana Dup", Email: "DANA.DUP@example.com",
+		BirthDate: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC), Country: "USA"})
+	resp, err := http.Post(testServer.URL+"/api/clients", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("Expected the plaintext duplicate to still be found, got %d", resp.StatusCode)
+	}
+	body, _ = json.Marshal(models.Client{FullName: "Nia New", Email: "nia.new@example.com",
+		BirthDate: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC), Country: "USA"})
+	resp, err = http.Post(testServer.URL+"/api/clients", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected 500 looking up an email among encrypted rows without a key, got %d", resp.StatusCode)
+	}
+}
+
+func TestIntegrationPurgeDeleted(t *testing.T) {
+	database.DB.Exec("DELETE FROM audit_events")
+	database.DB.Exec("DELETE FROM credits")
+	database.DB.Exec("DELETE FROM client_documents")
+	database.DB.Exec("DELETE FROM clients")
+
+	client := models.Client{
+		FullName:  "John Doe",
+		Email:     "john.doe@example.com",
+		BirthDate: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+		Country:   "USA",
+	}
+	jsonData, _ := json.Marshal(client)
+	resp, err := http.Post(testServer.URL+"/api/clients", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	json.NewDecoder(resp.Body).Decode(&client)
+	uploadVerifiedDocument(t, client.ID, models.DocumentTypeIDDocument)
+
+	database.DB.Exec("UPDATE clients SET deleted_at = NOW() - INTERVAL '2 days' WHERE id = $1", client.ID)
+	purged, err := handlers.PurgeDeleted(context.Background(), 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged["clients"] != 1 || purged["client_documents"] != 1 {
+		t.Errorf("Expected the client and its document to be purged, got %v", purged)
+	}
+
+	var audited int
+	database.DB.QueryRow(`SELECT COUNT(*) FROM audit_events
+		WHERE resource_type = 'client_document' AND operation = $1`, audit.OpPurge).Scan(&audited)
+	if audited != 1 {
+		t.Errorf("Expected the purged document to be audited, got %d events", audited)
+	}
+}
+
+func TestIntegrationBankOptimisticConcurrency(t *testing.T) {
+	database.DB.Exec("DELETE FROM banks")
+
+	jsonData, _ := json.Marshal(models.Bank{Name: "Test Bank", Type: models.BankTypePrivate})
+	resp, err := http.Post(testServer.URL+"/api/banks", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var createdBank models.Bank
+	json.NewDecoder(resp.Body).Decode(&createdBank)
+	etag := resp.Header.Get("ETag")
+
+	// A stale tag is rejected
+	jsonData2, _ := json.Marshal(models.Bank{Name: "Updated Bank", Type: models.BankTypePrivate})
+	req, _ := http.NewRequest("PUT", fmt.Sprintf("%s/api/banks/%d", testServer.URL, createdBank.ID), bytes.NewBuffer(jsonData2))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"99"`)
+	resp2, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp2.Body.Close()
+
+	if resp2.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("Expected status 412, got %d", resp2.StatusCode)
+	}
+
+	// The current tag is accepted
+	req2, _ := http.NewRequest("PUT", fmt.Sprintf("%s/api/banks/%d", testServer.URL, createdBank.ID), bytes.NewBuffer(jsonData2))
+	req2.Header.Set("Content-Type", "application/json")
+	req2.Header.Set("If-Match", etag)
+	resp3, err := http.DefaultClient.Do(req2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp3.Body.Close()
+
+	if resp3.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp3.StatusCode)
+	}
+
+	// Conditional GET with the new tag is not modified
+	req3, _ := http.NewRequest("GET", fmt.Sprintf("%s/api/banks/%d", testServer.URL, createdBank.ID), nil)
+	req3.Header.Set("If-None-Match", resp3.Header.Get("ETag"))
+	resp4, err := http.DefaultClient.Do(req3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp4.Body.Close()
+
+	if resp4.StatusCode != http.StatusNotModified {
+		t.Errorf("Expected status 304, got %d", resp4.StatusCode)
+	}
+}
+
+func TestIntegrationAuditLog(t *testing.T) {
+	cleanupTestData()
+
+	jsonData, _ := json.Marshal(models.Bank{Name: "Audited Bank", Type: models.BankTypePrivate})
+	req, _ := http.NewRequest("POST", testServer.URL+"/api/banks", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(handlers.ActorHeader, "alice")
+	req.Header.Set(middleware.RequestIDHeader, "req-audit-1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var createdBank models.Bank
+	json.NewDecoder(resp.Body).Decode(&createdBank)
+
+	jsonData2, _ := json.Marshal(models.Bank{Name: "Renamed Bank", Type: models.BankTypePrivate})
+	req2, _ := http.NewRequest("PUT", fmt.Sprintf("%s/api/banks/%d", testServer.URL, createdBank.ID), bytes.NewBuffer(jsonData2))
+	req2.Header.Set("Content-Type", "application/json")
+	req2.Header.Set(handlers.ActorHeader, "bob")
+	resp2, err := http.DefaultClient.Do(req2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp2.Body.Close()
+
+	resp3, err := http.Get(fmt.Sprintf("%s/api/audit?resource_type=bank&resource_id=%d", testServer.URL, createdBank.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp3.Body.Close()
+
+	var events []models.AuditEvent
+	json.NewDecoder(resp3.Body).Decode(&events)
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 audit events, got %d", len(events))
+	}
+	if events[0].Operation != "UPDATE" || events[0].Actor != "bob" {
+		t.Errorf("Expected an UPDATE by bob first, got %s by %s", events[0].Operation, events[0].Actor)
+	}
+	var changes map[string]map[string]interface{}
+	json.Unmarshal(events[0].Changes, &changes)
+	if changes["name"]["after"] != "Renamed Bank" {
+		t.Errorf("Expected the name change in the diff, got %s", events[0].Changes)
+	}
+	if events[1].Operation != "CREATE" || events[1].RequestID != "req-audit-1" {
+		t.Errorf("Expected a CREATE with request id req-audit-1, got %s with %q", events[1].Operation, events[1].RequestID)
+	}
+
+	resp4, err := http.Get(testServer.URL + "/api/audit?actor=alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp4.Body.Close()
+
+	var aliceEvents []models.AuditEvent
+	json.NewDecoder(resp4.Body).Decode(&aliceEvents)
+	if len(aliceEvents) != 1 {
+		t.Errorf("Expected 1 event by alice, got %d", len(aliceEvents))
+	}
+}
+
+func TestIntegrationOutboxRelay(t *testing.T) {
+	cleanupTestData()
+
+	client := models.Client{
+		FullName:  "John Doe",
+		Email:     "john.doe@example.com",
+		BirthDate: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+		Country:   "USA",
+	}
+	jsonData, _ := json.Marshal(client)
+	resp, err := http.Post(testServer.URL+"/api/clients", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var createdClient models.Client
+	json.NewDecoder(resp.Body).Decode(&createdClient)
+
+	jsonData2, _ := json.Marshal(models.Bank{Name: "Test Bank", Type: models.BankTypePrivate})
+	resp2, err := http.Post(testServer.URL+"/api/banks", "application/json", bytes.NewBuffer(jsonData2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp2.Body.Close()
+	var createdBank models.Bank
+	json.NewDecoder(resp2.Body).Decode(&createdBank)
+
+	credit := models.Credit{
+		ClientID:   createdClient.ID,
+		BankID:     createdBank.ID,
+		MinPayment: 100.0,
+		MaxPayment: 1000.0,
+		TermMonths: 12,
+		CreditType: models.CreditTypeAuto,
+		Status:     models.CreditStatusPending,
+	}
+	jsonData3, _ := json.Marshal(credit)
+	resp3, err := http.Post(testServer.URL+"/api/credits", "application/json", bytes.NewBuffer(jsonData3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp3.Body.Close()
+	var createdCredit models.Credit
+	json.NewDecoder(resp3.Body).Decode(&createdCredit)
+
+	credit.Status = models.CreditStatusRejected
+	jsonData4, _ := json.Marshal(credit)
+	req, _ := http.NewRequest("PUT", fmt.Sprintf("%s/api/credits/%d", testServer.URL, createdCredit.ID), bytes.NewBuffer(jsonData4))
+	req.Header.Set("Content-Type", "application/json")
+	resp4, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp4.Body.Close()
+
+	var buf bytes.Buffer
+	relay := outbox.NewRelay(database.DB, outbox.NewWriterPublisher(&buf))
+	if _, err := relay.ProcessBatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	published := map[string]int{}
+	decoder := json.NewDecoder(&buf)
+	for decoder.More() {
+		var msg outbox.Message
+		if err := decoder.Decode(&msg); err != nil {
+			t.Fatal(err)
+		}
+		published[msg.Type]++
+	}
+	for _, eventType := range []string{outbox.ClientCreated, outbox.BankCreated, outbox.CreditCreated, outbox.CreditUpdated, outbox.CreditStatusChanged} {
+		if published[eventType] != 1 {
+			t.Errorf("Expected one %s event, got %d", eventType, published[eventType])
+		}
+	}
+
+	var pending int
+	database.DB.QueryRow("SELECT COUNT(*) FROM outbox WHERE published_at IS NULL").Scan(&pending)
+	if pending != 0 {
+		t.Errorf("Expected every event to be marked published, %d pending", pending)
+	}
+
+	// Published events are kept for the retention period only
+	if n, err := relay.DeletePublished(context.Background()); err != nil || n != 0 {
+		t.Errorf("Expected recent events to be kept, deleted %d: %v", n, err)
+	}
+	database.DB.Exec("UPDATE outbox SET published_at = published_at - INTERVAL '8 days'")
+	total := 0
+	for _, count := range published {
+		total += count
+	}
+	if n, err := relay.DeletePublished(context.Background()); err != nil || n != int64(total) {
+		t.Errorf("Expected %d expired events to be deleted, deleted %d: %v", total, n, err)
+	}
+}
+
+func TestIntegrationWebhookDelivery(t *testing.T) {
+	cleanupTestData()
+
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	deliveries := make(chan received, 10)
+	var failing atomic.Bool
+	failing.Store(true)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		deliveries <- received{header: r.Header, body: body}
+	}))
+	defer receiver.Close()
+
+	jsonData, _ := json.Marshal(models.Bank{Name: "Partner Bank", Type: models.BankTypePrivate})
+	resp, err := http.Post(testServer.URL+"/api/banks", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var createdBank models.Bank
+	json.NewDecoder(resp.Body).Decode(&createdBank)
+
+	hook := models.WebhookSubscription{
+		URL:        receiver.URL,
+		Secret:     "partner-secret",
+		EventTypes: []string{outbox.CreditCreated},
+		BankID:     &createdBank.ID,
+	}
+	jsonData2, _ := json.Marshal(hook)
+	resp2, err := http.Post(testServer.URL+"/api/webhooks", "application/json", bytes.NewBuffer(jsonData2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp2.Body.Close()
+	if resp2.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", resp2.StatusCode)
+	}
+	var createdHook models.WebhookSubscription
+	json.NewDecoder(resp2.Body).Decode(&createdHook)
+
+	jsonData3, _ := json.Marshal(models.Client{
+		FullName:  "John Doe",
+		Email:     "john.doe@example.com",
+		BirthDate: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+		Country:   "USA",
+	})
+	resp3, err := http.Post(testServer.URL+"/api/clients", "application/json", bytes.NewBuffer(jsonData3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp3.Body.Close()
+	var createdClient models.Client
+	json.NewDecoder(resp3.Body).Decode(&createdClient)
+
+	jsonData4, _ := json.Marshal(models.Credit{
+		ClientID:   createdClient.ID,
+		BankID:     createdBank.ID,
+		MinPayment: 100.0,
+		MaxPayment: 1000.0,
+		TermMonths: 12,
+		CreditType: models.CreditTypeAuto,
+		Status:     models.CreditStatusPending,
+	})
+	resp4, err := http.Post(testServer.URL+"/api/credits", "application/json", bytes.NewBuffer(jsonData4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp4.Body.Close()
+
+	ctx := context.Background()
+	relay := outbox.NewRelay(database.DB, &webhooks.FanOut{DB: database.DB})
+	if _, err := relay.ProcessBatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first attempt fails and is scheduled for a retry
+	dispatcher := webhooks.NewDispatcher(database.DB)
+	if n, err := dispatcher.ProcessBatch(ctx); err != nil || n != 1 {
+		t.Fatalf("Expected 1 delivery attempt, got %d, %v", n, err)
+	}
+
+	resp5, err := http.Get(fmt.Sprintf("%s/api/webhooks/%d/deliveries", testServer.URL, createdHook.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp5.Body.Close()
+	var queued []models.WebhookDelivery
+	json.NewDecoder(resp5.Body).Decode(&queued)
+	if len(queued) != 1 || queued[0].Attempts != 1 || queued[0].Status != models.WebhookDeliveryPending {
+		t.Fatalf("Expected one pending delivery with one attempt, got %+v", queued)
+	}
+
+	// Manual redelivery sends it again right away
+	failing.Store(false)
+	resp6, err := http.Post(fmt.Sprintf("%s/api/webhooks/%d/deliveries/%d/redeliver", testServer.URL, createdHook.ID, queued[0].ID), "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp6.Body.Close()
+	if resp6.StatusCode != http.StatusAccepted {
+		t.Errorf("Expected status 202, got %d", resp6.StatusCode)
+	}
+	if _, err := dispatcher.ProcessBatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case delivery := <-deliveries:
+		if delivery.header.Get(webhooks.EventHeader) != outbox.CreditCreated {
+			t.Errorf("Expected a CreditCreated delivery, got %s", delivery.header.Get(webhooks.EventHeader))
+		}
+		err := webhooks.Verify("partner-secret", delivery.header.Get(webhooks.TimestampHeader), delivery.body,
+			delivery.header.Get(webhooks.SignatureHeader), time.Minute, time.Now())
+		if err != nil {
+			t.Errorf("Expected a valid signature, got %v", err)
+		}
+	default:
+		t.Fatal("Expected the receiver to get the redelivered event")
+	}
+
+	resp7, err := http.Get(fmt.Sprintf("%s/api/webhooks/%d/deliveries/%d/attempts", testServer.URL, createdHook.ID, queued[0].ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp7.Body.Close()
+	var attempts []models.WebhookDeliveryAttempt
+	json.NewDecoder(resp7.Body).Decode(&attempts)
+	if len(attempts) != 2 {
+		t.Errorf("Expected 2 logged attempts, got %d", len(attempts))
+	}
+
+	// The dispatcher skips inactive webhooks, so redelivering to one is refused
+	database.DB.Exec("UPDATE webhook_subscriptions SET active = false WHERE id = $1", createdHook.ID)
+	resp8, err := http.Post(fmt.Sprintf("%s/api/webhooks/%d/deliveries/%d/redeliver", testServer.URL, createdHook.ID, queued[0].ID), "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp8.Body.Close()
+	if resp8.StatusCode != http.StatusConflict {
+		t.Errorf("Expected status 409 redelivering to an inactive webhook, got %d", resp8.StatusCode)
+	}
+}
+
+// readStreamEvents reads Server-Sent Events from body until n events have
+// arrived or the stream ends, returning their ids and types.
+func readStreamEvents(t *testing.T, body io.Reader, n int) (ids, types []string) {
+	t.Helper()
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() && len(types) < n {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "id: ") {
+			ids = append(ids, strings.TrimPrefix(line, "id: "))
+		}
+		if strings.HasPrefix(line, "event: ") {
+			types = append(types, strings.TrimPrefix(line, "event: "))
+		}
+	}
+	return ids, types
+}
+
+func TestIntegrationEventStream(t *testing.T) {
+	cleanupTestData()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testServer.URL+"/api/events/stream?types=ClientCreated", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %s", resp.Header.Get("Content-Type"))
+	}
+
+	for _, email := range []string{"first@example.com", "second@example.com"} {
+		jsonData, _ := json.Marshal(models.Client{
+			FullName:  "John Doe",
+			Email:     email,
+			BirthDate: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+			Country:   "USA",
+		})
+		resp, err := http.Post(testServer.URL+"/api/clients", "application/json", bytes.NewBuffer(jsonData))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	ids, types := readStreamEvents(t, resp.Body, 2)
+	if len(types) != 2 || types[0] != outbox.ClientCreated || types[1] != outbox.ClientCreated {
+		t.Fatalf("Expected two ClientCreated events, got %v", types)
+	}
+
+	// Resuming after the first event replays only the second
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel2()
+	req2, _ := http.NewRequestWithContext(ctx2, "GET", testServer.URL+"/api/events/stream?types=ClientCreated", nil)
+	req2.Header.Set("Last-Event-ID", ids[0])
+	resp2, err := http.DefaultClient.Do(req2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp2.Body.Close()
+
+	resumedIDs, _ := readStreamEvents(t, resp2.Body, 1)
+	if len(resumedIDs) != 1 || resumedIDs[0] != ids[1] {
+		t.Errorf("Expected to resume with event %s, got %v", ids[1], resumedIDs)
+	}
+}
+
+func TestIntegrationEventStreamReplaysLateCommits(t *testing.T) {
+	cleanupTestData()
+
+	insert := func(tx *sql.Tx) int64 {
+		var id int64
+		err := tx.QueryRow(`
+			INSERT INTO outbox (event_type, aggregate_type, aggregate_id, payload)
+			VALUES ($1, 'client', 1, '{}') RETURNING id
+		`, outbox.ClientCreated).Scan(&id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	// The lower id commits last, after a client has seen the higher one
+	late, _ := database.DB.Begin()
+	defer late.Rollback()
+	lateID := insert(late)
+	early, _ := database.DB.Begin()
+	insert(early)
+	if err := early.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	var seen int64
+	database.DB.QueryRow("SELECT MAX(stream_seq) FROM outbox").Scan(&seen)
+	if err := late.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testServer.URL+"/api/events/stream", nil)
+	req.Header.Set("Last-Event-ID", strconv.FormatInt(seen, 10))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	ids, _ := readStreamEvents(t, resp.Body, 1)
+	var lateSeq int64
+	database.DB.QueryRow("SELECT stream_seq FROM outbox WHERE id = $1", lateID).Scan(&lateSeq)
+	if len(ids) != 1 || ids[0] != strconv.FormatInt(lateSeq, 10) {
+		t.Errorf("Expected the late commit %d to be replayed, got %v", lateSeq, ids)
+	}
+}
+
+func TestIntegrationIdempotencyKey(t *testing.T) {
+	cleanupTestData()
+
+	post := func(key string, bank models.Bank) *http.Response {
+		jsonData, _ := json.Marshal(bank)
+		req, _ := http.NewRequest("POST", testServer.URL+"/api/banks", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(idempotency.Header, key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	bank := models.Bank{Name: "Idempotent Bank", Type: models.BankTypePrivate}
+	resp := post("create-bank-1", bank)
+	defer resp.Body.Close()
+	var first models.Bank
+	json.NewDecoder(resp.Body).Decode(&first)
+
+	resp2 := post("create-bank-1", bank)
+	defer resp2.Body.Close()
+	var second models.Bank
+	json.NewDecoder(resp2.Body).Decode(&second)
+
+	if resp2.StatusCode != http.StatusCreated || resp2.Header.Get(idempotency.ReplayedHeader) != "true" {
+		t.Errorf("Expected a replayed 201, got %d (replayed=%q)", resp2.StatusCode, resp2.Header.Get(idempotency.ReplayedHeader))
+	}
+	if second.ID != first.ID {
+		t.Errorf("Expected the same bank %d, got %d", first.ID, second.ID)
+	}
+
+	var count int
+	database.DB.QueryRow("SELECT COUNT(*) FROM banks").Scan(&count)
+	if count != 1 {
+		t.Errorf("Expected 1 bank, got %d", count)
+	}
+
+	resp3 := post("create-bank-1", models.Bank{Name: "Other Bank", Type: models.BankTypePrivate})
+	defer resp3.Body.Close()
+	if resp3.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 for a reused key, got %d", resp3.StatusCode)
+	}
+
+	// Another caller's key of the same name is a different key
+	jsonData, _ := json.Marshal(bank)
+	req, _ := http.NewRequest("POST", testServer.URL+"/api/banks", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(idempotency.Header, "create-bank-1")
+	req.Header.Set(handlers.ActorHeader, "alice")
+	resp4, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp4.Body.Close()
+	if resp4.StatusCode != http.StatusCreated || resp4.Header.Get(idempotency.ReplayedHeader) != "" {
+		t.Errorf("Expected a new 201 for another caller, got %d (replayed=%q)", resp4.StatusCode, resp4.Header.Get(idempotency.ReplayedHeader))
+	}
+}
+
+func TestIntegrationBatchEndpoints(t *testing.T) {
+	cleanupTestData()
+
+	type batchResponse struct {
+		Succeeded int `json:"succeeded"`
+		Failed    int `json:"failed"`
+		Results   []struct {
+			Index  int    `json:"index"`
+			Status int    `json:"status"`
+			ID     int    `json:"id"`
+			Error  string `json:"error"`
+		} `json:"results"`
+	}
+	post := func(path string, body interface{}) (int, batchResponse) {
+		jsonData, _ := json.Marshal(body)
+		resp, err := http.Post(testServer.URL+path, "application/json", bytes.NewBuffer(jsonData))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var result batchResponse
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	birthDate := time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)
+	clients := []models.Client{
+		{FullName: "Ana Batch", Email: "ana.batch@example.com", BirthDate: birthDate, Country: "USA"},
+		{FullName: "Ben Batch", Email: "ben.batch@example.com", BirthDate: birthDate, Country: "USA"},
+		{FullName: "Ana Again", Email: "ANA.batch@example.com", BirthDate: birthDate, Country: "USA"},
+	}
+
+	// The duplicate email fails, so nothing is created in atomic mode
+	status, result := post("/api/clients:batch", clients)
+	if status != http.StatusUnprocessableEntity || result.Failed != 3 {
+		t.Fatalf("Expected 422 with every item failed, got %d %+v", status, result)
+	}
+	if result.Results[2].Status != http.StatusConflict || result.Results[0].Status != http.StatusFailedDependency {
+		t.Errorf("Unexpected per-item results: %+v", result.Results)
+	}
+	var count int
+	database.DB.QueryRow("SELECT COUNT(*) FROM clients").Scan(&count)
+	if count != 0 {
+		t.Errorf("Expected no clients after a failed atomic batch, got %d", count)
+	}
+
+	status, result = post("/api/clients:batch?mode=best_effort", clients)
+	if status != http.StatusMultiStatus || result.Succeeded != 2 || result.Failed != 1 {
+		t.Fatalf("Expected 207 with 2 created, got %d %+v", status, result)
+	}
+	clientID := result.Results[0].ID
+
+	bankData, _ := json.Marshal(models.Bank{Name: "Batch Bank", Type: models.BankTypePrivate})
+	resp, err := http.Post(testServer.URL+"/api/banks", "application/json", bytes.NewBuffer(bankData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var bank models.Bank
+	json.NewDecoder(resp.Body).Decode(&bank)
+
+	credit := models.Credit{ClientID: clientID, BankID: bank.ID, MinPayment: 100, MaxPayment: 500, TermMonths: 12, CreditType: models.CreditTypeAuto}
+	invalid := credit
+	invalid.CreditType = "BOAT"
+	status, result = post("/api/credits:batch", []models.Credit{credit, credit})
+	if status != http.StatusCreated || result.Succeeded != 2 {
+		t.Fatalf("Expected 201 with 2 credits, got %d %+v", status, result)
+	}
+	status, result = post("/api/credits:batch?mode=best_effort", []models.Credit{invalid, credit})
+	if status != http.StatusMultiStatus || result.Results[0].Status != http.StatusBadRequest || result.Results[1].ID == 0 {
+		t.Fatalf("Expected the invalid credit to fail alone, got %d %+v", status, result)
+	}
+
+	status, result = post("/api/credits:batch-status", map[string]interface{}{
+		"status": models.CreditStatusRejected,
+		"filter": map[string]interface{}{"bank_id": bank.ID},
+	})
+	if status != http.StatusOK || result.Succeeded != 3 {
+		t.Fatalf("Expected 3 credits rejected, got %d %+v", status, result)
+	}
+	database.DB.QueryRow("SELECT COUNT(*) FROM credits WHERE status = $1", models.CreditStatusRejected).Scan(&count)
+	if count != 3 {
+		t.Errorf("Expected 3 rejected credits, got %d", count)
+	}
+	database.DB.QueryRow("SELECT COUNT(*) FROM audit_events WHERE resource_type = 'credit' AND operation = 'UPDATE'").Scan(&count)
+	if count != 3 {
+		t.Errorf("Expected an audit event per credit, got %d", count)
+	}
+}
+
+func TestIntegrationImport(t *testing.T) {
+	cleanupTestData()
+
+	upload := func(fields map[string]string, fileName, content string) (int, models.ImportJob) {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		for key, value := range fields {
+			writer.WriteField(key, value)
+		}
+		part, _ := writer.CreateFormFile("file", fileName)
+		part.Write([]byte(content))
+		writer.Close()
+
+		resp, err := http.Post(testServer.URL+"/api/imports", writer.FormDataContentType(), &body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var job models.ImportJob
+		json.NewDecoder(resp.Body).Decode(&job)
+		return resp.StatusCode, job
+	}
+
+	clientsCSV := "Name,E-mail,Born,Country\n" +
+		"Ana Import,ana.import@example.com,1990-01-01,USA\n" +
+		"Ben Import,ben.import@example.com,not a date,USA\n" +
+		"Cy Import,cy.import@example.com,1985-06-30,Chile\n"
+	fields := map[string]string{
+		"resource": "clients",
+		"mapping":  `{"full_name": "Name", "email": "E-mail", "birth_date": "Born"}`,
+		"dry_run":  "true",
+	}
+
+	status, job := upload(fields, "clients.csv", clientsCSV)
+	if status != http.StatusCreated || job.Status != models.ImportJobCompleted || job.ImportedRows != 2 || job.RejectedRows != 1 {
+		t.Fatalf("Unexpected dry run result %d %+v", status, job)
+	}
+	var count int
+	database.DB.QueryRow("SELECT COUNT(*) FROM clients").Scan(&count)
+	if count != 0 {
+		t.Errorf("Expected a dry run to create no clients, got %d", count)
+	}
+
+	resp, err := http.Get(fmt.Sprintf("%s/api/imports/%d/errors", testServer.URL, job.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	report, _ := io.ReadAll(resp.Body)
+	if !strings.HasPrefix(string(report), "row,error,Name,E-mail,Born,Country\n3,") {
+		t.Errorf("Unexpected error report %q", report)
+	}
+
+	delete(fields, "dry_run")
+	status, job = upload(fields, "clients.csv", clientsCSV)
+	if status != http.StatusCreated || job.ImportedRows != 2 {
+		t.Fatalf("Unexpected import result %d %+v", status, job)
+	}
+
+	bankData, _ := json.Marshal(models.Bank{Name: "Import Bank", Type: models.BankTypePrivate})
+	resp2, err := http.Post(testServer.URL+"/api/banks", "application/json", bytes.NewBuffer(bankData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp2.Body.Close()
+	var bank models.Bank
+	json.NewDecoder(resp2.Body).Decode(&bank)
+
+	// Large files run in the background
+	handlers.ImportSyncRows = 1
+	defer func() { handlers.ImportSyncRows = 1000 }()
+	creditsCSV := "client_email,bank_id,min_payment,max_payment,term_months,credit_type\n" +
+		fmt.Sprintf("ana.import@example.com,%d,100,500,12,auto\n", bank.ID) +
+		fmt.Sprintf("nobody@example.com,%d,100,500,12,AUTO\n", bank.ID) +
+		fmt.Sprintf("ana.import@example.com,%d,100,500,twelve,AUTO\n", bank.ID)
+	status, job = upload(map[string]string{"resource": "credits"}, "credits.csv", creditsCSV)
+	if status != http.StatusAccepted {
+		t.Fatalf("Expected 202 for a background import, got %d", status)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Status != models.ImportJobCompleted && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+		resp, err := http.Get(fmt.Sprintf("%s/api/imports/%d", testServer.URL, job.ID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		json.NewDecoder(resp.Body).Decode(&job)
+		resp.Body.Close()
+	}
+	if job.Status != models.ImportJobCompleted || job.ProcessedRows != 3 || job.ImportedRows != 1 || job.RejectedRows != 2 {
+		t.Fatalf("Unexpected background import %+v", job)
+	}
+	database.DB.QueryRow("SELECT COUNT(*) FROM credits WHERE bank_id = $1", bank.ID).Scan(&count)
+	if count != 1 {
+		t.Errorf("Expected 1 imported credit, got %d", count)
+	}
+
+	// Rejected rows naming a client are part of their export and erasure
+	var clientID int
+	database.DB.QueryRow("SELECT id FROM clients WHERE email = 'ana.import@example.com'").Scan(&clientID)
+	resp3, err := http.Get(fmt.Sprintf("%s/api/clients/%d/export", testServer.URL, clientID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp3.Body.Close()
+	var export models.ClientExport
+	json.NewDecoder(resp3.Body).Decode(&export)
+	if len(export.ImportRejections) != 1 || export.ImportRejections[0].RowNumber != 4 {
+		t.Errorf("Expected the rejected credit row in the export, got %+v", export.ImportRejections)
+	}
+
+	resp4, err := http.Post(fmt.Sprintf("%s/api/clients/%d/erase", testServer.URL, clientID), "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp4.Body.Close()
+	database.DB.QueryRow("SELECT COUNT(*) FROM import_rejections WHERE job_id = $1", job.ID).Scan(&count)
+	if resp4.StatusCode != http.StatusOK || count != 1 {
+		t.Errorf("Expected erasure to remove the client's rejected row, got status %d and %d rows", resp4.StatusCode, count)
+	}
+
+	// A job left running by a previous process is failed on the next start
+	database.DB.Exec("UPDATE import_jobs SET status = $2 WHERE id = $1", job.ID, models.ImportJobRunning)
+	if failed, err := handlers.FailInterruptedImports(); err != nil || failed != 1 {
+		t.Errorf("Expected 1 interrupted import, got %d %v", failed, err)
+	}
+	database.DB.QueryRow("SELECT status FROM import_jobs WHERE id = $1", job.ID).Scan(&job.Status)
+	if job.Status != models.ImportJobFailed {
+		t.Errorf("Expected the interrupted import to be failed, got %s", job.Status)
+	}
+}
+
+func TestIntegrationListExport(t *testing.T) {
+	cleanupTestData()
+
+	for _, name := range []string{"First Bank", "Second Bank", "Deleted Bank"} {
+		jsonData, _ := json.Marshal(models.Bank{Name: name, Type: models.BankTypePrivate})
+		resp, err := http.Post(testServer.URL+"/api/banks", "application/json", bytes.NewBuffer(jsonData))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var bank models.Bank
+		json.NewDecoder(resp.Body).Decode(&bank)
+		resp.Body.Close()
+		if name == "Deleted Bank" {
+			req, _ := http.NewRequest("DELETE", fmt.Sprintf("%s/api/banks/%d", testServer.URL, bank.ID), nil)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+		}
+	}
+
+	actor := ""
+	get := func(path, accept string) (*http.Response, string) {
+		req, _ := http.NewRequest("GET", testServer.URL+path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		req.Header.Set(handlers.ActorHeader, actor)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	resp, body := get("/api/banks", "text/csv")
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	if resp.Header.Get("Content-Type") != "text/csv" || len(lines) != 3 {
+		t.Fatalf("Expected a CSV header and 2 live banks, got %q (%s)", body, resp.Header.Get("Content-Type"))
+	}
+	if lines[0] != "id,name,type,deleted_at,version,created_at" {
+		t.Errorf("Unexpected CSV header %q", lines[0])
+	}
+
+	// Deleted rows are only listed for admins
+	resp, _ = get("/api/banks?format=ndjson&include_deleted=true", "text/csv")
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected a non-admin to be refused deleted rows with 403, got %d", resp.StatusCode)
+	}
+	actor = "admin"
+	resp, body = get("/api/banks?format=ndjson&include_deleted=true", "text/csv")
+	lines = strings.Split(strings.TrimSpace(body), "\n")
+	if resp.Header.Get("Content-Type") != "application/x-ndjson" || len(lines) != 3 {
+		t.Fatalf("Expected 3 NDJSON lines including the deleted bank, got %q", body)
+	}
+	var bank models.Bank
+	if err := json.Unmarshal([]byte(lines[0]), &bank); err != nil || bank.Name == "" {
+		t.Errorf("Expected each line to be a bank, got %q (%v)", lines[0], err)
+	}
+}
+
+func TestIntegrationExpand(t *testing.T) {
+	cleanupTestData()
+
+	birthDate := time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)
+	clientData, _ := json.Marshal(models.Client{FullName: "Eva Expand", Email: "eva.expand@example.com", BirthDate: birthDate, Country: "USA"})
+	resp, err := http.Post(testServer.URL+"/api/clients", "application/json", bytes.NewBuffer(clientData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var client models.Client
+	json.NewDecoder(resp.Body).Decode(&client)
+	resp.Body.Close()
+
+	bankData, _ := json.Marshal(models.Bank{Name: "Expand Bank", Type: models.BankTypePrivate})
+	resp, err = http.Post(testServer.URL+"/api/banks", "application/json", bytes.NewBuffer(bankData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bank models.Bank
+	json.NewDecoder(resp.Body).Decode(&bank)
+	resp.Body.Close()
+
+	credit := models.Credit{ClientID: client.ID, BankID: bank.ID, MinPayment: 100, MaxPayment: 500, TermMonths: 12, CreditType: models.CreditTypeAuto}
+	creditData, _ := json.Marshal([]models.Credit{credit, credit})
+	resp, err = http.Post(testServer.URL+"/api/credits:batch", "application/json", bytes.NewBuffer(creditData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	get := func(path string, v interface{}) {
+		resp, err := http.Get(testServer.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200 for %s, got %d", path, resp.StatusCode)
+		}
+		json.NewDecoder(resp.Body).Decode(v)
+	}
+
+	var credits []struct {
+		models.Credit
+		Client *models.Client `json:"client"`
+		Bank   *models.Bank   `json:"bank"`
+	}
+	get("/api/credits?expand=client,bank", &credits)
+	if len(credits) != 2 {
+		t.Fatalf("Expected 2 credits, got %d", len(credits))
+	}
+	for _, c := range credits {
+		if c.Client == nil || c.Client.Email != "eva.expand@example.com" || c.Bank == nil || c.Bank.Name != "Expand Bank" {
+			t.Errorf("Expected the client and bank to be embedded, got %+v", c)
+		}
+	}
+
+	var plain []map[string]interface{}
+	get("/api/credits", &plain)
+	if _, ok := plain[0]["client"]; ok {
+		t.Errorf("Expected no embedded client without expand, got %v", plain[0])
+	}
+
+	var withCredits struct {
+		models.Client
+		Credits []models.Credit `json:"credits"`
+	}
+	get(fmt.Sprintf("/api/clients/%d?expand=credits", client.ID), &withCredits)
+	if withCredits.Email != client.Email || len(withCredits.Credits) != 2 {
+		t.Errorf("Expected the client with its 2 credits, got %+v", withCredits)
+	}
+
+	var banks []struct {
+		models.Bank
+		Credits []models.Credit `json:"credits"`
+	}
+	get("/api/banks?expand=credits", &banks)
+	if len(banks) != 1 || len(banks[0].Credits) != 2 {
+		t.Errorf("Expected the bank with its 2 credits, got %+v", banks)
+	}
+}
+
+func TestIntegrationSparseFields(t *testing.T) {
+	cleanupTestData()
+
+	birthDate := time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)
+	clientData, _ := json.Marshal(models.Client{FullName: "Finn Fields", Email: "finn.fields@example.com", BirthDate: birthDate, Country: "USA"})
+	resp, err := http.Post(testServer.URL+"/api/clients", "application/json", bytes.NewBuffer(clientData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var client models.Client
+	json.NewDecoder(resp.Body).Decode(&client)
+	resp.Body.Close()
+
+	bankData, _ := json.Marshal(models.Bank{Name: "Fields Bank", Type: models.BankTypePrivate})
+	resp, err = http.Post(testServer.URL+"/api/banks", "application/json", bytes.NewBuffer(bankData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bank models.Bank
+	json.NewDecoder(resp.Body).Decode(&bank)
+	resp.Body.Close()
+
+	creditData, _ := json.Marshal(models.Credit{ClientID: client.ID, BankID: bank.ID, MinPayment: 100, MaxPayment: 500, TermMonths: 12, CreditType: models.CreditTypeAuto})
+	resp, err = http.Post(testServer.URL+"/api/credits", "application/json", bytes.NewBuffer(creditData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var credit models.Credit
+	json.NewDecoder(resp.Body).Decode(&credit)
+	resp.Body.Close()
+
+	get := func(path string) (*http.Response, string) {
+		resp, err := http.Get(testServer.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, strings.TrimSpace(string(body))
+	}
+
+	resp, body := get("/api/credits?fields=id,status,max_payment")
+	if expected := fmt.Sprintf(`[{"id":%d,"max_payment":500,"status":"PENDING"}]`, credit.ID); body != expected {
+		t.Errorf("Expected %s, got %s", expected, body)
+	}
+
+	resp, body = get("/api/credits?fields=status&format=csv")
+	if body != "status\nPENDING" {
+		t.Errorf("Expected a status-only CSV, got %q", body)
+	}
+
+	resp, body = get(fmt.Sprintf("/api/credits/%d?fields=status&expand=bank", credit.ID))
+	var expanded map[string]json.RawMessage
+	json.Unmarshal([]byte(body), &expanded)
+	if len(expanded) != 2 || expanded["status"] == nil || expanded["bank"] == nil || resp.Header.Get("ETag") == "" {
+		t.Errorf("Expected only the status and the expanded bank with an ETag, got %s", body)
+	}
+
+	resp, body = get(fmt.Sprintf("/api/clients/%d?fields=email", client.ID))
+	if body != `{"email":"finn.fields@example.com"}` {
+		t.Errorf("Expected only the email, got %s", body)
+	}
+
+	resp, _ = get("/api/banks?fields=swift")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown field, got %d", resp.StatusCode)
+	}
+}
+
+func TestIntegrationRequestValidation(t *testing.T) {
+	resp, err := http.Post(testServer.URL+"/api/credits", "application/json",
+		strings.NewReader(`{"client_id": "1", "bank_id": 1, "credit_type": "BOAT"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result struct {
+		Error   string              `json:"error"`
+		Details []openapi.Violation `json:"details"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest || result.Error != "Invalid request" || len(result.Details) != 5 {
+		t.Errorf("Expected 400 listing the wrong client_id, credit_type and 3 missing fields, got %d %+v", resp.StatusCode, result)
+	}
+
+	resp, err = http.Get(testServer.URL + "/api/clients/abc?include_deleted=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest || len(result.Details) != 2 || result.Details[0].In != "path" {
+		t.Errorf("Expected 400 for the path and query parameters, got %d %+v", resp.StatusCode, result)
+	}
+}
+
+func TestIntegrationGraphQL(t *testing.T) {
+	cleanupTestData()
+
+	graphql := func(query string, variables map[string]interface{}, v interface{}) {
+		body, _ := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+		req, _ := http.NewRequest("POST", testServer.URL+"/graphql", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(handlers.ActorHeader, "graphql-test")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", resp.StatusCode)
+		}
+		json.NewDecoder(resp.Body).Decode(v)
+	}
+
+	var created struct {
+		Data struct {
+			CreateClient struct{ ID string } `json:"createClient"`
+			CreateBank   struct{ ID string } `json:"createBank"`
+		} `json:"data"`
+		Errors []map[string]interface{} `json:"errors"`
+	}
+	graphql(`mutation($client: ClientInput!) {
+		createClient(input: $client) { id }
+		createBank(input: {name: "Graph Bank", type: PRIVATE}) { id }
+	}`, map[string]interface{}{"client": map[string]interface{}{
+		"fullName": "Gina Graph", "email": "gina.graph@example.com", "birthDate": "1990-01-01T00:00:00Z", "country": "USA",
+	}}, &created)
+	if len(created.Errors) > 0 || created.Data.CreateClient.ID == "" || created.Data.CreateBank.ID == "" {
+		t.Fatalf("Expected a client and a bank, got %+v", created)
+	}
+
+	for i := 0; i < 3; i++ {
+		var credit struct {
+			Errors []map[string]interface{} `json:"errors"`
+		}
+		graphql(`mutation($input: CreditInput!) { createCredit(input: $input) { id status } }`,
+			map[string]interface{}{"input": map[string]interface{}{
+				"clientId": created.Data.CreateClient.ID, "bankId": created.Data.CreateBank.ID,
+				"minPayment": 100, "maxPayment": 500, "termMonths": 12, "creditType": "AUTO",
+			}}, &credit)
+		if len(credit.Errors) > 0 {
+			t.Fatalf("Expected the credit to be created, got %+v", credit.Errors)
+		}
+	}
+
+	var page struct {
+		Data struct {
+			Credits struct {
+				Items []struct {
+					Status string
+					Client struct{ FullName string }
+					Bank   struct{ Name string }
+				}
+				NextCursor *string
+			}
+		} `json:"data"`
+	}
+	graphql(`{ credits(first: 2, filter: {status: PENDING}) { items { status client { fullName } bank { name } } nextCursor } }`, nil, &page)
+	items := page.Data.Credits.Items
+	if len(items) != 2 || page.Data.Credits.NextCursor == nil || items[0].Client.FullName != "Gina Graph" || items[1].Bank.Name != "Graph Bank" {
+		t.Errorf("Expected a first page of 2 credits with their client and bank, got %+v", page.Data.Credits)
+	}
+
+	// A client's credits are paged like the top-level lists
+	var nested struct {
+		Data struct {
+			Client struct {
+				Credits struct {
+					Items      []struct{ ID string }
+					NextCursor *string
+				}
+			}
+		} `json:"data"`
+	}
+	query := `query($id: ID!, $after: ID) { client(id: $id) { credits(first: 2, after: $after) { items { id } nextCursor } } }`
+	graphql(query, map[string]interface{}{"id": created.Data.CreateClient.ID}, &nested)
+	credits := nested.Data.Client.Credits
+	if len(credits.Items) != 2 || credits.NextCursor == nil {
+		t.Fatalf("Expected a first page of 2 of the client's credits, got %+v", credits)
+	}
+	graphql(query, map[string]interface{}{"id": created.Data.CreateClient.ID, "after": *credits.NextCursor}, &nested)
+	if credits := nested.Data.Client.Credits; len(credits.Items) != 1 || credits.NextCursor != nil {
+		t.Errorf("Expected the last of the client's credits, got %+v", credits)
+	}
+
+	var conflict struct {
+		Errors []struct {
+			Message    string
+			Extensions map[string]interface{}
+		} `json:"errors"`
+	}
+	graphql(`mutation($id: ID!) { deleteBank(id: $id, version: 99) }`, map[string]interface{}{"id": created.Data.CreateBank.ID}, &conflict)
+	if len(conflict.Errors) != 1 || conflict.Errors[0].Extensions["status"] != float64(http.StatusPreconditionFailed) {
+		t.Errorf("Expected a stale version to fail with status 412, got %+v", conflict.Errors)
+	}
+}
+
+func TestIntegrationGRPC(t *testing.T) {
+	cleanupTestData()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpcserver.New()
+	go server.Serve(listener)
+	defer server.Stop()
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-actor", "grpc-test")
+	client, err := pb.NewClientServiceClient(conn).CreateClient(ctx, &pb.CreateClientRequest{Client: &pb.Client{
+		FullName: "Greta Grpc", Email: "greta.grpc@example.com", Country: "USA",
+		BirthDate: timestamppb.New(time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)),
+	}})
+	if err != nil {
+		t.Fatalf("Expected the client to be created, got %v", err)
+	}
+	_, err = pb.NewClientServiceClient(conn).CreateClient(ctx, &pb.CreateClientRequest{Client: &pb.Client{
+		FullName: "Greta Again", Email: "greta.grpc@example.com", Country: "USA",
+		BirthDate: timestamppb.New(time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)),
+	}})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Errorf("Expected a duplicate email to fail with AlreadyExists, got %v", err)
+	}
+
+	bank, err := pb.NewBankServiceClient(conn).CreateBank(ctx, &pb.CreateBankRequest{Bank: &pb.Bank{Name: "Grpc Bank", Type: pb.BankType_BANK_TYPE_PRIVATE}})
+	if err != nil {
+		t.Fatalf("Expected the bank to be created, got %v", err)
+	}
+
+	credits := pb.NewCreditServiceClient(conn)
+	var ids []int64
+	for i := 0; i < 3; i++ {
+		credit, err := credits.CreateCredit(ctx, &pb.CreateCreditRequest{Credit: &pb.Credit{
+			ClientId: client.Id, BankId: bank.Id, MinPayment: 100, MaxPayment: 500, TermMonths: 12, CreditType: pb.CreditType_CREDIT_TYPE_AUTO,
+		}})
+		if err != nil || credit.Status != pb.CreditStatus_CREDIT_STATUS_PENDING {
+			t.Fatalf("Expected a pending credit, got %v, %v", credit, err)
+		}
+		ids = append(ids, credit.Id)
+	}
+
+	stream, err := credits.ListCredits(ctx, &pb.ListCreditsRequest{BankId: bank.Id, Status: pb.CreditStatus_CREDIT_STATUS_PENDING})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var streamed []int64
+	for {
+		credit, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		streamed = append(streamed, credit.Id)
+	}
+	if fmt.Sprint(streamed) != fmt.Sprint(ids) {
+		t.Errorf("Expected credits %v in id order, got %v", ids, streamed)
+	}
+
+	batch, err := credits.BatchGetCredits(ctx, &pb.BatchGetCreditsRequest{Ids: []int64{ids[2], 999999}})
+	if err != nil || len(batch.Credits) != 1 || batch.Credits[0].ClientId != client.Id {
+		t.Errorf("Expected only the existing credit, got %v, %v", batch, err)
+	}
+
+	_, err = credits.DeleteCredit(ctx, &pb.DeleteCreditRequest{Id: ids[0], Version: 7})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected a stale version to fail with FailedPrecondition, got %v", err)
+	}
+	if _, err := credits.DeleteCredit(ctx, &pb.DeleteCreditRequest{Id: ids[0], Version: 1}); err != nil {
+		t.Errorf("Expected the credit to be deleted, got %v", err)
+	}
+	if _, err := credits.GetCredit(ctx, &pb.GetCreditRequest{Id: ids[0]}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected the deleted credit to be hidden, got %v", err)
+	}
+
+	var actor string
+	database.DB.QueryRow("SELECT actor FROM audit_events WHERE resource_type = 'bank' AND resource_id = $1", bank.Id).Scan(&actor)
+	if actor != "grpc-test" {
+		t.Errorf("Expected the bank creation to be audited for the x-actor, got %q", actor)
+	}
+}
+
+func TestIntegrationAPIVersions(t *testing.T) {
+	cleanupTestData()
+
+	var client models.Client
+	body, _ := json.Marshal(models.Client{FullName: "Vera Version", Email: "vera.version@example.com",
+		BirthDate: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC), Country: "USA"})
+	resp, err := http.Post(testServer.URL+"/api/clients", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.NewDecoder(resp.Body).Decode(&client)
+	resp.Body.Close()
+
+	var bank models.Bank
+	body, _ = json.Marshal(models.Bank{Name: "Version Bank", Type: models.BankTypePrivate})
+	resp, err = http.Post(testServer.URL+"/api/banks", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.NewDecoder(resp.Body).Decode(&bank)
+	resp.Body.Close()
+
+	var ids []int
+	for _, amount := range []string{"100.50", "200"} {
+		body, _ = json.Marshal(map[string]interface{}{
+			"client_id": client.ID, "bank_id": bank.ID, "min_payment": amount, "max_payment": "1000",
+			"term_months": 12, "credit_type": "AUTO",
+		})
+		resp, err = http.Post(testServer.URL+"/api/v2/credits", "application/json", bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var created handlers.CreditV2
+		json.NewDecoder(resp.Body).Decode(&created)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated || created.MaxPayment != "1000.00" || resp.Header.Get("ETag") == "" {
+			t.Fatalf("Expected a v2 credit with string amounts, got %d %+v", resp.StatusCode, created)
+		}
+		ids = append(ids, created.ID)
+	}
+
+	// v1 keeps its shape and announces its successor
+	resp, err = http.Get(fmt.Sprintf("%s/api/credits/%d", testServer.URL, ids[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v1 map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&v1)
+	resp.Body.Close()
+	if v1["min_payment"] != 100.5 || resp.Header.Get("Deprecation") == "" || resp.Header.Get("Sunset") == "" ||
+		!strings.Contains(resp.Header.Get("Link"), fmt.Sprintf("</api/v2/credits/%d>", ids[0])) {
+		t.Errorf("Expected a deprecated v1 credit with numeric amounts, got %v %v", v1, resp.Header)
+	}
+
+	// The Accept header selects v2 on the v1 path
+	var cursor string
+	var listed []string
+	for page := 0; page < 3; page++ {
+		req, _ := http.NewRequest("GET", testServer.URL+"/api/credits?limit=1&cursor="+cursor, nil)
+		req.Header.Set("Accept", middleware.MediaTypeV2)
+		resp, err = http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result handlers.CreditPageV2
+		json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || resp.Header.Get(middleware.APIVersionHeader) != "2" || resp.Header.Get("Deprecation") != "" {
+			t.Fatalf("Expected a v2 page, got %d %v", resp.StatusCode, resp.Header)
+		}
+		for _, credit := range result.Data {
+			listed = append(listed, credit.MinPayment)
+		}
+		if result.Pagination.NextCursor == nil {
+			break
+		}
+		cursor = *result.Pagination.NextCursor
+	}
+	if fmt.Sprint(listed) != "[100.50 200.00]" {
+		t.Errorf("Expected both credits in id order one page at a time, got %v", listed)
+	}
+
+	body, _ = json.Marshal(map[string]interface{}{
+		"client_id": client.ID, "bank_id": bank.ID, "min_payment": "150", "max_payment": "1000",
+		"term_months": 24, "credit_type": "AUTO", "status": "PENDING",
+	})
+	req, _ := http.NewRequest("PUT", fmt.Sprintf("%s/api/v2/credits/%d", testServer.URL, ids[0]), bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"7"`)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("Expected a stale If-Match to fail with 412, got %d", resp.StatusCode)
+	}
+}
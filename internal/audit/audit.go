@@ -0,0 +1,155 @@
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"reflect"
+)
+
+// Operations recorded in the audit log.
+const (
+	OpCreate  = "CREATE"
+	OpUpdate  = "UPDATE"
+	OpDelete  = "DELETE"
+	OpRestore = "RESTORE"
+	OpErase   = "ERASE"
+	OpMerge   = "MERGE"
+	OpPurge   = "PURGE"
+)
+
+// Redacted replaces the value of sensitive fields in stored snapshots. The
+// field still shows up in the changes so the log records that it changed.
+const Redacted = "[REDACTED]"
+
+// Execer is satisfied by *sql.DB and *sql.Tx. Callers pass the transaction of
+// the mutation so the event commits or rolls back with it.
+type Execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// Entry describes one mutation. Before is nil for creations and After is nil
+// for hard deletions.
+type Entry struct {
+	Actor        string
+	ResourceType string
+	ResourceID   int
+	Operation    string
+	Before       interface{}
+	After        interface{}
+	Redact       []string
+	RequestID    string
+}
+
+// FieldChange is the before and after value of a single changed field.
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Record writes the entry to audit_events.
+func Record(ex Execer, e Entry) error {
+	before, after, changes, err := Diff(e.Before, e.After, e.Redact)
+	if err != nil {
+		return err
+	}
+	_, err = ex.Exec(`
+		INSERT INTO audit_events (actor, resource_type, resource_id, operation, before, after, changes, request_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, e.Actor, e.ResourceType, e.ResourceID, e.Operation,
+		nullJSON(before), nullJSON(after), nullJSON(changes), sql.NullString{String: e.RequestID, Valid: e.RequestID != ""})
+	return err
+}
+
+// Diff serializes before and after and computes the fields whose values
+// differ between them. Fields listed in redact are masked in all three
+// results.
+func Diff(before, after interface{}, redact []string) (beforeJSON, afterJSON, changes json.RawMessage, err error) {
+	beforeFields, err := fields(before)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	afterFields, err := fields(after)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	changed := map[string]FieldChange{}
+	for key, value := range beforeFields {
+		if other, ok := afterFields[key]; !ok || !reflect.DeepEqual(value, other) {
+			changed[key] = FieldChange{Before: value, After: afterFields[key]}
+		}
+	}
+	for key, value := range afterFields {
+		if _, ok := beforeFields[key]; !ok {
+			changed[key] = FieldChange{After: value}
+		}
+	}
+
+	for _, key := range redact {
+		if _, ok := beforeFields[key]; ok {
+			beforeFields[key] = Redacted
+		}
+		if _, ok := afterFields[key]; ok {
+			afterFields[key] = Redacted
+		}
+		if change, ok := changed[key]; ok {
+			if change.Before != nil {
+				change.Before = Redacted
+			}
+			if change.After != nil {
+				change.After = Redacted
+			}
+			changed[key] = change
+		}
+	}
+
+	if beforeFields != nil {
+		if beforeJSON, err = json.Marshal(beforeFields); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if afterFields != nil {
+		if afterJSON, err = json.Marshal(afterFields); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if changes, err = json.Marshal(changed); err != nil {
+		return nil, nil, nil, err
+	}
+	return beforeJSON, afterJSON, changes, nil
+}
+
+// Snapshot serializes v with the fields listed in redact masked. A nil v
+// yields nil.
+func Snapshot(v interface{}, redact []string) (json.RawMessage, error) {
+	m, err := fields(v)
+	if err != nil || m == nil {
+		return nil, err
+	}
+	for _, key := range redact {
+		if _, ok := m[key]; ok {
+			m[key] = Redacted
+		}
+	}
+	return json.Marshal(m)
+}
+
+// fields flattens v into its top-level JSON fields. A nil v yields a nil map.
+func fields(v interface{}) (map[string]interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func nullJSON(data json.RawMessage) sql.NullString {
+	return sql.NullString{String: string(data), Valid: data != nil}
+}
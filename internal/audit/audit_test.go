@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type record struct {
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Version int    `json:"version"`
+}
+
+func decodeChanges(t *testing.T, data json.RawMessage) map[string]FieldChange {
+	t.Helper()
+	var changes map[string]FieldChange
+	if err := json.Unmarshal(data, &changes); err != nil {
+		t.Fatal(err)
+	}
+	return changes
+}
+
+func TestDiffReportsOnlyChangedFields(t *testing.T) {
+	before := record{Name: "John", Email: "john@example.com", Version: 1}
+	after := record{Name: "John", Email: "john@example.com", Version: 2}
+
+	_, _, data, err := Diff(before, after, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := decodeChanges(t, data)
+	if len(changes) != 1 {
+		t.Fatalf("Expected 1 change, got %v", changes)
+	}
+	if c := changes["version"]; c.Before != float64(1) || c.After != float64(2) {
+		t.Errorf("Unexpected version change %+v", c)
+	}
+}
+
+func TestDiffOfCreationHasNoBefore(t *testing.T) {
+	beforeJSON, afterJSON, data, err := Diff(nil, record{Name: "John"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if beforeJSON != nil {
+		t.Errorf("Expected no before snapshot, got %s", beforeJSON)
+	}
+	if afterJSON == nil {
+		t.Error("Expected an after snapshot")
+	}
+	if changes := decodeChanges(t, data); len(changes) != 3 {
+		t.Errorf("Expected every field as a change, got %v", changes)
+	}
+}
+
+func TestDiffRedactsSensitiveFields(t *testing.T) {
+	before := record{Name: "John", Email: "john@example.com"}
+	after := record{Name: "John", Email: "johnny@example.com"}
+
+	beforeJSON, afterJSON, data, err := Diff(before, after, []string{"email"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, snapshot := range []json.RawMessage{beforeJSON, afterJSON} {
+		var fields map[string]interface{}
+		json.Unmarshal(snapshot, &fields)
+		if fields["email"] != Redacted {
+			t.Errorf("Expected email to be redacted, got %v", fields["email"])
+		}
+	}
+	changes := decodeChanges(t, data)
+	if c, ok := changes["email"]; !ok || c.Before != Redacted || c.After != Redacted {
+		t.Errorf("Expected a redacted email change, got %+v", changes)
+	}
+}
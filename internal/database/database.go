@@ -70,24 +70,258 @@ func InitSchema() error {
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	);`
 
-	_, err := DB.Exec(itemsQuery)
-	if err != nil {
-		return err
-	}
+	// Columns added after the initial schema; ALTER keeps existing databases in step
+	creditsDTIQuery := `
+	ALTER TABLE credits ADD COLUMN IF NOT EXISTS debt_to_income NUMERIC(12,4);
+	ALTER TABLE credits ADD COLUMN IF NOT EXISTS dti_flagged BOOLEAN NOT NULL DEFAULT FALSE;`
 
-	_, err = DB.Exec(clientsQuery)
-	if err != nil {
-		return err
-	}
+	financialProfilesQuery := `
+	CREATE TABLE IF NOT EXISTS client_financial_profiles (
+		id SERIAL PRIMARY KEY,
+		client_id INTEGER NOT NULL REFERENCES clients(id) ON DELETE CASCADE,
+		version INTEGER NOT NULL,
+		monthly_income DECIMAL(15,2) NOT NULL,
+		employment_type VARCHAR(20) NOT NULL CHECK (employment_type IN ('EMPLOYED', 'SELF_EMPLOYED', 'UNEMPLOYED', 'RETIRED')),
+		external_debts DECIMAL(15,2) NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (client_id, version)
+	);`
 
-	_, err = DB.Exec(banksQuery)
-	if err != nil {
-		return err
+	clientDocumentsQuery := `
+	CREATE TABLE IF NOT EXISTS client_documents (
+		id SERIAL PRIMARY KEY,
+		client_id INTEGER NOT NULL REFERENCES clients(id) ON DELETE CASCADE,
+		type VARCHAR(30) NOT NULL CHECK (type IN ('ID_DOCUMENT', 'PROOF_OF_ADDRESS')),
+		file_name VARCHAR(255) NOT NULL,
+		content_type VARCHAR(100) NOT NULL,
+		size_bytes BIGINT NOT NULL,
+		sha256 CHAR(64) NOT NULL,
+		storage_key VARCHAR(500) NOT NULL,
+		expiry_date DATE,
+		status VARCHAR(20) NOT NULL DEFAULT 'PENDING' CHECK (status IN ('PENDING', 'VERIFIED', 'REJECTED')),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	clientsErasureQuery := `
+	ALTER TABLE clients ADD COLUMN IF NOT EXISTS erased_at TIMESTAMP;`
+
+	// PII is moved into the *_enc columns once encryption is enabled
+	clientsEncryptionQuery := `
+	ALTER TABLE clients ADD COLUMN IF NOT EXISTS full_name_enc TEXT;
+	ALTER TABLE clients ADD COLUMN IF NOT EXISTS email_enc TEXT;
+	ALTER TABLE clients ADD COLUMN IF NOT EXISTS birth_date_enc TEXT;
+	ALTER TABLE clients ADD COLUMN IF NOT EXISTS email_index VARCHAR(64) UNIQUE;
+	ALTER TABLE clients ADD COLUMN IF NOT EXISTS pii_key_id VARCHAR(64);
+	ALTER TABLE clients ALTER COLUMN full_name DROP NOT NULL;
+	ALTER TABLE clients ALTER COLUMN email DROP NOT NULL;
+	ALTER TABLE clients ALTER COLUMN birth_date DROP NOT NULL;`
+
+	clientMergesQuery := `
+	CREATE TABLE IF NOT EXISTS client_merges (
+		id SERIAL PRIMARY KEY,
+		survivor_id INTEGER NOT NULL,
+		duplicate_id INTEGER NOT NULL,
+		moved_credit_ids INTEGER[] NOT NULL DEFAULT '{}',
+		moved_document_ids INTEGER[] NOT NULL DEFAULT '{}',
+		moved_profile_versions INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	softDeleteQuery := `
+	ALTER TABLE clients ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+	ALTER TABLE banks ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+	ALTER TABLE credits ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;`
+
+	versionQuery := `
+	ALTER TABLE clients ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+	ALTER TABLE banks ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+	ALTER TABLE credits ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;`
+
+	auditEventsQuery := `
+	CREATE TABLE IF NOT EXISTS audit_events (
+		id BIGSERIAL PRIMARY KEY,
+		actor VARCHAR(255) NOT NULL,
+		resource_type VARCHAR(50) NOT NULL,
+		resource_id INTEGER NOT NULL,
+		operation VARCHAR(20) NOT NULL,
+		before JSONB,
+		after JSONB,
+		changes JSONB,
+		request_id VARCHAR(64),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_audit_events_resource ON audit_events (resource_type, resource_id);
+	CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events (actor);
+	CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at);`
+
+	// Domain events waiting for the outbox relay
+	outboxQuery := `
+	CREATE TABLE IF NOT EXISTS outbox (
+		id BIGSERIAL PRIMARY KEY,
+		event_type VARCHAR(64) NOT NULL,
+		aggregate_type VARCHAR(50) NOT NULL,
+		aggregate_id INTEGER NOT NULL,
+		payload JSONB NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		last_error TEXT,
+		published_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_outbox_unpublished ON outbox (next_attempt_at, id) WHERE published_at IS NULL;`
+
+	webhooksQuery := `
+	CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+		id SERIAL PRIMARY KEY,
+		url TEXT NOT NULL,
+		secret VARCHAR(255) NOT NULL,
+		event_types TEXT[] NOT NULL DEFAULT '{}',
+		bank_id INTEGER,
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		consecutive_failures INTEGER NOT NULL DEFAULT 0,
+		disabled_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id BIGSERIAL PRIMARY KEY,
+		subscription_id INTEGER NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+		event_id BIGINT NOT NULL,
+		event_type VARCHAR(64) NOT NULL,
+		payload JSONB NOT NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'PENDING' CHECK (status IN ('PENDING', 'SUCCEEDED', 'FAILED')),
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		last_error TEXT,
+		delivered_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (subscription_id, event_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_pending ON webhook_deliveries (next_attempt_at, id) WHERE status = 'PENDING';
+	CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+		id BIGSERIAL PRIMARY KEY,
+		delivery_id BIGINT NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+		status_code INTEGER,
+		error TEXT,
+		duration_ms BIGINT NOT NULL,
+		attempted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// Announce new outbox rows to event stream listeners; notifications are
+	// sent when the inserting transaction commits
+	outboxNotifyQuery := `
+	CREATE OR REPLACE FUNCTION notify_outbox_event() RETURNS trigger AS $$
+	BEGIN
+		PERFORM pg_notify('outbox_events', NEW.id::text);
+		RETURN NEW;
+	END;
+	$$ LANGUAGE plpgsql;
+	DROP TRIGGER IF EXISTS outbox_notify ON outbox;
+	CREATE TRIGGER outbox_notify AFTER INSERT ON outbox FOR EACH ROW EXECUTE FUNCTION notify_outbox_event();`
+
+	// Number outbox rows in commit order for event stream replay. Ids are
+	// taken at insert, so a lower id can commit after a higher one. The
+	// number is taken as the transaction commits, under a lock held until
+	// it has, which serializes the commits of transactions writing events;
+	// migration 000016 explains why a reader needs that. Rows from before
+	// the column existed keep their id as number.
+	outboxSequenceQuery := `
+	CREATE SEQUENCE IF NOT EXISTS outbox_stream_seq;
+	ALTER TABLE outbox ADD COLUMN IF NOT EXISTS stream_seq BIGINT;
+	UPDATE outbox SET stream_seq = id WHERE stream_seq IS NULL;
+	SELECT setval('outbox_stream_seq', GREATEST(
+		(SELECT COALESCE(MAX(stream_seq), 0) FROM outbox),
+		(SELECT last_value FROM outbox_stream_seq)));
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_outbox_stream_seq ON outbox (stream_seq);
+	CREATE OR REPLACE FUNCTION sequence_outbox_event() RETURNS trigger AS $$
+	BEGIN
+		PERFORM pg_advisory_xact_lock(hashtext('outbox_stream_seq'));
+		UPDATE outbox SET stream_seq = nextval('outbox_stream_seq') WHERE id = NEW.id;
+		RETURN NULL;
+	END;
+	$$ LANGUAGE plpgsql;
+	DROP TRIGGER IF EXISTS outbox_sequence ON outbox;
+	CREATE CONSTRAINT TRIGGER outbox_sequence AFTER INSERT ON outbox
+		DEFERRABLE INITIALLY DEFERRED FOR EACH ROW EXECUTE FUNCTION sequence_outbox_event();`
+
+	idempotencyKeysQuery := `
+	CREATE TABLE IF NOT EXISTS idempotency_keys (
+		key VARCHAR(255) PRIMARY KEY,
+		fingerprint VARCHAR(64) NOT NULL,
+		completed BOOLEAN NOT NULL DEFAULT FALSE,
+		response_status INTEGER,
+		response_headers JSONB,
+		response_body BYTEA,
+		locked_until TIMESTAMP NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	importJobsQuery := `
+	CREATE TABLE IF NOT EXISTS import_jobs (
+		id SERIAL PRIMARY KEY,
+		resource VARCHAR(20) NOT NULL,
+		format VARCHAR(10) NOT NULL,
+		file_name VARCHAR(255) NOT NULL,
+		dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+		mapping JSONB NOT NULL DEFAULT '{}',
+		columns TEXT[] NOT NULL DEFAULT '{}',
+		status VARCHAR(20) NOT NULL DEFAULT 'PENDING' CHECK (status IN ('PENDING', 'RUNNING', 'COMPLETED', 'FAILED')),
+		total_rows INTEGER NOT NULL DEFAULT 0,
+		processed_rows INTEGER NOT NULL DEFAULT 0,
+		imported_rows INTEGER NOT NULL DEFAULT 0,
+		rejected_rows INTEGER NOT NULL DEFAULT 0,
+		error TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		started_at TIMESTAMP,
+		finished_at TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS import_rejections (
+		id BIGSERIAL PRIMARY KEY,
+		job_id INTEGER NOT NULL REFERENCES import_jobs(id) ON DELETE CASCADE,
+		row_number INTEGER NOT NULL,
+		error TEXT NOT NULL,
+		data TEXT[] NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_import_rejections_job ON import_rejections (job_id, row_number);`
+
+	importRejectionsPIIQuery := `
+	ALTER TABLE import_rejections ADD COLUMN IF NOT EXISTS data_enc TEXT;
+	ALTER TABLE import_rejections ADD COLUMN IF NOT EXISTS email_index VARCHAR(64);
+	CREATE INDEX IF NOT EXISTS idx_import_rejections_email ON import_rejections (email_index);`
+
+	idempotencyKeySubjectsQuery := `
+	ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS body_encrypted BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS subjects TEXT[] NOT NULL DEFAULT '{}';
+	CREATE INDEX IF NOT EXISTS idx_idempotency_keys_subjects ON idempotency_keys USING GIN (subjects);`
+
+	queries := []string{
+		itemsQuery,
+		clientsQuery,
+		banksQuery,
+		creditsQuery,
+		creditsDTIQuery,
+		financialProfilesQuery,
+		clientDocumentsQuery,
+		clientsErasureQuery,
+		clientsEncryptionQuery,
+		clientMergesQuery,
+		softDeleteQuery,
+		versionQuery,
+		auditEventsQuery,
+		outboxQuery,
+		webhooksQuery,
+		outboxNotifyQuery,
+		idempotencyKeysQuery,
+		importJobsQuery,
+		importRejectionsPIIQuery,
+		outboxSequenceQuery,
+		idempotencyKeySubjectsQuery,
 	}
 
-	_, err = DB.Exec(creditsQuery)
-	if err != nil {
-		return err
+	for _, query := range queries {
+		if _, err := DB.Exec(query); err != nil {
+			return err
+		}
 	}
 
 	log.Println("Database schema initialized")
@@ -0,0 +1,192 @@
+package dedupe
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"backend/internal/models"
+)
+
+// Weights of each signal in the overall score. They sum to 1.
+const (
+	nameWeight      = 0.6
+	birthDateWeight = 0.3
+	countryWeight   = 0.1
+)
+
+// Candidate is a pair of clients that may be the same person.
+type Candidate struct {
+	Client         models.Client `json:"client"`
+	Duplicate      models.Client `json:"duplicate"`
+	Score          float64       `json:"score"`
+	NameSimilarity float64       `json:"name_similarity"`
+	SameBirthDate  bool          `json:"same_birth_date"`
+	// NearBirthDate is set when the birth dates are one typo apart: a single
+	// digit replaced or two adjacent digits swapped.
+	NearBirthDate bool `json:"near_birth_date"`
+	SameCountry   bool `json:"same_country"`
+}
+
+// Score compares two clients and returns a candidate with a score between 0
+// and 1. A birth date one typo away earns two thirds of the birth date weight.
+func Score(a, b models.Client) Candidate {
+	birthA, birthB := a.BirthDate.Format("2006-01-02"), b.BirthDate.Format("2006-01-02")
+	c := Candidate{
+		Client:         a,
+		Duplicate:      b,
+		NameSimilarity: NameSimilarity(a.FullName, b.FullName),
+		SameBirthDate:  birthA == birthB,
+		NearBirthDate:  oneTypoApart(birthA, birthB),
+		SameCountry:    strings.EqualFold(strings.TrimSpace(a.Country), strings.TrimSpace(b.Country)),
+	}
+	c.Score = nameWeight * c.NameSimilarity
+	switch {
+	case c.SameBirthDate:
+		c.Score += birthDateWeight
+	case c.NearBirthDate:
+		c.Score += birthDateWeight * 2 / 3
+	}
+	if c.SameCountry {
+		c.Score += countryWeight
+	}
+	return c
+}
+
+// FindCandidates returns every pair scoring at least minScore, best first.
+// Only clients sharing a blocking key are compared, which keeps the search
+// from growing quadratically with the whole client base. The keys are the
+// birth year, the birth month and day, and the start of the name, so a typo
+// in any one of them still leaves the pair in a shared block.
+func FindCandidates(clients []models.Client, minScore float64) []Candidate {
+	blocks := map[string][]int{}
+	for i, client := range clients {
+		for _, key := range blockingKeys(client) {
+			blocks[key] = append(blocks[key], i)
+		}
+	}
+
+	candidates := []Candidate{}
+	compared := map[[2]int]bool{}
+	for _, block := range blocks {
+		for i := 0; i < len(block); i++ {
+			for j := i + 1; j < len(block); j++ {
+				pair := [2]int{block[i], block[j]}
+				if compared[pair] {
+					continue
+				}
+				compared[pair] = true
+
+				a, b := clients[pair[0]], clients[pair[1]]
+				if a.ID > b.ID {
+					a, b = b, a
+				}
+				if c := Score(a, b); c.Score >= minScore {
+					candidates = append(candidates, c)
+				}
+			}
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].Client.ID < candidates[j].Client.ID
+	})
+	return candidates
+}
+
+func blockingKeys(client models.Client) []string {
+	keys := []string{
+		"year:" + client.BirthDate.Format("2006"),
+		"day:" + client.BirthDate.Format("01-02"),
+	}
+	if name := []rune(normalizeName(client.FullName)); len(name) > 0 {
+		keys = append(keys, "name:"+string(name[:min(len(name), 3)]))
+	}
+	return keys
+}
+
+// oneTypoApart reports whether two strings of the same length differ in
+// exactly one position or by one swap of adjacent characters.
+func oneTypoApart(a, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	var diffs []int
+	for i := 0; i < len(a); i++ {
+		if a[i] != b[i] {
+			diffs = append(diffs, i)
+		}
+	}
+	switch len(diffs) {
+	case 1:
+		return true
+	case 2:
+		i, j := diffs[0], diffs[1]
+		return j == i+1 && a[i] == b[j] && a[j] == b[i]
+	}
+	return false
+}
+
+// NameSimilarity returns 1 minus the normalized edit distance between two
+// names after case folding, accent removal and token sorting, so that
+// "Pérez, Juan" and "juan perez" are identical.
+func NameSimilarity(a, b string) float64 {
+	a, b = normalizeName(a), normalizeName(b)
+	if a == "" && b == "" {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+// accentFolder maps accented Latin letters to their base letter.
+var accentFolder = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ä", "a", "ã", "a", "å", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "ö", "o", "õ", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ñ", "n", "ç", "c",
+)
+
+func normalizeName(name string) string {
+	var b strings.Builder
+	for _, r := range accentFolder.Replace(strings.ToLower(name)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	tokens := strings.Fields(b.String())
+	sort.Strings(tokens)
+	return strings.Join(tokens, " ")
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
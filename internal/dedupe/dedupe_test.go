@@ -0,0 +1,54 @@
+package dedupe
+
+import (
+	"testing"
+	"time"
+
+	"backend/internal/models"
+)
+
+func TestNameSimilarityIgnoresOrderCaseAndAccents(t *testing.T) {
+	if sim := NameSimilarity("Pérez, Juan", "juan perez"); sim != 1 {
+		t.Errorf("Expected similarity 1, got %v", sim)
+	}
+	if sim := NameSimilarity("John Doe", "Jane Smith"); sim > 0.5 {
+		t.Errorf("Expected low similarity for different names, got %v", sim)
+	}
+}
+
+func TestFindCandidates(t *testing.T) {
+	birth := time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)
+	clients := []models.Client{
+		{ID: 1, FullName: "John Doe", BirthDate: birth, Country: "USA"},
+		{ID: 2, FullName: "Jon Doe", BirthDate: birth, Country: "usa"},
+		{ID: 3, FullName: "Jane Smith", BirthDate: birth, Country: "Panama"},
+		{ID: 4, FullName: "John Doe", BirthDate: birth.AddDate(20, 0, 0), Country: "USA"},
+	}
+
+	candidates := FindCandidates(clients, 0.8)
+	if len(candidates) != 1 {
+		t.Fatalf("Expected 1 candidate, got %d", len(candidates))
+	}
+	if candidates[0].Client.ID != 1 || candidates[0].Duplicate.ID != 2 {
+		t.Errorf("Expected pair (1, 2), got (%d, %d)", candidates[0].Client.ID, candidates[0].Duplicate.ID)
+	}
+	if !candidates[0].SameBirthDate || !candidates[0].SameCountry {
+		t.Errorf("Expected matching birth date and country, got %+v", candidates[0])
+	}
+}
+
+func TestFindCandidatesWithBirthYearTypo(t *testing.T) {
+	clients := []models.Client{
+		{ID: 1, FullName: "Maria Garcia", BirthDate: time.Date(1985, 3, 14, 0, 0, 0, 0, time.UTC), Country: "Spain"},
+		{ID: 2, FullName: "María García", BirthDate: time.Date(1958, 3, 14, 0, 0, 0, 0, time.UTC), Country: "Spain"},
+		{ID: 3, FullName: "Maria Garcia", BirthDate: time.Date(1971, 3, 14, 0, 0, 0, 0, time.UTC), Country: "Spain"},
+	}
+
+	candidates := FindCandidates(clients, 0.8)
+	if len(candidates) != 1 {
+		t.Fatalf("Expected 1 candidate, got %d", len(candidates))
+	}
+	if c := candidates[0]; c.Client.ID != 1 || c.Duplicate.ID != 2 || c.SameBirthDate || !c.NearBirthDate {
+		t.Errorf("Expected pair (1, 2) with a near birth date, got %+v", c)
+	}
+}
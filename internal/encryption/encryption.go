@@ -0,0 +1,188 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+const prefix = "enc:v1:"
+
+var errMalformed = errors.New("malformed ciphertext")
+
+// Encrypter performs envelope encryption: every value is sealed with a fresh
+// data key, and the data key is wrapped with the provider's current key.
+// Ciphertexts have the form enc:v1:<key id>:<wrapped data key>:<sealed value>.
+type Encrypter struct {
+	provider KeyProvider
+}
+
+func NewEncrypter(provider KeyProvider) *Encrypter {
+	return &Encrypter{provider: provider}
+}
+
+// Reload reloads the keys of a provider that is a Reloader, and does nothing
+// for other providers.
+func (e *Encrypter) Reload() error {
+	if reloader, ok := e.provider.(Reloader); ok {
+		return reloader.Reload()
+	}
+	return nil
+}
+
+// CurrentKeyID returns the id of the key new values are wrapped with.
+func (e *Encrypter) CurrentKeyID() (string, error) {
+	id, _, err := e.provider.CurrentKey()
+	return id, err
+}
+
+func (e *Encrypter) Encrypt(plaintext string) (string, error) {
+	keyID, kek, err := e.provider.CurrentKey()
+	if err != nil {
+		return "", err
+	}
+
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	sealed, err := seal(dek, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := seal(kek, dek)
+	if err != nil {
+		return "", err
+	}
+	return format(keyID, wrapped, sealed), nil
+}
+
+func (e *Encrypter) Decrypt(ciphertext string) (string, error) {
+	keyID, wrapped, sealed, err := parse(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	kek, err := e.provider.Key(keyID)
+	if err != nil {
+		return "", err
+	}
+	dek, err := open(kek, wrapped)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dek, sealed)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// KeyID reports which key a ciphertext's data key is wrapped with.
+func (e *Encrypter) KeyID(ciphertext string) (string, error) {
+	keyID, _, _, err := parse(ciphertext)
+	return keyID, err
+}
+
+// Rewrap re-wraps the data key of a ciphertext with the current key. The
+// sealed value itself is left untouched.
+func (e *Encrypter) Rewrap(ciphertext string) (string, error) {
+	keyID, wrapped, sealed, err := parse(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	currentID, currentKey, err := e.provider.CurrentKey()
+	if err != nil {
+		return "", err
+	}
+	if keyID == currentID {
+		return ciphertext, nil
+	}
+
+	oldKey, err := e.provider.Key(keyID)
+	if err != nil {
+		return "", err
+	}
+	dek, err := open(oldKey, wrapped)
+	if err != nil {
+		return "", err
+	}
+	rewrapped, err := seal(currentKey, dek)
+	if err != nil {
+		return "", err
+	}
+	return format(currentID, rewrapped, sealed), nil
+}
+
+// BlindIndex returns a deterministic keyed hash of a value, so equality
+// lookups and unique constraints work without storing the plaintext. Values
+// are trimmed and lower-cased first, which suits email addresses.
+func (e *Encrypter) BlindIndex(value string) (string, error) {
+	key, err := e.provider.IndexKey()
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(value))))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func format(keyID string, wrapped, sealed []byte) string {
+	return prefix + keyID + ":" + base64.RawStdEncoding.EncodeToString(wrapped) + ":" + base64.RawStdEncoding.EncodeToString(sealed)
+}
+
+func parse(ciphertext string) (string, []byte, []byte, error) {
+	if !strings.HasPrefix(ciphertext, prefix) {
+		return "", nil, nil, errMalformed
+	}
+	parts := strings.Split(strings.TrimPrefix(ciphertext, prefix), ":")
+	if len(parts) != 3 {
+		return "", nil, nil, errMalformed
+	}
+	wrapped, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, nil, errMalformed
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, nil, errMalformed
+	}
+	return parts[0], wrapped, sealed, nil
+}
+
+// seal encrypts with AES-256-GCM and prepends the nonce.
+func seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errMalformed
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
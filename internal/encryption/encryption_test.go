@@ -0,0 +1,140 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type staticProvider struct {
+	current string
+	keys    map[string][]byte
+}
+
+func (p *staticProvider) CurrentKey() (string, []byte, error) {
+	return p.current, p.keys[p.current], nil
+}
+
+func (p *staticProvider) Key(id string) ([]byte, error) {
+	key, ok := p.keys[id]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+func (p *staticProvider) IndexKey() ([]byte, error) {
+	return bytes.Repeat([]byte{9}, 32), nil
+}
+
+func newTestProvider() *staticProvider {
+	return &staticProvider{
+		current: "k1",
+		keys: map[string][]byte{
+			"k1": bytes.Repeat([]byte{1}, 32),
+			"k2": bytes.Repeat([]byte{2}, 32),
+		},
+	}
+}
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	e := NewEncrypter(newTestProvider())
+
+	ciphertext, err := e.Encrypt("john.doe@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ciphertext == "john.doe@example.com" {
+		t.Fatal("Expected ciphertext to differ from plaintext")
+	}
+
+	plaintext, err := e.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext != "john.doe@example.com" {
+		t.Errorf("Expected round trip to return the plaintext, got %q", plaintext)
+	}
+}
+
+func TestRewrapMovesToCurrentKey(t *testing.T) {
+	provider := newTestProvider()
+	e := NewEncrypter(provider)
+
+	ciphertext, err := e.Encrypt("John Doe")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider.current = "k2"
+	rewrapped, err := e.Rewrap(ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyID, _ := e.KeyID(rewrapped); keyID != "k2" {
+		t.Errorf("Expected rewrapped value to use k2, got %s", keyID)
+	}
+
+	// Retiring the old key must not affect rewrapped values
+	delete(provider.keys, "k1")
+	plaintext, err := e.Decrypt(rewrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext != "John Doe" {
+		t.Errorf("Expected 'John Doe', got %q", plaintext)
+	}
+}
+
+func TestBlindIndexIsDeterministicAndNormalized(t *testing.T) {
+	e := NewEncrypter(newTestProvider())
+
+	a, _ := e.BlindIndex("John.Doe@Example.com ")
+	b, _ := e.BlindIndex("john.doe@example.com")
+	c, _ := e.BlindIndex("jane.doe@example.com")
+
+	if a != b {
+		t.Error("Expected blind index to ignore case and surrounding whitespace")
+	}
+	if a == c {
+		t.Error("Expected different values to have different blind indexes")
+	}
+}
+
+func TestLocalKeyProviderReload(t *testing.T) {
+	key := func(b byte) string { return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32)) }
+	path := filepath.Join(t.TempDir(), "keys.json")
+	write := func(current, index string) {
+		data := fmt.Sprintf(`{"current_key_id": %q, "keys": {"k1": %q, "k2": %q}, "index_key": %q}`, current, key(1), key(2), index)
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("k1", key(9))
+	provider, err := NewLocalKeyProvider(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewEncrypter(provider)
+
+	write("k2", key(9))
+	if err := e.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := e.CurrentKeyID(); id != "k2" {
+		t.Errorf("Expected the rotated key k2 to be current, got %s", id)
+	}
+
+	// A changed index key would orphan every blind index
+	write("k1", key(8))
+	if err := e.Reload(); err == nil {
+		t.Error("Expected a changed index key to be refused")
+	}
+	if id, _ := e.CurrentKeyID(); id != "k2" {
+		t.Errorf("Expected a refused reload to keep k2, got %s", id)
+	}
+}
@@ -0,0 +1,138 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownKey is returned when a ciphertext references a key the provider
+// does not hold.
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// KeyProvider supplies the key-encryption keys used to wrap per-value data
+// keys, and the secret used for blind indexes.
+type KeyProvider interface {
+	// CurrentKey returns the key new values are wrapped with.
+	CurrentKey() (id string, key []byte, err error)
+	// Key returns a key by id, including retired keys still needed for decryption.
+	Key(id string) ([]byte, error)
+	// IndexKey returns the HMAC secret for blind indexes. It must not change
+	// once indexes have been written.
+	IndexKey() ([]byte, error)
+}
+
+// Reloader is implemented by key providers that can pick up rotated keys
+// without a restart.
+type Reloader interface {
+	Reload() error
+}
+
+// keyFile is the on-disk format read by LocalKeyProvider. Keys are base64
+// encoded 32-byte AES-256 keys.
+type keyFile struct {
+	CurrentKeyID string            `json:"current_key_id"`
+	Keys         map[string]string `json:"keys"`
+	IndexKey     string            `json:"index_key"`
+}
+
+// LocalKeyProvider reads keys from a JSON file on the local filesystem. Reload
+// picks up a rotated file without a restart.
+type LocalKeyProvider struct {
+	path string
+
+	mu        sync.RWMutex
+	currentID string
+	keys      map[string][]byte
+	indexKey  []byte
+}
+
+// NewLocalKeyProvider loads and validates the key file at path.
+func NewLocalKeyProvider(path string) (*LocalKeyProvider, error) {
+	provider := &LocalKeyProvider{path: path}
+	if err := provider.Reload(); err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+// Reload reads the key file again, typically after a new current key has been
+// added to it. The keys are left unchanged when the file is invalid or its
+// index key differs from the one already loaded.
+func (p *LocalKeyProvider) Reload() error {
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return fmt.Errorf("failed to read key file: %v", err)
+	}
+
+	var file keyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse key file: %v", err)
+	}
+
+	keys := map[string][]byte{}
+	for id, encoded := range file.Keys {
+		if id == "" || strings.Contains(id, ":") {
+			return fmt.Errorf("invalid key id %q", id)
+		}
+		key, err := decodeKey(encoded)
+		if err != nil {
+			return fmt.Errorf("key %q: %v", id, err)
+		}
+		keys[id] = key
+	}
+	if _, ok := keys[file.CurrentKeyID]; !ok {
+		return fmt.Errorf("current key %q is not defined", file.CurrentKeyID)
+	}
+
+	indexKey, err := decodeKey(file.IndexKey)
+	if err != nil {
+		return fmt.Errorf("index key: %v", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.indexKey != nil && !bytes.Equal(p.indexKey, indexKey) {
+		return errors.New("index key changed; existing blind indexes would no longer match")
+	}
+	p.currentID, p.keys, p.indexKey = file.CurrentKeyID, keys, indexKey
+	return nil
+}
+
+func decodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("expected a 32-byte key, got %d bytes", len(key))
+	}
+	return key, nil
+}
+
+func (p *LocalKeyProvider) CurrentKey() (string, []byte, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.currentID, p.keys[p.currentID], nil
+}
+
+func (p *LocalKeyProvider) Key(id string) ([]byte, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	key, ok := p.keys[id]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+func (p *LocalKeyProvider) IndexKey() ([]byte, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.indexKey, nil
+}
@@ -0,0 +1,383 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// List formats.
+const (
+	FormatJSON   = "json"
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// ContentTypes maps each format to its media type.
+var ContentTypes = map[string]string{
+	FormatJSON:   "application/json",
+	FormatCSV:    "text/csv",
+	FormatNDJSON: "application/x-ndjson",
+}
+
+// Negotiate picks the list format from an explicit format value, falling back
+// to the media type the Accept header prefers by quality, the earliest on a
+// tie, and then to JSON. Media types with q=0 are never picked. It returns
+// false when format names an unknown format.
+func Negotiate(format, accept string) (string, bool) {
+	if format != "" {
+		_, ok := ContentTypes[format]
+		return format, ok
+	}
+	best, bestQuality := FormatJSON, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mediaType := strings.TrimSpace(params[0])
+		quality := 1.0
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(strings.TrimSpace(name), "q") {
+				if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		for f, contentType := range ContentTypes {
+			if strings.EqualFold(mediaType, contentType) && quality > bestQuality {
+				best, bestQuality = f, quality
+			}
+		}
+	}
+	return best, true
+}
+
+// Writer streams the values of a list one at a time.
+type Writer interface {
+	Write(v interface{}) error
+	Close() error
+}
+
+// NewWriter returns a Writer for format. JSON lists are written as one array,
+// NDJSON lists as one object per line and CSV lists as a header row followed
+// by one row per value, with the columns of sample's type.
+func NewWriter(w io.Writer, format string, sample interface{}) Writer {
+	switch format {
+	case FormatCSV:
+		out := csv.NewWriter(w)
+		out.Write(Columns(sample))
+		return &csvWriter{out: out}
+	case FormatNDJSON:
+		return &ndjsonWriter{enc: json.NewEncoder(w)}
+	default:
+		return &jsonWriter{w: w}
+	}
+}
+
+// jsonWriter writes the same bytes as encoding a slice with json.Encoder.
+type jsonWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (j *jsonWriter) Write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	prefix := ","
+	if j.count == 0 {
+		prefix = "["
+	}
+	j.count++
+	if _, err := io.WriteString(j.w, prefix); err != nil {
+		return err
+	}
+	_, err = j.w.Write(data)
+	return err
+}
+
+func (j *jsonWriter) Close() error {
+	closing := "]\n"
+	if j.count == 0 {
+		closing = "[]\n"
+	}
+	_, err := io.WriteString(j.w, closing)
+	return err
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(v interface{}) error {
+	return n.enc.Encode(v)
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
+
+type csvWriter struct {
+	out *csv.Writer
+}
+
+func (c *csvWriter) Write(v interface{}) error {
+	return c.out.Write(Values(v))
+}
+
+func (c *csvWriter) Close() error {
+	c.out.Flush()
+	return c.out.Error()
+}
+
+type column struct {
+	name  string
+	index []int
+}
+
+var columnCache sync.Map
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// columnsOf lists the exported fields of t by JSON name in declaration order.
+// Fields tagged "-" are skipped, embedded structs contribute their own
+// columns as in JSON, and other nested structs, or pointers to them, are
+// flattened as "field.subfield"; every column is present whether or not it is
+// omitempty, so the column order is the same for every row.
+func columnsOf(t reflect.Type) []column {
+	if cached, ok := columnCache.Load(t); ok {
+		return cached.([]column)
+	}
+
+	var columns []column
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() == reflect.Struct && fieldType != timeType {
+			prefix := name + "."
+			if name == "" {
+				if field.Anonymous {
+					prefix = ""
+				} else {
+					prefix = field.Name + "."
+				}
+			}
+			for _, nested := range columnsOf(fieldType) {
+				columns = append(columns, column{
+					name:  prefix + nested.name,
+					index: append([]int{i}, nested.index...),
+				})
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		columns = append(columns, column{name: name, index: []int{i}})
+	}
+
+	columnCache.Store(t, columns)
+	return columns
+}
+
+func structType(v interface{}) (reflect.Value, bool) {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	return value, value.Kind() == reflect.Struct
+}
+
+// fieldOf returns the top-level JSON field a column belongs to.
+func fieldOf(column string) string {
+	return strings.SplitN(column, ".", 2)[0]
+}
+
+// selectedColumns returns the columns of v, limited to the fields of a
+// Selection.
+func selectedColumns(v interface{}) (reflect.Value, []column, bool) {
+	selection, isSelection := v.(Selection)
+	if isSelection {
+		v = selection.Value
+	}
+	value, ok := structType(v)
+	if !ok {
+		return value, nil, false
+	}
+	columns := columnsOf(value.Type())
+	if !isSelection {
+		return value, columns, true
+	}
+	var selected []column
+	for _, c := range columns {
+		if selection.Fields[fieldOf(c.name)] {
+			selected = append(selected, c)
+		}
+	}
+	return value, selected, true
+}
+
+// FieldNames returns the top-level JSON fields of v in declaration order,
+// including the fields of embedded structs.
+func FieldNames(v interface{}) []string {
+	value, ok := structType(v)
+	if !ok {
+		return nil
+	}
+	var names []string
+	for _, c := range columnsOf(value.Type()) {
+		name := fieldOf(c.name)
+		if len(names) == 0 || names[len(names)-1] != name {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Selection is a value limited to some of its top-level JSON fields. It is
+// written with those fields only, in declaration order, in every format.
+type Selection struct {
+	Value  interface{}
+	Fields map[string]bool
+}
+
+// Select limits v to fields, or returns v unchanged when fields is nil.
+func Select(v interface{}, fields map[string]bool) interface{} {
+	if fields == nil {
+		return v
+	}
+	return Selection{Value: v, Fields: fields}
+}
+
+func (s Selection) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(s.Value)
+	if err != nil {
+		return nil, err
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for _, name := range FieldNames(s.Value) {
+		value, ok := object[name]
+		if !ok || !s.Fields[name] {
+			continue
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Columns returns the CSV header for values like v.
+func Columns(v interface{}) []string {
+	_, columns, ok := selectedColumns(v)
+	if !ok {
+		return []string{"value"}
+	}
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.name
+	}
+	return names
+}
+
+// Values returns the CSV row for v, in the order of Columns.
+func Values(v interface{}) []string {
+	value, columns, ok := selectedColumns(v)
+	if !ok {
+		return []string{format(reflect.ValueOf(v))}
+	}
+	values := make([]string, len(columns))
+	for i, c := range columns {
+		// A nil pointer on the way leaves the cell empty
+		field, err := value.FieldByIndexErr(c.index)
+		if err != nil {
+			values[i] = ""
+			continue
+		}
+		values[i] = format(field)
+	}
+	return values
+}
+
+// format renders one cell. Missing values are empty, times are RFC 3339 as in
+// JSON, and slices and maps are written as JSON.
+func format(v reflect.Value) string {
+	if !v.IsValid() {
+		return ""
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return ""
+		}
+		return format(v.Elem())
+	case reflect.String:
+		return EscapeFormula(v.String())
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	case reflect.Slice:
+		if v.IsNil() {
+			return ""
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			// json.RawMessage and other raw bytes
+			return string(v.Bytes())
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			return ""
+		}
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.Format(time.RFC3339Nano)
+	}
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// EscapeFormula prefixes text that a spreadsheet would run as a formula with
+// a quote, so an exported name like "=HYPERLINK(...)" opens as plain text.
+// Every string cell written by a Writer goes through it; other CSV output
+// made of untrusted text should too.
+func EscapeFormula(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
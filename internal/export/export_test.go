@@ -0,0 +1,193 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type inner struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type record struct {
+	ID        int               `json:"id"`
+	Label     string            `json:"label"`
+	Amount    *float64          `json:"amount,omitempty"`
+	Tags      []string          `json:"tags"`
+	Meta      map[string]string `json:"meta"`
+	Raw       json.RawMessage   `json:"raw"`
+	Hidden    string            `json:"-"`
+	Owner     inner             `json:"owner"`
+	Active    bool              `json:"active"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+func TestNegotiate(t *testing.T) {
+	for _, tc := range []struct {
+		format, accept, expected string
+		ok                       bool
+	}{
+		{"", "", FormatJSON, true},
+		{"", "text/html, */*", FormatJSON, true},
+		{"", "text/csv", FormatCSV, true},
+		{"", "application/json;q=0.5, application/x-ndjson", FormatNDJSON, true},
+		{"", "text/csv;q=0", FormatJSON, true},
+		{"", "text/csv;q=0.4, application/x-ndjson;q=0.8", FormatNDJSON, true},
+		{"", "application/json, text/csv;q=0.9", FormatJSON, true},
+		{"", "text/csv; charset=utf-8; Q=0.5, */*;q=0.1", FormatCSV, true},
+		{"csv", "application/x-ndjson", FormatCSV, true},
+		{"xml", "", "xml", false},
+	} {
+		format, ok := Negotiate(tc.format, tc.accept)
+		if format != tc.expected || ok != tc.ok {
+			t.Errorf("Negotiate(%q, %q) = %q, %v; want %q, %v", tc.format, tc.accept, format, ok, tc.expected, tc.ok)
+		}
+	}
+}
+
+func TestColumnsAndValues(t *testing.T) {
+	amount := 12.5
+	created := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	r := record{
+		ID: 1, Label: `say "hi"`, Amount: &amount, Tags: []string{"a", "b"},
+		Raw: json.RawMessage(`{"x":1}`), Hidden: "secret", Owner: inner{ID: 7, Name: "Ana"},
+		Active: true, CreatedAt: created,
+	}
+
+	columns := Columns(r)
+	expectedColumns := []string{"id", "label", "amount", "tags", "meta", "raw", "owner.id", "owner.name", "active", "created_at"}
+	if !reflect.DeepEqual(columns, expectedColumns) {
+		t.Errorf("Expected columns %v, got %v", expectedColumns, columns)
+	}
+
+	values := Values(&r)
+	expectedValues := []string{"1", `say "hi"`, "12.5", `["a","b"]`, "", `{"x":1}`, "7", "Ana", "true", "2024-03-01T10:00:00Z"}
+	if !reflect.DeepEqual(values, expectedValues) {
+		t.Errorf("Expected values %v, got %v", expectedValues, values)
+	}
+
+	if values := Values(record{}); values[2] != "" {
+		t.Errorf("Expected an empty cell for a nil pointer, got %q", values[2])
+	}
+}
+
+func TestValuesEscapeFormulas(t *testing.T) {
+	amount := -12.5
+	values := Values(record{ID: -1, Label: "=HYPERLINK(\"http://example.com\")", Amount: &amount, Owner: inner{Name: "@SUM(A1)"}})
+	if values[0] != "-1" || values[2] != "-12.5" {
+		t.Errorf("Expected numbers to be left alone, got %q and %q", values[0], values[2])
+	}
+	if values[1] != "'=HYPERLINK(\"http://example.com\")" || values[7] != "'@SUM(A1)" {
+		t.Errorf("Expected formulas to be escaped, got %q and %q", values[1], values[7])
+	}
+	for _, text := range []string{"+1 555 0100", "-x", "\tcmd"} {
+		if value := Values(text)[0]; value != "'"+text {
+			t.Errorf("Expected %q to be escaped, got %q", text, value)
+		}
+	}
+	if value := Values("a=b")[0]; value != "a=b" {
+		t.Errorf("Expected text not starting with a formula character to be kept, got %q", value)
+	}
+}
+
+type wrapped struct {
+	record
+	Parent *inner `json:"parent,omitempty"`
+}
+
+func TestEmbeddedAndPointerStructs(t *testing.T) {
+	columns := Columns(wrapped{})
+	if len(columns) != 12 || columns[0] != "id" || columns[10] != "parent.id" || columns[11] != "parent.name" {
+		t.Errorf("Expected embedded fields unprefixed and pointer fields flattened, got %v", columns)
+	}
+
+	values := Values(wrapped{record: record{ID: 3}})
+	if values[0] != "3" || values[10] != "" || values[11] != "" {
+		t.Errorf("Expected empty cells for a nil pointer struct, got %v", values)
+	}
+
+	values = Values(wrapped{Parent: &inner{ID: 9, Name: "Bo"}})
+	if values[10] != "9" || values[11] != "Bo" {
+		t.Errorf("Expected the pointed-to struct's values, got %v", values)
+	}
+}
+
+func TestSelection(t *testing.T) {
+	v := wrapped{record: record{ID: 4, Label: "<b>", Owner: inner{ID: 1, Name: "Ana"}}, Parent: &inner{ID: 2}}
+	fields := map[string]bool{"parent": true, "owner": true, "id": true, "label": true}
+
+	if names := FieldNames(v); len(names) != 10 || names[6] != "owner" || names[9] != "parent" {
+		t.Errorf("Unexpected field names %v", names)
+	}
+
+	data, err := json.Marshal(Select(v, fields))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"id":4,"label":"\u003cb\u003e","owner":{"id":1,"name":"Ana"},"parent":{"id":2,"name":""}}`; string(data) != expected {
+		t.Errorf("Expected %s, got %s", expected, data)
+	}
+
+	if columns := Columns(Select(v, fields)); !reflect.DeepEqual(columns, []string{"id", "label", "owner.id", "owner.name", "parent.id", "parent.name"}) {
+		t.Errorf("Unexpected selected columns %v", columns)
+	}
+	if values := Values(Select(v, fields)); !reflect.DeepEqual(values, []string{"4", "<b>", "1", "Ana", "2", ""}) {
+		t.Errorf("Unexpected selected values %v", values)
+	}
+	if _, ok := Select(v, nil).(Selection); ok {
+		t.Errorf("Expected a nil field set to leave the value unchanged")
+	}
+}
+
+func TestJSONWriterMatchesEncoder(t *testing.T) {
+	items := []inner{{1, "<a>"}, {2, "b"}}
+
+	for _, list := range [][]inner{{}, items} {
+		var expected, actual bytes.Buffer
+		json.NewEncoder(&expected).Encode(list)
+
+		w := NewWriter(&actual, FormatJSON, inner{})
+		for _, item := range list {
+			if err := w.Write(item); err != nil {
+				t.Fatal(err)
+			}
+		}
+		w.Close()
+
+		if actual.String() != expected.String() {
+			t.Errorf("Expected %q, got %q", expected.String(), actual.String())
+		}
+	}
+}
+
+func TestCSVAndNDJSONWriters(t *testing.T) {
+	var csvOut bytes.Buffer
+	w := NewWriter(&csvOut, FormatCSV, inner{})
+	w.Write(inner{1, "Doe, John"})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "id,name\n1,\"Doe, John\"\n"; csvOut.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, csvOut.String())
+	}
+
+	var empty bytes.Buffer
+	w = NewWriter(&empty, FormatCSV, inner{})
+	w.Close()
+	if empty.String() != "id,name\n" {
+		t.Errorf("Expected only the header for an empty list, got %q", empty.String())
+	}
+
+	var ndjson bytes.Buffer
+	w = NewWriter(&ndjson, FormatNDJSON, inner{})
+	w.Write(inner{1, "a"})
+	w.Write(inner{2, "b"})
+	w.Close()
+	if expected := "{\"id\":1,\"name\":\"a\"}\n{\"id\":2,\"name\":\"b\"}\n"; ndjson.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, ndjson.String())
+	}
+}
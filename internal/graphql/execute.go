@@ -0,0 +1,958 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Request is the body of a GraphQL HTTP request.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Response is the result of a request. Data is left out when the request
+// could not be executed at all.
+type Response struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []*Error    `json:"errors,omitempty"`
+}
+
+// Error is a GraphQL error. Resolvers may return one to set extensions.
+type Error struct {
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// null marks a null that is the result of an error, which has already been
+// recorded. It nulls the nearest nullable parent.
+type null struct{}
+
+var errNull = null{}
+
+// Execute runs the operation of req against the schema.
+func (s *Schema) Execute(ctx context.Context, req Request) *Response {
+	doc, err := Parse(req.Query)
+	if err != nil {
+		return &Response{Errors: []*Error{{Message: err.Error()}}}
+	}
+	op, err := doc.operation(req.OperationName)
+	if err != nil {
+		return &Response{Errors: []*Error{{Message: err.Error()}}}
+	}
+	root := s.Query
+	if op.Kind == "mutation" {
+		root = s.Mutation
+		if root == nil {
+			return &Response{Errors: []*Error{{Message: "Mutations are not supported"}}}
+		}
+	}
+
+	v := &validator{schema: s, doc: doc, defined: map[string]bool{}}
+	for _, def := range op.Variables {
+		v.defined[def.Name] = true
+	}
+	v.selections(root, op.Selections, map[string]bool{}, 1)
+	if len(v.errors) > 0 {
+		return &Response{Errors: v.errors}
+	}
+	variables, err := s.coerceVariables(op.Variables, req.Variables)
+	if err != nil {
+		return &Response{Errors: []*Error{{Message: err.Error()}}}
+	}
+	if s.MaxCost > 0 {
+		// Sizes can come from variables, so the cost is known only now
+		c := &coster{max: s.MaxCost, fragments: doc.Fragments, variables: variables}
+		if !c.selections(root, op.Selections, 1) {
+			return &Response{Errors: []*Error{{Message: fmt.Sprintf("Query may resolve more than %d fields; ask for smaller pages", s.MaxCost)}}}
+		}
+	}
+
+	e := &executor{ctx: ctx, fragments: doc.Fragments, variables: variables}
+	result := e.executeObjects(root, []interface{}{nil}, op.Selections, []path{nil})[0]
+	response := &Response{Data: result, Errors: e.errors}
+	if result == errNull {
+		response.Data = json.RawMessage("null")
+	}
+	return response
+}
+
+// Mutation reports whether req runs a mutation. A request that cannot be
+// parsed does not.
+func (req Request) Mutation() bool {
+	doc, err := Parse(req.Query)
+	if err != nil {
+		return false
+	}
+	op, err := doc.operation(req.OperationName)
+	return err == nil && op.Kind == "mutation"
+}
+
+func (d *Document) operation(name string) (*Operation, error) {
+	if name == "" {
+		if len(d.Operations) > 1 {
+			return nil, fmt.Errorf("operationName is required for a document with several operations")
+		}
+		return d.Operations[0], nil
+	}
+	for _, op := range d.Operations {
+		if op.Name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("Unknown operation %s", name)
+}
+
+// validator checks selections against the schema before anything runs.
+type validator struct {
+	schema  *Schema
+	doc     *Document
+	defined map[string]bool
+	errors  []*Error
+	// fields counts the fields selected so far. Once the query is found
+	// too large the rest of it is not walked.
+	fields   int
+	tooLarge bool
+}
+
+func (v *validator) fail(format string, args ...interface{}) {
+	v.errors = append(v.errors, &Error{Message: fmt.Sprintf(format, args...)})
+}
+
+// limited counts a field at depth and reports whether it is beyond the
+// schema's limits.
+func (v *validator) limited(depth int) bool {
+	v.fields++
+	switch {
+	case v.schema.MaxDepth > 0 && depth > v.schema.MaxDepth:
+		v.fail("Query is nested more than %d fields deep", v.schema.MaxDepth)
+	case v.schema.MaxFields > 0 && v.fields > v.schema.MaxFields:
+		v.fail("Query selects more than %d fields", v.schema.MaxFields)
+	default:
+		return false
+	}
+	v.tooLarge = true
+	return true
+}
+
+func (v *validator) selections(t *Object, selections []Selection, spreading map[string]bool, depth int) {
+	for _, selection := range selections {
+		if v.tooLarge {
+			return
+		}
+		switch s := selection.(type) {
+		case *FieldNode:
+			if v.limited(depth) {
+				return
+			}
+			v.directives(s.Directives)
+			if s.Name == "__typename" {
+				if s.Selections != nil {
+					v.fail("Field __typename cannot have a selection")
+				}
+				continue
+			}
+			field := t.field(s.Name)
+			if field == nil {
+				v.fail("Cannot query field %s on type %s", s.Name, t.Name)
+				continue
+			}
+			v.arguments(t.Name+"."+s.Name, field.Args, s.Arguments)
+			object, isObject := named(field.Type).(*Object)
+			switch {
+			case isObject && s.Selections == nil:
+				v.fail("Field %s of type %s must have a selection of subfields", s.Name, field.Type)
+			case !isObject && s.Selections != nil:
+				v.fail("Field %s of type %s cannot have a selection", s.Name, field.Type)
+			case isObject:
+				v.selections(object, s.Selections, spreading, depth+1)
+			}
+		case *FragmentSpread:
+			v.directives(s.Directives)
+			fragment, ok := v.doc.Fragments[s.Name]
+			if !ok {
+				v.fail("Unknown fragment %s", s.Name)
+				continue
+			}
+			if spreading[s.Name] {
+				v.fail("Fragment %s spreads itself", s.Name)
+				continue
+			}
+			if fragment.On != t.Name {
+				v.fail("Fragment %s on %s cannot be spread within %s", s.Name, fragment.On, t.Name)
+				continue
+			}
+			spreading[s.Name] = true
+			v.selections(t, fragment.Selections, spreading, depth)
+			delete(spreading, s.Name)
+		case *InlineFragment:
+			v.directives(s.Directives)
+			if s.On != "" && s.On != t.Name {
+				v.fail("Fragment on %s cannot be spread within %s", s.On, t.Name)
+				continue
+			}
+			v.selections(t, s.Selections, spreading, depth)
+		}
+	}
+}
+
+// coster adds up the cost of a validated query, stopping as soon as it is
+// over max.
+type coster struct {
+	max       int
+	total     int
+	fragments map[string]*Fragment
+	variables map[string]interface{}
+}
+
+// selections adds the cost of selections resolved on multiplier objects of
+// type t and reports whether the total is still within max. Directives are
+// ignored, so skipped fields count too.
+func (c *coster) selections(t *Object, selections []Selection, multiplier int) bool {
+	for _, selection := range selections {
+		switch s := selection.(type) {
+		case *FieldNode:
+			c.total += multiplier
+			if c.total > c.max {
+				return false
+			}
+			field := t.field(s.Name)
+			if field == nil {
+				continue
+			}
+			object, isObject := named(field.Type).(*Object)
+			if !isObject {
+				continue
+			}
+			if !c.selections(object, s.Selections, c.times(multiplier, c.size(field, s))) {
+				return false
+			}
+		case *FragmentSpread:
+			if !c.selections(t, c.fragments[s.Name].Selections, multiplier) {
+				return false
+			}
+		case *InlineFragment:
+			if !c.selections(t, s.Selections, multiplier) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// size is the value of the field's SizeArg, or one. Invalid arguments are
+// reported when the field runs.
+func (c *coster) size(field *Field, node *FieldNode) int {
+	if field.SizeArg == "" {
+		return 1
+	}
+	args, err := coerceArguments(field.Args, node.Arguments, c.variables)
+	if err != nil {
+		return 1
+	}
+	if n, ok := args[field.SizeArg].(int); ok && n > 1 {
+		return n
+	}
+	return 1
+}
+
+// times multiplies without overflowing, saturating just above max.
+func (c *coster) times(a, b int) int {
+	if a > (c.max+1)/b {
+		return c.max + 1
+	}
+	return a * b
+}
+
+func (v *validator) directives(directives []*Directive) {
+	for _, d := range directives {
+		if d.Name != "include" && d.Name != "skip" {
+			v.fail("Unknown directive @%s", d.Name)
+			continue
+		}
+		v.arguments("@"+d.Name, []*Argument{{Name: "if", Type: &NonNull{Boolean}}}, d.Arguments)
+	}
+}
+
+func (v *validator) arguments(owner string, defs []*Argument, given map[string]Value) {
+	for name, value := range given {
+		known := false
+		for _, def := range defs {
+			known = known || def.Name == name
+		}
+		if !known {
+			v.fail("Unknown argument %s on %s", name, owner)
+		}
+		v.variables(value)
+	}
+	for _, def := range defs {
+		if _, ok := def.Type.(*NonNull); ok && def.Default == nil {
+			if _, given := given[def.Name]; !given {
+				v.fail("Argument %s of %s is required", def.Name, owner)
+			}
+		}
+	}
+}
+
+func (v *validator) variables(value Value) {
+	switch value := value.(type) {
+	case Variable:
+		if !v.defined[string(value)] {
+			v.fail("Variable $%s is not defined", value)
+		}
+	case ListValue:
+		for _, item := range value {
+			v.variables(item)
+		}
+	case ObjectValue:
+		for _, item := range value {
+			v.variables(item)
+		}
+	}
+}
+
+// named strips list and non-null wrappers.
+func named(t Type) Type {
+	for {
+		switch wrapper := t.(type) {
+		case *List:
+			t = wrapper.Of
+		case *NonNull:
+			t = wrapper.Of
+		default:
+			return t
+		}
+	}
+}
+
+func (s *Schema) typeOf(ref TypeRef) (Type, error) {
+	var t Type
+	if ref.Elem != nil {
+		elem, err := s.typeOf(*ref.Elem)
+		if err != nil {
+			return nil, err
+		}
+		t = &List{Of: elem}
+	} else {
+		builtin := map[string]Type{"Int": Int, "Float": Float, "String": String, "Boolean": Boolean, "ID": ID}
+		if t = builtin[ref.Name]; t == nil {
+			t = s.lookup(ref.Name)
+		}
+		switch t.(type) {
+		case *Scalar, *Enum, *InputObject:
+		default:
+			return nil, fmt.Errorf("Unknown input type %s", ref.Name)
+		}
+	}
+	if ref.NonNull {
+		t = &NonNull{Of: t}
+	}
+	return t, nil
+}
+
+// coerceVariables checks the given variables against their definitions.
+// Variables that are neither given nor defaulted are left out, so arguments
+// using them fall back to their own defaults.
+func (s *Schema) coerceVariables(defs []*VariableDefinition, given map[string]interface{}) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	for _, def := range defs {
+		t, err := s.typeOf(def.Type)
+		if err != nil {
+			return nil, err
+		}
+		raw, ok := given[def.Name]
+		if !ok {
+			if def.Default != nil {
+				value, err := coerceLiteral(t, def.Default, nil)
+				if err != nil {
+					return nil, fmt.Errorf("Variable $%s: %v", def.Name, err)
+				}
+				values[def.Name] = value
+			} else if _, required := t.(*NonNull); required {
+				return nil, fmt.Errorf("Variable $%s of type %s is required", def.Name, t)
+			}
+			continue
+		}
+		value, err := coerceLiteral(t, fromJSON(raw), nil)
+		if err != nil {
+			return nil, fmt.Errorf("Variable $%s: %v", def.Name, err)
+		}
+		values[def.Name] = value
+	}
+	return values, nil
+}
+
+// fromJSON converts a decoded JSON value to document literals, so variables
+// and inline values are coerced alike. Strings may stand for enum values.
+func fromJSON(v interface{}) Value {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v)
+		}
+		return v
+	case []interface{}:
+		list := ListValue{}
+		for _, item := range v {
+			list = append(list, fromJSON(item))
+		}
+		return list
+	case map[string]interface{}:
+		object := ObjectValue{}
+		for name, item := range v {
+			object[name] = fromJSON(item)
+		}
+		return object
+	}
+	return v
+}
+
+// coerceLiteral converts value to the input type t, substituting variables.
+func coerceLiteral(t Type, value Value, variables map[string]interface{}) (interface{}, error) {
+	if name, ok := value.(Variable); ok {
+		v, given := variables[string(name)]
+		if !given {
+			value = nil
+		} else {
+			// Already coerced to the variable's type
+			if _, required := t.(*NonNull); required && v == nil {
+				return nil, fmt.Errorf("must not be null")
+			}
+			return v, nil
+		}
+	}
+	switch t := t.(type) {
+	case *NonNull:
+		if value == nil {
+			return nil, fmt.Errorf("must not be null")
+		}
+		return coerceLiteral(t.Of, value, variables)
+	}
+	if value == nil {
+		return nil, nil
+	}
+	switch t := t.(type) {
+	case *List:
+		items, ok := value.(ListValue)
+		if !ok {
+			items = ListValue{value}
+		}
+		list := make([]interface{}, len(items))
+		for i, item := range items {
+			v, err := coerceLiteral(t.Of, item, variables)
+			if err != nil {
+				return nil, fmt.Errorf("item %d %v", i, err)
+			}
+			list[i] = v
+		}
+		return list, nil
+	case *Scalar:
+		if _, isEnum := value.(EnumValue); isEnum {
+			return nil, fmt.Errorf("expected %s, found enum value %s", t.Name, value)
+		}
+		v, err := t.Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("%v for %s", err, t.Name)
+		}
+		return v, nil
+	case *Enum:
+		var name string
+		switch v := value.(type) {
+		case EnumValue:
+			name = string(v)
+		case string:
+			name = v
+		}
+		for _, allowed := range t.Values {
+			if name == allowed {
+				return name, nil
+			}
+		}
+		return nil, fmt.Errorf("must be one of %s: %s", t.Name, strings.Join(t.Values, ", "))
+	case *InputObject:
+		object, ok := value.(ObjectValue)
+		if !ok {
+			return nil, fmt.Errorf("expected an object for %s", t.Name)
+		}
+		result := map[string]interface{}{}
+		for name := range object {
+			known := false
+			for _, field := range t.Fields {
+				known = known || field.Name == name
+			}
+			if !known {
+				return nil, fmt.Errorf("unknown field %s of %s", name, t.Name)
+			}
+		}
+		for _, field := range t.Fields {
+			item, given := object[field.Name]
+			if variable, isVariable := item.(Variable); isVariable {
+				_, given = variables[string(variable)]
+			}
+			if !given {
+				if field.Default != nil {
+					result[field.Name] = field.Default
+				} else if _, required := field.Type.(*NonNull); required {
+					return nil, fmt.Errorf("field %s of %s is required", field.Name, t.Name)
+				}
+				continue
+			}
+			v, err := coerceLiteral(field.Type, item, variables)
+			if err != nil {
+				return nil, fmt.Errorf("field %s %v", field.Name, err)
+			}
+			result[field.Name] = v
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("%s is not an input type", t)
+}
+
+func coerceArguments(defs []*Argument, given map[string]Value, variables map[string]interface{}) (map[string]interface{}, error) {
+	args := map[string]interface{}{}
+	for _, def := range defs {
+		value, ok := given[def.Name]
+		if variable, isVariable := value.(Variable); isVariable {
+			_, ok = variables[string(variable)]
+		}
+		if !ok {
+			if def.Default != nil {
+				args[def.Name] = def.Default
+			} else if _, required := def.Type.(*NonNull); required {
+				return nil, fmt.Errorf("Argument %s is required", def.Name)
+			}
+			continue
+		}
+		v, err := coerceLiteral(def.Type, value, variables)
+		if err != nil {
+			return nil, fmt.Errorf("Argument %s %v", def.Name, err)
+		}
+		args[def.Name] = v
+	}
+	return args, nil
+}
+
+type path []interface{}
+
+func (p path) with(key interface{}) path {
+	child := make(path, len(p), len(p)+1)
+	copy(child, p)
+	return append(child, key)
+}
+
+type executor struct {
+	ctx       context.Context
+	fragments map[string]*Fragment
+	variables map[string]interface{}
+	errors    []*Error
+}
+
+func (e *executor) fail(p path, err error) {
+	gqlErr := &Error{Message: err.Error()}
+	var resolverErr *Error
+	if errors.As(err, &resolverErr) {
+		gqlErr.Message, gqlErr.Extensions = resolverErr.Message, resolverErr.Extensions
+	}
+	gqlErr.Path = p
+	e.errors = append(e.errors, gqlErr)
+}
+
+type fieldGroup struct {
+	key   string
+	nodes []*FieldNode
+}
+
+// collect flattens fragments into the fields to resolve, merging fields
+// selected more than once under the same key.
+func (e *executor) collect(selections []Selection, groups []*fieldGroup) []*fieldGroup {
+	for _, selection := range selections {
+		switch s := selection.(type) {
+		case *FieldNode:
+			if !e.included(s.Directives) {
+				continue
+			}
+			merged := false
+			for _, g := range groups {
+				if g.key == s.Key() {
+					g.nodes = append(g.nodes, s)
+					merged = true
+				}
+			}
+			if !merged {
+				groups = append(groups, &fieldGroup{key: s.Key(), nodes: []*FieldNode{s}})
+			}
+		case *FragmentSpread:
+			if e.included(s.Directives) {
+				groups = e.collect(e.fragments[s.Name].Selections, groups)
+			}
+		case *InlineFragment:
+			if e.included(s.Directives) {
+				groups = e.collect(s.Selections, groups)
+			}
+		}
+	}
+	return groups
+}
+
+func (e *executor) included(directives []*Directive) bool {
+	for _, d := range directives {
+		value, _ := coerceLiteral(&NonNull{Boolean}, d.Arguments["if"], e.variables)
+		condition, _ := value.(bool)
+		if (d.Name == "include" && !condition) || (d.Name == "skip" && condition) {
+			return false
+		}
+	}
+	return true
+}
+
+// executeObjects resolves selections on every source at once, returning a
+// result object, or errNull, per source.
+func (e *executor) executeObjects(t *Object, sources []interface{}, selections []Selection, paths []path) []interface{} {
+	results := make([]interface{}, len(sources))
+	objects := make([]*orderedMap, len(sources))
+	for i := range sources {
+		objects[i] = &orderedMap{}
+		results[i] = objects[i]
+	}
+
+	for _, group := range e.collect(selections, nil) {
+		node := group.nodes[0]
+		fieldPaths := make([]path, len(sources))
+		for i := range sources {
+			fieldPaths[i] = paths[i].with(group.key)
+		}
+		if node.Name == "__typename" {
+			for i := range sources {
+				objects[i].set(group.key, t.Name)
+			}
+			continue
+		}
+
+		field := t.field(node.Name)
+		values := e.resolve(field, node, sources, fieldPaths)
+		var selections []Selection
+		for _, n := range group.nodes {
+			selections = append(selections, n.Selections...)
+		}
+		completed := e.complete(field.Type, values, selections, fieldPaths)
+		for i, value := range completed {
+			if value == errNull {
+				if _, required := field.Type.(*NonNull); required {
+					results[i] = errNull
+				}
+				value = nil
+			}
+			objects[i].set(group.key, value)
+		}
+	}
+	return results
+}
+
+// resolve computes a field for every source; a source whose resolver failed
+// gets errNull.
+func (e *executor) resolve(field *Field, node *FieldNode, sources []interface{}, paths []path) []interface{} {
+	values := make([]interface{}, len(sources))
+	failAll := func(err error) []interface{} {
+		for i := range values {
+			e.fail(paths[i], err)
+			values[i] = errNull
+		}
+		return values
+	}
+
+	args, err := coerceArguments(field.Args, node.Arguments, e.variables)
+	if err != nil {
+		return failAll(err)
+	}
+	switch {
+	case field.Batch != nil:
+		batch, err := field.Batch(BatchParams{Context: e.ctx, Sources: sources, Args: args})
+		if err == nil && len(batch) != len(sources) {
+			err = fmt.Errorf("resolver returned %d values for %d sources", len(batch), len(sources))
+		}
+		if err != nil {
+			return failAll(err)
+		}
+		return batch
+	case field.Resolve != nil:
+		for i, source := range sources {
+			value, err := field.Resolve(ResolveParams{Context: e.ctx, Source: source, Args: args})
+			if err != nil {
+				e.fail(paths[i], err)
+				value = errNull
+			}
+			values[i] = value
+		}
+	default:
+		for i, source := range sources {
+			values[i] = structField(source, field.Name)
+		}
+	}
+	return values
+}
+
+// structField reads the struct field or map entry called name, ignoring case.
+func structField(source interface{}, name string) interface{} {
+	v := reflect.ValueOf(source)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		field := v.FieldByNameFunc(func(n string) bool { return strings.EqualFold(n, name) })
+		if field.IsValid() && field.CanInterface() {
+			return field.Interface()
+		}
+	case reflect.Map:
+		if entry := v.MapIndex(reflect.ValueOf(name)); entry.IsValid() {
+			return entry.Interface()
+		}
+	}
+	return nil
+}
+
+// complete converts resolved values to their response form, resolving the
+// selections of objects for every value at once.
+func (e *executor) complete(t Type, values []interface{}, selections []Selection, paths []path) []interface{} {
+	results := make([]interface{}, len(values))
+	for i, value := range values {
+		results[i] = value
+		if value != errNull && isNil(value) {
+			results[i] = nil
+		}
+	}
+
+	switch t := t.(type) {
+	case *NonNull:
+		inner := e.complete(t.Of, results, selections, paths)
+		for i, value := range inner {
+			if value == nil {
+				e.fail(paths[i], fmt.Errorf("Cannot return null for non-nullable field"))
+				inner[i] = errNull
+			}
+		}
+		return inner
+
+	case *List:
+		var items []interface{}
+		var itemPaths []path
+		var owners []int
+		for i, value := range results {
+			if value == nil || value == errNull {
+				continue
+			}
+			list := reflect.ValueOf(value)
+			if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+				e.fail(paths[i], fmt.Errorf("expected a list"))
+				results[i] = errNull
+				continue
+			}
+			for j := 0; j < list.Len(); j++ {
+				items = append(items, list.Index(j).Interface())
+				itemPaths = append(itemPaths, paths[i].with(j))
+				owners = append(owners, i)
+			}
+			results[i] = []interface{}{}
+		}
+		_, required := t.Of.(*NonNull)
+		for k, item := range e.complete(t.Of, items, selections, itemPaths) {
+			i := owners[k]
+			if results[i] == errNull {
+				continue
+			}
+			if item == errNull {
+				if required {
+					results[i] = errNull
+					continue
+				}
+				item = nil
+			}
+			results[i] = append(results[i].([]interface{}), item)
+		}
+		return results
+
+	case *Object:
+		var sources []interface{}
+		var sourcePaths []path
+		var owners []int
+		for i, value := range results {
+			if value != nil && value != errNull {
+				sources = append(sources, value)
+				sourcePaths = append(sourcePaths, paths[i])
+				owners = append(owners, i)
+			}
+		}
+		if len(sources) > 0 {
+			for k, object := range e.executeObjects(t, sources, selections, sourcePaths) {
+				results[owners[k]] = object
+			}
+		}
+		return results
+
+	case *Scalar, *Enum:
+		for i, value := range results {
+			if value == nil || value == errNull {
+				continue
+			}
+			serialized, err := serialize(t, value)
+			if err != nil {
+				e.fail(paths[i], err)
+				serialized = errNull
+			}
+			results[i] = serialized
+		}
+		return results
+	}
+	return results
+}
+
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return value.IsNil()
+	}
+	return false
+}
+
+func serialize(t Type, value interface{}) (interface{}, error) {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	value = v.Interface()
+	switch t := t.(type) {
+	case *Scalar:
+		return t.Serialize(value)
+	case *Enum:
+		if v.Kind() == reflect.String {
+			for _, allowed := range t.Values {
+				if v.String() == allowed {
+					return allowed, nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("%v is not a %s value", value, t.Name)
+	}
+	return nil, fmt.Errorf("%s is not a leaf type", t)
+}
+
+func serializeInt(value interface{}) (interface{}, error) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Int() >= -1<<31 && v.Int() < 1<<31 {
+			return v.Int(), nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() < 1<<31 {
+			return int64(v.Uint()), nil
+		}
+	}
+	return nil, fmt.Errorf("%v is not a 32-bit integer", value)
+}
+
+func serializeFloat(value interface{}) (interface{}, error) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), nil
+	}
+	return nil, fmt.Errorf("%v is not a number", value)
+}
+
+func serializeString(value interface{}) (interface{}, error) {
+	if v := reflect.ValueOf(value); v.Kind() == reflect.String {
+		return v.String(), nil
+	}
+	if s, ok := value.(fmt.Stringer); ok {
+		return s.String(), nil
+	}
+	return nil, fmt.Errorf("%v is not a string", value)
+}
+
+func serializeBool(value interface{}) (interface{}, error) {
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Bool {
+		return v.Bool(), nil
+	}
+	return nil, fmt.Errorf("%v is not a boolean", value)
+}
+
+func serializeID(value interface{}) (interface{}, error) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	}
+	return nil, fmt.Errorf("%v is not an ID", value)
+}
+
+// orderedMap is a response object, which keeps fields in selection order.
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (m *orderedMap) set(key string, value interface{}) {
+	if m.values == nil {
+		m.values = map[string]interface{}{}
+	}
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Get returns the value of key, for tests and embedding callers.
+func (m *orderedMap) Get(key string) interface{} {
+	return m.values[key]
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		value, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
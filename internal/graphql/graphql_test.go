@@ -0,0 +1,286 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type author struct {
+	ID   int
+	Name string
+}
+
+type book struct {
+	ID       int
+	Title    string
+	AuthorID int
+}
+
+// library is a schema where books load their author in batches and count
+// how often the batch resolver runs.
+func library(batches *int) *Schema {
+	authors := map[int]*author{1: {1, "Ursula"}, 2: {2, "Italo"}}
+	books := []book{{1, "Earthsea", 1}, {2, "Invisible Cities", 2}, {3, "The Dispossessed", 1}, {4, "Orphan", 9}}
+
+	genre := &Enum{Name: "Genre", Values: []string{"FANTASY", "FICTION"}}
+	authorType := &Object{Name: "Author", Fields: []*Field{
+		{Name: "id", Type: &NonNull{ID}},
+		{Name: "name", Type: &NonNull{String}},
+	}}
+	bookType := &Object{Name: "Book", Fields: []*Field{
+		{Name: "id", Type: &NonNull{ID}},
+		{Name: "title", Type: &NonNull{String}},
+		{Name: "author", Type: authorType, Batch: func(p BatchParams) ([]interface{}, error) {
+			*batches++
+			values := make([]interface{}, len(p.Sources))
+			for i, source := range p.Sources {
+				values[i] = authors[source.(book).AuthorID]
+			}
+			return values, nil
+		}},
+		{Name: "requiredAuthor", Type: &NonNull{authorType}, Resolve: func(p ResolveParams) (interface{}, error) {
+			return authors[p.Source.(book).AuthorID], nil
+		}},
+	}}
+	filter := &InputObject{Name: "BookFilter", Fields: []*Argument{
+		{Name: "authorId", Type: ID},
+		{Name: "genre", Type: genre},
+	}}
+	query := &Object{Name: "Query", Fields: []*Field{
+		{Name: "books", Type: &NonNull{&List{&NonNull{bookType}}}, Args: []*Argument{
+			{Name: "filter", Type: filter},
+			{Name: "first", Type: Int, Default: 10},
+		}, Resolve: func(p ResolveParams) (interface{}, error) {
+			var result []book
+			f, _ := p.Args["filter"].(map[string]interface{})
+			for _, b := range books {
+				if id, ok := f["authorId"]; ok && id != strconv.Itoa(b.AuthorID) {
+					continue
+				}
+				if len(result) < p.Args["first"].(int) {
+					result = append(result, b)
+				}
+			}
+			return result, nil
+		}},
+		{Name: "book", Type: bookType, Args: []*Argument{{Name: "id", Type: &NonNull{ID}}}, Resolve: func(p ResolveParams) (interface{}, error) {
+			for _, b := range books {
+				if strconv.Itoa(b.ID) == p.Args["id"] {
+					return b, nil
+				}
+			}
+			return nil, &Error{Message: "Book not found", Extensions: map[string]interface{}{"status": 404}}
+		}},
+	}}
+	return &Schema{Query: query}
+}
+
+func run(t *testing.T, s *Schema, req Request) string {
+	t.Helper()
+	body, err := json.Marshal(s.Execute(context.Background(), req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestParse(t *testing.T) {
+	doc, err := Parse(`
+		query Books($first: Int = 2, $ids: [ID!]!) {
+			list: books(first: $first, filter: {genre: FANTASY}) { ...fields @skip(if: false) }
+		}
+		fragment fields on Book { id title }
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := doc.Operations[0]
+	if op.Name != "Books" || len(op.Variables) != 2 || op.Variables[0].Default != int64(2) {
+		t.Fatalf("Unexpected operation %+v", op)
+	}
+	if ref := op.Variables[1].Type; !ref.NonNull || ref.Elem == nil || !ref.Elem.NonNull || ref.Elem.Name != "ID" {
+		t.Errorf("Expected [ID!]!, got %+v", ref)
+	}
+	field := op.Selections[0].(*FieldNode)
+	if field.Key() != "list" || field.Arguments["first"] != Variable("first") {
+		t.Errorf("Unexpected field %+v", field)
+	}
+	if filter := field.Arguments["filter"].(ObjectValue); filter["genre"] != EnumValue("FANTASY") {
+		t.Errorf("Expected an enum value in the filter, got %v", filter)
+	}
+	if len(doc.Fragments["fields"].Selections) != 2 {
+		t.Errorf("Expected the fragment to select two fields")
+	}
+
+	_, err = Parse("{ books { id }")
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) || syntaxErr.Line != 1 {
+		t.Errorf("Expected a syntax error on line 1, got %v", err)
+	}
+}
+
+func TestExecuteBatchesFields(t *testing.T) {
+	batches := 0
+	got := run(t, library(&batches), Request{Query: `{
+		books { title author { name } ... on Book { id } }
+		again: books(first: 1) { __typename }
+	}`})
+	want := `{"data":{"books":[` +
+		`{"title":"Earthsea","author":{"name":"Ursula"},"id":"1"},` +
+		`{"title":"Invisible Cities","author":{"name":"Italo"},"id":"2"},` +
+		`{"title":"The Dispossessed","author":{"name":"Ursula"},"id":"3"},` +
+		`{"title":"Orphan","author":null,"id":"4"}],` +
+		`"again":[{"__typename":"Book"}]}}`
+	if got != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, got)
+	}
+	if batches != 1 {
+		t.Errorf("Expected the authors of every book to load in one batch, got %d", batches)
+	}
+}
+
+func TestExecuteVariables(t *testing.T) {
+	batches := 0
+	s := library(&batches)
+	got := run(t, s, Request{
+		Query:     `query($first: Int, $withAuthor: Boolean!) { books(first: $first) { id author @include(if: $withAuthor) { id } } }`,
+		Variables: map[string]interface{}{"first": float64(1), "withAuthor": false},
+	})
+	if got != `{"data":{"books":[{"id":"1"}]}}` || batches != 0 {
+		t.Errorf("Unexpected result %s after %d batches", got, batches)
+	}
+
+	got = run(t, s, Request{
+		Query:     `query($first: Int!) { books(first: $first) { id } }`,
+		Variables: map[string]interface{}{"first": "many"},
+	})
+	if !strings.Contains(got, `"errors":[{"message":"Variable $first: expected a 32-bit integer for Int"}]`) || strings.Contains(got, `"data"`) {
+		t.Errorf("Expected a variable error without data, got %s", got)
+	}
+}
+
+func TestExecuteValidation(t *testing.T) {
+	batches := 0
+	s := library(&batches)
+	for query, message := range map[string]string{
+		`{ books { isbn } }`:                                "Cannot query field isbn on type Book",
+		`{ books }`:                                         "Field books of type [Book!]! must have a selection of subfields",
+		`{ book { id } }`:                                   "Argument id of Query.book is required",
+		`{ books(sort: TITLE) { id } }`:                     "Unknown argument sort on Query.books",
+		`{ books(filter: {genre: POETRY}) { id } }`:         "Argument filter field genre must be one of Genre: FANTASY, FICTION",
+		`{ books { ...missing } }`:                          "Unknown fragment missing",
+		`query A { books { id } } query B { books { id } }`: "operationName is required for a document with several operations",
+		`mutation { books { id } }`:                         "Mutations are not supported",
+	} {
+		got := run(t, s, Request{Query: query})
+		if !strings.Contains(got, message) {
+			t.Errorf("Expected %q for %s, got %s", message, query, got)
+		}
+	}
+}
+
+func TestExecuteLimits(t *testing.T) {
+	batches := 0
+	s := library(&batches)
+	s.MaxDepth = 2
+	s.MaxFields = 5
+
+	if got := run(t, s, Request{Query: `{ books { id title } }`}); strings.Contains(got, "errors") {
+		t.Errorf("Expected a query within the limits to run, got %s", got)
+	}
+	if got := run(t, s, Request{Query: `{ books { author { name } } }`}); !strings.Contains(got, "Query is nested more than 2 fields deep") {
+		t.Errorf("Expected the depth limit, got %s", got)
+	}
+
+	// Fragments count once per spread, so doubling them does not get past
+	// the limit, and validation stops at the first field over it
+	query := `{ books { ...f0 } } fragment f0 on Book { id title }`
+	for i := 1; i <= 30; i++ {
+		query += " fragment f" + strconv.Itoa(i) + " on Book { ...f" + strconv.Itoa(i-1) + " ...f" + strconv.Itoa(i-1) + " }"
+	}
+	query = strings.Replace(query, "...f0 } }", "...f30 } }", 1)
+	got := run(t, s, Request{Query: query})
+	if got != `{"errors":[{"message":"Query selects more than 5 fields"}]}` {
+		t.Errorf("Expected the field limit without data, got %s", got)
+	}
+}
+
+func TestExecuteCost(t *testing.T) {
+	batches := 0
+	s := library(&batches)
+	s.Query.field("books").SizeArg = "first"
+	s.MaxCost = 25
+
+	// books costs 1, and each field below it once per book: 1 + 10*2
+	if got := run(t, s, Request{Query: `{ books { id title } }`}); strings.Contains(got, "errors") {
+		t.Errorf("Expected a query within the cost to run, got %s", got)
+	}
+	for _, req := range []Request{
+		{Query: `{ books(first: 20) { id title } }`},
+		{Query: `query($n: Int) { books(first: $n) { id } }`, Variables: map[string]interface{}{"n": json.Number("30")}},
+		{Query: `{ books { ...f } } fragment f on Book { id title author { name } }`},
+	} {
+		got := run(t, s, req)
+		if got != `{"errors":[{"message":"Query may resolve more than 25 fields; ask for smaller pages"}]}` {
+			t.Errorf("Expected the cost limit for %s, got %s", req.Query, got)
+		}
+	}
+	if batches != 0 {
+		t.Errorf("Expected rejected queries not to run, got %d batches", batches)
+	}
+}
+
+func TestExecuteNulls(t *testing.T) {
+	batches := 0
+	s := library(&batches)
+
+	got := run(t, s, Request{Query: `{ book(id: 7) { id } }`})
+	want := `{"data":{"book":null},"errors":[{"message":"Book not found","path":["book"],"extensions":{"status":404}}]}`
+	if got != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, got)
+	}
+
+	// A null in a non-null field nulls the nearest nullable parent: the book
+	// is non-null in the list, so the whole list, and then data, is null.
+	got = run(t, s, Request{Query: `{ books { requiredAuthor { name } } }`})
+	want = `{"data":null,"errors":[{"message":"Cannot return null for non-nullable field","path":["books",3,"requiredAuthor"]}]}`
+	if got != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestSDL(t *testing.T) {
+	batches := 0
+	sdl := library(&batches).SDL()
+	for _, line := range []string{
+		"schema {\n  query: Query\n}",
+		"enum Genre {\n  FANTASY\n  FICTION\n}",
+		"  books(filter: BookFilter, first: Int = 10): [Book!]!",
+		"input BookFilter {\n  authorId: ID\n  genre: Genre\n}",
+	} {
+		if !strings.Contains(sdl, line) {
+			t.Errorf("Expected the SDL to contain %q, got\n%s", line, sdl)
+		}
+	}
+}
+
+func TestRequestMutation(t *testing.T) {
+	for query, expected := range map[string]bool{
+		`{ books { id } }`:            false,
+		`query { books { id } }`:      false,
+		`mutation { addBook { id } }`: true,
+		`{ books { id`:                false,
+		`query A { books { id } } mutation B { add { id } }`: false,
+	} {
+		if got := (Request{Query: query}).Mutation(); got != expected {
+			t.Errorf("Expected Mutation() = %v for %s", expected, query)
+		}
+	}
+	if !(Request{Query: `query A { books { id } } mutation B { add { id } }`, OperationName: "B"}).Mutation() {
+		t.Error("Expected the named mutation to be found")
+	}
+}
@@ -0,0 +1,540 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Document is a parsed request: its operations and fragments.
+type Document struct {
+	Operations []*Operation
+	Fragments  map[string]*Fragment
+}
+
+type Operation struct {
+	Kind       string // query or mutation
+	Name       string
+	Variables  []*VariableDefinition
+	Selections []Selection
+}
+
+type VariableDefinition struct {
+	Name    string
+	Type    TypeRef
+	Default Value
+}
+
+// TypeRef is a type as written in a variable definition, such as [ID!]!.
+type TypeRef struct {
+	Name    string
+	Elem    *TypeRef
+	NonNull bool
+}
+
+type Fragment struct {
+	Name       string
+	On         string
+	Selections []Selection
+}
+
+// Selection is a *FieldNode, *FragmentSpread or *InlineFragment.
+type Selection interface{}
+
+type FieldNode struct {
+	Alias      string
+	Name       string
+	Arguments  map[string]Value
+	Directives []*Directive
+	Selections []Selection
+}
+
+// Key is the name of the field in the response.
+func (f *FieldNode) Key() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+type FragmentSpread struct {
+	Name       string
+	Directives []*Directive
+}
+
+type InlineFragment struct {
+	On         string
+	Directives []*Directive
+	Selections []Selection
+}
+
+type Directive struct {
+	Name      string
+	Arguments map[string]Value
+}
+
+// Value is a literal in a document. Variable, Enum, list and object values
+// have their own types; the rest are Go values: int64, float64, string,
+// bool or nil.
+type Value interface{}
+
+type Variable string
+
+type EnumValue string
+
+type ListValue []Value
+
+type ObjectValue map[string]Value
+
+// SyntaxError reports where a document could not be parsed.
+type SyntaxError struct {
+	Line, Column int
+	Message      string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("Syntax error at %d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunct
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+type parser struct {
+	src string
+	pos int
+	tok token
+}
+
+// Parse parses a request document.
+func Parse(src string) (doc *Document, err error) {
+	p := &parser{src: src}
+	defer func() {
+		if r := recover(); r != nil {
+			syntaxErr, ok := r.(*SyntaxError)
+			if !ok {
+				panic(r)
+			}
+			doc, err = nil, syntaxErr
+		}
+	}()
+	p.next()
+	doc = &Document{Fragments: map[string]*Fragment{}}
+	for p.tok.kind != tokenEOF {
+		switch {
+		case p.peek("{"):
+			doc.Operations = append(doc.Operations, &Operation{Kind: "query", Selections: p.selectionSet()})
+		case p.peekName("query"), p.peekName("mutation"):
+			doc.Operations = append(doc.Operations, p.operation())
+		case p.peekName("fragment"):
+			fragment := p.fragment()
+			if _, dup := doc.Fragments[fragment.Name]; dup {
+				p.fail("fragment " + fragment.Name + " is defined twice")
+			}
+			doc.Fragments[fragment.Name] = fragment
+		default:
+			p.fail("expected an operation or fragment, found " + p.describe())
+		}
+	}
+	if len(doc.Operations) == 0 {
+		p.fail("the document has no operation")
+	}
+	return doc, nil
+}
+
+func (p *parser) fail(message string) {
+	line, column := 1, 1
+	for _, r := range p.src[:p.tok.pos] {
+		if r == '\n' {
+			line, column = line+1, 1
+		} else {
+			column++
+		}
+	}
+	panic(&SyntaxError{Line: line, Column: column, Message: message})
+}
+
+func (p *parser) describe() string {
+	if p.tok.kind == tokenEOF {
+		return "end of document"
+	}
+	return strconv.Quote(p.tok.value)
+}
+
+// next reads the following token, skipping whitespace, commas and comments.
+func (p *parser) next() {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			p.pos++
+		} else if c == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		} else {
+			break
+		}
+	}
+	start := p.pos
+	p.tok = token{pos: start}
+	if p.pos >= len(p.src) {
+		p.tok.kind = tokenEOF
+		return
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.pos += 3
+		p.tok.kind, p.tok.value = tokenPunct, "..."
+	case strings.IndexByte("!$()&:=@[]{}|", c) >= 0:
+		p.pos++
+		p.tok.kind, p.tok.value = tokenPunct, string(c)
+	case c == '_' || isLetter(c):
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || isLetter(p.src[p.pos]) || isDigit(p.src[p.pos])) {
+			p.pos++
+		}
+		p.tok.kind, p.tok.value = tokenName, p.src[start:p.pos]
+	case c == '-' || isDigit(c):
+		p.number()
+	case c == '"':
+		p.string()
+	default:
+		p.fail("unexpected character " + strconv.QuoteRune(rune(c)))
+	}
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (p *parser) number() {
+	start := p.pos
+	p.tok.kind = tokenInt
+	if p.src[p.pos] == '-' {
+		p.pos++
+	}
+	digits := func() {
+		n := p.pos
+		for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+			p.pos++
+		}
+		if p.pos == n {
+			p.fail("invalid number")
+		}
+	}
+	digits()
+	if p.pos < len(p.src) && p.src[p.pos] == '.' {
+		p.pos++
+		p.tok.kind = tokenFloat
+		digits()
+	}
+	if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+		p.pos++
+		p.tok.kind = tokenFloat
+		if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+			p.pos++
+		}
+		digits()
+	}
+	p.tok.value = p.src[start:p.pos]
+}
+
+func (p *parser) string() {
+	if strings.HasPrefix(p.src[p.pos:], `"""`) {
+		end := strings.Index(p.src[p.pos+3:], `"""`)
+		if end < 0 {
+			p.fail("unterminated block string")
+		}
+		p.tok.kind, p.tok.value = tokenString, p.src[p.pos+3:p.pos+3+end]
+		p.pos += end + 6
+		return
+	}
+	var b strings.Builder
+	p.pos++
+	for {
+		if p.pos >= len(p.src) || p.src[p.pos] == '\n' {
+			p.fail("unterminated string")
+		}
+		c := p.src[p.pos]
+		if c == '"' {
+			p.pos++
+			break
+		}
+		if c != '\\' {
+			r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+			b.WriteRune(r)
+			p.pos += size
+			continue
+		}
+		if p.pos+1 >= len(p.src) {
+			p.fail("unterminated string")
+		}
+		escape := p.src[p.pos+1]
+		p.pos += 2
+		switch escape {
+		case '"', '\\', '/':
+			b.WriteByte(escape)
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			if p.pos+4 > len(p.src) {
+				p.fail("invalid unicode escape")
+			}
+			code, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 32)
+			if err != nil {
+				p.fail("invalid unicode escape")
+			}
+			b.WriteRune(rune(code))
+			p.pos += 4
+		default:
+			p.fail("invalid escape \\" + string(escape))
+		}
+	}
+	p.tok.kind, p.tok.value = tokenString, b.String()
+}
+
+func (p *parser) peek(punct string) bool {
+	return p.tok.kind == tokenPunct && p.tok.value == punct
+}
+
+func (p *parser) peekName(name string) bool {
+	return p.tok.kind == tokenName && p.tok.value == name
+}
+
+func (p *parser) expect(punct string) {
+	if !p.peek(punct) {
+		p.fail("expected " + strconv.Quote(punct) + ", found " + p.describe())
+	}
+	p.next()
+}
+
+func (p *parser) name() string {
+	if p.tok.kind != tokenName {
+		p.fail("expected a name, found " + p.describe())
+	}
+	name := p.tok.value
+	p.next()
+	return name
+}
+
+func (p *parser) operation() *Operation {
+	op := &Operation{Kind: p.name()}
+	if p.tok.kind == tokenName {
+		op.Name = p.name()
+	}
+	if p.peek("(") {
+		p.next()
+		for !p.peek(")") {
+			p.expect("$")
+			def := &VariableDefinition{Name: p.name()}
+			p.expect(":")
+			def.Type = p.typeRef()
+			if p.peek("=") {
+				p.next()
+				def.Default = p.value(true)
+			}
+			op.Variables = append(op.Variables, def)
+		}
+		p.next()
+	}
+	p.directives()
+	op.Selections = p.selectionSet()
+	return op
+}
+
+func (p *parser) typeRef() TypeRef {
+	var t TypeRef
+	if p.peek("[") {
+		p.next()
+		elem := p.typeRef()
+		t.Elem = &elem
+		p.expect("]")
+	} else {
+		t.Name = p.name()
+	}
+	if p.peek("!") {
+		p.next()
+		t.NonNull = true
+	}
+	return t
+}
+
+func (p *parser) fragment() *Fragment {
+	p.next()
+	fragment := &Fragment{Name: p.name()}
+	if fragment.Name == "on" {
+		p.fail("a fragment cannot be named on")
+	}
+	if !p.peekName("on") {
+		p.fail("expected on, found " + p.describe())
+	}
+	p.next()
+	fragment.On = p.name()
+	p.directives()
+	fragment.Selections = p.selectionSet()
+	return fragment
+}
+
+func (p *parser) selectionSet() []Selection {
+	p.expect("{")
+	var selections []Selection
+	for !p.peek("}") {
+		if p.tok.kind == tokenEOF {
+			p.fail("unterminated selection set")
+		}
+		selections = append(selections, p.selection())
+	}
+	p.next()
+	if len(selections) == 0 {
+		p.fail("a selection set cannot be empty")
+	}
+	return selections
+}
+
+func (p *parser) selection() Selection {
+	if p.peek("...") {
+		p.next()
+		if p.tok.kind == tokenName && !p.peekName("on") {
+			return &FragmentSpread{Name: p.name(), Directives: p.directives()}
+		}
+		inline := &InlineFragment{}
+		if p.peekName("on") {
+			p.next()
+			inline.On = p.name()
+		}
+		inline.Directives = p.directives()
+		inline.Selections = p.selectionSet()
+		return inline
+	}
+
+	field := &FieldNode{Name: p.name()}
+	if p.peek(":") {
+		p.next()
+		field.Alias, field.Name = field.Name, p.name()
+	}
+	field.Arguments = p.arguments()
+	field.Directives = p.directives()
+	if p.peek("{") {
+		field.Selections = p.selectionSet()
+	}
+	return field
+}
+
+func (p *parser) arguments() map[string]Value {
+	if !p.peek("(") {
+		return nil
+	}
+	p.next()
+	args := map[string]Value{}
+	for !p.peek(")") {
+		name := p.name()
+		if _, dup := args[name]; dup {
+			p.fail("argument " + name + " is given twice")
+		}
+		p.expect(":")
+		args[name] = p.value(false)
+	}
+	p.next()
+	return args
+}
+
+func (p *parser) directives() []*Directive {
+	var directives []*Directive
+	for p.peek("@") {
+		p.next()
+		directives = append(directives, &Directive{Name: p.name(), Arguments: p.arguments()})
+	}
+	return directives
+}
+
+// value parses a literal; constant values, such as variable defaults, cannot
+// refer to variables.
+func (p *parser) value(constant bool) Value {
+	tok := p.tok
+	switch tok.kind {
+	case tokenPunct:
+		switch tok.value {
+		case "$":
+			if constant {
+				p.fail("a default value cannot use a variable")
+			}
+			p.next()
+			return Variable(p.name())
+		case "[":
+			p.next()
+			list := ListValue{}
+			for !p.peek("]") {
+				if p.tok.kind == tokenEOF {
+					p.fail("unterminated list")
+				}
+				list = append(list, p.value(constant))
+			}
+			p.next()
+			return list
+		case "{":
+			p.next()
+			object := ObjectValue{}
+			for !p.peek("}") {
+				name := p.name()
+				p.expect(":")
+				object[name] = p.value(constant)
+			}
+			p.next()
+			return object
+		}
+	case tokenInt:
+		p.next()
+		n, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			p.fail("integer out of range")
+		}
+		return n
+	case tokenFloat:
+		p.next()
+		f, _ := strconv.ParseFloat(tok.value, 64)
+		return f
+	case tokenString:
+		p.next()
+		return tok.value
+	case tokenName:
+		p.next()
+		switch tok.value {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		}
+		return EnumValue(tok.value)
+	}
+	p.fail("expected a value, found " + p.describe())
+	return nil
+}
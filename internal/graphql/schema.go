@@ -0,0 +1,303 @@
+// Package graphql is a small GraphQL executor: it parses request documents,
+// checks them against a schema built from Go values and resolves them. It
+// supports queries, mutations, variables, fragments and the @include and
+// @skip directives, and resolves each field once per level for every parent
+// object, so a batch resolver can load a relation with one query instead of
+// one per parent. Introspection is limited to __typename; the schema is
+// published as SDL instead.
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Type is a *Scalar, *Enum, *Object, *InputObject, *List or *NonNull.
+type Type interface {
+	String() string
+}
+
+// Scalar is a leaf type. Serialize converts a resolved Go value to its JSON
+// form; Parse converts an input value (int64, float64, string or bool) to
+// the Go value resolvers receive.
+type Scalar struct {
+	Name        string
+	Description string
+	Serialize   func(v interface{}) (interface{}, error)
+	Parse       func(v interface{}) (interface{}, error)
+}
+
+type Enum struct {
+	Name        string
+	Description string
+	Values      []string
+}
+
+type Object struct {
+	Name        string
+	Description string
+	Fields      []*Field
+}
+
+type InputObject struct {
+	Name        string
+	Description string
+	Fields      []*Argument
+}
+
+type List struct {
+	Of Type
+}
+
+type NonNull struct {
+	Of Type
+}
+
+func (t *Scalar) String() string      { return t.Name }
+func (t *Enum) String() string        { return t.Name }
+func (t *Object) String() string      { return t.Name }
+func (t *InputObject) String() string { return t.Name }
+func (t *List) String() string        { return "[" + t.Of.String() + "]" }
+func (t *NonNull) String() string     { return t.Of.String() + "!" }
+
+// Field is a field of an object. Resolve computes it for one parent object;
+// Batch, when set, computes it for every parent at a level at once and
+// returns one value per source. Without either, the parent's struct field of
+// the same name, ignoring case, is returned.
+type Field struct {
+	Name        string
+	Description string
+	Type        Type
+	Args        []*Argument
+	Resolve     func(p ResolveParams) (interface{}, error)
+	Batch       func(p BatchParams) ([]interface{}, error)
+	// SizeArg names the Int argument bounding how many objects the field
+	// returns, such as a page size. Its value multiplies the cost of the
+	// field's selections.
+	SizeArg string
+}
+
+type Argument struct {
+	Name        string
+	Description string
+	Type        Type
+	// Default is used when the argument is left out; nil means none.
+	Default interface{}
+}
+
+type ResolveParams struct {
+	Context context.Context
+	Source  interface{}
+	Args    map[string]interface{}
+}
+
+type BatchParams struct {
+	Context context.Context
+	Sources []interface{}
+	Args    map[string]interface{}
+}
+
+// Schema is the entry points of the API. MaxDepth and MaxFields, when set,
+// reject queries nested deeper than MaxDepth fields or selecting more than
+// MaxFields fields, counting each fragment spread in full, before anything
+// runs. MaxCost, when set, rejects queries that may resolve more than MaxCost
+// fields: each field costs one for every object it can be resolved on, which
+// is the product of the sizes of the fields with a SizeArg above it.
+type Schema struct {
+	Query     *Object
+	Mutation  *Object
+	MaxDepth  int
+	MaxFields int
+	MaxCost   int
+}
+
+func (o *Object) field(name string) *Field {
+	for _, f := range o.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// Built-in scalars.
+var (
+	Int = &Scalar{Name: "Int", Serialize: serializeInt, Parse: func(v interface{}) (interface{}, error) {
+		if n, ok := v.(int64); ok && n >= -1<<31 && n < 1<<31 {
+			return int(n), nil
+		}
+		return nil, fmt.Errorf("expected a 32-bit integer")
+	}}
+	Float = &Scalar{Name: "Float", Serialize: serializeFloat, Parse: func(v interface{}) (interface{}, error) {
+		switch v := v.(type) {
+		case int64:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		}
+		return nil, fmt.Errorf("expected a number")
+	}}
+	String = &Scalar{Name: "String", Serialize: serializeString, Parse: func(v interface{}) (interface{}, error) {
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("expected a string")
+	}}
+	Boolean = &Scalar{Name: "Boolean", Serialize: serializeBool, Parse: func(v interface{}) (interface{}, error) {
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("expected a boolean")
+	}}
+	// ID is serialized as a string and accepts strings or integers.
+	ID = &Scalar{Name: "ID", Serialize: serializeID, Parse: func(v interface{}) (interface{}, error) {
+		switch v := v.(type) {
+		case int64:
+			return fmt.Sprint(v), nil
+		case string:
+			return v, nil
+		}
+		return nil, fmt.Errorf("expected an ID")
+	}}
+)
+
+func (s *Schema) types() []Type {
+	seen := map[string]Type{}
+	var visit func(t Type)
+	visit = func(t Type) {
+		switch t := t.(type) {
+		case *List:
+			visit(t.Of)
+			return
+		case *NonNull:
+			visit(t.Of)
+			return
+		}
+		if _, ok := seen[t.String()]; ok {
+			return
+		}
+		seen[t.String()] = t
+		switch t := t.(type) {
+		case *Object:
+			for _, f := range t.Fields {
+				visit(f.Type)
+				for _, arg := range f.Args {
+					visit(arg.Type)
+				}
+			}
+		case *InputObject:
+			for _, f := range t.Fields {
+				visit(f.Type)
+			}
+		}
+	}
+	visit(s.Query)
+	if s.Mutation != nil {
+		visit(s.Mutation)
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	types := make([]Type, len(names))
+	for i, name := range names {
+		types[i] = seen[name]
+	}
+	return types
+}
+
+// lookup finds a named type used by the schema.
+func (s *Schema) lookup(name string) Type {
+	for _, t := range s.types() {
+		if t.String() == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// SDL prints the schema in the GraphQL schema definition language.
+func (s *Schema) SDL() string {
+	var b strings.Builder
+	b.WriteString("schema {\n  query: " + s.Query.Name + "\n")
+	if s.Mutation != nil {
+		b.WriteString("  mutation: " + s.Mutation.Name + "\n")
+	}
+	b.WriteString("}\n")
+
+	builtin := map[Type]bool{Int: true, Float: true, String: true, Boolean: true, ID: true}
+	for _, t := range s.types() {
+		if builtin[t] {
+			continue
+		}
+		b.WriteString("\n")
+		switch t := t.(type) {
+		case *Scalar:
+			description(&b, "", t.Description)
+			b.WriteString("scalar " + t.Name + "\n")
+		case *Enum:
+			description(&b, "", t.Description)
+			b.WriteString("enum " + t.Name + " {\n")
+			for _, v := range t.Values {
+				b.WriteString("  " + v + "\n")
+			}
+			b.WriteString("}\n")
+		case *Object:
+			description(&b, "", t.Description)
+			b.WriteString("type " + t.Name + " {\n")
+			for _, f := range t.Fields {
+				description(&b, "  ", f.Description)
+				b.WriteString("  " + f.Name + arguments(f.Args) + ": " + f.Type.String() + "\n")
+			}
+			b.WriteString("}\n")
+		case *InputObject:
+			description(&b, "", t.Description)
+			b.WriteString("input " + t.Name + " {\n")
+			for _, f := range t.Fields {
+				description(&b, "  ", f.Description)
+				b.WriteString("  " + argument(f) + "\n")
+			}
+			b.WriteString("}\n")
+		}
+	}
+	return b.String()
+}
+
+func description(b *strings.Builder, indent, text string) {
+	if text != "" {
+		b.WriteString(indent + `"""` + text + `"""` + "\n")
+	}
+}
+
+func arguments(args []*Argument) string {
+	if len(args) == 0 {
+		return ""
+	}
+	list := make([]string, len(args))
+	for i, arg := range args {
+		list[i] = argument(arg)
+	}
+	return "(" + strings.Join(list, ", ") + ")"
+}
+
+func argument(arg *Argument) string {
+	s := arg.Name + ": " + arg.Type.String()
+	if arg.Default != nil {
+		s += " = " + literal(arg.Default)
+	}
+	return s
+}
+
+func literal(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return fmt.Sprintf("%q", v)
+	case EnumValue:
+		return string(v)
+	}
+	return fmt.Sprint(v)
+}
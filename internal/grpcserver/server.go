@@ -0,0 +1,159 @@
+// Package grpcserver serves the gRPC API next to the REST one: the client,
+// bank and credit services from backend.proto, gRPC health checking and
+// server reflection. Calls get a request id and are logged like HTTP
+// requests.
+package grpcserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"backend/internal/handlers"
+	"backend/internal/logger"
+	"backend/internal/middleware"
+	"backend/pkg/pb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// Server is a gRPC server with its health service, which reports every
+// service as serving until Health.Shutdown.
+type Server struct {
+	*grpc.Server
+	Health *health.Server
+}
+
+// New builds the server with every service registered.
+func New() *Server {
+	s := &Server{
+		Server: grpc.NewServer(
+			grpc.ChainUnaryInterceptor(unaryInterceptor),
+			grpc.ChainStreamInterceptor(streamInterceptor),
+		),
+		Health: health.NewServer(),
+	}
+	pb.RegisterClientServiceServer(s.Server, handlers.ClientService{})
+	pb.RegisterBankServiceServer(s.Server, handlers.BankService{})
+	pb.RegisterCreditServiceServer(s.Server, handlers.CreditService{})
+	healthpb.RegisterHealthServer(s.Server, s.Health)
+	reflection.Register(s.Server)
+
+	for _, service := range []string{
+		pb.ClientService_ServiceDesc.ServiceName,
+		pb.BankService_ServiceDesc.ServiceName,
+		pb.CreditService_ServiceDesc.ServiceName,
+	} {
+		s.Health.SetServingStatus(service, healthpb.HealthCheckResponse_SERVING)
+	}
+	return s
+}
+
+// withRequestID assigns the call a request id, keeping one sent as
+// x-request-id metadata, and returns it in the response headers.
+func withRequestID(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var id string
+	if ids := md.Get(strings.ToLower(middleware.RequestIDHeader)); len(ids) > 0 {
+		id = ids[0]
+	}
+	ctx, id = middleware.WithRequestID(ctx, id)
+	grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(middleware.RequestIDHeader), id))
+	return ctx
+}
+
+// recoverCall turns a panic in a handler into an Internal error.
+func recoverCall(method string, err *error) {
+	if p := recover(); p != nil {
+		if logger.APILogger != nil {
+			logger.APILogger.LogError("gRPC", method, fmt.Sprintf("panic: %v", p))
+		}
+		*err = status.Error(codes.Internal, "Internal error")
+	}
+}
+
+func logCall(ctx context.Context, method string, start time.Time, err error) {
+	if logger.APILogger == nil {
+		return
+	}
+	entry := logger.LogEntry{
+		Timestamp:    start,
+		Method:       "gRPC",
+		Path:         method,
+		StatusCode:   httpStatus(status.Code(err)),
+		ResponseTime: time.Since(start).Milliseconds(),
+		RequestID:    middleware.RequestID(ctx),
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		entry.RemoteAddr = p.Addr.String()
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if agent := md.Get("user-agent"); len(agent) > 0 {
+			entry.UserAgent = agent[0]
+		}
+	}
+	if err != nil {
+		entry.Error = status.Convert(err).Message()
+	}
+	logger.APILogger.LogRequest(entry)
+}
+
+// httpStatus is the HTTP status logged for a gRPC code.
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists:
+		return http.StatusConflict
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.Canceled:
+		return 499
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	}
+	return http.StatusInternalServerError
+}
+
+func unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	start := time.Now()
+	ctx = withRequestID(ctx)
+	defer func() { logCall(ctx, info.FullMethod, start, err) }()
+	defer recoverCall(info.FullMethod, &err)
+	return handler(ctx, req)
+}
+
+// contextStream replaces the context of a server stream.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s contextStream) Context() context.Context {
+	return s.ctx
+}
+
+func streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	start := time.Now()
+	ctx := withRequestID(stream.Context())
+	defer func() { logCall(ctx, info.FullMethod, start, err) }()
+	defer recoverCall(info.FullMethod, &err)
+	return handler(srv, contextStream{stream, ctx})
+}
@@ -0,0 +1,86 @@
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"backend/pkg/pb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// dial serves a new server over an in-memory listener.
+func dial(t *testing.T) (*Server, *grpc.ClientConn) {
+	listener := bufconn.Listen(1 << 20)
+	server := New()
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return server, conn
+}
+
+func TestHealth(t *testing.T) {
+	server, conn := dial(t)
+	health := healthpb.NewHealthClient(conn)
+
+	for _, service := range []string{"", "backend.v1.CreditService"} {
+		resp, err := health.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil || resp.Status != healthpb.HealthCheckResponse_SERVING {
+			t.Errorf("Expected %q to be serving, got %v, %v", service, resp, err)
+		}
+	}
+
+	server.Health.Shutdown()
+	resp, err := health.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "backend.v1.ClientService"})
+	if err != nil || resp.Status != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("Expected not serving after shutdown, got %v, %v", resp, err)
+	}
+}
+
+func TestValidationMatchesREST(t *testing.T) {
+	_, conn := dial(t)
+	credits := pb.NewCreditServiceClient(conn)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "trace-1")
+	var header metadata.MD
+	_, err := credits.CreateCredit(ctx, &pb.CreateCreditRequest{Credit: &pb.Credit{
+		ClientId: 1, BankId: 2, MinPayment: 900, MaxPayment: 100, TermMonths: 12, CreditType: pb.CreditType_CREDIT_TYPE_AUTO,
+	}}, grpc.Header(&header))
+	if status.Code(err) != codes.InvalidArgument || status.Convert(err).Message() == "" {
+		t.Errorf("Expected the REST validation error as InvalidArgument, got %v", err)
+	}
+	if ids := header.Get("x-request-id"); len(ids) != 1 || ids[0] != "trace-1" {
+		t.Errorf("Expected the request id to be echoed, got %v", ids)
+	}
+
+	_, err = credits.CreateCredit(context.Background(), &pb.CreateCreditRequest{Credit: &pb.Credit{
+		ClientId: 1, BankId: 2, MinPayment: 100, MaxPayment: 900, TermMonths: 12,
+	}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected an unspecified credit type to be rejected, got %v", err)
+	}
+
+	_, err = pb.NewClientServiceClient(conn).GetClient(context.Background(), &pb.GetClientRequest{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected a missing id to be rejected, got %v", err)
+	}
+
+	_, err = credits.BatchGetCredits(context.Background(), &pb.BatchGetCreditsRequest{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected an empty batch to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/internal/audit"
+	"backend/internal/database"
+	"backend/internal/export"
+	"backend/internal/logger"
+	"backend/internal/middleware"
+	"backend/internal/models"
+)
+
+// ActorHeader names the caller responsible for a mutation. Requests without it
+// are attributed to anonymousActor.
+const (
+	ActorHeader    = "X-Actor"
+	anonymousActor = "anonymous"
+	systemActor    = "system"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// auditRedactedFields lists personal data that is masked in audit snapshots
+// so erasure does not leave copies behind in the log.
+var auditRedactedFields = map[string][]string{
+	"client":  {"full_name", "email", "birth_date"},
+	"webhook": {"secret"},
+}
+
+func auditActor(r *http.Request) string {
+	if actor := strings.TrimSpace(r.Header.Get(ActorHeader)); actor != "" {
+		return actor
+	}
+	return anonymousActor
+}
+
+// recordChange writes the audit event and any domain events for a mutation of
+// resourceType inside the mutation's transaction.
+func recordChange(tx *sql.Tx, r *http.Request, resourceType string, resourceID int, operation string, before, after interface{}) error {
+	err := audit.Record(tx, audit.Entry{
+		Actor:        auditActor(r),
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Operation:    operation,
+		Before:       before,
+		After:        after,
+		Redact:       auditRedactedFields[resourceType],
+		RequestID:    middleware.RequestID(r.Context()),
+	})
+	if err != nil {
+		return err
+	}
+	return enqueueDomainEvents(tx, resourceType, resourceID, operation, before, after)
+}
+
+// auditedTable describes how to snapshot rows of a table for the audit log.
+type auditedTable struct {
+	resource string
+	columns  string
+	scan     func(row rowScanner) (interface{}, error)
+}
+
+var auditedTables = map[string]auditedTable{
+	"clients": {"client", clientColumns, func(row rowScanner) (interface{}, error) {
+		var client models.Client
+		err := scanClient(row, &client)
+		return client, err
+	}},
+	"banks": {"bank", bankColumns, func(row rowScanner) (interface{}, error) {
+		var bank models.Bank
+		err := scanBank(row, &bank)
+		return bank, err
+	}},
+	"credits": {"credit", creditColumns, func(row rowScanner) (interface{}, error) {
+		var credit models.Credit
+		err := scanCredit(row, &credit)
+		return credit, err
+	}},
+	"client_documents": {"client_document", documentColumns, func(row rowScanner) (interface{}, error) {
+		var doc models.ClientDocument
+		err := scanDocument(row, &doc)
+		return doc, err
+	}},
+}
+
+// updateAudited applies "SET set WHERE id = $1 AND condition" to one row of
+// table inside tx and records the row before and after. Extra args are bound
+// from $2. It returns sql.ErrNoRows when the row is missing or the condition
+// does not hold.
+func updateAudited(tx *sql.Tx, r *http.Request, table string, id int, operation, set, condition string, args ...interface{}) (interface{}, error) {
+	t := auditedTables[table]
+
+	before, err := t.scan(tx.QueryRow("SELECT "+t.columns+" FROM "+table+" WHERE id = $1 FOR UPDATE", id))
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := t.scan(tx.QueryRow(
+		"UPDATE "+table+" SET "+set+" WHERE id = $1 AND "+condition+" RETURNING "+t.columns,
+		append([]interface{}{id}, args...)...))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordChange(tx, r, t.resource, id, operation, before, after); err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+var auditEventFields = fieldColumns{
+	{"id", "id", zeroNumber},
+	{"actor", "actor", zeroText},
+	{"resource_type", "resource_type", zeroText},
+	{"resource_id", "resource_id", zeroNumber},
+	{"operation", "operation", zeroText},
+	{"before", "before", zeroNull},
+	{"after", "after", zeroNull},
+	{"changes", "changes", zeroNull},
+	{"request_id", "request_id", zeroNull},
+	{"created_at", "created_at", zeroTime},
+}
+
+var auditEventColumns = auditEventFields.all()
+
+func scanAuditEvent(row rowScanner, event *models.AuditEvent) error {
+	var before, after, changes []byte
+	var requestID sql.NullString
+	if err := row.Scan(&event.ID, &event.Actor, &event.ResourceType, &event.ResourceID, &event.Operation,
+		&before, &after, &changes, &requestID, &event.CreatedAt); err != nil {
+		return err
+	}
+	event.Before = before
+	event.After = after
+	event.Changes = changes
+	event.RequestID = requestID.String
+	return nil
+}
+
+// Audit handlers
+
+// GetAuditEvents lists audit events, newest first. It accepts the filters
+// resource_type, resource_id, actor, from and to (RFC 3339) and a limit.
+func GetAuditEvents(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	format, ok := listFormat(w, r)
+	if !ok {
+		return
+	}
+	fields, ok := parseFields(w, r, models.AuditEvent{})
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+
+	conditions := []string{}
+	args := []interface{}{}
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, strings.Replace(condition, "?", "$"+strconv.Itoa(len(args)), 1))
+	}
+
+	if value := query.Get("resource_type"); value != "" {
+		addCondition("resource_type = ?", value)
+	}
+	if value := query.Get("resource_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid resource_id"})
+			return
+		}
+		addCondition("resource_id = ?", id)
+	}
+	if value := query.Get("actor"); value != "" {
+		addCondition("actor = ?", value)
+	}
+	for _, bound := range []struct{ param, condition string }{
+		{"from", "created_at >= ?"},
+		{"to", "created_at < ?"},
+	} {
+		value := query.Get(bound.param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid " + bound.param + ", expected an RFC 3339 timestamp"})
+			return
+		}
+		addCondition(bound.condition, t)
+	}
+
+	limit := defaultAuditLimit
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > maxAuditLimit {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "limit must be between 1 and " + strconv.Itoa(maxAuditLimit)})
+			return
+		}
+		limit = parsed
+	}
+
+	sqlQuery := "SELECT " + auditEventFields.selecting(fields) + " FROM audit_events"
+	if len(conditions) > 0 {
+		sqlQuery += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, limit)
+	sqlQuery += " ORDER BY created_at DESC, id DESC LIMIT $" + strconv.Itoa(len(args))
+
+	rows, err := database.DB.Query(sqlQuery, args...)
+	if err != nil {
+		if logger.APILogger != nil {
+			logger.APILogger.LogError(r.Method, r.URL.Path, "Database query failed: "+err.Error())
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	list := newListWriter(w, format, "audit-events", export.Select(models.AuditEvent{}, fields))
+	for rows.Next() {
+		var event models.AuditEvent
+		if err := scanAuditEvent(rows, &event); err != nil {
+			if logger.APILogger != nil {
+				logger.APILogger.LogError(r.Method, r.URL.Path, "Row scan failed: "+err.Error())
+			}
+			continue
+		}
+		if err := list.Write(export.Select(event, fields)); err != nil {
+			break
+		}
+	}
+
+	list.Close()
+}
@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"backend/internal/audit"
+	"backend/internal/database"
+	"backend/internal/logger"
+	"backend/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// MaxBatchSize caps the number of items in one batch request and the number
+// of credits one bulk status change may touch.
+const MaxBatchSize = 1000
+
+// Batch modes. In atomic mode any failed item rolls back the whole batch; in
+// best-effort mode the items that succeed are kept.
+const (
+	BatchModeAtomic     = "atomic"
+	BatchModeBestEffort = "best_effort"
+)
+
+type batchResult struct {
+	Index   int         `json:"index"`
+	Status  int         `json:"status"`
+	ID      int         `json:"id,omitempty"`
+	Skipped bool        `json:"skipped,omitempty"`
+	Error   string      `json:"error,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+type batchResponse struct {
+	Mode      string        `json:"mode"`
+	Succeeded int           `json:"succeeded"`
+	Skipped   int           `json:"skipped"`
+	Failed    int           `json:"failed"`
+	Results   []batchResult `json:"results"`
+}
+
+// batchMode reads the mode query parameter, defaulting to atomic.
+func batchMode(r *http.Request) (string, bool) {
+	switch mode := r.URL.Query().Get("mode"); mode {
+	case "", BatchModeAtomic:
+		return BatchModeAtomic, true
+	case BatchModeBestEffort:
+		return mode, true
+	default:
+		return "", false
+	}
+}
+
+// decodeBatchItems decodes a JSON array request body without decoding the
+// items themselves, so each one can fail on its own.
+func decodeBatchItems(w http.ResponseWriter, r *http.Request) ([]json.RawMessage, bool) {
+	var items []json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Request body must be a JSON array"})
+		return nil, false
+	}
+	if len(items) == 0 || len(items) > MaxBatchSize {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Batch must contain between 1 and " + strconv.Itoa(MaxBatchSize) + " items"})
+		return nil, false
+	}
+	return items, true
+}
+
+// runBatch applies n items inside one transaction, each behind a savepoint so
+// a failed item leaves the others intact. apply reports a failure by setting
+// Error on the result, and an item it left alone by setting Skipped; skipped
+// items are neither kept changes nor failures. In atomic mode one failure rolls back every item and
+// the response is 422; otherwise the transaction is committed and the
+// response is successStatus, or 207 when some items failed.
+func runBatch(w http.ResponseWriter, r *http.Request, mode string, n int, successStatus int, apply func(tx *sql.Tx, index int) batchResult) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	response := batchResponse{Mode: mode, Results: make([]batchResult, 0, n)}
+	for i := 0; i < n; i++ {
+		if _, err = tx.Exec("SAVEPOINT batch_item"); err != nil {
+			break
+		}
+		result := apply(tx, i)
+		result.Index = i
+		switch {
+		case result.Error != "":
+			_, err = tx.Exec("ROLLBACK TO SAVEPOINT batch_item")
+			response.Failed++
+		case result.Skipped:
+			_, err = tx.Exec("RELEASE SAVEPOINT batch_item")
+			response.Skipped++
+		default:
+			_, err = tx.Exec("RELEASE SAVEPOINT batch_item")
+			response.Succeeded++
+		}
+		if err != nil {
+			break
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	status := successStatus
+	switch {
+	case err != nil:
+	case mode == BatchModeAtomic && response.Failed > 0:
+		// Nothing is kept, so items that would have succeeded are reported as
+		// failed because of the others.
+		for i := range response.Results {
+			if response.Results[i].Error == "" && !response.Results[i].Skipped {
+				response.Results[i] = batchResult{
+					Index:  i,
+					Status: http.StatusFailedDependency,
+					Error:  "Not applied because other items failed",
+				}
+			}
+		}
+		response.Failed, response.Succeeded = n-response.Skipped, 0
+		status = http.StatusUnprocessableEntity
+	default:
+		err = tx.Commit()
+		if response.Failed > 0 {
+			status = http.StatusMultiStatus
+		}
+	}
+	if err != nil {
+		if logger.APILogger != nil {
+			logger.APILogger.LogError(r.Method, r.URL.Path, "Batch failed: "+err.Error())
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Batch handlers
+
+// CreateClientsBatch creates every client in a JSON array body. The mode
+// query parameter selects atomic (default) or best_effort.
+func CreateClientsBatch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	mode, ok := batchMode(r)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid mode. Must be atomic or best_effort"})
+		return
+	}
+	items, ok := decodeBatchItems(w, r)
+	if !ok {
+		return
+	}
+
+	runBatch(w, r, mode, len(items), http.StatusCreated, func(tx *sql.Tx, i int) batchResult {
+		var client models.Client
+		if err := json.Unmarshal(items[i], &client); err != nil {
+			return batchResult{Status: http.StatusBadRequest, Error: "Invalid client"}
+		}
+		if status, msg := createClient(tx, r, &client); status != 0 {
+			return batchResult{Status: status, Error: msg}
+		}
+		return batchResult{Status: http.StatusCreated, ID: client.ID, Data: client}
+	})
+}
+
+// CreateCreditsBatch creates every credit in a JSON array body with the same
+// checks as CreateCredit. The mode query parameter selects atomic (default)
+// or best_effort.
+func CreateCreditsBatch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	mode, ok := batchMode(r)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid mode. Must be atomic or best_effort"})
+		return
+	}
+	items, ok := decodeBatchItems(w, r)
+	if !ok {
+		return
+	}
+
+	runBatch(w, r, mode, len(items), http.StatusCreated, func(tx *sql.Tx, i int) batchResult {
+		var credit models.Credit
+		if err := json.Unmarshal(items[i], &credit); err != nil {
+			return batchResult{Status: http.StatusBadRequest, Error: "Invalid credit"}
+		}
+		if status, msg := createCredit(tx, r, &credit); status != 0 {
+			return batchResult{Status: status, Error: msg}
+		}
+		return batchResult{Status: http.StatusCreated, ID: credit.ID, Data: credit}
+	})
+}
+
+type bulkStatusFilter struct {
+	IDs        []int                `json:"ids"`
+	ClientID   *int                 `json:"client_id"`
+	BankID     *int                 `json:"bank_id"`
+	Status     *models.CreditStatus `json:"status"`
+	CreditType *models.CreditType   `json:"credit_type"`
+}
+
+type bulkStatusChange struct {
+	Status models.CreditStatus `json:"status"`
+	Filter bulkStatusFilter    `json:"filter"`
+}
+
+// UpdateCreditsStatus moves every live credit matching the filter to the
+// given status. Credits already in that status are left alone, credits that
+// stop matching before they are written are reported as skipped, and
+// approvals still require the client's documents. The mode query parameter selects
+// atomic (default) or best_effort.
+func UpdateCreditsStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	mode, ok := batchMode(r)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid mode. Must be atomic or best_effort"})
+		return
+	}
+
+	var change bulkStatusChange
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+		return
+	}
+	if change.Status != models.CreditStatusPending &&
+		change.Status != models.CreditStatusApproved &&
+		change.Status != models.CreditStatusRejected {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid status. Must be PENDING, APPROVED, or REJECTED"})
+		return
+	}
+
+	// The filter is rendered twice: for the selection, where the new status
+	// is $1, and for the write, where updateAudited puts the id first
+	var filterConditions []string
+	var filterArgs []interface{}
+	addCondition := func(condition string, value interface{}) {
+		filterConditions = append(filterConditions, condition)
+		filterArgs = append(filterArgs, value)
+	}
+
+	filter := change.Filter
+	if filter.IDs != nil {
+		addCondition("id = ANY(?)", pq.Array(filter.IDs))
+	}
+	if filter.ClientID != nil {
+		addCondition("client_id = ?", *filter.ClientID)
+	}
+	if filter.BankID != nil {
+		addCondition("bank_id = ?", *filter.BankID)
+	}
+	if filter.Status != nil {
+		addCondition("status = ?", *filter.Status)
+	}
+	if filter.CreditType != nil {
+		addCondition("credit_type = ?", *filter.CreditType)
+	}
+	if len(filterConditions) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Filter must set at least one of ids, client_id, bank_id, status or credit_type"})
+		return
+	}
+	matches := func(firstPlaceholder int, status string) string {
+		conditions := []string{"deleted_at IS NULL", "status <> " + status}
+		for i, condition := range filterConditions {
+			conditions = append(conditions, strings.Replace(condition, "?", "$"+strconv.Itoa(firstPlaceholder+i), 1))
+		}
+		return strings.Join(conditions, " AND ")
+	}
+	args := append([]interface{}{change.Status}, filterArgs...)
+
+	rows, err := database.DB.Query(
+		"SELECT id, client_id FROM credits WHERE "+matches(2, "$1")+
+			" ORDER BY id LIMIT "+strconv.Itoa(MaxBatchSize+1), args...)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	var ids, clientIDs []int
+	for rows.Next() {
+		var id, clientID int
+		if err = rows.Scan(&id, &clientID); err != nil {
+			break
+		}
+		ids = append(ids, id)
+		clientIDs = append(clientIDs, clientID)
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	rows.Close()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	if len(ids) > MaxBatchSize {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Filter matches more than " + strconv.Itoa(MaxBatchSize) + " credits"})
+		return
+	}
+	if len(ids) == 0 {
+		json.NewEncoder(w).Encode(batchResponse{Mode: mode, Results: []batchResult{}})
+		return
+	}
+
+	// Document checks depend only on the client, so each is done once
+	approvals := map[int]batchResult{}
+	runBatch(w, r, mode, len(ids), http.StatusOK, func(tx *sql.Tx, i int) batchResult {
+		if change.Status == models.CreditStatusApproved {
+			failure, checked := approvals[clientIDs[i]]
+			if !checked {
+				if status, msg := approvalDocumentsError(tx, r, clientIDs[i]); status != 0 {
+					failure = batchResult{Status: status, Error: msg}
+				}
+				approvals[clientIDs[i]] = failure
+			}
+			if failure.Error != "" {
+				failure.ID = ids[i]
+				return failure
+			}
+		}
+
+		// The whole filter is checked again so a credit changed since it was
+		// selected is skipped rather than silently overwritten
+		updated, err := updateAudited(tx, r, "credits", ids[i], audit.OpUpdate,
+			"status = $2, version = version + 1", matches(3, "$2"), append([]interface{}{change.Status}, filterArgs...)...)
+		if err == sql.ErrNoRows {
+			return batchResult{Status: http.StatusConflict, ID: ids[i], Skipped: true}
+		}
+		if err != nil {
+			return batchResult{Status: http.StatusInternalServerError, ID: ids[i], Error: "Failed to update credit"}
+		}
+		return batchResult{Status: http.StatusOK, ID: ids[i], Data: updated}
+	})
+}
@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"backend/internal/database"
+	"backend/internal/encryption"
+	"backend/internal/logger"
+	"backend/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// PIIEncrypter encrypts client full name, email and birth date at rest. When
+// nil, those columns are stored in plaintext.
+var PIIEncrypter *encryption.Encrypter
+
+const birthDateLayout = "2006-01-02"
+
+type encryptedClientPII struct {
+	fullName, email, birthDate sql.NullString
+}
+
+// clientPIIValues holds the column values written for a client's PII. Exactly
+// one of the plaintext or encrypted sets is non-nil.
+type clientPIIValues struct {
+	fullName, email, birthDate                      interface{}
+	fullNameEnc, emailEnc, birthDateEnc, emailIndex interface{}
+	keyID                                           interface{}
+}
+
+func encryptClientPII(client models.Client) (clientPIIValues, error) {
+	if PIIEncrypter == nil {
+		return clientPIIValues{fullName: client.FullName, email: client.Email, birthDate: client.BirthDate}, nil
+	}
+	return encryptPIIFields(client.FullName, client.Email, client.BirthDate.Format(birthDateLayout))
+}
+
+func encryptPIIFields(fullName, email, birthDate string) (clientPIIValues, error) {
+	var values clientPIIValues
+	var err error
+	if values.fullNameEnc, err = PIIEncrypter.Encrypt(fullName); err != nil {
+		return values, err
+	}
+	if values.emailEnc, err = PIIEncrypter.Encrypt(email); err != nil {
+		return values, err
+	}
+	if values.birthDateEnc, err = PIIEncrypter.Encrypt(birthDate); err != nil {
+		return values, err
+	}
+	if values.emailIndex, err = PIIEncrypter.BlindIndex(email); err != nil {
+		return values, err
+	}
+	values.keyID, err = PIIEncrypter.CurrentKeyID()
+	return values, err
+}
+
+// decryptClientPII decrypts the encrypted columns that were selected; a field
+// set may leave some of them out.
+func decryptClientPII(encrypted encryptedClientPII, client *models.Client) error {
+	if PIIEncrypter == nil {
+		return encryption.ErrUnknownKey
+	}
+	var err error
+	if encrypted.fullName.Valid {
+		if client.FullName, err = PIIEncrypter.Decrypt(encrypted.fullName.String); err != nil {
+			return err
+		}
+	}
+	if encrypted.email.Valid {
+		if client.Email, err = PIIEncrypter.Decrypt(encrypted.email.String); err != nil {
+			return err
+		}
+	}
+	if !encrypted.birthDate.Valid {
+		return nil
+	}
+	birthDate, err := PIIEncrypter.Decrypt(encrypted.birthDate.String)
+	if err != nil {
+		return err
+	}
+	client.BirthDate, err = time.Parse(birthDateLayout, birthDate)
+	return err
+}
+
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// clientIDByEmail finds a client by email, case-insensitively. Encrypted rows
+// are matched through the blind index; rows not yet encrypted by plaintext.
+// Without PIIEncrypter, encrypted rows cannot be searched, so a miss while any
+// exist is encryption.ErrUnknownKey rather than sql.ErrNoRows.
+func clientIDByEmail(q queryRower, email string) (int, error) {
+	var id int
+	if PIIEncrypter == nil {
+		err := q.QueryRow("SELECT id FROM clients WHERE lower(email) = lower($1)", email).Scan(&id)
+		if err != sql.ErrNoRows {
+			return id, err
+		}
+		var encrypted bool
+		if err := q.QueryRow("SELECT EXISTS (SELECT 1 FROM clients WHERE email_enc IS NOT NULL)").Scan(&encrypted); err != nil {
+			return 0, err
+		}
+		if encrypted {
+			return 0, encryption.ErrUnknownKey
+		}
+		return 0, sql.ErrNoRows
+	}
+
+	index, err := PIIEncrypter.BlindIndex(email)
+	if err != nil {
+		return 0, err
+	}
+	err = q.QueryRow("SELECT id FROM clients WHERE email_index = $1 OR lower(email) = lower($2)", index, email).Scan(&id)
+	return id, err
+}
+
+// ReencryptBatchSize is the number of clients rewritten per transaction.
+var ReencryptBatchSize = 100
+
+// ErrReencryptionRunning is returned when a re-encryption run is already
+// active.
+var ErrReencryptionRunning = errors.New("client re-encryption is already running")
+
+var reencryptMu sync.Mutex
+
+// ReencryptClients encrypts plaintext client rows and re-wraps rows whose data
+// keys use a retired key, in batches of batchSize rows per transaction. It
+// returns the number of rows rewritten. Only one run is active at a time; a
+// second one returns ErrReencryptionRunning.
+func ReencryptClients(ctx context.Context, batchSize int) (int, error) {
+	if PIIEncrypter == nil {
+		return 0, nil
+	}
+	if !reencryptMu.TryLock() {
+		return 0, ErrReencryptionRunning
+	}
+	defer reencryptMu.Unlock()
+	return reencryptClientsLocked(ctx, batchSize)
+}
+
+// reencryptClientsLocked runs the batches; the caller holds reencryptMu.
+func reencryptClientsLocked(ctx context.Context, batchSize int) (int, error) {
+	// The key id written to each row, so that a batch made only of rows this
+	// run already moved to the current key ends the run instead of repeating
+	rewritten := map[int]string{}
+	// Rows left alone because their email collides with another client's
+	skipped := []int64{}
+	total := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		// Read the current key for every batch: the key file may be reloaded
+		// mid-run, and rows are stamped with whichever key wraps them
+		currentKeyID, err := PIIEncrypter.CurrentKeyID()
+		if err != nil {
+			return total, err
+		}
+		stamped, duplicates, err := reencryptClientBatch(currentKeyID, batchSize, skipped)
+		if err != nil {
+			return total, err
+		}
+		skipped = append(skipped, duplicates...)
+		fresh := 0
+		for id, keyID := range stamped {
+			if previous, ok := rewritten[id]; !ok || previous != keyID {
+				fresh++
+			}
+			rewritten[id] = keyID
+		}
+		total += fresh
+		if len(stamped)+len(duplicates) < batchSize || (fresh == 0 && len(duplicates) == 0) {
+			return total, nil
+		}
+	}
+}
+
+type pendingClientPII struct {
+	id              int
+	fullName, email sql.NullString
+	birthDate       sql.NullTime
+	encrypted       encryptedClientPII
+}
+
+// reencryptClientBatch rewrites up to batchSize rows not yet on currentKeyID,
+// other than the skip ones, and returns the key id each rewritten row was
+// stamped with. Rows whose email differs from another client's only in case
+// cannot take the same blind index; they are logged and returned as
+// duplicates, and stay as they are until one of the two is changed.
+func reencryptClientBatch(currentKeyID string, batchSize int, skip []int64) (map[int]string, []int64, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id, full_name, email, birth_date, full_name_enc, email_enc, birth_date_enc
+		FROM clients
+		WHERE erased_at IS NULL AND (pii_key_id IS NULL OR pii_key_id <> $1) AND NOT (id = ANY($3))
+		ORDER BY id
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`, currentKeyID, batchSize, pq.Array(skip))
+	if err != nil {
+		return nil, nil, err
+	}
+	pending := []pendingClientPII{}
+	for rows.Next() {
+		var p pendingClientPII
+		if err := rows.Scan(&p.id, &p.fullName, &p.email, &p.birthDate,
+			&p.encrypted.fullName, &p.encrypted.email, &p.encrypted.birthDate); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		pending = append(pending, p)
+	}
+	rows.Close()
+
+	stamped := make(map[int]string, len(pending))
+	duplicates := []int64{}
+	for _, p := range pending {
+		var values clientPIIValues
+		if p.encrypted.fullName.Valid {
+			values, err = rewrapClientPII(p.encrypted)
+		} else {
+			values, err = encryptPIIFields(p.fullName.String, p.email.String, p.birthDate.Time.Format(birthDateLayout))
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var other int
+		err = tx.QueryRow("SELECT id FROM clients WHERE email_index = $1 AND id <> $2", values.emailIndex, p.id).Scan(&other)
+		if err == nil {
+			log.Printf("Client re-encryption skipped client %d: its email matches client %d's apart from case", p.id, other)
+			duplicates = append(duplicates, int64(p.id))
+			continue
+		}
+		if err != sql.ErrNoRows {
+			return nil, nil, err
+		}
+
+		_, err = tx.Exec(`
+			UPDATE clients
+			SET full_name = NULL, email = NULL, birth_date = NULL,
+			    full_name_enc = $1, email_enc = $2, birth_date_enc = $3, email_index = $4, pii_key_id = $5
+			WHERE id = $6
+		`, values.fullNameEnc, values.emailEnc, values.birthDateEnc, values.emailIndex, values.keyID, p.id)
+		if err != nil {
+			return nil, nil, err
+		}
+		stamped[p.id] = values.keyID.(string)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return stamped, duplicates, nil
+}
+
+// rewrapClientPII moves already encrypted values to the current key without
+// decrypting the values themselves.
+func rewrapClientPII(encrypted encryptedClientPII) (clientPIIValues, error) {
+	var values clientPIIValues
+	var err error
+	if values.fullNameEnc, err = PIIEncrypter.Rewrap(encrypted.fullName.String); err != nil {
+		return values, err
+	}
+	if values.emailEnc, err = PIIEncrypter.Rewrap(encrypted.email.String); err != nil {
+		return values, err
+	}
+	if values.birthDateEnc, err = PIIEncrypter.Rewrap(encrypted.birthDate.String); err != nil {
+		return values, err
+	}
+
+	// The blind index key does not rotate, but recompute it in case the row
+	// predates the index
+	email, err := PIIEncrypter.Decrypt(encrypted.email.String)
+	if err != nil {
+		return values, err
+	}
+	if values.emailIndex, err = PIIEncrypter.BlindIndex(email); err != nil {
+		return values, err
+	}
+	values.keyID, err = PIIEncrypter.CurrentKeyID()
+	return values, err
+}
+
+// StartClientReencryption runs ReencryptClients in the background and logs the
+// outcome. The returned channel is closed once it has finished.
+func StartClientReencryption(ctx context.Context, batchSize int) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		reencryptClientsAndLog(ctx, batchSize)
+	}()
+	return done
+}
+
+func reencryptClientsAndLog(ctx context.Context, batchSize int) {
+	n, err := ReencryptClients(ctx, batchSize)
+	logReencryption(n, err)
+}
+
+func logReencryption(n int, err error) {
+	if errors.Is(err, ErrReencryptionRunning) {
+		log.Printf("Client re-encryption skipped: %v", err)
+		return
+	}
+	if err != nil {
+		log.Printf("Client re-encryption stopped after %d rows: %v", n, err)
+		return
+	}
+	if n > 0 {
+		log.Printf("Client re-encryption rewrote %d rows", n)
+	}
+}
+
+// ReencryptClientsHandler reloads the PII keys and starts a background
+// re-encryption run, typically after the current key in the key file has been
+// rotated.
+func ReencryptClientsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if PIIEncrypter == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "PII encryption is not configured"})
+		return
+	}
+
+	if err := PIIEncrypter.Reload(); err != nil {
+		if logger.APILogger != nil {
+			logger.APILogger.LogError(r.Method, r.URL.Path, "PII key reload failed: "+err.Error())
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to reload PII keys"})
+		return
+	}
+
+	// Take the run lock here rather than in the job, so that a busy run is
+	// reported to the caller instead of being skipped in the background
+	if !reencryptMu.TryLock() {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrReencryptionRunning.Error()})
+		return
+	}
+	started := startJob(func(ctx context.Context) {
+		defer reencryptMu.Unlock()
+		logReencryption(reencryptClientsLocked(ctx, ReencryptBatchSize))
+	})
+	if !started {
+		reencryptMu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Server is shutting down"})
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Client re-encryption started"})
+}
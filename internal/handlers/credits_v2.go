@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"backend/internal/models"
+
+	"github.com/gorilla/mux"
+)
+
+// Version 2 of the credit endpoints, under /api/v2. Money amounts are decimal
+// strings so they survive clients that parse JSON numbers as floats, and lists
+// come a page at a time in an envelope. Writes run the v1 handlers.
+
+// V2DefaultPageSize and V2MaxPageSize bound the limit of v2 list endpoints.
+const (
+	V2DefaultPageSize = 50
+	V2MaxPageSize     = 500
+)
+
+// CreditV2 is a credit as version 2 of the API shows it. MinPayment and
+// MaxPayment are decimal strings with two fraction digits, such as "1250.00".
+type CreditV2 struct {
+	ID           int                 `json:"id"`
+	ClientID     int                 `json:"client_id"`
+	BankID       int                 `json:"bank_id"`
+	MinPayment   string              `json:"min_payment"`
+	MaxPayment   string              `json:"max_payment"`
+	TermMonths   int                 `json:"term_months"`
+	CreditType   models.CreditType   `json:"credit_type"`
+	Status       models.CreditStatus `json:"status"`
+	DebtToIncome *float64            `json:"debt_to_income,omitempty"`
+	DTIFlagged   bool                `json:"dti_flagged"`
+	DeletedAt    *time.Time          `json:"deleted_at,omitempty"`
+	Version      int                 `json:"version"`
+	CreatedAt    time.Time           `json:"created_at"`
+}
+
+// CreditPageV2 is one page of a v2 credit list.
+type CreditPageV2 struct {
+	Data       []CreditV2   `json:"data"`
+	Pagination PaginationV2 `json:"pagination"`
+}
+
+// PaginationV2 describes a page. NextCursor, passed back as the cursor query
+// parameter, fetches the next page; it is null on the last one.
+type PaginationV2 struct {
+	Limit      int     `json:"limit"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+var moneyPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]{1,2})?$`)
+
+func formatMoney(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+// parseMoney parses a non-negative decimal string with at most two fraction
+// digits.
+func parseMoney(amount string) (float64, bool) {
+	if !moneyPattern.MatchString(amount) {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(amount, 64)
+	return value, err == nil
+}
+
+func creditToV2(credit models.Credit) CreditV2 {
+	return CreditV2{
+		ID:           credit.ID,
+		ClientID:     credit.ClientID,
+		BankID:       credit.BankID,
+		MinPayment:   formatMoney(credit.MinPayment),
+		MaxPayment:   formatMoney(credit.MaxPayment),
+		TermMonths:   credit.TermMonths,
+		CreditType:   credit.CreditType,
+		Status:       credit.Status,
+		DebtToIncome: credit.DebtToIncome,
+		DTIFlagged:   credit.DTIFlagged,
+		DeletedAt:    credit.DeletedAt,
+		Version:      credit.Version,
+		CreatedAt:    credit.CreatedAt,
+	}
+}
+
+// creditFromV2 reads the writable fields of a v2 credit. It returns an error
+// message when an amount is not a decimal string.
+func creditFromV2(credit CreditV2) (models.Credit, string) {
+	minPayment, okMin := parseMoney(credit.MinPayment)
+	maxPayment, okMax := parseMoney(credit.MaxPayment)
+	if !okMin || !okMax {
+		return models.Credit{}, `Invalid payment amounts. Min and max must be decimal strings such as "1250.00"`
+	}
+	return models.Credit{
+		ClientID:   credit.ClientID,
+		BankID:     credit.BankID,
+		MinPayment: minPayment,
+		MaxPayment: maxPayment,
+		TermMonths: credit.TermMonths,
+		CreditType: credit.CreditType,
+		Status:     credit.Status,
+	}, ""
+}
+
+func GetCreditsV2(w http.ResponseWriter, r *http.Request) {
+	listCreditsV2(w, r, nil, nil)
+}
+
+func GetCreditsByClientV2(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	clientID, err := strconv.Atoi(mux.Vars(r)["clientId"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid client ID"})
+		return
+	}
+	listCreditsV2(w, r, clientID, nil)
+}
+
+func GetCreditsByBankV2(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	bankID, err := strconv.Atoi(mux.Vars(r)["bankId"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid bank ID"})
+		return
+	}
+	listCreditsV2(w, r, nil, bankID)
+}
+
+// listCreditsV2 writes a page of credits in id order, of the client and bank
+// when they are not nil, filtered by the status and credit_type query
+// parameters.
+func listCreditsV2(w http.ResponseWriter, r *http.Request, clientID, bankID interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	query := r.URL.Query()
+	limit, after, ok := parsePageV2(w, r)
+	if !ok {
+		return
+	}
+
+	var status, creditType interface{}
+	if value := query.Get("status"); value != "" {
+		switch models.CreditStatus(value) {
+		case models.CreditStatusPending, models.CreditStatusApproved, models.CreditStatusRejected:
+			status = value
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid status. Must be PENDING, APPROVED, or REJECTED"})
+			return
+		}
+	}
+	if value := query.Get("credit_type"); value != "" {
+		switch models.CreditType(value) {
+		case models.CreditTypeAuto, models.CreditTypeMortgage, models.CreditTypeCommercial:
+			creditType = value
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid credit type. Must be AUTO, MORTGAGE, or COMMERCIAL"})
+			return
+		}
+	}
+
+	// One row more than the page tells whether there is a next one
+	rows, err := queryRows(r.Context(), "credits", creditColumns, creditFilter, includeDeleted(r), after, limit+1,
+		clientID, bankID, status, creditType)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	page := CreditPageV2{Data: []CreditV2{}, Pagination: PaginationV2{Limit: limit}}
+	for rows.Next() {
+		var credit models.Credit
+		if err := scanCredit(rows, &credit); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+			return
+		}
+		page.Data = append(page.Data, creditToV2(credit))
+	}
+	if err := rows.Err(); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	if len(page.Data) > limit {
+		page.Data = page.Data[:limit]
+		cursor := strconv.Itoa(page.Data[limit-1].ID)
+		page.Pagination.NextCursor = &cursor
+	}
+
+	json.NewEncoder(w).Encode(page)
+}
+
+// parsePageV2 reads the limit and cursor query parameters, writing a 400
+// response when they are invalid.
+func parsePageV2(w http.ResponseWriter, r *http.Request) (limit, after int, ok bool) {
+	query := r.URL.Query()
+	limit = V2DefaultPageSize
+	if value := query.Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > V2MaxPageSize {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "limit must be between 1 and " + strconv.Itoa(V2MaxPageSize)})
+			return 0, 0, false
+		}
+	}
+	if value := query.Get("cursor"); value != "" {
+		var err error
+		after, err = strconv.Atoi(value)
+		if err != nil || after < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid cursor"})
+			return 0, 0, false
+		}
+	}
+	return limit, after, true
+}
+
+func GetCreditV2(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	found, err := findRow(r.Context(), "credits", creditColumns, id, includeDeleted(r), func(row rowScanner) (interface{}, error) {
+		var credit models.Credit
+		err := scanCredit(row, &credit)
+		return credit, err
+	})
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Credit not found"})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	credit := found.(models.Credit)
+
+	if checkNotModified(w, r, credit.Version) {
+		return
+	}
+	setETag(w, credit.Version)
+	json.NewEncoder(w).Encode(creditToV2(credit))
+}
+
+func CreateCreditV2(w http.ResponseWriter, r *http.Request) {
+	writeCreditV2(w, r, CreateCredit)
+}
+
+func UpdateCreditV2(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := strconv.Atoi(mux.Vars(r)["id"]); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid ID"})
+		return
+	}
+	writeCreditV2(w, r, UpdateCredit)
+}
+
+// writeCreditV2 decodes a v2 credit, runs the v1 handler with it and answers
+// with its response, converting a credit back to v2.
+func writeCreditV2(w http.ResponseWriter, r *http.Request, handler http.HandlerFunc) {
+	w.Header().Set("Content-Type", "application/json")
+	var input CreditV2
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+		return
+	}
+	credit, msg := creditFromV2(input)
+	if msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": msg})
+		return
+	}
+
+	resp := callHandler(r.Context(), handler, r.Method, r.URL.Path, mux.Vars(r), r.Header.Clone(), credit)
+	if etag := resp.header.Get("ETag"); etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	if resp.status >= http.StatusBadRequest {
+		w.WriteHeader(resp.status)
+		w.Write(resp.body.Bytes())
+		return
+	}
+	if err := json.Unmarshal(resp.body.Bytes(), &credit); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	w.WriteHeader(resp.status)
+	json.NewEncoder(w).Encode(creditToV2(credit))
+}
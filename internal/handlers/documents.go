@@ -0,0 +1,446 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/internal/audit"
+	"backend/internal/database"
+	"backend/internal/export"
+	"backend/internal/logger"
+	"backend/internal/models"
+	"backend/internal/storage"
+	"github.com/gorilla/mux"
+)
+
+// DocumentStore holds uploaded KYC files. It is configured from main; uploads
+// are refused while it is nil.
+var DocumentStore storage.BlobStore
+
+// MaxDocumentSize is the largest accepted document upload in bytes.
+var MaxDocumentSize int64 = 10 << 20
+
+var allowedDocumentContentTypes = map[string]bool{
+	"application/pdf": true,
+	"image/jpeg":      true,
+	"image/png":       true,
+}
+
+var documentFields = fieldColumns{
+	{"id", "id", zeroNumber},
+	{"client_id", "client_id", zeroNumber},
+	{"type", "type", zeroText},
+	{"file_name", "file_name", zeroText},
+	{"content_type", "content_type", zeroText},
+	{"size_bytes", "size_bytes", zeroNumber},
+	{"sha256", "sha256", zeroText},
+	{"", "storage_key", zeroText},
+	{"expiry_date", "expiry_date", zeroNull},
+	{"status", "status", zeroText},
+	{"created_at", "created_at", zeroTime},
+}
+
+var documentColumns = documentFields.all()
+
+func scanDocument(row rowScanner, doc *models.ClientDocument) error {
+	var expiryDate sql.NullTime
+	if err := row.Scan(&doc.ID, &doc.ClientID, &doc.Type, &doc.FileName, &doc.ContentType,
+		&doc.SizeBytes, &doc.SHA256, &doc.StorageKey, &expiryDate, &doc.Status, &doc.CreatedAt); err != nil {
+		return err
+	}
+	doc.ExpiryDate = nil
+	if expiryDate.Valid {
+		doc.ExpiryDate = &expiryDate.Time
+	}
+	return nil
+}
+
+func newStorageKey(clientID int) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("clients/%d/%s", clientID, hex.EncodeToString(b)), nil
+}
+
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// missingRequiredDocuments returns the required document types for which the
+// client has no verified, unexpired document.
+func missingRequiredDocuments(q queryer, clientID int) ([]models.DocumentType, error) {
+	rows, err := q.Query(`
+		SELECT DISTINCT type
+		FROM client_documents
+		WHERE client_id = $1 AND status = $2
+		  AND (expiry_date IS NULL OR expiry_date >= CURRENT_DATE)
+	`, clientID, models.DocumentStatusVerified)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	present := map[models.DocumentType]bool{}
+	for rows.Next() {
+		var docType models.DocumentType
+		if err := rows.Scan(&docType); err != nil {
+			return nil, err
+		}
+		present[docType] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	missing := []models.DocumentType{}
+	for _, docType := range models.RequiredDocumentTypes {
+		if !present[docType] {
+			missing = append(missing, docType)
+		}
+	}
+	return missing, nil
+}
+
+// approvalDocumentsError returns the HTTP status and error message to report
+// when the client cannot have a credit approved yet, or a zero status. The
+// documents are read through q, usually the transaction approving the credit.
+func approvalDocumentsError(q queryer, r *http.Request, clientID int) (int, string) {
+	missing, err := missingRequiredDocuments(q, clientID)
+	if err != nil {
+		if logger.APILogger != nil {
+			logger.APILogger.LogError(r.Method, r.URL.Path, "Document check failed: "+err.Error())
+		}
+		return http.StatusInternalServerError, "Database error"
+	}
+	if len(missing) > 0 {
+		names := make([]string, len(missing))
+		for i, docType := range missing {
+			names[i] = string(docType)
+		}
+		return http.StatusUnprocessableEntity, "Credit cannot be approved: missing or expired documents: " + strings.Join(names, ", ")
+	}
+	return 0, ""
+}
+
+// Document handlers
+
+// UploadClientDocument accepts a multipart form with a "file" part and the
+// "type" and optional "expiry_date" (YYYY-MM-DD) fields.
+func UploadClientDocument(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	params := mux.Vars(r)
+	clientID, err := strconv.Atoi(params["clientId"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid client ID"})
+		return
+	}
+
+	if DocumentStore == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Document storage is not configured"})
+		return
+	}
+
+	// Leave room for the multipart envelope and form fields
+	r.Body = http.MaxBytesReader(w, r.Body, MaxDocumentSize+1<<20)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid multipart form or file too large"})
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	doc := models.ClientDocument{
+		ClientID: clientID,
+		Type:     models.DocumentType(r.FormValue("type")),
+		Status:   models.DocumentStatusPending,
+	}
+
+	if doc.Type != models.DocumentTypeIDDocument && doc.Type != models.DocumentTypeProofOfAddress {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid document type. Must be ID_DOCUMENT or PROOF_OF_ADDRESS"})
+		return
+	}
+
+	if value := r.FormValue("expiry_date"); value != "" {
+		expiry, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid expiry_date. Use YYYY-MM-DD"})
+			return
+		}
+		doc.ExpiryDate = &expiry
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Missing file"})
+		return
+	}
+	defer file.Close()
+
+	if header.Size <= 0 || header.Size > MaxDocumentSize {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("File must be between 1 byte and %d bytes", MaxDocumentSize)})
+		return
+	}
+
+	// Trust the file's content rather than the client-declared type
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(file, sniff)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unable to read file"})
+		return
+	}
+	doc.ContentType = http.DetectContentType(sniff[:n])
+	if !allowedDocumentContentTypes[doc.ContentType] {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unsupported file type. Must be PDF, JPEG, or PNG"})
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unable to read file"})
+		return
+	}
+
+	// Deleted and erased clients take no new documents
+	const liveClient = "SELECT 1 FROM clients WHERE id = $1 AND deleted_at IS NULL AND erased_at IS NULL"
+	var exists bool
+	if err := database.DB.QueryRow("SELECT EXISTS("+liveClient+")", clientID).Scan(&exists); err != nil || !exists {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Client not found"})
+		return
+	}
+
+	doc.FileName = filepath.Base(header.Filename)
+	doc.StorageKey, err = newStorageKey(clientID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to store document"})
+		return
+	}
+
+	hash := sha256.New()
+	counter := &countingWriter{}
+	if err := DocumentStore.Put(r.Context(), doc.StorageKey, io.TeeReader(file, io.MultiWriter(hash, counter))); err != nil {
+		if logger.APILogger != nil {
+			logger.APILogger.LogError(r.Method, r.URL.Path, "Failed to store document: "+err.Error())
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to store document"})
+		return
+	}
+	doc.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	doc.SizeBytes = counter.n
+
+	tx, err := database.DB.Begin()
+	if err == nil {
+		defer tx.Rollback()
+		// Check again under a lock, as the client may have been erased while
+		// the file was stored
+		var live int
+		err = tx.QueryRow(liveClient+" FOR SHARE", clientID).Scan(&live)
+	}
+	if err == sql.ErrNoRows {
+		DocumentStore.Delete(r.Context(), doc.StorageKey)
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Client not found"})
+		return
+	}
+	if err == nil {
+		err = tx.QueryRow(`
+			INSERT INTO client_documents (client_id, type, file_name, content_type, size_bytes, sha256, storage_key, expiry_date, status)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			RETURNING id, created_at
+		`, doc.ClientID, doc.Type, doc.FileName, doc.ContentType, doc.SizeBytes, doc.SHA256,
+			doc.StorageKey, doc.ExpiryDate, doc.Status).Scan(&doc.ID, &doc.CreatedAt)
+	}
+	if err == nil {
+		err = recordChange(tx, r, "client_document", doc.ID, audit.OpCreate, nil, doc)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		DocumentStore.Delete(r.Context(), doc.StorageKey)
+		if logger.APILogger != nil {
+			logger.APILogger.LogError(r.Method, r.URL.Path, "Failed to insert document: "+err.Error())
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to store document"})
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(doc)
+}
+
+func GetClientDocuments(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	format, ok := listFormat(w, r)
+	if !ok {
+		return
+	}
+	fields, ok := parseFields(w, r, models.ClientDocument{})
+	if !ok {
+		return
+	}
+	params := mux.Vars(r)
+	clientID, err := strconv.Atoi(params["clientId"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid client ID"})
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT `+documentFields.selecting(fields)+`
+		FROM client_documents
+		WHERE client_id = $1
+		ORDER BY created_at DESC
+	`, clientID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	list := newListWriter(w, format, "documents", export.Select(models.ClientDocument{}, fields))
+	for rows.Next() {
+		var doc models.ClientDocument
+		if err := scanDocument(rows, &doc); err != nil {
+			continue
+		}
+		if err := list.Write(export.Select(doc, fields)); err != nil {
+			break
+		}
+	}
+
+	list.Close()
+}
+
+// DownloadClientDocument streams the stored file back with its detected
+// content type and checksum.
+func DownloadClientDocument(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	clientID, err1 := strconv.Atoi(params["clientId"])
+	id, err2 := strconv.Atoi(params["id"])
+	if err1 != nil || err2 != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	var doc models.ClientDocument
+	err := scanDocument(database.DB.QueryRow(`
+		SELECT `+documentColumns+`
+		FROM client_documents WHERE id = $1 AND client_id = $2
+	`, id, clientID), &doc)
+	if err != nil || DocumentStore == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Document not found"})
+		return
+	}
+
+	content, err := DocumentStore.Get(r.Context(), doc.StorageKey)
+	if err != nil {
+		if logger.APILogger != nil {
+			logger.APILogger.LogError(r.Method, r.URL.Path, "Failed to read document: "+err.Error())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Document content not found"})
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", doc.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(doc.SizeBytes, 10))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", doc.FileName))
+	w.Header().Set("X-Content-SHA256", doc.SHA256)
+	io.Copy(w, content)
+}
+
+// UpdateClientDocumentStatus records the outcome of a compliance review.
+func UpdateClientDocumentStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	params := mux.Vars(r)
+	clientID, err1 := strconv.Atoi(params["clientId"])
+	id, err2 := strconv.Atoi(params["id"])
+	if err1 != nil || err2 != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	var body struct {
+		Status models.DocumentStatus `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+		return
+	}
+
+	if body.Status != models.DocumentStatusPending &&
+		body.Status != models.DocumentStatusVerified &&
+		body.Status != models.DocumentStatusRejected {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid status. Must be PENDING, VERIFIED, or REJECTED"})
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	updated, err := updateAudited(tx, r, "client_documents", id, audit.OpUpdate,
+		"status = $2", "client_id = $3", body.Status, clientID)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Document not found"})
+		return
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update document"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(updated)
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
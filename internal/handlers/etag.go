@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"backend/internal/database"
+)
+
+// RequireIfMatch makes If-Match mandatory on PUT, DELETE and restore of
+// versioned resources. When false the header is honored but optional.
+var RequireIfMatch = false
+
+// entityTag formats a row version as a strong entity tag.
+func entityTag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+func setETag(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", entityTag(version))
+}
+
+// etagListMatches reports whether a comma-separated If-Match or If-None-Match
+// value contains the tag or "*". Weak tags are compared by their opaque value.
+func etagListMatches(header, tag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkNotModified answers a conditional GET with 304 when If-None-Match
+// matches the current version. It returns true when the response is done.
+func checkNotModified(w http.ResponseWriter, r *http.Request, version int) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" || !etagListMatches(header, entityTag(version)) {
+		return false
+	}
+	setETag(w, version)
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// expectedVersion reads If-Match. It returns nil when any version is
+// acceptable. ok is false, with the error response already written, when the
+// header is required but missing or cannot match any version.
+func expectedVersion(w http.ResponseWriter, r *http.Request) (version *int, ok bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		if RequireIfMatch {
+			w.WriteHeader(http.StatusPreconditionRequired)
+			json.NewEncoder(w).Encode(map[string]string{"error": "If-Match header is required"})
+			return nil, false
+		}
+		return nil, true
+	}
+	if header == "*" {
+		return nil, true
+	}
+
+	// A single strong tag is the only form that can match one row version
+	value, err := strconv.Atoi(strings.Trim(header, `"`))
+	if err != nil || strings.Contains(header, ",") || strings.HasPrefix(header, "W/") {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "If-Match does not match the current version"})
+		return nil, false
+	}
+	return &value, true
+}
+
+// writeUpdateMiss explains why a versioned UPDATE affected no rows: the row's
+// version moved on (412) or the row does not exist (404).
+func writeUpdateMiss(w http.ResponseWriter, table string, id int, expected *int, notFound string) {
+	writeVersionMiss(w, table, "deleted_at IS NULL", id, expected, notFound)
+}
+
+// writeVersionMiss is writeUpdateMiss for rows matching scope, such as the
+// soft-deleted rows a restore applies to.
+func writeVersionMiss(w http.ResponseWriter, table, scope string, id int, expected *int, notFound string) {
+	if expected != nil {
+		var version int
+		err := database.DB.QueryRow("SELECT version FROM "+table+" WHERE id = $1 AND "+scope, id).Scan(&version)
+		if err == nil && version != *expected {
+			setETag(w, version)
+			w.WriteHeader(http.StatusPreconditionFailed)
+			json.NewEncoder(w).Encode(map[string]string{"error": "If-Match does not match the current version"})
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(map[string]string{"error": notFound})
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"backend/internal/audit"
+	"backend/internal/models"
+	"backend/internal/outbox"
+)
+
+// domainEventTypes maps an audited resource and operation to the domain event
+// written to the outbox.
+var domainEventTypes = map[string]map[string]string{
+	"client": {
+		audit.OpCreate:  outbox.ClientCreated,
+		audit.OpUpdate:  outbox.ClientUpdated,
+		audit.OpDelete:  outbox.ClientDeleted,
+		audit.OpRestore: outbox.ClientRestored,
+		audit.OpErase:   outbox.ClientErased,
+		audit.OpMerge:   outbox.ClientMerged,
+	},
+	"bank": {
+		audit.OpCreate:  outbox.BankCreated,
+		audit.OpUpdate:  outbox.BankUpdated,
+		audit.OpDelete:  outbox.BankDeleted,
+		audit.OpRestore: outbox.BankRestored,
+	},
+	"credit": {
+		audit.OpCreate:  outbox.CreditCreated,
+		audit.OpUpdate:  outbox.CreditUpdated,
+		audit.OpDelete:  outbox.CreditDeleted,
+		audit.OpRestore: outbox.CreditRestored,
+	},
+	"client_document": {
+		audit.OpCreate: outbox.DocumentUploaded,
+		audit.OpUpdate: outbox.DocumentStatusChanged,
+		audit.OpDelete: outbox.DocumentDeleted,
+	},
+	"financial_profile": {
+		audit.OpCreate: outbox.FinancialProfileUpdated,
+	},
+}
+
+// creditStatusChange is the payload of CreditStatusChanged.
+type creditStatusChange struct {
+	models.Credit
+	PreviousStatus models.CreditStatus `json:"previous_status"`
+}
+
+// enqueueDomainEvents writes the domain events for an audited change to the
+// outbox. The payload is the resource after the change, or before it when the
+// resource is gone, with the same fields redacted as in the audit log.
+func enqueueDomainEvents(tx *sql.Tx, resourceType string, resourceID int, operation string, before, after interface{}) error {
+	eventType, ok := domainEventTypes[resourceType][operation]
+	if !ok {
+		return nil
+	}
+	// A merge is announced once, on the surviving client
+	if operation == audit.OpMerge && after == nil {
+		return nil
+	}
+
+	// Documents only announce updates that change their status; moving one
+	// to another client in a merge is covered by ClientMerged
+	if previous, ok := before.(models.ClientDocument); ok && operation == audit.OpUpdate {
+		if current, ok := after.(models.ClientDocument); ok && previous.Status == current.Status {
+			return nil
+		}
+	}
+
+	state := after
+	if state == nil {
+		state = before
+	}
+	if err := enqueueEvent(tx, eventType, resourceType, resourceID, state); err != nil {
+		return err
+	}
+
+	if operation == audit.OpUpdate {
+		previous, ok1 := before.(models.Credit)
+		current, ok2 := after.(models.Credit)
+		if ok1 && ok2 && previous.Status != current.Status {
+			return enqueueEvent(tx, outbox.CreditStatusChanged, resourceType, resourceID,
+				creditStatusChange{Credit: current, PreviousStatus: previous.Status})
+		}
+	}
+	return nil
+}
+
+func enqueueEvent(tx *sql.Tx, eventType, resourceType string, resourceID int, payload interface{}) error {
+	snapshot, err := audit.Snapshot(payload, auditRedactedFields[resourceType])
+	if err != nil {
+		return err
+	}
+	return outbox.Enqueue(tx, eventType, resourceType, resourceID, json.RawMessage(snapshot))
+}
@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"backend/internal/database"
+	"backend/internal/export"
+	"backend/internal/logger"
+	"backend/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// expandBatchSize is the number of list rows whose related objects are
+// loaded with one query.
+const expandBatchSize = 500
+
+// expandedCredit is a credit with the related objects asked for with
+// ?expand=client,bank.
+type expandedCredit struct {
+	models.Credit
+	Client *models.Client `json:"client,omitempty"`
+	Bank   *models.Bank   `json:"bank,omitempty"`
+}
+
+// clientWithCredits and bankWithCredits answer ?expand=credits.
+type clientWithCredits struct {
+	models.Client
+	Credits []models.Credit `json:"credits"`
+}
+
+type bankWithCredits struct {
+	models.Bank
+	Credits []models.Credit `json:"credits"`
+}
+
+// parseExpand reads the comma-separated expand query parameter and checks it
+// against the relations the resource has. It writes a 400 response for an
+// unknown relation.
+func parseExpand(w http.ResponseWriter, r *http.Request, allowed ...string) (map[string]bool, bool) {
+	expand := map[string]bool{}
+	value := r.URL.Query().Get("expand")
+	if value == "" {
+		return expand, true
+	}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		known := false
+		for _, relation := range allowed {
+			known = known || name == relation
+		}
+		if !known {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid expand " + name + ". Must be one of: " + strings.Join(allowed, ", "),
+			})
+			return nil, false
+		}
+		expand[name] = true
+	}
+	return expand, true
+}
+
+func uniqueIDs(ids []int) []int {
+	seen := map[int]bool{}
+	unique := []int{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// loadClients fetches clients by id with one query. Deleted clients are
+// included so a credit can always show who it belongs to.
+func loadClients(ids []int) (map[int]models.Client, error) {
+	clients := map[int]models.Client{}
+	rows, err := database.DB.Query("SELECT "+clientColumns+" FROM clients WHERE id = ANY($1)", pq.Array(uniqueIDs(ids)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var client models.Client
+		if err := scanClient(rows, &client); err != nil {
+			return nil, err
+		}
+		clients[client.ID] = client
+	}
+	return clients, rows.Err()
+}
+
+// loadBanks fetches banks by id with one query, including deleted ones.
+func loadBanks(ids []int) (map[int]models.Bank, error) {
+	banks := map[int]models.Bank{}
+	rows, err := database.DB.Query("SELECT "+bankColumns+" FROM banks WHERE id = ANY($1)", pq.Array(uniqueIDs(ids)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var bank models.Bank
+		if err := scanBank(rows, &bank); err != nil {
+			return nil, err
+		}
+		banks[bank.ID] = bank
+	}
+	return banks, rows.Err()
+}
+
+// loadCreditsBy fetches the credits of several clients or banks with one
+// query, grouped by the owner column (client_id or bank_id). Every owner gets
+// a non-nil list.
+func loadCreditsBy(owner string, ids []int, withDeleted bool) (map[int][]models.Credit, error) {
+	credits := map[int][]models.Credit{}
+	for _, id := range ids {
+		credits[id] = []models.Credit{}
+	}
+	rows, err := database.DB.Query(`
+		SELECT `+creditColumns+`
+		FROM credits
+		WHERE `+owner+` = ANY($1) AND ($2 OR deleted_at IS NULL)
+		ORDER BY created_at DESC
+	`, pq.Array(uniqueIDs(ids)), withDeleted)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var credit models.Credit
+		if err := scanCredit(rows, &credit); err != nil {
+			return nil, err
+		}
+		key := credit.ClientID
+		if owner == "bank_id" {
+			key = credit.BankID
+		}
+		credits[key] = append(credits[key], credit)
+	}
+	return credits, rows.Err()
+}
+
+// expandCredits attaches the clients and banks asked for in expand, with at
+// most one query per relation.
+func expandCredits(credits []models.Credit, expand map[string]bool) ([]expandedCredit, error) {
+	expanded := make([]expandedCredit, len(credits))
+	clientIDs := make([]int, len(credits))
+	bankIDs := make([]int, len(credits))
+	for i, credit := range credits {
+		expanded[i].Credit = credit
+		clientIDs[i] = credit.ClientID
+		bankIDs[i] = credit.BankID
+	}
+	if len(credits) == 0 {
+		return expanded, nil
+	}
+
+	if expand["client"] {
+		clients, err := loadClients(clientIDs)
+		if err != nil {
+			return nil, err
+		}
+		for i := range expanded {
+			if client, ok := clients[expanded[i].ClientID]; ok {
+				expanded[i].Client = &client
+			}
+		}
+	}
+	if expand["bank"] {
+		banks, err := loadBanks(bankIDs)
+		if err != nil {
+			return nil, err
+		}
+		for i := range expanded {
+			if bank, ok := banks[expanded[i].BankID]; ok {
+				expanded[i].Bank = &bank
+			}
+		}
+	}
+	return expanded, nil
+}
+
+// creditExpandKeys lists the credit fields expandCredits reads to find the
+// related objects, so a field set still selects them.
+func creditExpandKeys(expand map[string]bool) []string {
+	var keys []string
+	if expand["client"] {
+		keys = append(keys, "client_id")
+	}
+	if expand["bank"] {
+		keys = append(keys, "bank_id")
+	}
+	return keys
+}
+
+// writeCredits streams the credits in rows as a list in format, limited to
+// fields. When relations are expanded, rows are read in chunks of
+// expandBatchSize so each chunk needs one query per relation instead of one
+// per credit.
+func writeCredits(w http.ResponseWriter, r *http.Request, format string, rows *sql.Rows, expand, fields map[string]bool) {
+	var sample interface{} = models.Credit{}
+	if len(expand) > 0 {
+		sample = expandedCredit{}
+	}
+	fields = withRelations(fields, expand)
+	list := newListWriter(w, format, "credits", export.Select(sample, fields))
+	defer list.Close()
+
+	chunk := []models.Credit{}
+	flush := func() bool {
+		expanded, err := expandCredits(chunk, expand)
+		if err != nil {
+			// Rows may already be out, so the list is cut short
+			if logger.APILogger != nil {
+				logger.APILogger.LogError(r.Method, r.URL.Path, "Failed to expand credits: "+err.Error())
+			}
+			return false
+		}
+		for _, credit := range expanded {
+			if err := list.Write(export.Select(credit, fields)); err != nil {
+				return false
+			}
+		}
+		chunk = chunk[:0]
+		return true
+	}
+
+	for rows.Next() {
+		var credit models.Credit
+		if err := scanCredit(rows, &credit); err != nil {
+			continue
+		}
+		if len(expand) == 0 {
+			if err := list.Write(export.Select(credit, fields)); err != nil {
+				return
+			}
+			continue
+		}
+		chunk = append(chunk, credit)
+		if len(chunk) == expandBatchSize && !flush() {
+			return
+		}
+	}
+	flush()
+}
+
+// writeBanks streams the banks in rows as a list in format, limited to
+// fields, with their credits when expand asks for them.
+func writeBanks(w http.ResponseWriter, r *http.Request, format string, rows *sql.Rows, expand, fields map[string]bool) {
+	var sample interface{} = models.Bank{}
+	if expand["credits"] {
+		sample = bankWithCredits{}
+	}
+	fields = withRelations(fields, expand)
+	list := newListWriter(w, format, "banks", export.Select(sample, fields))
+	defer list.Close()
+
+	chunk := []models.Bank{}
+	flush := func() bool {
+		if len(chunk) == 0 {
+			return true
+		}
+		ids := make([]int, len(chunk))
+		for i, bank := range chunk {
+			ids[i] = bank.ID
+		}
+		credits, err := loadCreditsBy("bank_id", ids, includeDeleted(r))
+		if err != nil {
+			if logger.APILogger != nil {
+				logger.APILogger.LogError(r.Method, r.URL.Path, "Failed to expand banks: "+err.Error())
+			}
+			return false
+		}
+		for _, bank := range chunk {
+			if err := list.Write(export.Select(bankWithCredits{Bank: bank, Credits: credits[bank.ID]}, fields)); err != nil {
+				return false
+			}
+		}
+		chunk = chunk[:0]
+		return true
+	}
+
+	for rows.Next() {
+		var bank models.Bank
+		if err := scanBank(rows, &bank); err != nil {
+			continue
+		}
+		if !expand["credits"] {
+			if err := list.Write(export.Select(bank, fields)); err != nil {
+				return
+			}
+			continue
+		}
+		chunk = append(chunk, bank)
+		if len(chunk) == expandBatchSize && !flush() {
+			return
+		}
+	}
+	flush()
+}
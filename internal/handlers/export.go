@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"backend/internal/export"
+)
+
+// StreamedWriteTimeout bounds each write of a response that may take longer
+// than the server's write timeout as a whole, such as a large list export or
+// an import run within the request. The server sets it to its write timeout.
+var StreamedWriteTimeout = 2 * time.Minute
+
+// listFormat returns the format requested for a list response, from the
+// format query parameter or the Accept header, defaulting to JSON. It writes
+// a 400 response for an unknown format.
+func listFormat(w http.ResponseWriter, r *http.Request) (string, bool) {
+	format, ok := export.Negotiate(r.URL.Query().Get("format"), r.Header.Get("Accept"))
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid format. Must be json, csv or ndjson"})
+	}
+	return format, ok
+}
+
+// newListWriter sets the content type of format and returns a writer that
+// streams the list, so rows go out as they are read from the database. CSV
+// lists are offered as a download named after the list, with the columns of
+// sample.
+func newListWriter(w http.ResponseWriter, format, name string, sample interface{}) export.Writer {
+	w.Header().Set("Content-Type", export.ContentTypes[format])
+	if format == export.FormatCSV {
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.csv"`)
+	}
+	return export.NewWriter(&deadlineWriter{w: w, controller: http.NewResponseController(w)}, format, sample)
+}
+
+// deadlineWriter moves the write deadline StreamedWriteTimeout ahead before
+// every write, so a long list keeps streaming while a client that stops
+// reading is still dropped.
+type deadlineWriter struct {
+	w          http.ResponseWriter
+	controller *http.ResponseController
+}
+
+func (d *deadlineWriter) Write(p []byte) (int, error) {
+	extendWriteDeadline(d.controller)
+	return d.w.Write(p)
+}
+
+// extendWriteDeadline gives the response another StreamedWriteTimeout from
+// now. Writers that cannot set deadlines, such as test recorders, are left
+// alone.
+func extendWriteDeadline(controller *http.ResponseController) {
+	controller.SetWriteDeadline(time.Now().Add(StreamedWriteTimeout))
+}
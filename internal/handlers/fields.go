@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"backend/internal/export"
+)
+
+// Zero values selected in place of columns a field set leaves out.
+const (
+	zeroNumber = "0"
+	zeroText   = "''"
+	zeroBool   = "false"
+	zeroNull   = "NULL"
+	zeroTime   = "'epoch'::timestamptz"
+)
+
+// fieldColumn ties a selected column to the JSON field it is read into. When
+// a field set leaves the field out, zero is selected in place of the column,
+// so the resource's scan function reads the same row shape without loading
+// the value. Columns without a field are never part of a response and are
+// only loaded when every field is.
+type fieldColumn struct {
+	field  string
+	column string
+	zero   string
+}
+
+type fieldColumns []fieldColumn
+
+// all lists every column, for queries that read whole rows.
+func (c fieldColumns) all() string {
+	return c.selecting(nil)
+}
+
+// selecting lists the columns of fields, plus the ones of needed fields the
+// handler uses itself, such as version for the ETag. A nil fields selects
+// every column.
+func (c fieldColumns) selecting(fields map[string]bool, needed ...string) string {
+	list := make([]string, len(c))
+	for i, col := range c {
+		list[i] = col.column
+		if fields == nil || fields[col.field] {
+			continue
+		}
+		wanted := false
+		for _, field := range needed {
+			wanted = wanted || col.field == field
+		}
+		if !wanted {
+			list[i] = col.zero
+		}
+	}
+	return strings.Join(list, ", ")
+}
+
+// parseFields reads the comma-separated fields query parameter and checks it
+// against the JSON fields of model. It returns nil when the parameter is
+// absent, meaning every field, and writes a 400 response for an unknown
+// field.
+func parseFields(w http.ResponseWriter, r *http.Request, model interface{}) (map[string]bool, bool) {
+	value := r.URL.Query().Get("fields")
+	if value == "" {
+		return nil, true
+	}
+	allowed := export.FieldNames(model)
+	fields := map[string]bool{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		known := false
+		for _, field := range allowed {
+			known = known || name == field
+		}
+		if !known {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid field " + name + ". Must be one of: " + strings.Join(allowed, ", "),
+			})
+			return nil, false
+		}
+		fields[name] = true
+	}
+	return fields, true
+}
+
+// withRelations adds the expanded relations to a field set, so embedded
+// objects are returned alongside the fields asked for.
+func withRelations(fields, expand map[string]bool) map[string]bool {
+	if fields == nil {
+		return nil
+	}
+	combined := map[string]bool{}
+	for name := range fields {
+		combined[name] = true
+	}
+	for name := range expand {
+		combined[name] = true
+	}
+	return combined
+}
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"backend/internal/audit"
+	"backend/internal/database"
+	"backend/internal/export"
+	"backend/internal/logger"
+	"backend/internal/models"
+	"github.com/gorilla/mux"
+)
+
+// DTIThreshold is the highest debt-to-income ratio accepted for a new credit.
+// DTIRejectAboveThreshold selects whether credits above it are rejected or
+// stored with dti_flagged set. Both are configured from main.
+var (
+	DTIThreshold            = 0.43
+	DTIRejectAboveThreshold = false
+)
+
+var (
+	errNoFinancialProfile = errors.New("client has no financial profile")
+	errNoMonthlyIncome    = errors.New("client has no declared monthly income")
+)
+
+var financialProfileFields = fieldColumns{
+	{"id", "id", zeroNumber},
+	{"client_id", "client_id", zeroNumber},
+	{"version", "version", zeroNumber},
+	{"monthly_income", "monthly_income", zeroNumber},
+	{"employment_type", "employment_type", zeroText},
+	{"external_debts", "external_debts", zeroNumber},
+	{"created_at", "created_at", zeroTime},
+}
+
+var financialProfileColumns = financialProfileFields.all()
+
+func scanFinancialProfile(row rowScanner, profile *models.FinancialProfile) error {
+	return row.Scan(&profile.ID, &profile.ClientID, &profile.Version, &profile.MonthlyIncome,
+		&profile.EmploymentType, &profile.ExternalDebts, &profile.CreatedAt)
+}
+
+func validateFinancialProfile(profile models.FinancialProfile) string {
+	if profile.EmploymentType != models.EmploymentTypeEmployed &&
+		profile.EmploymentType != models.EmploymentTypeSelfEmployed &&
+		profile.EmploymentType != models.EmploymentTypeUnemployed &&
+		profile.EmploymentType != models.EmploymentTypeRetired {
+		return "Invalid employment type. Must be EMPLOYED, SELF_EMPLOYED, UNEMPLOYED, or RETIRED"
+	}
+	if profile.MonthlyIncome < 0 || profile.ExternalDebts < 0 {
+		return "Monthly income and external debts must not be negative"
+	}
+	return ""
+}
+
+// maxDebtToIncome is the largest ratio credits.debt_to_income stores. A tiny
+// declared income can give any ratio; past this one it is flagged anyway.
+const maxDebtToIncome = 99999999.9999
+
+// computeDebtToIncome returns the share of monthly income consumed by external
+// debts, open credits and the requested payment, capped at maxDebtToIncome.
+func computeDebtToIncome(monthlyIncome, externalDebts, openCreditPayments, newPayment float64) (float64, error) {
+	if monthlyIncome <= 0 {
+		return 0, errNoMonthlyIncome
+	}
+	return math.Min((externalDebts+openCreditPayments+newPayment)/monthlyIncome, maxDebtToIncome), nil
+}
+
+// assessDebtToIncome computes the debt-to-income ratio of a client taking on a
+// credit with the given maximum payment. Rejected credits are not counted, nor
+// is the stored row of creditID, which newPayment replaces; 0 is a new credit.
+func assessDebtToIncome(q queryRower, clientID, creditID int, newPayment float64) (float64, error) {
+	var profile models.FinancialProfile
+	err := scanFinancialProfile(q.QueryRow(`
+		SELECT `+financialProfileColumns+`
+		FROM client_financial_profiles
+		WHERE client_id = $1
+		ORDER BY version DESC
+		LIMIT 1
+	`, clientID), &profile)
+	if err == sql.ErrNoRows {
+		return 0, errNoFinancialProfile
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var openCreditPayments float64
+	err = q.QueryRow(`
+		SELECT COALESCE(SUM(max_payment), 0)
+		FROM credits
+		WHERE client_id = $1 AND status <> $2 AND deleted_at IS NULL AND id <> $3
+	`, clientID, models.CreditStatusRejected, creditID).Scan(&openCreditPayments)
+	if err != nil {
+		return 0, err
+	}
+
+	return computeDebtToIncome(profile.MonthlyIncome, profile.ExternalDebts, openCreditPayments, newPayment)
+}
+
+// Financial profile handlers
+
+func GetFinancialProfile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	params := mux.Vars(r)
+	clientID, err := strconv.Atoi(params["clientId"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid client ID"})
+		return
+	}
+	fields, ok := parseFields(w, r, models.FinancialProfile{})
+	if !ok {
+		return
+	}
+
+	var profile models.FinancialProfile
+	err = scanFinancialProfile(database.DB.QueryRow(`
+		SELECT `+financialProfileFields.selecting(fields)+`
+		FROM client_financial_profiles
+		WHERE client_id = $1
+		ORDER BY version DESC
+		LIMIT 1
+	`, clientID), &profile)
+
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Financial profile not found"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(export.Select(profile, fields))
+}
+
+func GetFinancialProfileHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	format, ok := listFormat(w, r)
+	if !ok {
+		return
+	}
+	fields, ok := parseFields(w, r, models.FinancialProfile{})
+	if !ok {
+		return
+	}
+	params := mux.Vars(r)
+	clientID, err := strconv.Atoi(params["clientId"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid client ID"})
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT `+financialProfileFields.selecting(fields)+`
+		FROM client_financial_profiles
+		WHERE client_id = $1
+		ORDER BY version DESC
+	`, clientID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	list := newListWriter(w, format, "financial-profiles", export.Select(models.FinancialProfile{}, fields))
+	for rows.Next() {
+		var profile models.FinancialProfile
+		if err := scanFinancialProfile(rows, &profile); err != nil {
+			continue
+		}
+		if err := list.Write(export.Select(profile, fields)); err != nil {
+			break
+		}
+	}
+
+	list.Close()
+}
+
+// UpdateFinancialProfile records a new version of the client's financial
+// profile. Previous versions are kept for history.
+func UpdateFinancialProfile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	params := mux.Vars(r)
+	clientID, err := strconv.Atoi(params["clientId"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid client ID"})
+		return
+	}
+
+	var profile models.FinancialProfile
+	if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+		return
+	}
+
+	if msg := validateFinancialProfile(profile); msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": msg})
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock the client so concurrent updates get consecutive versions
+	var lockedID int
+	err = tx.QueryRow("SELECT id FROM clients WHERE id = $1 FOR UPDATE", clientID).Scan(&lockedID)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Client not found"})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+
+	profile.ClientID = clientID
+	err = tx.QueryRow(`
+		INSERT INTO client_financial_profiles (client_id, version, monthly_income, employment_type, external_debts)
+		SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3, $4
+		FROM client_financial_profiles WHERE client_id = $1
+		RETURNING id, version, created_at
+	`, clientID, profile.MonthlyIncome, profile.EmploymentType, profile.ExternalDebts).
+		Scan(&profile.ID, &profile.Version, &profile.CreatedAt)
+	if err == nil {
+		err = recordChange(tx, r, "financial_profile", profile.ID, audit.OpCreate, nil, profile)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		if logger.APILogger != nil {
+			logger.APILogger.LogError(r.Method, r.URL.Path, "Failed to save financial profile: "+err.Error())
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to save financial profile"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(profile)
+}
+
+func dtiExceededMessage(ratio float64) string {
+	return fmt.Sprintf("Debt-to-income ratio %.4f exceeds the allowed threshold of %.4f", ratio, DTIThreshold)
+}
@@ -0,0 +1,636 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/internal/database"
+	"backend/internal/graphql"
+	"backend/internal/idempotency"
+	"backend/internal/logger"
+	"backend/internal/models"
+	"github.com/lib/pq"
+)
+
+// GraphQL lists return at most this many rows per page, and a query may
+// nest and select at most this many fields. GraphQLMaxCost bounds the fields
+// a query may resolve once page sizes multiply through nested lists.
+const (
+	GraphQLDefaultPageSize = 50
+	GraphQLMaxPageSize     = 500
+	GraphQLMaxDepth        = 10
+	GraphQLMaxFields       = 300
+	GraphQLMaxCost         = 100000
+)
+
+// GraphQL runs a GraphQL query or mutation. Mutations go through the REST
+// handlers.
+func GraphQL(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var req graphql.Request
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&req); err != nil || req.Query == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Request body must be a JSON object with a query"})
+		return
+	}
+	// Queries only read, and their results may carry client PII, so they are
+	// never kept for replay. Mutations are, like the REST calls they make.
+	if !req.Mutation() {
+		idempotency.Discard(r)
+	}
+
+	ctx := context.WithValue(r.Context(), graphqlRequestKey{}, r)
+	json.NewEncoder(w).Encode(graphqlSchema.Execute(ctx, req))
+}
+
+// GraphQLSchema serves the GraphQL schema in SDL.
+func GraphQLSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(graphqlSchema.SDL()))
+}
+
+type graphqlRequestKey struct{}
+
+// graphqlDatabaseError logs a failed query and hides its details from the
+// caller, as the REST handlers do.
+func graphqlDatabaseError(ctx context.Context, err error) error {
+	if r, ok := ctx.Value(graphqlRequestKey{}).(*http.Request); ok && logger.APILogger != nil {
+		logger.APILogger.LogError(r.Method, r.URL.Path, "GraphQL database error: "+err.Error())
+	}
+	return &graphql.Error{Message: "Database error", Extensions: map[string]interface{}{"status": http.StatusInternalServerError}}
+}
+
+// graphqlPage is one page of a list; NextCursor is the after argument for the
+// next page, or nil on the last one.
+type graphqlPage struct {
+	Items      interface{}
+	NextCursor *int
+}
+
+var graphqlSchema = newGraphQLSchema()
+
+func newGraphQLSchema() *graphql.Schema {
+	dateTime := &graphql.Scalar{
+		Name:        "DateTime",
+		Description: "An RFC 3339 timestamp.",
+		Serialize: func(v interface{}) (interface{}, error) {
+			t, ok := v.(time.Time)
+			if !ok {
+				return nil, fmt.Errorf("%v is not a time", v)
+			}
+			return t.Format(time.RFC3339Nano), nil
+		},
+		Parse: func(v interface{}) (interface{}, error) {
+			s, _ := v.(string)
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return nil, fmt.Errorf("expected an RFC 3339 timestamp")
+			}
+			return t, nil
+		},
+	}
+	bankType := &graphql.Enum{Name: "BankType", Values: []string{
+		string(models.BankTypePrivate), string(models.BankTypeGovernment),
+	}}
+	creditType := &graphql.Enum{Name: "CreditType", Values: []string{
+		string(models.CreditTypeAuto), string(models.CreditTypeMortgage), string(models.CreditTypeCommercial),
+	}}
+	creditStatus := &graphql.Enum{Name: "CreditStatus", Values: []string{
+		string(models.CreditStatusPending), string(models.CreditStatusApproved), string(models.CreditStatusRejected),
+	}}
+
+	id := &graphql.NonNull{Of: graphql.ID}
+	str := &graphql.NonNull{Of: graphql.String}
+	integer := &graphql.NonNull{Of: graphql.Int}
+	number := &graphql.NonNull{Of: graphql.Float}
+	boolean := &graphql.NonNull{Of: graphql.Boolean}
+	timestamp := &graphql.NonNull{Of: dateTime}
+	withDeleted := &graphql.Argument{Name: "includeDeleted", Type: graphql.Boolean, Default: false}
+
+	page := func(of *graphql.Object) *graphql.NonNull {
+		return &graphql.NonNull{Of: &graphql.Object{Name: of.Name + "Page", Fields: []*graphql.Field{
+			{Name: "items", Type: &graphql.NonNull{Of: &graphql.List{Of: &graphql.NonNull{Of: of}}}},
+			{Name: "nextCursor", Type: graphql.ID, Description: "Pass as after to get the next page; null on the last page."},
+		}}}
+	}
+	first := &graphql.Argument{Name: "first", Type: graphql.Int, Default: GraphQLDefaultPageSize}
+	after := &graphql.Argument{Name: "after", Type: graphql.ID}
+
+	client := &graphql.Object{Name: "Client"}
+	bank := &graphql.Object{Name: "Bank"}
+	credit := &graphql.Object{Name: "Credit"}
+	credits := page(credit)
+	creditsArgs := []*graphql.Argument{first, after, withDeleted}
+
+	client.Fields = []*graphql.Field{
+		{Name: "id", Type: id},
+		{Name: "fullName", Type: str},
+		{Name: "email", Type: str},
+		{Name: "birthDate", Type: timestamp},
+		{Name: "country", Type: str},
+		{Name: "erasedAt", Type: dateTime},
+		{Name: "deletedAt", Type: dateTime},
+		{Name: "version", Type: integer},
+		{Name: "createdAt", Type: timestamp},
+		{Name: "credits", Type: credits, Args: creditsArgs, SizeArg: "first", Batch: func(p graphql.BatchParams) ([]interface{}, error) {
+			ids := make([]int, len(p.Sources))
+			for i, source := range p.Sources {
+				ids[i] = source.(models.Client).ID
+			}
+			return batchCredits(p, "client_id", ids)
+		}},
+	}
+	bank.Fields = []*graphql.Field{
+		{Name: "id", Type: id},
+		{Name: "name", Type: str},
+		{Name: "type", Type: &graphql.NonNull{Of: bankType}},
+		{Name: "deletedAt", Type: dateTime},
+		{Name: "version", Type: integer},
+		{Name: "createdAt", Type: timestamp},
+		{Name: "credits", Type: credits, Args: creditsArgs, SizeArg: "first", Batch: func(p graphql.BatchParams) ([]interface{}, error) {
+			ids := make([]int, len(p.Sources))
+			for i, source := range p.Sources {
+				ids[i] = source.(models.Bank).ID
+			}
+			return batchCredits(p, "bank_id", ids)
+		}},
+	}
+	credit.Fields = []*graphql.Field{
+		{Name: "id", Type: id},
+		{Name: "clientId", Type: id},
+		{Name: "bankId", Type: id},
+		{Name: "minPayment", Type: number},
+		{Name: "maxPayment", Type: number},
+		{Name: "termMonths", Type: integer},
+		{Name: "creditType", Type: &graphql.NonNull{Of: creditType}},
+		{Name: "status", Type: &graphql.NonNull{Of: creditStatus}},
+		{Name: "debtToIncome", Type: graphql.Float},
+		{Name: "dtiFlagged", Type: boolean},
+		{Name: "deletedAt", Type: dateTime},
+		{Name: "version", Type: integer},
+		{Name: "createdAt", Type: timestamp},
+		{Name: "client", Type: &graphql.NonNull{Of: client}, Batch: func(p graphql.BatchParams) ([]interface{}, error) {
+			ids := make([]int, len(p.Sources))
+			for i, source := range p.Sources {
+				ids[i] = source.(models.Credit).ClientID
+			}
+			clients, err := loadClients(ids)
+			if err != nil {
+				return nil, graphqlDatabaseError(p.Context, err)
+			}
+			values := make([]interface{}, len(ids))
+			for i, id := range ids {
+				if c, ok := clients[id]; ok {
+					values[i] = c
+				}
+			}
+			return values, nil
+		}},
+		{Name: "bank", Type: &graphql.NonNull{Of: bank}, Batch: func(p graphql.BatchParams) ([]interface{}, error) {
+			ids := make([]int, len(p.Sources))
+			for i, source := range p.Sources {
+				ids[i] = source.(models.Credit).BankID
+			}
+			banks, err := loadBanks(ids)
+			if err != nil {
+				return nil, graphqlDatabaseError(p.Context, err)
+			}
+			values := make([]interface{}, len(ids))
+			for i, id := range ids {
+				if b, ok := banks[id]; ok {
+					values[i] = b
+				}
+			}
+			return values, nil
+		}},
+	}
+
+	listArgs := func(filter *graphql.InputObject) []*graphql.Argument {
+		return []*graphql.Argument{{Name: "filter", Type: filter}, first, after}
+	}
+	lookupArgs := []*graphql.Argument{{Name: "id", Type: id}, withDeleted}
+
+	query := &graphql.Object{Name: "Query", Fields: []*graphql.Field{
+		{Name: "client", Type: client, Args: lookupArgs, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return lookupRow(p, "clients", clientColumns, func(row rowScanner) (interface{}, error) {
+				var c models.Client
+				err := scanClient(row, &c)
+				return c, err
+			})
+		}},
+		{Name: "bank", Type: bank, Args: lookupArgs, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return lookupRow(p, "banks", bankColumns, func(row rowScanner) (interface{}, error) {
+				var b models.Bank
+				err := scanBank(row, &b)
+				return b, err
+			})
+		}},
+		{Name: "credit", Type: credit, Args: lookupArgs, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return lookupRow(p, "credits", creditColumns, func(row rowScanner) (interface{}, error) {
+				var c models.Credit
+				err := scanCredit(row, &c)
+				return c, err
+			})
+		}},
+		{Name: "clients", Type: page(client), SizeArg: "first", Args: listArgs(&graphql.InputObject{Name: "ClientFilter", Fields: []*graphql.Argument{
+			{Name: "country", Type: graphql.String},
+			withDeleted,
+		}}), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			filter, _ := p.Args["filter"].(map[string]interface{})
+			return listPage(p, "clients", clientColumns, "($3::text IS NULL OR country = $3)", []interface{}{filter["country"]},
+				func(rows rowScanner) (interface{}, int, error) {
+					var c models.Client
+					err := scanClient(rows, &c)
+					return c, c.ID, err
+				})
+		}},
+		{Name: "banks", Type: page(bank), SizeArg: "first", Args: listArgs(&graphql.InputObject{Name: "BankFilter", Fields: []*graphql.Argument{
+			{Name: "type", Type: bankType},
+			withDeleted,
+		}}), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			filter, _ := p.Args["filter"].(map[string]interface{})
+			return listPage(p, "banks", bankColumns, "($3::text IS NULL OR type = $3)", []interface{}{filter["type"]},
+				func(rows rowScanner) (interface{}, int, error) {
+					var b models.Bank
+					err := scanBank(rows, &b)
+					return b, b.ID, err
+				})
+		}},
+		{Name: "credits", Type: credits, SizeArg: "first", Args: listArgs(&graphql.InputObject{Name: "CreditFilter", Fields: []*graphql.Argument{
+			{Name: "clientId", Type: graphql.ID},
+			{Name: "bankId", Type: graphql.ID},
+			{Name: "status", Type: creditStatus},
+			{Name: "creditType", Type: creditType},
+			withDeleted,
+		}}), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			filter, _ := p.Args["filter"].(map[string]interface{})
+			var clientID, bankID interface{}
+			if filter["clientId"] != nil {
+				id, err := graphqlID(filter["clientId"])
+				if err != nil {
+					return nil, err
+				}
+				clientID = id
+			}
+			if filter["bankId"] != nil {
+				id, err := graphqlID(filter["bankId"])
+				if err != nil {
+					return nil, err
+				}
+				bankID = id
+			}
+			return listPage(p, "credits", creditColumns, creditFilter, []interface{}{clientID, bankID, filter["status"], filter["creditType"]},
+				func(rows rowScanner) (interface{}, int, error) {
+					var c models.Credit
+					err := scanCredit(rows, &c)
+					return c, c.ID, err
+				})
+		}},
+	}}
+
+	clientInput := &graphql.InputObject{Name: "ClientInput", Fields: []*graphql.Argument{
+		{Name: "fullName", Type: str},
+		{Name: "email", Type: str},
+		{Name: "birthDate", Type: timestamp},
+		{Name: "country", Type: str},
+	}}
+	bankInput := &graphql.InputObject{Name: "BankInput", Fields: []*graphql.Argument{
+		{Name: "name", Type: str},
+		{Name: "type", Type: &graphql.NonNull{Of: bankType}},
+	}}
+	creditInput := &graphql.InputObject{Name: "CreditInput", Fields: []*graphql.Argument{
+		{Name: "clientId", Type: id},
+		{Name: "bankId", Type: id},
+		{Name: "minPayment", Type: number},
+		{Name: "maxPayment", Type: number},
+		{Name: "termMonths", Type: integer},
+		{Name: "creditType", Type: &graphql.NonNull{Of: creditType}},
+		{Name: "status", Type: creditStatus, Description: "Required when updating; new credits start PENDING."},
+	}}
+	clientBody := func(p graphql.ResolveParams) (interface{}, error) {
+		input := p.Args["input"].(map[string]interface{})
+		return models.Client{
+			FullName:  input["fullName"].(string),
+			Email:     input["email"].(string),
+			BirthDate: input["birthDate"].(time.Time),
+			Country:   input["country"].(string),
+		}, nil
+	}
+	bankBody := func(p graphql.ResolveParams) (interface{}, error) {
+		input := p.Args["input"].(map[string]interface{})
+		return models.Bank{Name: input["name"].(string), Type: models.BankType(input["type"].(string))}, nil
+	}
+	creditBody := func(p graphql.ResolveParams) (interface{}, error) {
+		input := p.Args["input"].(map[string]interface{})
+		clientID, err := graphqlID(input["clientId"])
+		if err != nil {
+			return nil, err
+		}
+		bankID, err := graphqlID(input["bankId"])
+		if err != nil {
+			return nil, err
+		}
+		status, _ := input["status"].(string)
+		return models.Credit{
+			ClientID:   clientID,
+			BankID:     bankID,
+			MinPayment: input["minPayment"].(float64),
+			MaxPayment: input["maxPayment"].(float64),
+			TermMonths: input["termMonths"].(int),
+			CreditType: models.CreditType(input["creditType"].(string)),
+			Status:     models.CreditStatus(status),
+		}, nil
+	}
+
+	mutation := &graphql.Object{Name: "Mutation"}
+	for _, resource := range []struct {
+		name, path string
+		object     *graphql.Object
+		input      *graphql.InputObject
+		body       func(graphql.ResolveParams) (interface{}, error)
+		model      func() interface{}
+		load       func(id int) (interface{}, error)
+		create     http.HandlerFunc
+		update     http.HandlerFunc
+		remove     http.HandlerFunc
+		restore    http.HandlerFunc
+	}{
+		{"Client", "/api/clients", client, clientInput, clientBody, func() interface{} { return &models.Client{} },
+			func(id int) (interface{}, error) {
+				clients, err := loadClients([]int{id})
+				return clients[id], err
+			}, CreateClient, UpdateClient, DeleteClient, RestoreClient},
+		{"Bank", "/api/banks", bank, bankInput, bankBody, func() interface{} { return &models.Bank{} },
+			func(id int) (interface{}, error) {
+				banks, err := loadBanks([]int{id})
+				return banks[id], err
+			}, CreateBank, UpdateBank, DeleteBank, RestoreBank},
+		{"Credit", "/api/credits", credit, creditInput, creditBody, func() interface{} { return &models.Credit{} },
+			func(id int) (interface{}, error) {
+				var c models.Credit
+				err := scanCredit(database.DB.QueryRow("SELECT "+creditColumns+" FROM credits WHERE id = $1", id), &c)
+				return c, err
+			}, CreateCredit, UpdateCredit, DeleteCredit, RestoreCredit},
+	} {
+		resource := resource
+		version := &graphql.Argument{Name: "version", Type: graphql.Int, Description: "Apply the change only at this version."}
+		inputArg := &graphql.Argument{Name: "input", Type: &graphql.NonNull{Of: resource.input}}
+		idArg := &graphql.Argument{Name: "id", Type: id}
+		result := &graphql.NonNull{Of: resource.object}
+
+		mutation.Fields = append(mutation.Fields,
+			&graphql.Field{Name: "create" + resource.name, Type: result, Args: []*graphql.Argument{inputArg},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					body, err := resource.body(p)
+					if err != nil {
+						return nil, err
+					}
+					return forwardGraphQL(p, resource.create, "POST", resource.path, nil, body, resource.model())
+				}},
+			&graphql.Field{Name: "update" + resource.name, Type: result, Args: []*graphql.Argument{idArg, inputArg, version},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					body, err := resource.body(p)
+					if err != nil {
+						return nil, err
+					}
+					return forwardGraphQL(p, resource.update, "PUT", resource.path+"/{id}", p.Args["version"], body, resource.model())
+				}},
+			&graphql.Field{Name: "delete" + resource.name, Type: boolean, Args: []*graphql.Argument{idArg, version},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if _, err := forwardGraphQL(p, resource.remove, "DELETE", resource.path+"/{id}", p.Args["version"], nil, nil); err != nil {
+						return nil, err
+					}
+					return true, nil
+				}},
+			&graphql.Field{Name: "restore" + resource.name, Type: result, Args: []*graphql.Argument{idArg, version},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if _, err := forwardGraphQL(p, resource.restore, "POST", resource.path+"/{id}/restore", p.Args["version"], nil, nil); err != nil {
+						return nil, err
+					}
+					id, _ := graphqlID(p.Args["id"])
+					row, err := resource.load(id)
+					if err != nil {
+						return nil, graphqlDatabaseError(p.Context, err)
+					}
+					return row, nil
+				}},
+		)
+	}
+
+	return &graphql.Schema{Query: query, Mutation: mutation, MaxDepth: GraphQLMaxDepth, MaxFields: GraphQLMaxFields,
+		MaxCost: GraphQLMaxCost}
+}
+
+// graphqlID converts an ID argument to a row id.
+func graphqlID(v interface{}) (int, error) {
+	id, err := strconv.Atoi(fmt.Sprint(v))
+	if err != nil || id <= 0 {
+		return 0, &graphql.Error{Message: "Invalid ID", Extensions: map[string]interface{}{"status": http.StatusBadRequest}}
+	}
+	return id, nil
+}
+
+// batchCredits loads a page of credits of every owner with one query, in
+// the order of ids. Each page holds the owner's first credits in id order
+// after the after argument.
+func batchCredits(p graphql.BatchParams, owner string, ids []int) ([]interface{}, error) {
+	first, after, err := graphqlPageArgs(p.Args)
+	if err != nil {
+		return nil, err
+	}
+	withDeleted := p.Args["includeDeleted"].(bool)
+	if err := graphqlDeletedAllowed(p.Context, withDeleted); err != nil {
+		return nil, err
+	}
+
+	// One extra row per owner tells whether there is a next page
+	rows, err := database.DB.QueryContext(p.Context, `
+		SELECT `+creditColumns+` FROM (
+			SELECT `+creditColumns+`, ROW_NUMBER() OVER (PARTITION BY `+owner+` ORDER BY id) AS position
+			FROM credits
+			WHERE `+owner+` = ANY($1) AND id > $2 AND ($3 OR deleted_at IS NULL)
+		) owned
+		WHERE position <= $4
+		ORDER BY id
+	`, pq.Array(uniqueIDs(ids)), after, withDeleted, first+1)
+	if err != nil {
+		return nil, graphqlDatabaseError(p.Context, err)
+	}
+	defer rows.Close()
+	items := map[int][]interface{}{}
+	next := map[int]*int{}
+	for _, id := range ids {
+		items[id] = []interface{}{}
+	}
+	for rows.Next() {
+		var c models.Credit
+		if err := scanCredit(rows, &c); err != nil {
+			return nil, graphqlDatabaseError(p.Context, err)
+		}
+		key := c.ClientID
+		if owner == "bank_id" {
+			key = c.BankID
+		}
+		if len(items[key]) == first {
+			last := items[key][first-1].(models.Credit).ID
+			next[key] = &last
+			continue
+		}
+		items[key] = append(items[key], c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, graphqlDatabaseError(p.Context, err)
+	}
+	values := make([]interface{}, len(ids))
+	for i, id := range ids {
+		values[i] = graphqlPage{Items: items[id], NextCursor: next[id]}
+	}
+	return values, nil
+}
+
+// graphqlPageArgs reads the first and after arguments of a list.
+func graphqlPageArgs(args map[string]interface{}) (int, int, error) {
+	first := args["first"].(int)
+	if first < 1 || first > GraphQLMaxPageSize {
+		return 0, 0, &graphql.Error{
+			Message:    "first must be between 1 and " + strconv.Itoa(GraphQLMaxPageSize),
+			Extensions: map[string]interface{}{"status": http.StatusBadRequest},
+		}
+	}
+	after := 0
+	if args["after"] != nil {
+		var err error
+		if after, err = graphqlID(args["after"]); err != nil {
+			return 0, 0, err
+		}
+	}
+	return first, after, nil
+}
+
+// graphqlDeletedAllowed refuses a query for soft-deleted rows from a caller
+// that is not one of AdminActors.
+func graphqlDeletedAllowed(ctx context.Context, includeDeleted bool) error {
+	if !includeDeleted {
+		return nil
+	}
+	if r, ok := ctx.Value(graphqlRequestKey{}).(*http.Request); ok && isAdmin(r.Header.Get(ActorHeader)) {
+		return nil
+	}
+	return &graphql.Error{Message: deletedForbiddenMessage, Extensions: map[string]interface{}{"status": http.StatusForbidden}}
+}
+
+// lookupRow fetches one row by the id argument, or nil when there is none.
+func lookupRow(p graphql.ResolveParams, table, columns string, scan func(rowScanner) (interface{}, error)) (interface{}, error) {
+	id, err := graphqlID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+	withDeleted := p.Args["includeDeleted"].(bool)
+	if err := graphqlDeletedAllowed(p.Context, withDeleted); err != nil {
+		return nil, err
+	}
+	row, err := findRow(p.Context, table, columns, id, withDeleted, scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, graphqlDatabaseError(p.Context, err)
+	}
+	return row, nil
+}
+
+// listPage fetches a page of table in id order, after the row whose id is
+// the after argument. where filters with placeholders from $3 bound to args.
+func listPage(p graphql.ResolveParams, table, columns, where string, args []interface{}, scan func(rowScanner) (interface{}, int, error)) (interface{}, error) {
+	first, after, err := graphqlPageArgs(p.Args)
+	if err != nil {
+		return nil, err
+	}
+	filter, _ := p.Args["filter"].(map[string]interface{})
+	withDeleted, _ := filter["includeDeleted"].(bool)
+	if err := graphqlDeletedAllowed(p.Context, withDeleted); err != nil {
+		return nil, err
+	}
+
+	// One extra row tells whether there is a next page
+	rows, err := queryRows(p.Context, table, columns, where, withDeleted, after, first+1, args...)
+	if err != nil {
+		return nil, graphqlDatabaseError(p.Context, err)
+	}
+	defer rows.Close()
+	page := graphqlPage{}
+	items := []interface{}{}
+	var lastID int
+	for rows.Next() {
+		item, id, err := scan(rows)
+		if err != nil {
+			return nil, graphqlDatabaseError(p.Context, err)
+		}
+		if len(items) == first {
+			page.NextCursor = &lastID
+			break
+		}
+		items = append(items, item)
+		lastID = id
+	}
+	if err := rows.Err(); err != nil {
+		return nil, graphqlDatabaseError(p.Context, err)
+	}
+	page.Items = items
+	return page, nil
+}
+
+// forwardGraphQL runs a REST handler for a mutation on behalf of the GraphQL
+// request, with its headers, and decodes the response into result. An {id}
+// in path is replaced by the id argument. version, when set, is sent as
+// If-Match. An error response becomes a GraphQL error with the HTTP status in
+// its extensions.
+func forwardGraphQL(p graphql.ResolveParams, handler http.HandlerFunc, method, path string, version interface{}, body, result interface{}) (interface{}, error) {
+	original := p.Context.Value(graphqlRequestKey{}).(*http.Request)
+	vars := map[string]string{}
+	if id, ok := p.Args["id"]; ok {
+		rowID, err := graphqlID(id)
+		if err != nil {
+			return nil, err
+		}
+		vars["id"] = strconv.Itoa(rowID)
+		path = strings.Replace(path, "{id}", vars["id"], 1)
+	}
+
+	header := original.Header.Clone()
+	for _, name := range []string{"If-Match", "If-None-Match", "Idempotency-Key"} {
+		header.Del(name)
+	}
+	if version != nil {
+		header.Set("If-Match", entityTag(version.(int)))
+	}
+
+	w := callHandler(p.Context, handler, method, path, vars, header, body)
+	if w.status >= 400 {
+		return nil, &graphql.Error{Message: w.errorMessage(), Extensions: map[string]interface{}{"status": w.status}}
+	}
+	if result == nil {
+		return nil, nil
+	}
+	if err := json.Unmarshal(w.body.Bytes(), result); err != nil {
+		return nil, err
+	}
+	// Dereference so batch resolvers see the same types as in queries
+	switch v := result.(type) {
+	case *models.Client:
+		return *v, nil
+	case *models.Bank:
+		return *v, nil
+	case *models.Credit:
+		return *v, nil
+	}
+	return result, nil
+}
@@ -0,0 +1,457 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/internal/database"
+	"backend/internal/logger"
+	"backend/internal/models"
+	"backend/pkg/pb"
+
+	"github.com/lib/pq"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcCodes maps the statuses the REST handlers answer with to gRPC codes.
+var grpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:           codes.InvalidArgument,
+	http.StatusForbidden:            codes.PermissionDenied,
+	http.StatusNotFound:             codes.NotFound,
+	http.StatusConflict:             codes.AlreadyExists,
+	http.StatusPreconditionFailed:   codes.FailedPrecondition,
+	http.StatusPreconditionRequired: codes.FailedPrecondition,
+	http.StatusUnprocessableEntity:  codes.FailedPrecondition,
+	http.StatusServiceUnavailable:   codes.Unavailable,
+}
+
+// grpcHeader builds the headers of a REST call from the incoming metadata.
+// version, when nonzero, is sent as If-Match.
+func grpcHeader(ctx context.Context, version int32) http.Header {
+	header := http.Header{}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if actor := md.Get(strings.ToLower(ActorHeader)); len(actor) > 0 {
+		header.Set(ActorHeader, actor[0])
+	}
+	if version != 0 {
+		header.Set("If-Match", entityTag(int(version)))
+	}
+	return header
+}
+
+// grpcDeletedAllowed refuses a request for soft-deleted rows from a caller
+// that is not one of AdminActors.
+func grpcDeletedAllowed(ctx context.Context, includeDeleted bool) error {
+	if !includeDeleted {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if actor := md.Get(strings.ToLower(ActorHeader)); len(actor) > 0 && isAdmin(actor[0]) {
+		return nil
+	}
+	return status.Error(codes.PermissionDenied, deletedForbiddenMessage)
+}
+
+// grpcCall runs a REST handler on behalf of a gRPC call and decodes a
+// successful response into result, when given.
+func grpcCall(ctx context.Context, handler http.HandlerFunc, method, path string, id int64, version int32, body, result interface{}) error {
+	vars := map[string]string{}
+	if id != 0 {
+		vars["id"] = strconv.FormatInt(id, 10)
+		path = strings.Replace(path, "{id}", vars["id"], 1)
+	}
+	w := callHandler(ctx, handler, method, path, vars, grpcHeader(ctx, version), body)
+	if w.status >= 400 {
+		code, ok := grpcCodes[w.status]
+		if !ok {
+			code = codes.Internal
+		}
+		return status.Error(code, w.errorMessage())
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(w.body.Bytes(), result)
+}
+
+func grpcDatabaseError(method string, err error) error {
+	if logger.APILogger != nil {
+		logger.APILogger.LogError("gRPC", method, "Database error: "+err.Error())
+	}
+	return status.Error(codes.Internal, "Database error")
+}
+
+func validID(id int64) error {
+	if id <= 0 {
+		return status.Error(codes.InvalidArgument, "Invalid ID")
+	}
+	return nil
+}
+
+// Conversions between models and protobuf messages. Enums drop their type
+// prefix, so BANK_TYPE_PRIVATE is PRIVATE and UNSPECIFIED is empty.
+
+func toTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func toOptionalTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func fromTimestamp(t *timestamppb.Timestamp) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return t.AsTime()
+}
+
+func enumName(names map[int32]string, value int32, prefix string) string {
+	name := strings.TrimPrefix(names[value], prefix)
+	if name == "UNSPECIFIED" {
+		return ""
+	}
+	return name
+}
+
+func toProtoClient(c models.Client) *pb.Client {
+	return &pb.Client{
+		Id:        int64(c.ID),
+		FullName:  c.FullName,
+		Email:     c.Email,
+		BirthDate: toTimestamp(c.BirthDate),
+		Country:   c.Country,
+		ErasedAt:  toOptionalTimestamp(c.ErasedAt),
+		DeletedAt: toOptionalTimestamp(c.DeletedAt),
+		Version:   int32(c.Version),
+		CreatedAt: toTimestamp(c.CreatedAt),
+	}
+}
+
+func fromProtoClient(c *pb.Client) models.Client {
+	return models.Client{
+		FullName:  c.GetFullName(),
+		Email:     c.GetEmail(),
+		BirthDate: fromTimestamp(c.GetBirthDate()),
+		Country:   c.GetCountry(),
+	}
+}
+
+func toProtoBank(b models.Bank) *pb.Bank {
+	return &pb.Bank{
+		Id:        int64(b.ID),
+		Name:      b.Name,
+		Type:      pb.BankType(pb.BankType_value["BANK_TYPE_"+string(b.Type)]),
+		DeletedAt: toOptionalTimestamp(b.DeletedAt),
+		Version:   int32(b.Version),
+		CreatedAt: toTimestamp(b.CreatedAt),
+	}
+}
+
+func fromProtoBank(b *pb.Bank) models.Bank {
+	return models.Bank{
+		Name: b.GetName(),
+		Type: models.BankType(enumName(pb.BankType_name, int32(b.GetType()), "BANK_TYPE_")),
+	}
+}
+
+func toProtoCredit(c models.Credit) *pb.Credit {
+	return &pb.Credit{
+		Id:           int64(c.ID),
+		ClientId:     int64(c.ClientID),
+		BankId:       int64(c.BankID),
+		MinPayment:   c.MinPayment,
+		MaxPayment:   c.MaxPayment,
+		TermMonths:   int32(c.TermMonths),
+		CreditType:   pb.CreditType(pb.CreditType_value["CREDIT_TYPE_"+string(c.CreditType)]),
+		Status:       pb.CreditStatus(pb.CreditStatus_value["CREDIT_STATUS_"+string(c.Status)]),
+		DebtToIncome: c.DebtToIncome,
+		DtiFlagged:   c.DTIFlagged,
+		DeletedAt:    toOptionalTimestamp(c.DeletedAt),
+		Version:      int32(c.Version),
+		CreatedAt:    toTimestamp(c.CreatedAt),
+	}
+}
+
+func fromProtoCredit(c *pb.Credit) models.Credit {
+	return models.Credit{
+		ClientID:   int(c.GetClientId()),
+		BankID:     int(c.GetBankId()),
+		MinPayment: c.GetMinPayment(),
+		MaxPayment: c.GetMaxPayment(),
+		TermMonths: int(c.GetTermMonths()),
+		CreditType: models.CreditType(enumName(pb.CreditType_name, int32(c.GetCreditType()), "CREDIT_TYPE_")),
+		Status:     models.CreditStatus(enumName(pb.CreditStatus_name, int32(c.GetStatus()), "CREDIT_STATUS_")),
+	}
+}
+
+// ClientService implements the gRPC client API.
+type ClientService struct {
+	pb.UnimplementedClientServiceServer
+}
+
+func (ClientService) GetClient(ctx context.Context, req *pb.GetClientRequest) (*pb.Client, error) {
+	if err := validID(req.Id); err != nil {
+		return nil, err
+	}
+	if err := grpcDeletedAllowed(ctx, req.IncludeDeleted); err != nil {
+		return nil, err
+	}
+	var client models.Client
+	_, err := findRow(ctx, "clients", clientColumns, int(req.Id), req.IncludeDeleted, func(row rowScanner) (interface{}, error) {
+		return nil, scanClient(row, &client)
+	})
+	if err == sql.ErrNoRows {
+		return nil, status.Error(codes.NotFound, "Client not found")
+	}
+	if err != nil {
+		return nil, grpcDatabaseError("GetClient", err)
+	}
+	return toProtoClient(client), nil
+}
+
+func (ClientService) CreateClient(ctx context.Context, req *pb.CreateClientRequest) (*pb.Client, error) {
+	var client models.Client
+	if err := grpcCall(ctx, CreateClient, "POST", "/api/clients", 0, 0, fromProtoClient(req.Client), &client); err != nil {
+		return nil, err
+	}
+	return toProtoClient(client), nil
+}
+
+func (ClientService) UpdateClient(ctx context.Context, req *pb.UpdateClientRequest) (*pb.Client, error) {
+	if err := validID(req.Id); err != nil {
+		return nil, err
+	}
+	var client models.Client
+	if err := grpcCall(ctx, UpdateClient, "PUT", "/api/clients/{id}", req.Id, req.Version, fromProtoClient(req.Client), &client); err != nil {
+		return nil, err
+	}
+	return toProtoClient(client), nil
+}
+
+func (ClientService) DeleteClient(ctx context.Context, req *pb.DeleteClientRequest) (*emptypb.Empty, error) {
+	if err := validID(req.Id); err != nil {
+		return nil, err
+	}
+	if err := grpcCall(ctx, DeleteClient, "DELETE", "/api/clients/{id}", req.Id, req.Version, nil, nil); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// BankService implements the gRPC bank API.
+type BankService struct {
+	pb.UnimplementedBankServiceServer
+}
+
+func (BankService) GetBank(ctx context.Context, req *pb.GetBankRequest) (*pb.Bank, error) {
+	if err := validID(req.Id); err != nil {
+		return nil, err
+	}
+	if err := grpcDeletedAllowed(ctx, req.IncludeDeleted); err != nil {
+		return nil, err
+	}
+	var bank models.Bank
+	_, err := findRow(ctx, "banks", bankColumns, int(req.Id), req.IncludeDeleted, func(row rowScanner) (interface{}, error) {
+		return nil, scanBank(row, &bank)
+	})
+	if err == sql.ErrNoRows {
+		return nil, status.Error(codes.NotFound, "Bank not found")
+	}
+	if err != nil {
+		return nil, grpcDatabaseError("GetBank", err)
+	}
+	return toProtoBank(bank), nil
+}
+
+func (BankService) ListBanks(req *pb.ListBanksRequest, stream pb.BankService_ListBanksServer) error {
+	if err := grpcDeletedAllowed(stream.Context(), req.IncludeDeleted); err != nil {
+		return err
+	}
+	rows, err := queryRows(stream.Context(), "banks", bankColumns, "TRUE", req.IncludeDeleted, 0, 0)
+	if err != nil {
+		return grpcDatabaseError("ListBanks", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var bank models.Bank
+		if err := scanBank(rows, &bank); err != nil {
+			return grpcDatabaseError("ListBanks", err)
+		}
+		if err := stream.Send(toProtoBank(bank)); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return grpcDatabaseError("ListBanks", err)
+	}
+	return nil
+}
+
+func (BankService) CreateBank(ctx context.Context, req *pb.CreateBankRequest) (*pb.Bank, error) {
+	var bank models.Bank
+	if err := grpcCall(ctx, CreateBank, "POST", "/api/banks", 0, 0, fromProtoBank(req.Bank), &bank); err != nil {
+		return nil, err
+	}
+	return toProtoBank(bank), nil
+}
+
+func (BankService) UpdateBank(ctx context.Context, req *pb.UpdateBankRequest) (*pb.Bank, error) {
+	if err := validID(req.Id); err != nil {
+		return nil, err
+	}
+	var bank models.Bank
+	if err := grpcCall(ctx, UpdateBank, "PUT", "/api/banks/{id}", req.Id, req.Version, fromProtoBank(req.Bank), &bank); err != nil {
+		return nil, err
+	}
+	return toProtoBank(bank), nil
+}
+
+func (BankService) DeleteBank(ctx context.Context, req *pb.DeleteBankRequest) (*emptypb.Empty, error) {
+	if err := validID(req.Id); err != nil {
+		return nil, err
+	}
+	if err := grpcCall(ctx, DeleteBank, "DELETE", "/api/banks/{id}", req.Id, req.Version, nil, nil); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// CreditService implements the gRPC credit API.
+type CreditService struct {
+	pb.UnimplementedCreditServiceServer
+}
+
+func (CreditService) GetCredit(ctx context.Context, req *pb.GetCreditRequest) (*pb.Credit, error) {
+	if err := validID(req.Id); err != nil {
+		return nil, err
+	}
+	if err := grpcDeletedAllowed(ctx, req.IncludeDeleted); err != nil {
+		return nil, err
+	}
+	var credit models.Credit
+	_, err := findRow(ctx, "credits", creditColumns, int(req.Id), req.IncludeDeleted, func(row rowScanner) (interface{}, error) {
+		return nil, scanCredit(row, &credit)
+	})
+	if err == sql.ErrNoRows {
+		return nil, status.Error(codes.NotFound, "Credit not found")
+	}
+	if err != nil {
+		return nil, grpcDatabaseError("GetCredit", err)
+	}
+	return toProtoCredit(credit), nil
+}
+
+func (CreditService) BatchGetCredits(ctx context.Context, req *pb.BatchGetCreditsRequest) (*pb.BatchGetCreditsResponse, error) {
+	if len(req.Ids) == 0 || len(req.Ids) > MaxBatchSize {
+		return nil, status.Error(codes.InvalidArgument, "Batch must contain between 1 and "+strconv.Itoa(MaxBatchSize)+" ids")
+	}
+	if err := grpcDeletedAllowed(ctx, req.IncludeDeleted); err != nil {
+		return nil, err
+	}
+	rows, err := database.DB.QueryContext(ctx,
+		"SELECT "+creditColumns+" FROM credits WHERE id = ANY($1) AND ($2 OR deleted_at IS NULL) ORDER BY id",
+		pq.Array(req.Ids), req.IncludeDeleted,
+	)
+	if err != nil {
+		return nil, grpcDatabaseError("BatchGetCredits", err)
+	}
+	defer rows.Close()
+	response := &pb.BatchGetCreditsResponse{}
+	for rows.Next() {
+		var credit models.Credit
+		if err := scanCredit(rows, &credit); err != nil {
+			return nil, grpcDatabaseError("BatchGetCredits", err)
+		}
+		response.Credits = append(response.Credits, toProtoCredit(credit))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, grpcDatabaseError("BatchGetCredits", err)
+	}
+	return response, nil
+}
+
+func (CreditService) ListCredits(req *pb.ListCreditsRequest, stream pb.CreditService_ListCreditsServer) error {
+	if err := grpcDeletedAllowed(stream.Context(), req.IncludeDeleted); err != nil {
+		return err
+	}
+
+	// Unset filters are bound as NULL
+	var clientID, bankID, creditStatus, creditType interface{}
+	if req.ClientId != 0 {
+		clientID = req.ClientId
+	}
+	if req.BankId != 0 {
+		bankID = req.BankId
+	}
+	if name := enumName(pb.CreditStatus_name, int32(req.Status), "CREDIT_STATUS_"); name != "" {
+		creditStatus = name
+	}
+	if name := enumName(pb.CreditType_name, int32(req.CreditType), "CREDIT_TYPE_"); name != "" {
+		creditType = name
+	}
+
+	rows, err := queryRows(stream.Context(), "credits", creditColumns, creditFilter, req.IncludeDeleted, 0, 0,
+		clientID, bankID, creditStatus, creditType)
+	if err != nil {
+		return grpcDatabaseError("ListCredits", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var credit models.Credit
+		if err := scanCredit(rows, &credit); err != nil {
+			return grpcDatabaseError("ListCredits", err)
+		}
+		if err := stream.Send(toProtoCredit(credit)); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return grpcDatabaseError("ListCredits", err)
+	}
+	return nil
+}
+
+func (CreditService) CreateCredit(ctx context.Context, req *pb.CreateCreditRequest) (*pb.Credit, error) {
+	var credit models.Credit
+	if err := grpcCall(ctx, CreateCredit, "POST", "/api/credits", 0, 0, fromProtoCredit(req.Credit), &credit); err != nil {
+		return nil, err
+	}
+	return toProtoCredit(credit), nil
+}
+
+func (CreditService) UpdateCredit(ctx context.Context, req *pb.UpdateCreditRequest) (*pb.Credit, error) {
+	if err := validID(req.Id); err != nil {
+		return nil, err
+	}
+	var credit models.Credit
+	if err := grpcCall(ctx, UpdateCredit, "PUT", "/api/credits/{id}", req.Id, req.Version, fromProtoCredit(req.Credit), &credit); err != nil {
+		return nil, err
+	}
+	return toProtoCredit(credit), nil
+}
+
+func (CreditService) DeleteCredit(ctx context.Context, req *pb.DeleteCreditRequest) (*emptypb.Empty, error) {
+	if err := validID(req.Id); err != nil {
+		return nil, err
+	}
+	if err := grpcCall(ctx, DeleteCredit, "DELETE", "/api/credits/{id}", req.Id, req.Version, nil, nil); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
@@ -1,12 +1,16 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
 	"net/http"
 	"strconv"
 	"time"
 
+	"backend/internal/audit"
 	"backend/internal/database"
+	"backend/internal/export"
+	"backend/internal/idempotency"
 	"backend/internal/logger"
 	"backend/internal/models"
 	"github.com/gorilla/mux"
@@ -17,10 +21,25 @@ func HealthCheck(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
+var itemFields = fieldColumns{
+	{"id", "id", zeroNumber},
+	{"name", "name", zeroText},
+	{"description", "description", zeroText},
+	{"created_at", "created_at", zeroTime},
+}
+
 func GetItems(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	format, ok := listFormat(w, r)
+	if !ok {
+		return
+	}
+	fields, ok := parseFields(w, r, models.Item{})
+	if !ok {
+		return
+	}
 	
-	rows, err := database.DB.Query("SELECT id, name, description, created_at FROM items ORDER BY created_at DESC")
+	rows, err := database.DB.Query("SELECT " + itemFields.selecting(fields) + " FROM items ORDER BY created_at DESC")
 	if err != nil {
 		if logger.APILogger != nil {
 			logger.APILogger.LogError(r.Method, r.URL.Path, "Database query failed: "+err.Error())
@@ -31,7 +50,7 @@ func GetItems(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
-	items := []models.Item{}
+	list := newListWriter(w, format, "items", export.Select(models.Item{}, fields))
 	for rows.Next() {
 		var item models.Item
 		if err := rows.Scan(&item.ID, &item.Name, &item.Description, &item.CreatedAt); err != nil {
@@ -40,10 +59,12 @@ func GetItems(w http.ResponseWriter, r *http.Request) {
 			}
 			continue
 		}
-		items = append(items, item)
+		if err := list.Write(export.Select(item, fields)); err != nil {
+			break
+		}
 	}
 
-	json.NewEncoder(w).Encode(items)
+	list.Close()
 }
 
 func GetItem(w http.ResponseWriter, r *http.Request) {
@@ -55,9 +76,13 @@ func GetItem(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid ID"})
 		return
 	}
+	fields, ok := parseFields(w, r, models.Item{})
+	if !ok {
+		return
+	}
 
 	var item models.Item
-	err = database.DB.QueryRow("SELECT id, name, description, created_at FROM items WHERE id = $1", id).
+	err = database.DB.QueryRow("SELECT "+itemFields.selecting(fields)+" FROM items WHERE id = $1", id).
 		Scan(&item.ID, &item.Name, &item.Description, &item.CreatedAt)
 	
 	if err != nil {
@@ -66,7 +91,7 @@ func GetItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	json.NewEncoder(w).Encode(item)
+	json.NewEncoder(w).Encode(export.Select(item, fields))
 }
 
 func CreateItem(w http.ResponseWriter, r *http.Request) {
@@ -82,10 +107,24 @@ func CreateItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := database.DB.QueryRow(
+	tx, err := database.DB.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(
 		"INSERT INTO items (name, description) VALUES ($1, $2) RETURNING id, created_at",
 		item.Name, item.Description,
 	).Scan(&item.ID, &item.CreatedAt)
+	if err == nil {
+		err = recordChange(tx, r, "item", item.ID, audit.OpCreate, nil, item)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 
 	if err != nil {
 		if logger.APILogger != nil {
@@ -101,6 +140,50 @@ func CreateItem(w http.ResponseWriter, r *http.Request) {
 }
 // Client handlers
 
+var clientFields = fieldColumns{
+	{"id", "id", zeroNumber},
+	{"full_name", "full_name", zeroNull},
+	{"email", "email", zeroNull},
+	{"birth_date", "birth_date", zeroNull},
+	{"country", "country", zeroText},
+	{"erased_at", "erased_at", zeroNull},
+	{"deleted_at", "deleted_at", zeroNull},
+	{"version", "version", zeroNumber},
+	{"created_at", "created_at", zeroTime},
+	{"full_name", "full_name_enc", zeroNull},
+	{"email", "email_enc", zeroNull},
+	{"birth_date", "birth_date_enc", zeroNull},
+}
+
+var clientColumns = clientFields.all()
+
+// scanClient reads a client row, decrypting the PII columns when the row has
+// been encrypted.
+func scanClient(row rowScanner, client *models.Client) error {
+	var fullName, email sql.NullString
+	var birthDate, erasedAt, deletedAt sql.NullTime
+	var encrypted encryptedClientPII
+	if err := row.Scan(&client.ID, &fullName, &email, &birthDate, &client.Country, &erasedAt, &deletedAt, &client.Version, &client.CreatedAt,
+		&encrypted.fullName, &encrypted.email, &encrypted.birthDate); err != nil {
+		return err
+	}
+	client.FullName = fullName.String
+	client.Email = email.String
+	client.BirthDate = birthDate.Time
+	client.ErasedAt = nil
+	if erasedAt.Valid {
+		client.ErasedAt = &erasedAt.Time
+	}
+	client.DeletedAt = nil
+	if deletedAt.Valid {
+		client.DeletedAt = &deletedAt.Time
+	}
+	if encrypted.fullName.Valid || encrypted.email.Valid || encrypted.birthDate.Valid {
+		return decryptClientPII(encrypted, client)
+	}
+	return nil
+}
+
 func GetClient(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	params := mux.Vars(r)
@@ -110,10 +193,20 @@ func GetClient(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid ID"})
 		return
 	}
+	expand, ok := parseExpand(w, r, "credits")
+	if !ok {
+		return
+	}
+	fields, ok := parseFields(w, r, models.Client{})
+	if !ok {
+		return
+	}
 
 	var client models.Client
-	err = database.DB.QueryRow("SELECT id, full_name, email, birth_date, country, created_at FROM clients WHERE id = $1", id).
-		Scan(&client.ID, &client.FullName, &client.Email, &client.BirthDate, &client.Country, &client.CreatedAt)
+	err = scanClient(database.DB.QueryRow(
+		"SELECT "+clientFields.selecting(fields, "version")+" FROM clients WHERE id = $1 AND ($2 OR deleted_at IS NULL)",
+		id, includeDeleted(r),
+	), &client)
 	
 	if err != nil {
 		w.WriteHeader(http.StatusNotFound)
@@ -121,7 +214,25 @@ func GetClient(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	json.NewEncoder(w).Encode(client)
+	if !expand["credits"] {
+		if checkNotModified(w, r, client.Version) {
+			return
+		}
+		setETag(w, client.Version)
+		json.NewEncoder(w).Encode(export.Select(client, fields))
+		return
+	}
+
+	// The version only covers the client, so an expanded response is never
+	// answered with 304
+	credits, err := loadCreditsBy("client_id", []int{id}, includeDeleted(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	setETag(w, client.Version)
+	json.NewEncoder(w).Encode(export.Select(clientWithCredits{Client: client, Credits: credits[id]}, withRelations(fields, expand)))
 }
 
 func UpdateClient(w http.ResponseWriter, r *http.Request) {
@@ -147,27 +258,57 @@ func UpdateClient(w http.ResponseWriter, r *http.Request) {
 		birthDate = client.BirthDate
 	}
 
-	_, err = database.DB.Exec(
-		"UPDATE clients SET full_name = $1, email = $2, birth_date = $3, country = $4 WHERE id = $5",
-		client.FullName, client.Email, birthDate, client.Country, id,
-	)
+	client.BirthDate = birthDate
 
+	expected, ok := expectedVersion(w, r)
+	if !ok {
+		return
+	}
+
+	pii, err := encryptClientPII(client)
 	if err != nil {
+		if logger.APILogger != nil {
+			logger.APILogger.LogError(r.Method, r.URL.Path, "Failed to encrypt client data: "+err.Error())
+		}
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update client"})
 		return
 	}
 
-	// Fetch updated client
-	err = database.DB.QueryRow("SELECT id, full_name, email, birth_date, country, created_at FROM clients WHERE id = $1", id).
-		Scan(&client.ID, &client.FullName, &client.Email, &client.BirthDate, &client.Country, &client.CreatedAt)
-	
+	tx, err := database.DB.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Erased clients keep their anonymized data
+	updated, err := updateAudited(tx, r, "clients", id, audit.OpUpdate, `
+		full_name = $2, email = $3, birth_date = $4, country = $5,
+		full_name_enc = $6, email_enc = $7, birth_date_enc = $8, email_index = $9, pii_key_id = $10,
+		version = version + 1`,
+		"erased_at IS NULL AND deleted_at IS NULL AND ($11::int IS NULL OR version = $11)",
+		pii.fullName, pii.email, pii.birthDate, client.Country,
+		pii.fullNameEnc, pii.emailEnc, pii.birthDateEnc, pii.emailIndex, pii.keyID, expected)
+	if err == sql.ErrNoRows {
+		writeUpdateMiss(w, "clients", id, expected, "Client not found or erased")
+		return
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
+		if logger.APILogger != nil {
+			logger.APILogger.LogError(r.Method, r.URL.Path, "Failed to update client: "+err.Error())
+		}
 		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch updated client"})
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update client"})
 		return
 	}
+	client = updated.(models.Client)
 
+	setETag(w, client.Version)
 	json.NewEncoder(w).Encode(client)
 }
 
@@ -181,17 +322,32 @@ func DeleteClient(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := database.DB.Exec("DELETE FROM clients WHERE id = $1", id)
+	expected, ok := expectedVersion(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := database.DB.Begin()
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to delete client"})
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
 		return
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil || rowsAffected == 0 {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Client not found"})
+	_, err = updateAudited(tx, r, "clients", id, audit.OpDelete,
+		"deleted_at = CURRENT_TIMESTAMP, version = version + 1",
+		"deleted_at IS NULL AND ($2::int IS NULL OR version = $2)", expected)
+	if err == sql.ErrNoRows {
+		writeUpdateMiss(w, "clients", id, expected, "Client not found")
+		return
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to delete client"})
 		return
 	}
 
@@ -209,27 +365,119 @@ func CreateClient(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := database.DB.QueryRow(
-		"INSERT INTO clients (full_name, email, birth_date, country) VALUES ($1, $2, $3, $4) RETURNING id, created_at",
-		client.FullName, client.Email, client.BirthDate, client.Country,
-	).Scan(&client.ID, &client.CreatedAt)
-
+	tx, err := database.DB.Begin()
 	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	if status, msg := createClient(tx, r, &client); status != 0 {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": msg})
+		return
+	}
+	if err := tx.Commit(); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create client"})
 		return
 	}
 
+	setETag(w, client.Version)
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(client)
 }
 
+// createClient inserts client inside tx unless its email is already taken.
+// On failure it returns the HTTP status and error message to report; on
+// success the status is zero.
+func createClient(tx *sql.Tx, r *http.Request, client *models.Client) (int, string) {
+	if _, err := clientIDByEmail(tx, client.Email); err == nil {
+		return http.StatusConflict, "A client with this email already exists"
+	} else if err != sql.ErrNoRows {
+		if logger.APILogger != nil {
+			logger.APILogger.LogError(r.Method, r.URL.Path, "Client email lookup failed: "+err.Error())
+		}
+		return http.StatusInternalServerError, "Failed to create client"
+	}
+
+	pii, err := encryptClientPII(*client)
+	if err != nil {
+		if logger.APILogger != nil {
+			logger.APILogger.LogError(r.Method, r.URL.Path, "Failed to encrypt client data: "+err.Error())
+		}
+		return http.StatusInternalServerError, "Failed to create client"
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO clients (full_name, email, birth_date, country,
+		                     full_name_enc, email_enc, birth_date_enc, email_index, pii_key_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, version, created_at
+	`, pii.fullName, pii.email, pii.birthDate, client.Country,
+		pii.fullNameEnc, pii.emailEnc, pii.birthDateEnc, pii.emailIndex, pii.keyID,
+	).Scan(&client.ID, &client.Version, &client.CreatedAt)
+	if err == nil {
+		err = recordChange(tx, r, "client", client.ID, audit.OpCreate, nil, *client)
+	}
+	if err != nil {
+		return http.StatusInternalServerError, "Failed to create client"
+	}
+	// The response carries the client's PII, so a stored copy goes on erasure
+	idempotency.Tag(r, clientSubject(client.ID))
+	return 0, ""
+}
+
+// clientSubject tags stored idempotent responses that carry a client's data.
+func clientSubject(id int) string {
+	return "client:" + strconv.Itoa(id)
+}
+
 // Bank handlers
 
+var bankFields = fieldColumns{
+	{"id", "id", zeroNumber},
+	{"name", "name", zeroText},
+	{"type", "type", zeroText},
+	{"deleted_at", "deleted_at", zeroNull},
+	{"version", "version", zeroNumber},
+	{"created_at", "created_at", zeroTime},
+}
+
+var bankColumns = bankFields.all()
+
+func scanBank(row rowScanner, bank *models.Bank) error {
+	var deletedAt sql.NullTime
+	if err := row.Scan(&bank.ID, &bank.Name, &bank.Type, &deletedAt, &bank.Version, &bank.CreatedAt); err != nil {
+		return err
+	}
+	bank.DeletedAt = nil
+	if deletedAt.Valid {
+		bank.DeletedAt = &deletedAt.Time
+	}
+	return nil
+}
+
 func GetBanks(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	format, ok := listFormat(w, r)
+	if !ok {
+		return
+	}
+	expand, ok := parseExpand(w, r, "credits")
+	if !ok {
+		return
+	}
+	fields, ok := parseFields(w, r, models.Bank{})
+	if !ok {
+		return
+	}
 	
-	rows, err := database.DB.Query("SELECT id, name, type, created_at FROM banks ORDER BY created_at DESC")
+	rows, err := database.DB.Query(
+		"SELECT "+bankFields.selecting(fields, "id")+" FROM banks WHERE $1 OR deleted_at IS NULL ORDER BY created_at DESC",
+		includeDeleted(r),
+	)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
@@ -237,16 +485,7 @@ func GetBanks(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
-	banks := []models.Bank{}
-	for rows.Next() {
-		var bank models.Bank
-		if err := rows.Scan(&bank.ID, &bank.Name, &bank.Type, &bank.CreatedAt); err != nil {
-			continue
-		}
-		banks = append(banks, bank)
-	}
-
-	json.NewEncoder(w).Encode(banks)
+	writeBanks(w, r, format, rows, expand, fields)
 }
 
 func GetBank(w http.ResponseWriter, r *http.Request) {
@@ -258,10 +497,20 @@ func GetBank(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid ID"})
 		return
 	}
+	expand, ok := parseExpand(w, r, "credits")
+	if !ok {
+		return
+	}
+	fields, ok := parseFields(w, r, models.Bank{})
+	if !ok {
+		return
+	}
 
 	var bank models.Bank
-	err = database.DB.QueryRow("SELECT id, name, type, created_at FROM banks WHERE id = $1", id).
-		Scan(&bank.ID, &bank.Name, &bank.Type, &bank.CreatedAt)
+	err = scanBank(database.DB.QueryRow(
+		"SELECT "+bankFields.selecting(fields, "version")+" FROM banks WHERE id = $1 AND ($2 OR deleted_at IS NULL)",
+		id, includeDeleted(r),
+	), &bank)
 	
 	if err != nil {
 		w.WriteHeader(http.StatusNotFound)
@@ -269,7 +518,23 @@ func GetBank(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	json.NewEncoder(w).Encode(bank)
+	if !expand["credits"] {
+		if checkNotModified(w, r, bank.Version) {
+			return
+		}
+		setETag(w, bank.Version)
+		json.NewEncoder(w).Encode(export.Select(bank, fields))
+		return
+	}
+
+	credits, err := loadCreditsBy("bank_id", []int{id}, includeDeleted(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	setETag(w, bank.Version)
+	json.NewEncoder(w).Encode(export.Select(bankWithCredits{Bank: bank, Credits: credits[id]}, withRelations(fields, expand)))
 }
 
 func CreateBank(w http.ResponseWriter, r *http.Request) {
@@ -289,10 +554,24 @@ func CreateBank(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := database.DB.QueryRow(
-		"INSERT INTO banks (name, type) VALUES ($1, $2) RETURNING id, created_at",
+	tx, err := database.DB.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(
+		"INSERT INTO banks (name, type) VALUES ($1, $2) RETURNING id, version, created_at",
 		bank.Name, bank.Type,
-	).Scan(&bank.ID, &bank.CreatedAt)
+	).Scan(&bank.ID, &bank.Version, &bank.CreatedAt)
+	if err == nil {
+		err = recordChange(tx, r, "bank", bank.ID, audit.OpCreate, nil, bank)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -300,6 +579,7 @@ func CreateBank(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, bank.Version)
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(bank)
 }
@@ -328,27 +608,38 @@ func UpdateBank(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = database.DB.Exec(
-		"UPDATE banks SET name = $1, type = $2 WHERE id = $3",
-		bank.Name, bank.Type, id,
-	)
+	expected, ok := expectedVersion(w, r)
+	if !ok {
+		return
+	}
 
+	tx, err := database.DB.Begin()
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update bank"})
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
 		return
 	}
-
-	// Fetch updated bank
-	err = database.DB.QueryRow("SELECT id, name, type, created_at FROM banks WHERE id = $1", id).
-		Scan(&bank.ID, &bank.Name, &bank.Type, &bank.CreatedAt)
-	
+	defer tx.Rollback()
+
+	updated, err := updateAudited(tx, r, "banks", id, audit.OpUpdate,
+		"name = $2, type = $3, version = version + 1",
+		"deleted_at IS NULL AND ($4::int IS NULL OR version = $4)",
+		bank.Name, bank.Type, expected)
+	if err == sql.ErrNoRows {
+		writeUpdateMiss(w, "banks", id, expected, "Bank not found")
+		return
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch updated bank"})
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update bank"})
 		return
 	}
+	bank = updated.(models.Bank)
 
+	setETag(w, bank.Version)
 	json.NewEncoder(w).Encode(bank)
 }
 
@@ -362,17 +653,32 @@ func DeleteBank(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := database.DB.Exec("DELETE FROM banks WHERE id = $1", id)
+	expected, ok := expectedVersion(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := database.DB.Begin()
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to delete bank"})
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
 		return
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil || rowsAffected == 0 {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Bank not found"})
+	_, err = updateAudited(tx, r, "banks", id, audit.OpDelete,
+		"deleted_at = CURRENT_TIMESTAMP, version = version + 1",
+		"deleted_at IS NULL AND ($2::int IS NULL OR version = $2)", expected)
+	if err == sql.ErrNoRows {
+		writeUpdateMiss(w, "banks", id, expected, "Bank not found")
+		return
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to delete bank"})
 		return
 	}
 
@@ -381,15 +687,68 @@ func DeleteBank(w http.ResponseWriter, r *http.Request) {
 }
 // Credit handlers
 
+var creditFields = fieldColumns{
+	{"id", "id", zeroNumber},
+	{"client_id", "client_id", zeroNumber},
+	{"bank_id", "bank_id", zeroNumber},
+	{"min_payment", "min_payment", zeroNumber},
+	{"max_payment", "max_payment", zeroNumber},
+	{"term_months", "term_months", zeroNumber},
+	{"credit_type", "credit_type", zeroText},
+	{"status", "status", zeroText},
+	{"debt_to_income", "debt_to_income", zeroNull},
+	{"dti_flagged", "dti_flagged", zeroBool},
+	{"deleted_at", "deleted_at", zeroNull},
+	{"version", "version", zeroNumber},
+	{"created_at", "created_at", zeroTime},
+}
+
+var creditColumns = creditFields.all()
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanCredit(row rowScanner, credit *models.Credit) error {
+	var debtToIncome sql.NullFloat64
+	var deletedAt sql.NullTime
+	if err := row.Scan(&credit.ID, &credit.ClientID, &credit.BankID,
+		&credit.MinPayment, &credit.MaxPayment, &credit.TermMonths,
+		&credit.CreditType, &credit.Status, &debtToIncome, &credit.DTIFlagged, &deletedAt, &credit.Version, &credit.CreatedAt); err != nil {
+		return err
+	}
+	credit.DeletedAt = nil
+	if deletedAt.Valid {
+		credit.DeletedAt = &deletedAt.Time
+	}
+	credit.DebtToIncome = nil
+	if debtToIncome.Valid {
+		credit.DebtToIncome = &debtToIncome.Float64
+	}
+	return nil
+}
+
 func GetCredits(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	format, ok := listFormat(w, r)
+	if !ok {
+		return
+	}
+	expand, ok := parseExpand(w, r, "client", "bank")
+	if !ok {
+		return
+	}
+	fields, ok := parseFields(w, r, models.Credit{})
+	if !ok {
+		return
+	}
 	
 	rows, err := database.DB.Query(`
-		SELECT id, client_id, bank_id, min_payment, max_payment, term_months, 
-		       credit_type, status, created_at 
+		SELECT ` + creditFields.selecting(fields, creditExpandKeys(expand)...) + `
 		FROM credits 
+		WHERE $1 OR deleted_at IS NULL
 		ORDER BY created_at DESC
-	`)
+	`, includeDeleted(r))
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
@@ -397,18 +756,7 @@ func GetCredits(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
-	credits := []models.Credit{}
-	for rows.Next() {
-		var credit models.Credit
-		if err := rows.Scan(&credit.ID, &credit.ClientID, &credit.BankID, 
-			&credit.MinPayment, &credit.MaxPayment, &credit.TermMonths,
-			&credit.CreditType, &credit.Status, &credit.CreatedAt); err != nil {
-			continue
-		}
-		credits = append(credits, credit)
-	}
-
-	json.NewEncoder(w).Encode(credits)
+	writeCredits(w, r, format, rows, expand, fields)
 }
 
 func GetCredit(w http.ResponseWriter, r *http.Request) {
@@ -420,15 +768,20 @@ func GetCredit(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid ID"})
 		return
 	}
+	expand, ok := parseExpand(w, r, "client", "bank")
+	if !ok {
+		return
+	}
+	fields, ok := parseFields(w, r, models.Credit{})
+	if !ok {
+		return
+	}
 
 	var credit models.Credit
-	err = database.DB.QueryRow(`
-		SELECT id, client_id, bank_id, min_payment, max_payment, term_months, 
-		       credit_type, status, created_at 
-		FROM credits WHERE id = $1
-	`, id).Scan(&credit.ID, &credit.ClientID, &credit.BankID, 
-		&credit.MinPayment, &credit.MaxPayment, &credit.TermMonths,
-		&credit.CreditType, &credit.Status, &credit.CreatedAt)
+	err = scanCredit(database.DB.QueryRow(`
+		SELECT ` + creditFields.selecting(fields, append(creditExpandKeys(expand), "version")...) + `
+		FROM credits WHERE id = $1 AND ($2 OR deleted_at IS NULL)
+	`, id, includeDeleted(r)), &credit)
 	
 	if err != nil {
 		w.WriteHeader(http.StatusNotFound)
@@ -436,7 +789,23 @@ func GetCredit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	json.NewEncoder(w).Encode(credit)
+	if len(expand) == 0 {
+		if checkNotModified(w, r, credit.Version) {
+			return
+		}
+		setETag(w, credit.Version)
+		json.NewEncoder(w).Encode(export.Select(credit, fields))
+		return
+	}
+
+	expanded, err := expandCredits([]models.Credit{credit}, expand)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	setETag(w, credit.Version)
+	json.NewEncoder(w).Encode(export.Select(expanded[0], withRelations(fields, expand)))
 }
 
 func CreateCredit(w http.ResponseWriter, r *http.Request) {
@@ -448,56 +817,161 @@ func CreateCredit(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
 		return
 	}
-
-	// Validate credit type
-	if credit.CreditType != models.CreditTypeAuto && 
-	   credit.CreditType != models.CreditTypeMortgage && 
-	   credit.CreditType != models.CreditTypeCommercial {
+	if msg := validateCredit(&credit); msg != "" {
 		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid credit type. Must be AUTO, MORTGAGE, or COMMERCIAL"})
+		json.NewEncoder(w).Encode(map[string]string{"error": msg})
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
 		return
 	}
+	defer tx.Rollback()
+
+	if status, msg := createCredit(tx, r, &credit); status != 0 {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": msg})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create credit"})
+		return
+	}
+
+	setETag(w, credit.Version)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(credit)
+}
+
+// validateCredit checks the fields of a new credit and defaults an empty
+// status to PENDING. It returns an empty string when the credit is valid.
+func validateCredit(credit *models.Credit) string {
+	// Validate credit type
+	if credit.CreditType != models.CreditTypeAuto &&
+		credit.CreditType != models.CreditTypeMortgage &&
+		credit.CreditType != models.CreditTypeCommercial {
+		return "Invalid credit type. Must be AUTO, MORTGAGE, or COMMERCIAL"
+	}
 
 	// Validate status if provided, otherwise default to PENDING
 	if credit.Status == "" {
 		credit.Status = models.CreditStatusPending
-	} else if credit.Status != models.CreditStatusPending && 
-	          credit.Status != models.CreditStatusApproved && 
-	          credit.Status != models.CreditStatusRejected {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid status. Must be PENDING, APPROVED, or REJECTED"})
-		return
+	} else if credit.Status != models.CreditStatusPending &&
+		credit.Status != models.CreditStatusApproved &&
+		credit.Status != models.CreditStatusRejected {
+		return "Invalid status. Must be PENDING, APPROVED, or REJECTED"
 	}
 
 	// Validate payment amounts
 	if credit.MinPayment <= 0 || credit.MaxPayment <= 0 || credit.MinPayment > credit.MaxPayment {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid payment amounts. Min and max must be positive, and min must be <= max"})
-		return
+		return "Invalid payment amounts. Min and max must be positive, and min must be <= max"
 	}
 
 	// Validate term months
 	if credit.TermMonths <= 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Term months must be positive"})
-		return
+		return "Term months must be positive"
+	}
+	return ""
+}
+
+// createCredit validates credit, checks its approval documents and
+// affordability and inserts it inside tx. On failure it returns the HTTP
+// status and error message to report; on success the status is zero.
+func createCredit(tx *sql.Tx, r *http.Request, credit *models.Credit) (int, string) {
+	if msg := validateCredit(credit); msg != "" {
+		return http.StatusBadRequest, msg
+	}
+
+	if status, msg := creditReferencesError(tx, r, credit); status != 0 {
+		return status, msg
 	}
 
-	err := database.DB.QueryRow(`
-		INSERT INTO credits (client_id, bank_id, min_payment, max_payment, term_months, credit_type, status) 
-		VALUES ($1, $2, $3, $4, $5, $6, $7) 
-		RETURNING id, created_at
-	`, credit.ClientID, credit.BankID, credit.MinPayment, credit.MaxPayment, 
-	   credit.TermMonths, credit.CreditType, credit.Status).Scan(&credit.ID, &credit.CreatedAt)
+	// Approval requires verified, unexpired KYC documents
+	if credit.Status == models.CreditStatusApproved {
+		if status, msg := approvalDocumentsError(tx, r, credit.ClientID); status != 0 {
+			return status, msg
+		}
+	}
 
+	// Check affordability against the client's latest financial profile.
+	// Credits created earlier in tx count towards the client's debt.
+	if status, msg := assessCredit(tx, r, credit, true); status != 0 {
+		return status, msg
+	}
+
+	err := tx.QueryRow(`
+		INSERT INTO credits (client_id, bank_id, min_payment, max_payment, term_months, credit_type, status, debt_to_income, dti_flagged)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, version, created_at
+	`, credit.ClientID, credit.BankID, credit.MinPayment, credit.MaxPayment,
+		credit.TermMonths, credit.CreditType, credit.Status, credit.DebtToIncome, credit.DTIFlagged).Scan(&credit.ID, &credit.Version, &credit.CreatedAt)
+	if err == nil {
+		err = recordChange(tx, r, "credit", credit.ID, audit.OpCreate, nil, *credit)
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create credit"})
-		return
+		return http.StatusInternalServerError, "Failed to create credit"
 	}
+	return 0, ""
+}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(credit)
+// assessCredit sets the debt-to-income ratio and flag of credit from its
+// client's latest financial profile inside tx. The client's other credits
+// count towards the debt, credit itself only with its new max payment. With
+// enforce set, a ratio above DTIThreshold is refused when
+// DTIRejectAboveThreshold is. It returns the HTTP status and error message to
+// report, or a zero status.
+func assessCredit(tx *sql.Tx, r *http.Request, credit *models.Credit, enforce bool) (int, string) {
+	credit.DebtToIncome = nil
+	credit.DTIFlagged = false
+	ratio, err := assessDebtToIncome(tx, credit.ClientID, credit.ID, credit.MaxPayment)
+	switch {
+	case err == errNoFinancialProfile:
+		// Nothing declared yet, so the ratio cannot be computed
+	case err == errNoMonthlyIncome:
+		return http.StatusUnprocessableEntity, "Client has no declared monthly income"
+	case err != nil:
+		if logger.APILogger != nil {
+			logger.APILogger.LogError(r.Method, r.URL.Path, "Debt-to-income check failed: "+err.Error())
+		}
+		return http.StatusInternalServerError, "Database error"
+	default:
+		credit.DebtToIncome = &ratio
+		if ratio > DTIThreshold {
+			if enforce && DTIRejectAboveThreshold {
+				return http.StatusUnprocessableEntity, dtiExceededMessage(ratio)
+			}
+			credit.DTIFlagged = true
+		}
+	}
+	return 0, ""
+}
+
+// creditReferencesError checks inside tx that the client and bank of credit
+// exist and are not soft-deleted, and keeps them from being deleted until tx
+// ends. It returns the HTTP status and error message to report, or a zero
+// status.
+func creditReferencesError(tx *sql.Tx, r *http.Request, credit *models.Credit) (int, string) {
+	for _, ref := range []struct {
+		table, name string
+		id          int
+	}{{"clients", "Client", credit.ClientID}, {"banks", "Bank", credit.BankID}} {
+		var id int
+		err := tx.QueryRow("SELECT id FROM "+ref.table+" WHERE id = $1 AND deleted_at IS NULL FOR SHARE", ref.id).Scan(&id)
+		if err == sql.ErrNoRows {
+			return http.StatusUnprocessableEntity, ref.name + " " + strconv.Itoa(ref.id) + " does not exist or has been deleted"
+		}
+		if err != nil {
+			if logger.APILogger != nil {
+				logger.APILogger.LogError(r.Method, r.URL.Path, "Credit reference check failed: "+err.Error())
+			}
+			return http.StatusInternalServerError, "Database error"
+		}
+	}
+	return 0, ""
 }
 
 func UpdateCredit(w http.ResponseWriter, r *http.Request) {
@@ -549,35 +1023,86 @@ func UpdateCredit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = database.DB.Exec(`
-		UPDATE credits 
-		SET client_id = $1, bank_id = $2, min_payment = $3, max_payment = $4, 
-		    term_months = $5, credit_type = $6, status = $7 
-		WHERE id = $8
-	`, credit.ClientID, credit.BankID, credit.MinPayment, credit.MaxPayment, 
-	   credit.TermMonths, credit.CreditType, credit.Status, id)
+	expected, ok := expectedVersion(w, r)
+	if !ok {
+		return
+	}
 
+	tx, err := database.DB.Begin()
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update credit"})
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
 		return
 	}
+	defer tx.Rollback()
 
-	// Fetch updated credit
-	err = database.DB.QueryRow(`
-		SELECT id, client_id, bank_id, min_payment, max_payment, term_months, 
-		       credit_type, status, created_at 
-		FROM credits WHERE id = $1
-	`, id).Scan(&credit.ID, &credit.ClientID, &credit.BankID, 
-		&credit.MinPayment, &credit.MaxPayment, &credit.TermMonths,
-		&credit.CreditType, &credit.Status, &credit.CreatedAt)
-	
+	if status, msg := creditReferencesError(tx, r, &credit); status != 0 {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": msg})
+		return
+	}
+
+	// The row stays locked until the update so the checks below cannot go
+	// stale. A missing credit is reported by the update.
+	var currentStatus models.CreditStatus
+	var currentClientID int
+	var currentMaxPayment float64
+	err = tx.QueryRow("SELECT status, client_id, max_payment FROM credits WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", id).
+		Scan(&currentStatus, &currentClientID, &currentMaxPayment)
+	if err != nil && err != sql.ErrNoRows {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	found := err == nil
+
+	// An approved credit needs verified, unexpired KYC documents of its
+	// client, both when it is approved and when it moves to another client.
+	if found && credit.Status == models.CreditStatusApproved &&
+		(currentStatus != models.CreditStatusApproved || currentClientID != credit.ClientID) {
+		if status, msg := approvalDocumentsError(tx, r, credit.ClientID); status != 0 {
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]string{"error": msg})
+			return
+		}
+	}
+
+	// The stored affordability follows the credit's client and max payment.
+	// Only a change taking on more debt is refused above the threshold, so a
+	// credit already over it can still be updated or rejected.
+	credit.ID = id
+	if found {
+		enforce := credit.Status != models.CreditStatusRejected &&
+			(currentClientID != credit.ClientID || credit.MaxPayment > currentMaxPayment)
+		if status, msg := assessCredit(tx, r, &credit, enforce); status != 0 {
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]string{"error": msg})
+			return
+		}
+	}
+
+	updated, err := updateAudited(tx, r, "credits", id, audit.OpUpdate, `
+		client_id = $2, bank_id = $3, min_payment = $4, max_payment = $5,
+		term_months = $6, credit_type = $7, status = $8, debt_to_income = $9, dti_flagged = $10,
+		version = version + 1`,
+		"deleted_at IS NULL AND ($11::int IS NULL OR version = $11)",
+		credit.ClientID, credit.BankID, credit.MinPayment, credit.MaxPayment,
+		credit.TermMonths, credit.CreditType, credit.Status, credit.DebtToIncome, credit.DTIFlagged, expected)
+	if err == sql.ErrNoRows {
+		writeUpdateMiss(w, "credits", id, expected, "Credit not found")
+		return
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch updated credit"})
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update credit"})
 		return
 	}
+	credit = updated.(models.Credit)
 
+	setETag(w, credit.Version)
 	json.NewEncoder(w).Encode(credit)
 }
 
@@ -591,17 +1116,32 @@ func DeleteCredit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := database.DB.Exec("DELETE FROM credits WHERE id = $1", id)
+	expected, ok := expectedVersion(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := database.DB.Begin()
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to delete credit"})
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
 		return
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil || rowsAffected == 0 {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Credit not found"})
+	_, err = updateAudited(tx, r, "credits", id, audit.OpDelete,
+		"deleted_at = CURRENT_TIMESTAMP, version = version + 1",
+		"deleted_at IS NULL AND ($2::int IS NULL OR version = $2)", expected)
+	if err == sql.ErrNoRows {
+		writeUpdateMiss(w, "credits", id, expected, "Credit not found")
+		return
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to delete credit"})
 		return
 	}
 
@@ -611,6 +1151,18 @@ func DeleteCredit(w http.ResponseWriter, r *http.Request) {
 
 func GetCreditsByClient(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	format, ok := listFormat(w, r)
+	if !ok {
+		return
+	}
+	expand, ok := parseExpand(w, r, "client", "bank")
+	if !ok {
+		return
+	}
+	fields, ok := parseFields(w, r, models.Credit{})
+	if !ok {
+		return
+	}
 	params := mux.Vars(r)
 	clientID, err := strconv.Atoi(params["clientId"])
 	if err != nil {
@@ -620,12 +1172,11 @@ func GetCreditsByClient(w http.ResponseWriter, r *http.Request) {
 	}
 
 	rows, err := database.DB.Query(`
-		SELECT id, client_id, bank_id, min_payment, max_payment, term_months, 
-		       credit_type, status, created_at 
+		SELECT ` + creditFields.selecting(fields, creditExpandKeys(expand)...) + `
 		FROM credits 
-		WHERE client_id = $1 
+		WHERE client_id = $1 AND ($2 OR deleted_at IS NULL)
 		ORDER BY created_at DESC
-	`, clientID)
+	`, clientID, includeDeleted(r))
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
@@ -633,22 +1184,23 @@ func GetCreditsByClient(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
-	credits := []models.Credit{}
-	for rows.Next() {
-		var credit models.Credit
-		if err := rows.Scan(&credit.ID, &credit.ClientID, &credit.BankID, 
-			&credit.MinPayment, &credit.MaxPayment, &credit.TermMonths,
-			&credit.CreditType, &credit.Status, &credit.CreatedAt); err != nil {
-			continue
-		}
-		credits = append(credits, credit)
-	}
-
-	json.NewEncoder(w).Encode(credits)
+	writeCredits(w, r, format, rows, expand, fields)
 }
 
 func GetCreditsByBank(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	format, ok := listFormat(w, r)
+	if !ok {
+		return
+	}
+	expand, ok := parseExpand(w, r, "client", "bank")
+	if !ok {
+		return
+	}
+	fields, ok := parseFields(w, r, models.Credit{})
+	if !ok {
+		return
+	}
 	params := mux.Vars(r)
 	bankID, err := strconv.Atoi(params["bankId"])
 	if err != nil {
@@ -658,12 +1210,11 @@ func GetCreditsByBank(w http.ResponseWriter, r *http.Request) {
 	}
 
 	rows, err := database.DB.Query(`
-		SELECT id, client_id, bank_id, min_payment, max_payment, term_months, 
-		       credit_type, status, created_at 
+		SELECT ` + creditFields.selecting(fields, creditExpandKeys(expand)...) + `
 		FROM credits 
-		WHERE bank_id = $1 
+		WHERE bank_id = $1 AND ($2 OR deleted_at IS NULL)
 		ORDER BY created_at DESC
-	`, bankID)
+	`, bankID, includeDeleted(r))
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
@@ -671,16 +1222,5 @@ func GetCreditsByBank(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
-	credits := []models.Credit{}
-	for rows.Next() {
-		var credit models.Credit
-		if err := rows.Scan(&credit.ID, &credit.ClientID, &credit.BankID, 
-			&credit.MinPayment, &credit.MaxPayment, &credit.TermMonths,
-			&credit.CreditType, &credit.Status, &credit.CreatedAt); err != nil {
-			continue
-		}
-		credits = append(credits, credit)
-	}
-
-	json.NewEncoder(w).Encode(credits)
+	writeCredits(w, r, format, rows, expand, fields)
 }
\ No newline at end of file
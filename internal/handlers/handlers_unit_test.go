@@ -1,15 +1,31 @@
 package handlers
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"backend/internal/encryption"
+	"backend/internal/importer"
 	"backend/internal/models"
+	"backend/internal/outbox"
+	"backend/internal/storage"
+	"backend/internal/stream"
 	"github.com/gorilla/mux"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // Test data for unit tests
@@ -303,4 +319,616 @@ func TestGetCreditsByBankInvalidID(t *testing.T) {
 		t.Errorf("handler returned wrong status code: got %v want %v",
 			status, http.StatusBadRequest)
 	}
-}
\ No newline at end of file
+}
+
+func TestUpdateFinancialProfileInvalidEmploymentType(t *testing.T) {
+	profile := models.FinancialProfile{
+		MonthlyIncome:  3000.0,
+		EmploymentType: "INVALID_TYPE",
+	}
+	jsonData, _ := json.Marshal(profile)
+	req, _ := http.NewRequest("PUT", "/api/clients/1/financial-profile", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	router := mux.NewRouter()
+	router.HandleFunc("/api/clients/{clientId}/financial-profile", UpdateFinancialProfile).Methods("PUT")
+
+	router.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code for invalid employment type: got %v want %v",
+			status, http.StatusBadRequest)
+	}
+}
+
+func TestComputeDebtToIncome(t *testing.T) {
+	ratio, err := computeDebtToIncome(4000.0, 500.0, 700.0, 300.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ratio != 0.375 {
+		t.Errorf("Expected debt-to-income 0.375, got %v", ratio)
+	}
+
+	// A tiny income must not overflow the stored ratio
+	if ratio, _ := computeDebtToIncome(0.01, 5e6, 0, 5e6); ratio != maxDebtToIncome {
+		t.Errorf("Expected the ratio to be capped at %v, got %v", maxDebtToIncome, ratio)
+	}
+
+	if _, err := computeDebtToIncome(0, 500.0, 0, 300.0); err != errNoMonthlyIncome {
+		t.Errorf("Expected errNoMonthlyIncome for zero income, got %v", err)
+	}
+}
+
+func TestUploadClientDocumentInvalidType(t *testing.T) {
+	store, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	DocumentStore = store
+	defer func() { DocumentStore = nil }()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("type", "INVALID_TYPE")
+	part, _ := writer.CreateFormFile("file", "document.pdf")
+	part.Write([]byte("%PDF-1.4 test document"))
+	writer.Close()
+
+	req, _ := http.NewRequest("POST", "/api/clients/1/documents", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	rr := httptest.NewRecorder()
+	router := mux.NewRouter()
+	router.HandleFunc("/api/clients/{clientId}/documents", UploadClientDocument).Methods("POST")
+
+	router.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code for invalid document type: got %v want %v",
+			status, http.StatusBadRequest)
+	}
+}
+
+func TestMergeClientIntoItself(t *testing.T) {
+	req, _ := http.NewRequest("POST", "/api/clients/1/merge", bytes.NewBufferString(`{"duplicate_id": 1}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	router := mux.NewRouter()
+	router.HandleFunc("/api/clients/{id}/merge", MergeClient).Methods("POST")
+
+	router.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code for self merge: got %v want %v",
+			status, http.StatusBadRequest)
+	}
+}
+
+func TestExpectedVersion(t *testing.T) {
+	req, _ := http.NewRequest("PUT", "/api/credits/1", nil)
+	req.Header.Set("If-Match", `"3"`)
+	rr := httptest.NewRecorder()
+
+	version, ok := expectedVersion(rr, req)
+	if !ok || version == nil || *version != 3 {
+		t.Errorf("Expected version 3, got %v (ok=%v)", version, ok)
+	}
+
+	req.Header.Set("If-Match", `W/"3"`)
+	rr = httptest.NewRecorder()
+	if _, ok := expectedVersion(rr, req); ok || rr.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected weak If-Match to fail with 412, got %v", rr.Code)
+	}
+
+	req.Header.Del("If-Match")
+	RequireIfMatch = true
+	defer func() { RequireIfMatch = false }()
+	rr = httptest.NewRecorder()
+	if _, ok := expectedVersion(rr, req); ok || rr.Code != http.StatusPreconditionRequired {
+		t.Errorf("Expected missing If-Match to fail with 428, got %v", rr.Code)
+	}
+}
+
+func TestDeletedRowsAreForAdmins(t *testing.T) {
+	AdminActors = map[string]bool{"ops": true}
+	defer func() { AdminActors = map[string]bool{} }()
+	handler := GuardDeleted(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !includeDeleted(r) {
+			t.Error("Expected an admin to get deleted rows")
+		}
+	}))
+
+	for actor, expected := range map[string]int{"": http.StatusForbidden, "clerk": http.StatusForbidden, "ops": http.StatusOK} {
+		req, _ := http.NewRequest("GET", "/api/clients?include_deleted=true", nil)
+		req.Header.Set(ActorHeader, actor)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != expected {
+			t.Errorf("Expected %d for actor %q, got %d", expected, actor, rr.Code)
+		}
+	}
+
+	// Without the guard a non-admin still does not get them
+	req, _ := http.NewRequest("GET", "/api/clients?include_deleted=true", nil)
+	req.Header.Set(ActorHeader, "clerk")
+	if includeDeleted(req) {
+		t.Error("Expected a non-admin not to get deleted rows")
+	}
+
+	if err := graphqlDeletedAllowed(context.WithValue(context.Background(), graphqlRequestKey{}, req), true); err == nil {
+		t.Error("Expected GraphQL to refuse deleted rows to a non-admin")
+	}
+	if err := grpcDeletedAllowed(context.Background(), true); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected gRPC to refuse deleted rows to a non-admin, got %v", err)
+	}
+}
+
+func TestCheckNotModified(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/api/credits/1", nil)
+	req.Header.Set("If-None-Match", `"1", "2"`)
+
+	rr := httptest.NewRecorder()
+	if !checkNotModified(rr, req, 2) || rr.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for matching version, got %v", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	if checkNotModified(rr, req, 3) {
+		t.Error("Expected no 304 for a newer version")
+	}
+}
+
+func TestGetAuditEventsInvalidFilters(t *testing.T) {
+	for _, query := range []string{"resource_id=abc", "from=yesterday", "limit=0", "limit=5000"} {
+		req, _ := http.NewRequest("GET", "/api/audit?"+query, nil)
+		rr := httptest.NewRecorder()
+		GetAuditEvents(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %q, got %v", query, rr.Code)
+		}
+	}
+}
+
+func TestAuditActor(t *testing.T) {
+	req, _ := http.NewRequest("POST", "/api/banks", nil)
+	if actor := auditActor(req); actor != anonymousActor {
+		t.Errorf("Expected %q without a header, got %q", anonymousActor, actor)
+	}
+
+	req.Header.Set(ActorHeader, " alice ")
+	if actor := auditActor(req); actor != "alice" {
+		t.Errorf("Expected alice, got %q", actor)
+	}
+}
+
+func TestStreamEventsInvalidFilters(t *testing.T) {
+	EventBroker = stream.NewBroker()
+	defer func() { EventBroker = nil }()
+
+	for _, query := range []string{"types=Unknown", "bank_id=abc", "last_event_id=x"} {
+		req, _ := http.NewRequest("GET", "/api/events/stream?"+query, nil)
+		rr := httptest.NewRecorder()
+		StreamEvents(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %q, got %v", query, rr.Code)
+		}
+	}
+}
+
+func TestStreamEventsLive(t *testing.T) {
+	EventBroker = stream.NewBroker()
+	defer func() { EventBroker = nil }()
+	server := httptest.NewServer(http.HandlerFunc(StreamEvents))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?types=CreditCreated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	lines := bufio.NewScanner(resp.Body)
+	for lines.Scan() && lines.Text() != "retry: 3000" {
+	}
+
+	// Subscribed once the retry line is written
+	// Event ids are commit sequence numbers, not outbox ids
+	EventBroker.Publish(outbox.Message{ID: 1, Sequence: 11, Type: outbox.ClientCreated, AggregateType: "client", Payload: json.RawMessage(`{}`)})
+	EventBroker.Publish(outbox.Message{ID: 2, Sequence: 12, Type: outbox.CreditCreated, AggregateType: "credit", Payload: json.RawMessage(`{}`)})
+	for lines.Scan() {
+		if id, ok := strings.CutPrefix(lines.Text(), "id: "); ok {
+			if id != "12" {
+				t.Errorf("Expected only the credit event with its sequence number, got id %s", id)
+			}
+			break
+		}
+	}
+}
+
+func TestStreamEventsWithoutBroker(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/api/events/stream", nil)
+	rr := httptest.NewRecorder()
+	StreamEvents(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503, got %v", rr.Code)
+	}
+}
+
+func TestCreateCreditsBatchInvalidInput(t *testing.T) {
+	tooMany := "[" + strings.Repeat("{},", MaxBatchSize) + "{}]"
+	for name, tc := range map[string]struct{ query, body string }{
+		"unknown mode": {"?mode=partial", `[{}]`},
+		"not an array": {"", `{"client_id": 1}`},
+		"empty":        {"", `[]`},
+		"too many":     {"", tooMany},
+	} {
+		req, _ := http.NewRequest("POST", "/api/credits:batch"+tc.query, strings.NewReader(tc.body))
+		rr := httptest.NewRecorder()
+		CreateCreditsBatch(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %v", name, rr.Code)
+		}
+	}
+}
+
+func TestUpdateCreditsStatusInvalidInput(t *testing.T) {
+	for _, body := range []string{
+		`not json`,
+		`{"status": "CLOSED", "filter": {"bank_id": 1}}`,
+		`{"status": "REJECTED", "filter": {}}`,
+	} {
+		req, _ := http.NewRequest("POST", "/api/credits:batch-status", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		UpdateCreditsStatus(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %q, got %v", body, rr.Code)
+		}
+	}
+}
+
+func TestCreateImportInvalidInput(t *testing.T) {
+	for name, tc := range map[string]struct {
+		fields   map[string]string
+		fileName string
+		content  string
+	}{
+		"unknown resource": {map[string]string{"resource": "banks"}, "clients.csv", "full_name\n"},
+		"bad mapping":      {map[string]string{"resource": "clients", "mapping": "[1]"}, "clients.csv", "full_name\n"},
+		"unknown format":   {map[string]string{"resource": "clients"}, "clients.txt", "full_name\n"},
+		"empty file":       {map[string]string{"resource": "clients"}, "clients.csv", ""},
+		"missing column":   {map[string]string{"resource": "clients"}, "clients.csv", "full_name,email,country\n"},
+		"not a workbook":   {map[string]string{"resource": "clients"}, "clients.xlsx", "full_name\n"},
+	} {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		for key, value := range tc.fields {
+			writer.WriteField(key, value)
+		}
+		part, _ := writer.CreateFormFile("file", tc.fileName)
+		part.Write([]byte(tc.content))
+		writer.Close()
+
+		req, _ := http.NewRequest("POST", "/api/imports", &body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rr := httptest.NewRecorder()
+		CreateImport(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %v", name, rr.Code)
+		}
+	}
+}
+
+func TestListInvalidFormat(t *testing.T) {
+	for _, handler := range []http.HandlerFunc{GetItems, GetBanks, GetCredits, GetAuditEvents, GetWebhooks, GetImports} {
+		req, _ := http.NewRequest("GET", "/api/list?format=xml", nil)
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for an unknown format, got %v", rr.Code)
+		}
+	}
+}
+
+func TestInvalidExpand(t *testing.T) {
+	for name, tc := range map[string]struct {
+		handler http.HandlerFunc
+		path    string
+	}{
+		"credits": {GetCredits, "/api/credits?expand=client,owner"},
+		"banks":   {GetBanks, "/api/banks?expand=client"},
+		"credit":  {GetCredit, "/api/credits/1?expand=credits"},
+		"client":  {GetClient, "/api/clients/1?expand=bank"},
+	} {
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		req = mux.SetURLVars(req, map[string]string{"id": "1"})
+		rr := httptest.NewRecorder()
+		tc.handler(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400 for an unknown relation, got %v", name, rr.Code)
+		}
+	}
+}
+
+func TestInvalidFields(t *testing.T) {
+	for name, tc := range map[string]struct {
+		handler http.HandlerFunc
+		path    string
+	}{
+		"credits":  {GetCredits, "/api/credits?fields=id,balance"},
+		"credit":   {GetCredit, "/api/credits/1?fields=client"},
+		"banks":    {GetBanks, "/api/banks?fields=name,email"},
+		"client":   {GetClient, "/api/clients/1?fields=full_name,,email"},
+		"webhooks": {GetWebhooks, "/api/webhooks?fields=payload"},
+	} {
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		req = mux.SetURLVars(req, map[string]string{"id": "1"})
+		rr := httptest.NewRecorder()
+		tc.handler(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400 for an unknown field, got %v", name, rr.Code)
+		}
+	}
+}
+
+func TestFieldColumnsSelecting(t *testing.T) {
+	if creditColumns != creditFields.selecting(nil) {
+		t.Errorf("Expected every column without a field set, got %q", creditColumns)
+	}
+
+	selected := creditFields.selecting(map[string]bool{"status": true, "max_payment": true}, "version")
+	expected := "0, 0, 0, 0, max_payment, 0, '', status, NULL, false, NULL, version, 'epoch'::timestamptz"
+	if selected != expected {
+		t.Errorf("Expected %q, got %q", expected, selected)
+	}
+
+	selected = clientFields.selecting(map[string]bool{"email": true})
+	if !strings.Contains(selected, "email_enc") || strings.Contains(selected, "full_name") {
+		t.Errorf("Expected the email columns only, got %q", selected)
+	}
+}
+
+func TestGraphQLWithoutDatabase(t *testing.T) {
+	for name, tc := range map[string]struct {
+		body     string
+		status   int
+		contains string
+	}{
+		"no query":       {`{"variables":{}}`, http.StatusBadRequest, "Request body must be a JSON object with a query"},
+		"unknown field":  {`{"query":"{ clients { items { balance } } }"}`, http.StatusOK, "Cannot query field balance on type Client"},
+		"page too large": {`{"query":"{ banks(first: 501) { items { id } } }"}`, http.StatusOK, "first must be between 1 and 500"},
+		"invalid id":     {`{"query":"{ credit(id: \"abc\") { id } }"}`, http.StatusOK, `"message":"Invalid ID","path":["credit"]`},
+		"too deep": {
+			`{"query":"{ clients { items { credits { items { bank { credits { items { client { credits { items { id } } } } } } } } } } }"}`,
+			http.StatusOK, "Query is nested more than 10 fields deep",
+		},
+		"too costly": {
+			`{"query":"query($n: Int) { clients(first: 500) { items { credits(first: $n) { items { bank { credits(first: 500) { items { id } } } } } } } }","variables":{"n":500}}`,
+			http.StatusOK, "Query may resolve more than 100000 fields",
+		},
+		"rest validation": {
+			`{"query":"mutation($input: CreditInput!) { createCredit(input: $input) { id } }","variables":{"input":` +
+				`{"clientId":"1","bankId":"2","minPayment":900,"maxPayment":100,"termMonths":12,"creditType":"AUTO"}}}`,
+			http.StatusOK, `"path":["createCredit"],"extensions":{"status":400}`,
+		},
+	} {
+		req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(tc.body))
+		rr := httptest.NewRecorder()
+		GraphQL(rr, req)
+
+		if rr.Code != tc.status || !strings.Contains(rr.Body.String(), tc.contains) {
+			t.Errorf("%s: expected %d with %q, got %d %s", name, tc.status, tc.contains, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestGraphQLSchemaSDL(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/graphql/schema", nil)
+	rr := httptest.NewRecorder()
+	GraphQLSchema(rr, req)
+
+	for _, line := range []string{
+		"  clients(filter: ClientFilter, first: Int = 50, after: ID): ClientPage!",
+		"  credits(first: Int = 50, after: ID, includeDeleted: Boolean = false): CreditPage!",
+		"  updateCredit(id: ID!, input: CreditInput!, version: Int): Credit!",
+		"enum CreditStatus {",
+	} {
+		if !strings.Contains(rr.Body.String(), line) {
+			t.Errorf("Expected the schema to contain %q, got\n%s", line, rr.Body.String())
+		}
+	}
+}
+
+func TestMoneyV2(t *testing.T) {
+	if got := formatMoney(1250); got != "1250.00" {
+		t.Errorf("Expected 1250.00, got %q", got)
+	}
+	for amount, valid := range map[string]bool{
+		"1250": true, "1250.5": true, "1250.50": true,
+		"1250.505": false, "-1": false, "1e3": false, "": false, " 1": false,
+	} {
+		if _, ok := parseMoney(amount); ok != valid {
+			t.Errorf("parseMoney(%q): expected %v", amount, valid)
+		}
+	}
+
+	credit, msg := creditFromV2(CreditV2{ClientID: 1, BankID: 2, MinPayment: "100.10", MaxPayment: "900", TermMonths: 12})
+	if msg != "" || credit.MinPayment != 100.10 || credit.MaxPayment != 900 {
+		t.Errorf("Expected the amounts to be parsed, got %+v %q", credit, msg)
+	}
+	if v2 := creditToV2(credit); v2.MinPayment != "100.10" || v2.MaxPayment != "900.00" {
+		t.Errorf("Expected decimal strings, got %+v", v2)
+	}
+}
+
+func TestCreditsV2WithoutDatabase(t *testing.T) {
+	for name, tc := range map[string]struct {
+		method, path string
+		vars         map[string]string
+		body         string
+		handler      http.HandlerFunc
+		contains     string
+	}{
+		"limit too large": {"GET", "/api/v2/credits?limit=501", nil, "", GetCreditsV2, "limit must be between 1 and 500"},
+		"bad cursor":      {"GET", "/api/v2/credits?cursor=abc", nil, "", GetCreditsV2, "Invalid cursor"},
+		"bad status":      {"GET", "/api/v2/credits?status=OPEN", nil, "", GetCreditsV2, "Invalid status"},
+		"bad client":      {"GET", "/api/v2/clients/abc/credits", map[string]string{"clientId": "abc"}, "", GetCreditsByClientV2, "Invalid client ID"},
+		"bad id":          {"GET", "/api/v2/credits/abc", map[string]string{"id": "abc"}, "", GetCreditV2, "Invalid ID"},
+		"number amounts": {"POST", "/api/v2/credits", nil, `{"client_id":1,"bank_id":2,"min_payment":100,"max_payment":900}`,
+			CreateCreditV2, "Invalid request body"},
+		"bad amounts": {"PUT", "/api/v2/credits/1", map[string]string{"id": "1"}, `{"client_id":1,"bank_id":2,"min_payment":"1,000","max_payment":"900"}`,
+			UpdateCreditV2, "decimal strings"},
+		"v1 validation": {"POST", "/api/v2/credits", nil,
+			`{"client_id":1,"bank_id":2,"min_payment":"900","max_payment":"100","term_months":12,"credit_type":"AUTO"}`,
+			CreateCreditV2, "Invalid payment amounts. Min and max must be positive"},
+	} {
+		req, _ := http.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		rr := httptest.NewRecorder()
+		tc.handler(rr, mux.SetURLVars(req, tc.vars))
+
+		if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), tc.contains) {
+			t.Errorf("%s: expected 400 with %q, got %d %s", name, tc.contains, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestTrackJobs(t *testing.T) {
+	defer func() { jobs.ctx = context.Background() }()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := TrackJobs(ctx)
+
+	release := make(chan struct{})
+	stopped := false
+	if !startJob(func(ctx context.Context) {
+		<-ctx.Done()
+		<-release
+		stopped = true
+	}) {
+		t.Fatal("Expected the job to start")
+	}
+
+	cancel()
+	select {
+	case <-done:
+		t.Fatal("Expected TrackJobs to wait for the running job")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	<-done
+	if !stopped {
+		t.Error("Expected the job to have stopped")
+	}
+	if startJob(func(context.Context) { t.Error("Expected no job to run after shutdown") }) {
+		t.Error("Expected startJob to refuse work after shutdown")
+	}
+}
+
+func TestRejectedRowsAreEncrypted(t *testing.T) {
+	row := []string{"ana@example.com", "1", "100", "500", "twelve", "AUTO"}
+	record := importer.Record{"client_email": "ana@example.com"}
+
+	data, dataEnc, emailIndex, err := rejectedRowValues(importer.ResourceCredits, record, row)
+	if err != nil || dataEnc != nil || len(data) != len(row) || emailIndex == nil {
+		t.Fatalf("Expected a plaintext row with an email index, got %v %v %v %v", data, dataEnc, emailIndex, err)
+	}
+
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	path := filepath.Join(t.TempDir(), "keys.json")
+	keys := `{"current_key_id": "k1", "keys": {"k1": "` + key + `"}, "index_key": "` + key + `"}`
+	if err := os.WriteFile(path, []byte(keys), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	provider, err := encryption.NewLocalKeyProvider(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	PIIEncrypter = encryption.NewEncrypter(provider)
+	defer func() { PIIEncrypter = nil }()
+
+	data, dataEnc, emailIndex, err = rejectedRowValues(importer.ResourceCredits, record, row)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 0 || dataEnc == nil || strings.Contains(dataEnc.(string), "ana@example.com") {
+		t.Errorf("Expected the row to be stored encrypted only, got %v %v", data, dataEnc)
+	}
+	blindIndex, _ := PIIEncrypter.BlindIndex("ANA@example.com")
+	if emailIndex != blindIndex {
+		t.Errorf("Expected the blind index of the client email, got %v", emailIndex)
+	}
+
+	decrypted, err := rejectedRowData(data, sql.NullString{String: dataEnc.(string), Valid: true})
+	if err != nil || strings.Join(decrypted, ",") != strings.Join(row, ",") {
+		t.Errorf("Expected the original row back, got %v %v", decrypted, err)
+	}
+}
+
+func TestReencryptionReportsBusyRun(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	path := filepath.Join(t.TempDir(), "keys.json")
+	keys := `{"current_key_id": "k1", "keys": {"k1": "` + key + `"}, "index_key": "` + key + `"}`
+	if err := os.WriteFile(path, []byte(keys), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	provider, err := encryption.NewLocalKeyProvider(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	PIIEncrypter = encryption.NewEncrypter(provider)
+	defer func() { PIIEncrypter = nil }()
+
+	reencryptMu.Lock()
+	defer reencryptMu.Unlock()
+
+	if _, err := ReencryptClients(context.Background(), 10); !errors.Is(err, ErrReencryptionRunning) {
+		t.Errorf("Expected ErrReencryptionRunning, got %v", err)
+	}
+
+	req, _ := http.NewRequest("POST", "/api/admin/pii/reencrypt", nil)
+	rr := httptest.NewRecorder()
+	ReencryptClientsHandler(rr, req)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected 409 while a run is active, got %d", rr.Code)
+	}
+}
+
+func TestListWriterOutlivesWriteTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { StreamedWriteTimeout = timeout }(StreamedWriteTimeout)
+	StreamedWriteTimeout = 50 * time.Millisecond
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		list := newListWriter(w, "ndjson", "items", models.Item{})
+		for i := 1; i <= 5; i++ {
+			time.Sleep(30 * time.Millisecond)
+			list.Write(models.Item{ID: i, Name: "Item"})
+		}
+		list.Close()
+	}))
+	server.Config.WriteTimeout = 50 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	lines := 0
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		lines++
+	}
+	if lines != 5 {
+		t.Errorf("Expected all 5 rows after the write timeout, got %d: %v", lines, scanner.Err())
+	}
+}
@@ -0,0 +1,626 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"backend/internal/database"
+	"backend/internal/encryption"
+	"backend/internal/export"
+	"backend/internal/importer"
+	"backend/internal/logger"
+	"backend/internal/models"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// MaxImportSize caps uploaded spreadsheets.
+const MaxImportSize = 32 << 20
+
+// MaxImportRequestSize caps import requests, leaving room for the multipart
+// envelope and form fields around the file.
+const MaxImportRequestSize = MaxImportSize + 1<<20
+
+// ImportSyncRows is the largest import run within the request. Bigger files
+// run as a background job whose progress is polled with GetImport.
+var ImportSyncRows = 1000
+
+// importProgressEvery is the number of rows between progress updates.
+const importProgressEvery = 100
+
+var errImportInterrupted = errors.New("import interrupted by server shutdown")
+
+var importJobFields = fieldColumns{
+	{"id", "id", zeroNumber},
+	{"resource", "resource", zeroText},
+	{"format", "format", zeroText},
+	{"file_name", "file_name", zeroText},
+	{"dry_run", "dry_run", zeroBool},
+	{"mapping", "mapping", `'{}'`},
+	{"status", "status", zeroText},
+	{"total_rows", "total_rows", zeroNumber},
+	{"processed_rows", "processed_rows", zeroNumber},
+	{"imported_rows", "imported_rows", zeroNumber},
+	{"rejected_rows", "rejected_rows", zeroNumber},
+	{"error", "error", zeroNull},
+	{"created_at", "created_at", zeroTime},
+	{"started_at", "started_at", zeroNull},
+	{"finished_at", "finished_at", zeroNull},
+}
+
+var importJobColumns = importJobFields.all()
+
+func scanImportJob(row rowScanner, job *models.ImportJob) error {
+	var mapping []byte
+	var jobError sql.NullString
+	var startedAt, finishedAt sql.NullTime
+	if err := row.Scan(&job.ID, &job.Resource, &job.Format, &job.FileName, &job.DryRun, &mapping, &job.Status,
+		&job.TotalRows, &job.ProcessedRows, &job.ImportedRows, &job.RejectedRows, &jobError,
+		&job.CreatedAt, &startedAt, &finishedAt); err != nil {
+		return err
+	}
+	job.Mapping = map[string]string{}
+	if err := json.Unmarshal(mapping, &job.Mapping); err != nil {
+		return err
+	}
+	job.Error = jobError.String
+	job.StartedAt = nil
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	job.FinishedAt = nil
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return nil
+}
+
+// importRow validates and creates the resource in one row inside tx, behind a
+// savepoint so a rejected row leaves the rest of the import intact. It
+// returns the reason a row was rejected, or an empty string.
+func importRow(tx *sql.Tx, r *http.Request, resource string, record importer.Record) (string, error) {
+	if _, err := tx.Exec("SAVEPOINT import_row"); err != nil {
+		return "", err
+	}
+
+	var rejection string
+	switch resource {
+	case importer.ResourceClients:
+		client, err := importer.ParseClient(record)
+		if err != nil {
+			rejection = err.Error()
+		} else if _, msg := createClient(tx, r, &client); msg != "" {
+			rejection = msg
+		}
+	case importer.ResourceCredits:
+		row, err := importer.ParseCredit(record)
+		if err != nil {
+			rejection = err.Error()
+			break
+		}
+		if row.ClientEmail != "" {
+			clientID, err := clientIDByEmail(tx, row.ClientEmail)
+			if err == sql.ErrNoRows {
+				rejection = "No client with this email"
+				break
+			}
+			if err != nil {
+				rejection = "Database error"
+				break
+			}
+			row.Credit.ClientID = clientID
+		}
+		if _, msg := createCredit(tx, r, &row.Credit); msg != "" {
+			rejection = msg
+		}
+	}
+
+	if rejection != "" {
+		_, err := tx.Exec("ROLLBACK TO SAVEPOINT import_row")
+		return rejection, err
+	}
+	_, err := tx.Exec("RELEASE SAVEPOINT import_row")
+	return "", err
+}
+
+// rejectedRowValues returns the data, data_enc and email_index values stored
+// for a rejected row. With PII encryption the whole row is encrypted, as any
+// column may hold personal data. The email index ties the row to the client
+// it names, so client export and erasure find it.
+func rejectedRowValues(resource string, record importer.Record, row []string) ([]string, interface{}, interface{}, error) {
+	email := record["email"]
+	if resource == importer.ResourceCredits {
+		email = record["client_email"]
+	}
+	var emailIndex interface{}
+	if strings.TrimSpace(email) != "" {
+		indexes, err := rejectionEmailIndexes(email)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		emailIndex = indexes[len(indexes)-1]
+	}
+
+	if PIIEncrypter == nil {
+		return row, nil, emailIndex, nil
+	}
+	encoded, err := json.Marshal(row)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	dataEnc, err := PIIEncrypter.Encrypt(string(encoded))
+	return []string{}, dataEnc, emailIndex, err
+}
+
+// rejectionEmailIndexes returns the email_index values rejected rows naming
+// email may carry: a plain hash for rows stored without PII encryption and,
+// last, the blind index when encryption is configured.
+func rejectionEmailIndexes(email string) ([]string, error) {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	indexes := []string{hex.EncodeToString(sum[:])}
+	if PIIEncrypter == nil {
+		return indexes, nil
+	}
+	index, err := PIIEncrypter.BlindIndex(email)
+	if err != nil {
+		return nil, err
+	}
+	return append(indexes, index), nil
+}
+
+// rejectedRowData returns the stored row of a rejection, decrypting it when
+// it was stored encrypted.
+func rejectedRowData(data []string, dataEnc sql.NullString) ([]string, error) {
+	if !dataEnc.Valid {
+		return data, nil
+	}
+	if PIIEncrypter == nil {
+		return nil, encryption.ErrUnknownKey
+	}
+	decrypted, err := PIIEncrypter.Decrypt(dataEnc.String)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal([]byte(decrypted), &data)
+	return data, err
+}
+
+// runImport processes the file at path for job and records progress,
+// rejected rows and the outcome on the job. The file is removed afterwards.
+// All rows are created in one transaction, which a dry run rolls back and
+// which is abandoned when ctx is done.
+func runImport(ctx context.Context, r *http.Request, job models.ImportJob, path string, columns importer.Columns) {
+	defer os.Remove(path)
+
+	err := processImport(ctx, r, job, path, columns)
+	if err != nil {
+		log.Printf("Import %d failed: %v", job.ID, err)
+		err = failImport(job.ID, err.Error())
+	}
+	if err != nil {
+		log.Printf("Failed to record the outcome of import %d: %v", job.ID, err)
+	}
+}
+
+func failImport(id int, reason string) error {
+	_, err := database.DB.Exec(`
+		UPDATE import_jobs SET status = $2, error = $3, finished_at = CURRENT_TIMESTAMP WHERE id = $1
+	`, id, models.ImportJobFailed, reason)
+	return err
+}
+
+// FailInterruptedImports marks imports left pending or running by a previous
+// run of the server as failed. Their transaction died with that process, so
+// none of their rows were kept.
+func FailInterruptedImports() (int64, error) {
+	result, err := database.DB.Exec(`
+		UPDATE import_jobs SET status = $1, error = 'Interrupted by a server restart', finished_at = CURRENT_TIMESTAMP
+		WHERE status IN ($2, $3)
+	`, models.ImportJobFailed, models.ImportJobPending, models.ImportJobRunning)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func processImport(ctx context.Context, r *http.Request, job models.ImportJob, path string, columns importer.Columns) error {
+	if _, err := database.DB.Exec(
+		"UPDATE import_jobs SET status = $2, started_at = CURRENT_TIMESTAMP WHERE id = $1",
+		job.ID, models.ImportJobRunning); err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	reader, err := importer.Open(job.Format, f, info.Size())
+	if err != nil {
+		return err
+	}
+	if _, err := reader.Read(); err != nil {
+		return err
+	}
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Rows are numbered as in the spreadsheet, so the first one after the
+	// header is row 2
+	processed, imported, rejected := 0, 0, 0
+	for rowNumber := 2; ; rowNumber++ {
+		if ctx.Err() != nil {
+			return errImportInterrupted
+		}
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		record := columns.Record(row)
+		rejection, err := importRow(tx, r, job.Resource, record)
+		if err != nil {
+			return err
+		}
+		processed++
+		if rejection == "" {
+			imported++
+		} else {
+			rejected++
+			data, dataEnc, emailIndex, err := rejectedRowValues(job.Resource, record, row)
+			if err != nil {
+				return err
+			}
+			if _, err := database.DB.Exec(`
+				INSERT INTO import_rejections (job_id, row_number, error, data, data_enc, email_index)
+				VALUES ($1, $2, $3, $4, $5, $6)
+			`, job.ID, rowNumber, rejection, pq.Array(data), dataEnc, emailIndex); err != nil {
+				return err
+			}
+		}
+
+		// Imported rows are only counted once they are committed
+		if processed%importProgressEvery == 0 {
+			if _, err := database.DB.Exec(
+				"UPDATE import_jobs SET processed_rows = $2, rejected_rows = $3 WHERE id = $1",
+				job.ID, processed, rejected); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The job completes in the transaction that keeps its rows
+	const complete = `
+		UPDATE import_jobs
+		SET status = $2, processed_rows = $3, imported_rows = $4, rejected_rows = $5, finished_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`
+	if job.DryRun {
+		tx.Rollback()
+		_, err = database.DB.Exec(complete, job.ID, models.ImportJobCompleted, processed, imported, rejected)
+		return err
+	}
+	if _, err := tx.Exec(complete, job.ID, models.ImportJobCompleted, processed, imported, rejected); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Import handlers
+
+// CreateImport accepts a multipart form with a "file" part (CSV or XLSX), the
+// "resource" to import (clients or credits) and the optional "mapping" (a
+// JSON object of field to column header), "format" and "dry_run" fields.
+// Files with up to ImportSyncRows rows are imported before responding with
+// 201; larger ones run in the background and the response is 202.
+func CreateImport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxImportRequestSize)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid multipart form or file too large"})
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	job := models.ImportJob{
+		Resource: r.FormValue("resource"),
+		Format:   r.FormValue("format"),
+		DryRun:   r.FormValue("dry_run") == "true",
+		Mapping:  map[string]string{},
+		Status:   models.ImportJobPending,
+	}
+	if _, ok := importer.Fields[job.Resource]; !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid resource. Must be clients or credits"})
+		return
+	}
+	if value := r.FormValue("mapping"); value != "" {
+		if err := json.Unmarshal([]byte(value), &job.Mapping); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid mapping. Expected a JSON object of field to column header"})
+			return
+		}
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Missing file"})
+		return
+	}
+	defer file.Close()
+	job.FileName = header.Filename
+	if job.Format == "" {
+		job.Format = importer.FormatFromName(header.Filename)
+	}
+	if job.Format != importer.FormatCSV && job.Format != importer.FormatXLSX {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid format. Must be csv or xlsx"})
+		return
+	}
+
+	// The upload is copied to a file of its own because the multipart form
+	// is removed when the request ends, before a background import is done
+	tmp, err := os.CreateTemp("", "import-*")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to store file"})
+		return
+	}
+	keep := false
+	defer func() {
+		tmp.Close()
+		if !keep {
+			os.Remove(tmp.Name())
+		}
+	}()
+	size, err := io.Copy(tmp, file)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to store file"})
+		return
+	}
+
+	// Check the header and count the rows up front so mapping mistakes are
+	// reported immediately and progress has a total
+	reader, err := importer.Open(job.Format, tmp, size)
+	var headerRow []string
+	if err == nil {
+		headerRow, err = reader.Read()
+		if err == io.EOF {
+			err = fmt.Errorf("file has no header row")
+		}
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid file: " + err.Error()})
+		return
+	}
+	columns, err := importer.Mapping(job.Mapping).Resolve(job.Resource, headerRow)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid mapping: " + err.Error()})
+		return
+	}
+	rows, err := importer.CountRows(reader)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid file: " + err.Error()})
+		return
+	}
+	job.TotalRows = rows
+
+	mapping, _ := json.Marshal(job.Mapping)
+	err = database.DB.QueryRow(`
+		INSERT INTO import_jobs (resource, format, file_name, dry_run, mapping, columns, status, total_rows)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`, job.Resource, job.Format, job.FileName, job.DryRun, mapping, pq.Array(headerRow), job.Status, job.TotalRows,
+	).Scan(&job.ID, &job.CreatedAt)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create import"})
+		return
+	}
+
+	// The import runs as a background job, which a shutdown stops, and
+	// outlives the request when the file is large. It still records the
+	// caller as the actor of every change.
+	jobRequest := r.Clone(context.WithoutCancel(r.Context()))
+	finished := make(chan struct{})
+	started := startJob(func(ctx context.Context) {
+		defer close(finished)
+		runImport(ctx, jobRequest, job, tmp.Name(), columns)
+	})
+	if !started {
+		if err := failImport(job.ID, "Server is shutting down"); err != nil {
+			log.Printf("Failed to record the outcome of import %d: %v", job.ID, err)
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Server is shutting down"})
+		return
+	}
+	keep = true
+	if job.TotalRows > ImportSyncRows {
+		w.Header().Set("Location", "/api/imports/"+strconv.Itoa(job.ID))
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(job)
+		return
+	}
+
+	// The import may take longer than the server's write timeout allows
+	<-finished
+	extendWriteDeadline(http.NewResponseController(w))
+	err = scanImportJob(database.DB.QueryRow("SELECT "+importJobColumns+" FROM import_jobs WHERE id = $1", job.ID), &job)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	w.Header().Set("Location", "/api/imports/"+strconv.Itoa(job.ID))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(job)
+}
+
+func GetImports(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	format, ok := listFormat(w, r)
+	if !ok {
+		return
+	}
+	fields, ok := parseFields(w, r, models.ImportJob{})
+	if !ok {
+		return
+	}
+	rows, err := database.DB.Query("SELECT " + importJobFields.selecting(fields) + " FROM import_jobs ORDER BY id DESC LIMIT 100")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	list := newListWriter(w, format, "imports", export.Select(models.ImportJob{}, fields))
+	for rows.Next() {
+		var job models.ImportJob
+		if err := scanImportJob(rows, &job); err != nil {
+			if logger.APILogger != nil {
+				logger.APILogger.LogError(r.Method, r.URL.Path, "Row scan failed: "+err.Error())
+			}
+			continue
+		}
+		if err := list.Write(export.Select(job, fields)); err != nil {
+			break
+		}
+	}
+
+	list.Close()
+}
+
+// GetImport returns an import job, including its progress while it runs.
+func GetImport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	params := mux.Vars(r)
+	id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid ID"})
+		return
+	}
+	fields, ok := parseFields(w, r, models.ImportJob{})
+	if !ok {
+		return
+	}
+
+	var job models.ImportJob
+	err = scanImportJob(database.DB.QueryRow("SELECT "+importJobFields.selecting(fields)+" FROM import_jobs WHERE id = $1", id), &job)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Import not found"})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(export.Select(job, fields))
+}
+
+// GetImportErrors downloads the rejected rows of an import as CSV: the row
+// number, the reason and the row as it was uploaded.
+func GetImportErrors(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	var columns []string
+	err = database.DB.QueryRow("SELECT columns FROM import_jobs WHERE id = $1", id).Scan(pq.Array(&columns))
+	if err == sql.ErrNoRows {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Import not found"})
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+
+	rows, err := database.DB.Query(
+		"SELECT row_number, error, data, data_enc FROM import_rejections WHERE job_id = $1 ORDER BY row_number", id)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="import-%d-errors.csv"`, id))
+	out := csv.NewWriter(&deadlineWriter{w: w, controller: http.NewResponseController(w)})
+	out.Write(append([]string{"row", "error"}, columns...))
+	for rows.Next() {
+		var rowNumber int
+		var rejection string
+		var data []string
+		var dataEnc sql.NullString
+		if err := rows.Scan(&rowNumber, &rejection, pq.Array(&data), &dataEnc); err != nil {
+			// The status line is already sent, so the report is cut short
+			if logger.APILogger != nil {
+				logger.APILogger.LogError(r.Method, r.URL.Path, "Error scanning import rejections: "+err.Error())
+			}
+			break
+		}
+		data, err := rejectedRowData(data, dataEnc)
+		if err != nil {
+			if logger.APILogger != nil {
+				logger.APILogger.LogError(r.Method, r.URL.Path, "Failed to decrypt import rejection: "+err.Error())
+			}
+			break
+		}
+		// The cells are untrusted spreadsheet input, so none may open as a
+		// formula
+		record := []string{strconv.Itoa(rowNumber), export.EscapeFormula(rejection)}
+		for _, cell := range data {
+			record = append(record, export.EscapeFormula(cell))
+		}
+		out.Write(record)
+	}
+	out.Flush()
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+)
+
+// Background work started by requests, such as import jobs and PII
+// re-encryption runs, outlives the request that started it. It runs under the
+// context given to TrackJobs so that a shutdown can stop it and wait for it.
+var jobs = struct {
+	mu      sync.Mutex
+	ctx     context.Context
+	running sync.WaitGroup
+}{ctx: context.Background()}
+
+// TrackJobs runs background work started by requests under ctx from now on.
+// Once ctx is done no new work starts, and the returned channel is closed when
+// the work already running has stopped.
+func TrackJobs(ctx context.Context) <-chan struct{} {
+	jobs.mu.Lock()
+	jobs.ctx = ctx
+	jobs.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-ctx.Done()
+		// Jobs starting right now have either been added or will see ctx done
+		jobs.mu.Lock()
+		jobs.mu.Unlock()
+		jobs.running.Wait()
+	}()
+	return done
+}
+
+// startJob runs job in a goroutine under the TrackJobs context. It returns
+// false without running job once that context is done.
+func startJob(job func(ctx context.Context)) bool {
+	jobs.mu.Lock()
+	defer jobs.mu.Unlock()
+	if jobs.ctx.Err() != nil {
+		return false
+	}
+	jobs.running.Add(1)
+	go func(ctx context.Context) {
+		defer jobs.running.Done()
+		job(ctx)
+	}(jobs.ctx)
+	return true
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"backend/internal/audit"
+	"backend/internal/database"
+	"backend/internal/dedupe"
+	"backend/internal/export"
+	"backend/internal/logger"
+	"backend/internal/models"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+const defaultDuplicateMinScore = 0.8
+
+// Duplicate handlers
+
+// GetDuplicateClients lists pairs of clients that are likely the same person,
+// scored on name similarity, birth date and country. Pass ?min_score= to
+// change the cut-off (default 0.8).
+func GetDuplicateClients(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	format, ok := listFormat(w, r)
+	if !ok {
+		return
+	}
+	fields, ok := parseFields(w, r, dedupe.Candidate{})
+	if !ok {
+		return
+	}
+
+	minScore := defaultDuplicateMinScore
+	if value := r.URL.Query().Get("min_score"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "min_score must be a number between 0 and 1"})
+			return
+		}
+		minScore = parsed
+	}
+
+	// Names may be encrypted, so scoring happens here rather than in SQL, and
+	// needs every client column whatever the field set
+	rows, err := database.DB.Query("SELECT " + clientColumns + " FROM clients WHERE erased_at IS NULL AND deleted_at IS NULL")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	clients := []models.Client{}
+	for rows.Next() {
+		var client models.Client
+		if err := scanClient(rows, &client); err != nil {
+			if logger.APILogger != nil {
+				logger.APILogger.LogError(r.Method, r.URL.Path, "Row scan failed: "+err.Error())
+			}
+			continue
+		}
+		clients = append(clients, client)
+	}
+
+	list := newListWriter(w, format, "duplicate-clients", export.Select(dedupe.Candidate{}, fields))
+	for _, candidate := range dedupe.FindCandidates(clients, minScore) {
+		if err := list.Write(export.Select(candidate, fields)); err != nil {
+			break
+		}
+	}
+	list.Close()
+}
+
+// MergeClient moves the credits, documents and financial profile history of
+// the client given as duplicate_id onto the client in the path, deletes the
+// duplicate and records the merge, all in one transaction.
+func MergeClient(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	params := mux.Vars(r)
+	survivorID, err := strconv.Atoi(params["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	var body struct {
+		DuplicateID int `json:"duplicate_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+		return
+	}
+	if body.DuplicateID <= 0 || body.DuplicateID == survivorID {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "duplicate_id must reference a different client"})
+		return
+	}
+
+	merge, status, msg := mergeClients(r, survivorID, body.DuplicateID)
+	if status != http.StatusOK {
+		if status == http.StatusInternalServerError && logger.APILogger != nil {
+			logger.APILogger.LogError(r.Method, r.URL.Path, "Client merge failed: "+msg)
+			msg = "Failed to merge clients"
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": msg})
+		return
+	}
+
+	json.NewEncoder(w).Encode(merge)
+}
+
+// mergeClients performs the merge and returns the HTTP status and error
+// message to report when it fails.
+func mergeClients(r *http.Request, survivorID, duplicateID int) (models.ClientMerge, int, string) {
+	merge := models.ClientMerge{
+		SurvivorID:       survivorID,
+		DuplicateID:      duplicateID,
+		MovedCreditIDs:   []int64{},
+		MovedDocumentIDs: []int64{},
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return merge, http.StatusInternalServerError, err.Error()
+	}
+	defer tx.Rollback()
+
+	// Lock both clients in id order to avoid deadlocks with concurrent merges
+	rows, err := tx.Query(`
+		SELECT id, erased_at IS NOT NULL OR deleted_at IS NOT NULL
+		FROM clients WHERE id IN ($1, $2)
+		ORDER BY id
+		FOR UPDATE
+	`, survivorID, duplicateID)
+	if err != nil {
+		return merge, http.StatusInternalServerError, err.Error()
+	}
+	found := 0
+	anyErased := false
+	for rows.Next() {
+		var id int
+		var erased bool
+		if err := rows.Scan(&id, &erased); err != nil {
+			rows.Close()
+			return merge, http.StatusInternalServerError, err.Error()
+		}
+		found++
+		anyErased = anyErased || erased
+	}
+	rows.Close()
+	if found != 2 {
+		return merge, http.StatusNotFound, "Client not found"
+	}
+	if anyErased {
+		return merge, http.StatusConflict, "Erased or deleted clients cannot be merged"
+	}
+
+	if merge.MovedCreditIDs, err = moveClientRows(tx, r, "credits", ", version = version + 1", survivorID, duplicateID); err != nil {
+		return merge, http.StatusInternalServerError, err.Error()
+	}
+	if merge.MovedDocumentIDs, err = moveClientRows(tx, r, "client_documents", "", survivorID, duplicateID); err != nil {
+		return merge, http.StatusInternalServerError, err.Error()
+	}
+	if merge.MovedProfileVersions, err = moveProfileVersions(tx, survivorID, duplicateID); err != nil {
+		return merge, http.StatusInternalServerError, err.Error()
+	}
+
+	var duplicate models.Client
+	err = scanClient(tx.QueryRow("DELETE FROM clients WHERE id = $1 RETURNING "+clientColumns, duplicateID), &duplicate)
+	if err != nil {
+		return merge, http.StatusInternalServerError, err.Error()
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO client_merges (survivor_id, duplicate_id, moved_credit_ids, moved_document_ids, moved_profile_versions)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, survivorID, duplicateID, pq.Array(merge.MovedCreditIDs), pq.Array(merge.MovedDocumentIDs),
+		merge.MovedProfileVersions).Scan(&merge.ID, &merge.CreatedAt)
+	if err == nil {
+		err = recordChange(tx, r, "client", duplicateID, audit.OpMerge, duplicate, nil)
+	}
+	if err == nil {
+		err = recordChange(tx, r, "client", survivorID, audit.OpMerge, nil, merge)
+	}
+	if err != nil {
+		return merge, http.StatusInternalServerError, err.Error()
+	}
+
+	if err := tx.Commit(); err != nil {
+		return merge, http.StatusInternalServerError, err.Error()
+	}
+	return merge, http.StatusOK, ""
+}
+
+// moveClientRows reassigns every row of table owned by duplicateID to
+// survivorID and returns the ids of the moved rows. Each row goes through
+// updateAudited so it gets its own audit and domain events. extraSet is
+// appended to the SET clause, e.g. to bump row versions.
+func moveClientRows(tx *sql.Tx, r *http.Request, table, extraSet string, survivorID, duplicateID int) ([]int64, error) {
+	rows, err := tx.Query("SELECT id FROM "+table+" WHERE client_id = $1 ORDER BY id", duplicateID)
+	if err != nil {
+		return nil, err
+	}
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, err
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		_, err := updateAudited(tx, r, table, int(id), audit.OpUpdate,
+			"client_id = $2"+extraSet, "client_id = $3", survivorID, duplicateID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// moveProfileVersions moves the duplicate's financial profile history onto
+// the survivor, before the survivor's own versions, so that the survivor's
+// current profile stays the latest one. It returns the number of versions
+// moved.
+func moveProfileVersions(tx *sql.Tx, survivorID, duplicateID int) (int, error) {
+	// (client_id, version) is unique and checked row by row, so the
+	// survivor's versions are shifted through negative numbers
+	_, err := tx.Exec(`
+		UPDATE client_financial_profiles
+		SET version = -(version + (SELECT COALESCE(MAX(version), 0) FROM client_financial_profiles WHERE client_id = $2))
+		WHERE client_id = $1
+	`, survivorID, duplicateID)
+	if err != nil {
+		return 0, err
+	}
+	result, err := tx.Exec("UPDATE client_financial_profiles SET client_id = $1 WHERE client_id = $2", survivorID, duplicateID)
+	if err != nil {
+		return 0, err
+	}
+	moved, _ := result.RowsAffected()
+	_, err = tx.Exec("UPDATE client_financial_profiles SET version = -version WHERE client_id = $1 AND version < 0", survivorID)
+	return int(moved), err
+}
@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/internal/audit"
+	"backend/internal/database"
+	"backend/internal/idempotency"
+	"backend/internal/logger"
+	"backend/internal/models"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// erasedBirthDate is the placeholder birth date stored for erased clients.
+var erasedBirthDate = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func erasedEmail(id int) string {
+	return fmt.Sprintf("erased-%d@erased.invalid", id)
+}
+
+// Privacy handlers
+
+// ExportClient returns everything stored about a client as a single JSON
+// archive, including the merges it took part in and its audit trail.
+func ExportClient(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	params := mux.Vars(r)
+	id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	export := models.ClientExport{
+		ExportedAt:        time.Now().UTC(),
+		FinancialProfiles: []models.FinancialProfile{},
+		Documents:         []models.ClientDocument{},
+		Credits:           []models.Credit{},
+		ImportRejections:  []models.ImportRejection{},
+		Merges:            []models.ClientMerge{},
+		AuditEvents:       []models.AuditEvent{},
+	}
+
+	err = scanClient(database.DB.QueryRow("SELECT "+clientColumns+" FROM clients WHERE id = $1", id), &export.Client)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Client not found"})
+		return
+	}
+
+	if err := exportClientRecords(id, &export); err != nil {
+		if logger.APILogger != nil {
+			logger.APILogger.LogError(r.Method, r.URL.Path, "Client export failed: "+err.Error())
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to export client"})
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"client-%d-export.json\"", id))
+	json.NewEncoder(w).Encode(export)
+}
+
+func exportClientRecords(clientID int, export *models.ClientExport) error {
+	rows, err := database.DB.Query(`
+		SELECT `+financialProfileColumns+`
+		FROM client_financial_profiles WHERE client_id = $1 ORDER BY version
+	`, clientID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var profile models.FinancialProfile
+		if err := scanFinancialProfile(rows, &profile); err != nil {
+			rows.Close()
+			return err
+		}
+		export.FinancialProfiles = append(export.FinancialProfiles, profile)
+	}
+	rows.Close()
+
+	rows, err = database.DB.Query(`
+		SELECT `+documentColumns+`
+		FROM client_documents WHERE client_id = $1 ORDER BY created_at
+	`, clientID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var doc models.ClientDocument
+		if err := scanDocument(rows, &doc); err != nil {
+			rows.Close()
+			return err
+		}
+		export.Documents = append(export.Documents, doc)
+	}
+	rows.Close()
+
+	rows, err = database.DB.Query(`
+		SELECT `+creditColumns+`
+		FROM credits WHERE client_id = $1 ORDER BY created_at
+	`, clientID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var credit models.Credit
+		if err := scanCredit(rows, &credit); err != nil {
+			rows.Close()
+			return err
+		}
+		export.Credits = append(export.Credits, credit)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Rejected import rows are linked to the client by the email they name
+	indexes, err := rejectionEmailIndexes(export.Client.Email)
+	if err != nil {
+		return err
+	}
+	rows, err = database.DB.Query(`
+		SELECT r.job_id, r.row_number, r.error, j.columns, r.data, r.data_enc
+		FROM import_rejections r JOIN import_jobs j ON j.id = r.job_id
+		WHERE r.email_index = ANY($1) ORDER BY r.job_id, r.row_number
+	`, pq.Array(indexes))
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var rejection models.ImportRejection
+		var dataEnc sql.NullString
+		if err := rows.Scan(&rejection.JobID, &rejection.RowNumber, &rejection.Error,
+			pq.Array(&rejection.Columns), pq.Array(&rejection.Data), &dataEnc); err != nil {
+			rows.Close()
+			return err
+		}
+		if rejection.Data, err = rejectedRowData(rejection.Data, dataEnc); err != nil {
+			rows.Close()
+			return err
+		}
+		export.ImportRejections = append(export.ImportRejections, rejection)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Merges the client took part in; the history of duplicates merged into
+	// it belongs to the client too
+	rows, err = database.DB.Query(`
+		SELECT id, survivor_id, duplicate_id, moved_credit_ids, moved_document_ids, moved_profile_versions, created_at
+		FROM client_merges WHERE survivor_id = $1 OR duplicate_id = $1 ORDER BY id
+	`, clientID)
+	if err != nil {
+		return err
+	}
+	clientIDs := []int64{int64(clientID)}
+	for rows.Next() {
+		var merge models.ClientMerge
+		if err := rows.Scan(&merge.ID, &merge.SurvivorID, &merge.DuplicateID, pq.Array(&merge.MovedCreditIDs),
+			pq.Array(&merge.MovedDocumentIDs), &merge.MovedProfileVersions, &merge.CreatedAt); err != nil {
+			rows.Close()
+			return err
+		}
+		if merge.SurvivorID == clientID {
+			clientIDs = append(clientIDs, int64(merge.DuplicateID))
+		}
+		export.Merges = append(export.Merges, merge)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// The audit trail of the client and of every record exported with it
+	creditIDs := []int64{}
+	for _, credit := range export.Credits {
+		creditIDs = append(creditIDs, int64(credit.ID))
+	}
+	documentIDs := []int64{}
+	for _, doc := range export.Documents {
+		documentIDs = append(documentIDs, int64(doc.ID))
+	}
+	profileIDs := []int64{}
+	for _, profile := range export.FinancialProfiles {
+		profileIDs = append(profileIDs, int64(profile.ID))
+	}
+	rows, err = database.DB.Query(`
+		SELECT `+auditEventColumns+` FROM audit_events
+		WHERE (resource_type = 'client' AND resource_id = ANY($1))
+		   OR (resource_type = 'credit' AND resource_id = ANY($2))
+		   OR (resource_type = 'client_document' AND resource_id = ANY($3))
+		   OR (resource_type = 'financial_profile' AND resource_id = ANY($4))
+		ORDER BY created_at, id
+	`, pq.Array(clientIDs), pq.Array(creditIDs), pq.Array(documentIDs), pq.Array(profileIDs))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var event models.AuditEvent
+		if err := scanAuditEvent(rows, &event); err != nil {
+			return err
+		}
+		export.AuditEvents = append(export.AuditEvents, event)
+	}
+	return rows.Err()
+}
+
+// EraseClient anonymizes a client's personal data while keeping their credits
+// and financial history for retention. KYC documents, rejected import rows
+// naming the client and stored idempotent responses carrying their data are
+// removed entirely.
+func EraseClient(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	params := mux.Vars(r)
+	id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	var client models.Client
+	err = scanClient(tx.QueryRow("SELECT "+clientColumns+" FROM clients WHERE id = $1 FOR UPDATE", id), &client)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Client not found"})
+		return
+	}
+	if err != nil {
+		if logger.APILogger != nil {
+			logger.APILogger.LogError(r.Method, r.URL.Path, "Client erasure failed: "+err.Error())
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to erase client"})
+		return
+	}
+
+	erased, err := updateAudited(tx, r, "clients", id, audit.OpErase, `
+		full_name = 'ERASED', email = $2, birth_date = $3, erased_at = CURRENT_TIMESTAMP,
+		full_name_enc = NULL, email_enc = NULL, birth_date_enc = NULL, email_index = NULL, pii_key_id = NULL,
+		version = version + 1`, "TRUE", erasedEmail(id), erasedBirthDate)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Client not found"})
+		return
+	}
+	if err != nil {
+		if logger.APILogger != nil {
+			logger.APILogger.LogError(r.Method, r.URL.Path, "Client erasure failed: "+err.Error())
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to erase client"})
+		return
+	}
+
+	rows, err := tx.Query("DELETE FROM client_documents WHERE client_id = $1 RETURNING "+documentColumns, id)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to erase client"})
+		return
+	}
+	deleted := []models.ClientDocument{}
+	for rows.Next() {
+		var doc models.ClientDocument
+		if err := scanDocument(rows, &doc); err == nil {
+			deleted = append(deleted, doc)
+		}
+	}
+	rows.Close()
+
+	indexes, err := rejectionEmailIndexes(client.Email)
+	if err == nil {
+		_, err = tx.Exec("DELETE FROM import_rejections WHERE email_index = ANY($1)", pq.Array(indexes))
+	}
+	if err == nil {
+		err = idempotency.DeleteSubject(tx, clientSubject(id))
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to erase client"})
+		return
+	}
+
+	storageKeys := []string{}
+	for _, doc := range deleted {
+		if err := recordChange(tx, r, "client_document", doc.ID, audit.OpDelete, doc, nil); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to erase client"})
+			return
+		}
+		storageKeys = append(storageKeys, doc.StorageKey)
+	}
+
+	if err := tx.Commit(); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to erase client"})
+		return
+	}
+
+	// Remove files only once the erasure is committed
+	if DocumentStore != nil {
+		for _, key := range storageKeys {
+			if err := DocumentStore.Delete(r.Context(), key); err != nil && logger.APILogger != nil {
+				logger.APILogger.LogError(r.Method, r.URL.Path, "Failed to delete document "+key+": "+err.Error())
+			}
+		}
+	}
+
+	json.NewEncoder(w).Encode(erased)
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/internal/audit"
+	"backend/internal/database"
+	"github.com/gorilla/mux"
+)
+
+// AdminActors lists the callers, named in X-Actor, that may see soft-deleted
+// rows. It is configured from main; empty allows nobody.
+var AdminActors = map[string]bool{}
+
+const deletedForbiddenMessage = "Only admins may include deleted rows"
+
+func isAdmin(actor string) bool {
+	return AdminActors[strings.TrimSpace(actor)]
+}
+
+// includeDeleted reports whether a list or get request asked for soft-deleted
+// rows with ?include_deleted=true. Only admins get them; GuardDeleted refuses
+// the others before they reach a handler.
+func includeDeleted(r *http.Request) bool {
+	return r.URL.Query().Get("include_deleted") == "true" && isAdmin(r.Header.Get(ActorHeader))
+}
+
+// GuardDeleted answers 403 to requests for soft-deleted rows from callers
+// that are not AdminActors.
+func GuardDeleted(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("include_deleted") == "true" && !isAdmin(r.Header.Get(ActorHeader)) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{"error": deletedForbiddenMessage})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Restore handlers
+
+func RestoreClient(w http.ResponseWriter, r *http.Request) {
+	restoreRow(w, r, "clients", "Client")
+}
+
+func RestoreBank(w http.ResponseWriter, r *http.Request) {
+	restoreRow(w, r, "banks", "Bank")
+}
+
+func RestoreCredit(w http.ResponseWriter, r *http.Request) {
+	restoreRow(w, r, "credits", "Credit")
+}
+
+// restoreRow clears deleted_at on a soft-deleted row of table, guarded by
+// If-Match like any other write.
+func restoreRow(w http.ResponseWriter, r *http.Request, table, name string) {
+	w.Header().Set("Content-Type", "application/json")
+	params := mux.Vars(r)
+	id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	expected, ok := expectedVersion(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = updateAudited(tx, r, table, id, audit.OpRestore,
+		"deleted_at = NULL, version = version + 1",
+		"deleted_at IS NOT NULL AND ($2::int IS NULL OR version = $2)", expected)
+	if err == sql.ErrNoRows {
+		writeVersionMiss(w, table, "deleted_at IS NOT NULL", id, expected, "Deleted "+table+" not found")
+		return
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to restore " + table})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"message": name + " restored successfully"})
+}
+
+// PurgeDeleted hard-deletes rows soft-deleted longer than retention ago, in
+// one transaction with an audit event per row. Clients and banks that still
+// own credits are kept so purging never cascades into credit history. The
+// document files of purged clients are deleted once the transaction has
+// committed. Rejected rows of imports that finished before the cutoff go as
+// well. It returns the number of rows removed per table.
+func PurgeDeleted(ctx context.Context, retention time.Duration) (map[string]int64, error) {
+	cutoff := time.Now().Add(-retention)
+	purged := map[string]int64{}
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, purgeAudited(`
+		DELETE FROM credits WHERE deleted_at IS NOT NULL AND deleted_at < $1
+		RETURNING id`, "credit"), cutoff, systemActor, audit.OpPurge)
+	if err != nil {
+		return nil, err
+	}
+	purged["credits"], _ = result.RowsAffected()
+
+	// Collect document files before their rows disappear with the client
+	rows, err := tx.QueryContext(ctx, `
+		WITH purged AS (
+			DELETE FROM client_documents
+			WHERE client_id IN (
+				SELECT id FROM clients c
+				WHERE c.deleted_at IS NOT NULL AND c.deleted_at < $1
+				  AND NOT EXISTS (SELECT 1 FROM credits WHERE client_id = c.id)
+			)
+			RETURNING id, storage_key
+		), audited AS (
+			INSERT INTO audit_events (actor, resource_type, resource_id, operation)
+			SELECT $2, 'client_document', id, $3 FROM purged
+		)
+		SELECT storage_key FROM purged
+	`, cutoff, systemActor, audit.OpPurge)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	storageKeys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		storageKeys = append(storageKeys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	purged["client_documents"] = int64(len(storageKeys))
+
+	result, err = tx.ExecContext(ctx, purgeAudited(`
+		DELETE FROM clients c
+		WHERE c.deleted_at IS NOT NULL AND c.deleted_at < $1
+		  AND NOT EXISTS (SELECT 1 FROM credits WHERE client_id = c.id)
+		RETURNING c.id`, "client"), cutoff, systemActor, audit.OpPurge)
+	if err != nil {
+		return nil, err
+	}
+	purged["clients"], _ = result.RowsAffected()
+
+	result, err = tx.ExecContext(ctx, purgeAudited(`
+		DELETE FROM banks b
+		WHERE b.deleted_at IS NOT NULL AND b.deleted_at < $1
+		  AND NOT EXISTS (SELECT 1 FROM credits WHERE bank_id = b.id)
+		RETURNING b.id`, "bank"), cutoff, systemActor, audit.OpPurge)
+	if err != nil {
+		return nil, err
+	}
+	purged["banks"], _ = result.RowsAffected()
+
+	result, err = tx.ExecContext(ctx, `
+		DELETE FROM import_rejections
+		WHERE job_id IN (SELECT id FROM import_jobs WHERE finished_at < $1)
+	`, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	purged["import_rejections"], _ = result.RowsAffected()
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// The rows are gone, so a file left behind here is only logged. Deletes
+	// run to the end even when ctx is cancelled meanwhile.
+	if DocumentStore != nil {
+		deleteCtx := context.WithoutCancel(ctx)
+		for _, key := range storageKeys {
+			if err := DocumentStore.Delete(deleteCtx, key); err != nil {
+				log.Printf("Failed to delete purged document file %s: %v", key, err)
+			}
+		}
+	}
+	return purged, nil
+}
+
+// purgeAudited wraps a "DELETE ... RETURNING id" statement so every purged row
+// gets an audit event in the same statement. The actor and operation are
+// bound to $2 and $3; the affected row count equals the number purged.
+func purgeAudited(deleteQuery, resourceType string) string {
+	return `
+		WITH purged AS (` + deleteQuery + `)
+		INSERT INTO audit_events (actor, resource_type, resource_id, operation)
+		SELECT $2, '` + resourceType + `', id, $3 FROM purged`
+}
+
+// StartPurgeJob runs PurgeDeleted every interval until ctx is cancelled. The
+// returned channel is closed once it has stopped.
+func StartPurgeJob(ctx context.Context, retention, interval time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			purged, err := PurgeDeleted(ctx, retention)
+			if err != nil {
+				log.Printf("Purge of soft-deleted rows failed: %v", err)
+			} else if purged["credits"]+purged["client_documents"]+purged["clients"]+purged["banks"]+purged["import_rejections"] > 0 {
+				log.Printf("Purged rows past retention: %d credits, %d client documents, %d clients, %d banks, %d import rejections",
+					purged["credits"], purged["client_documents"], purged["clients"], purged["banks"], purged["import_rejections"])
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/internal/database"
+	"backend/internal/outbox"
+	"backend/internal/stream"
+	"backend/internal/webhooks"
+)
+
+// EventBroker delivers new outbox events to event stream clients. The stream
+// is unavailable while it is nil.
+var EventBroker *stream.Broker
+
+// StreamHeartbeatInterval is how often an idle stream sends a comment line so
+// proxies keep the connection open.
+var StreamHeartbeatInterval = 15 * time.Second
+
+// streamedAggregates are the resources whose events are streamed.
+var streamedAggregates = []string{"client", "credit"}
+
+const streamReplayPageSize = 500
+
+// loadOutboxMessages returns up to limit outbox rows about streamed
+// aggregates matching condition, in commit order.
+func loadOutboxMessages(ctx context.Context, limit int, condition string, args ...interface{}) ([]outbox.Message, error) {
+	rows, err := database.DB.QueryContext(ctx, `
+		SELECT id, stream_seq, event_type, aggregate_type, aggregate_id, payload, created_at
+		FROM outbox
+		WHERE aggregate_type IN ('`+strings.Join(streamedAggregates, "', '")+`') AND stream_seq IS NOT NULL AND `+condition+`
+		ORDER BY stream_seq
+		LIMIT `+strconv.Itoa(limit), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []outbox.Message{}
+	for rows.Next() {
+		var msg outbox.Message
+		var payload []byte
+		if err := rows.Scan(&msg.ID, &msg.Sequence, &msg.Type, &msg.AggregateType, &msg.AggregateID, &payload, &msg.OccurredAt); err != nil {
+			return nil, err
+		}
+		msg.Payload = payload
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
+// LoadStreamedEvent is the stream.Loader of EventBroker: it loads an outbox
+// event once for every client of the stream, and skips events about
+// resources that are not streamed.
+func LoadStreamedEvent(ctx context.Context, id int64) (outbox.Message, bool, error) {
+	messages, err := loadOutboxMessages(ctx, 1, "id = $1", id)
+	if err != nil || len(messages) == 0 {
+		return outbox.Message{}, false, err
+	}
+	return messages[0], true, nil
+}
+
+func writeStreamEvent(w http.ResponseWriter, msg outbox.Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", msg.Sequence, msg.Type, data)
+	return err
+}
+
+// Event stream handlers
+
+// StreamEvents pushes client and credit events as Server-Sent Events. Event
+// ids are the commit-ordered sequence numbers of the events, so clients
+// resume with the Last-Event-ID header (or ?last_event_id=) without missing
+// events that committed late, and may filter with ?bank_id= and a comma
+// separated ?types= list.
+func StreamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok || EventBroker == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Event stream is not available"})
+		return
+	}
+
+	query := r.URL.Query()
+	var eventTypes []string
+	if value := query.Get("types"); value != "" {
+		eventTypes = strings.Split(value, ",")
+		if msg := validateEventTypes(eventTypes); msg != "" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": msg})
+			return
+		}
+	}
+	var bankID *int
+	if value := query.Get("bank_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid bank_id"})
+			return
+		}
+		bankID = &id
+	}
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = query.Get("last_event_id")
+	}
+	var resumeAfter int64 = -1
+	if lastEventID != "" {
+		id, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid Last-Event-ID"})
+			return
+		}
+		resumeAfter = id
+	}
+
+	// Subscribe before replaying so nothing committed in between is lost
+	live, cancel := EventBroker.Subscribe()
+	defer cancel()
+
+	// The stream outlives the server's read and write timeouts; it ends when
+	// the client leaves or the broker is closed on shutdown
+	controller := http.NewResponseController(w)
+	controller.SetReadDeadline(time.Time{})
+	controller.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+	flusher.Flush()
+
+	send := func(msg outbox.Message) bool {
+		if !webhooks.Matches(eventTypes, bankID, msg) {
+			return true
+		}
+		return writeStreamEvent(w, msg) == nil
+	}
+
+	// Events replayed here may also arrive live. Their numbers are skipped
+	// until a live event passes the last one replayed.
+	replayed := map[int64]bool{}
+	var replayedUpTo int64
+	for resumeAfter >= 0 {
+		messages, err := loadOutboxMessages(r.Context(), streamReplayPageSize, "stream_seq > $1", resumeAfter)
+		if err != nil {
+			return
+		}
+		for _, msg := range messages {
+			if !send(msg) {
+				return
+			}
+			replayed[msg.Sequence] = true
+			replayedUpTo = msg.Sequence
+			resumeAfter = msg.Sequence
+		}
+		if len(messages) < streamReplayPageSize {
+			break
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(StreamHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg, ok := <-live:
+			// A closed channel means this client fell behind; it reconnects
+			// with its last event id and catches up from the outbox
+			if !ok {
+				return
+			}
+			if replayed != nil {
+				if msg.Sequence > replayedUpTo {
+					replayed = nil
+				} else if replayed[msg.Sequence] {
+					continue
+				}
+			}
+			if !send(msg) {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,530 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"backend/internal/audit"
+	"backend/internal/database"
+	"backend/internal/export"
+	"backend/internal/logger"
+	"backend/internal/models"
+	"backend/internal/outbox"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+var webhookFields = fieldColumns{
+	{"id", "id", zeroNumber},
+	{"url", "url", zeroText},
+	{"event_types", "event_types", zeroNull},
+	{"bank_id", "bank_id", zeroNull},
+	{"active", "active", zeroBool},
+	{"consecutive_failures", "consecutive_failures", zeroNumber},
+	{"disabled_at", "disabled_at", zeroNull},
+	{"created_at", "created_at", zeroTime},
+}
+
+var webhookColumns = webhookFields.all()
+
+func scanWebhook(row rowScanner, hook *models.WebhookSubscription) error {
+	var bankID sql.NullInt64
+	var disabledAt sql.NullTime
+	if err := row.Scan(&hook.ID, &hook.URL, pq.Array(&hook.EventTypes), &bankID, &hook.Active,
+		&hook.ConsecutiveFailures, &disabledAt, &hook.CreatedAt); err != nil {
+		return err
+	}
+	if hook.EventTypes == nil {
+		hook.EventTypes = []string{}
+	}
+	hook.BankID = nil
+	if bankID.Valid {
+		id := int(bankID.Int64)
+		hook.BankID = &id
+	}
+	hook.DisabledAt = nil
+	if disabledAt.Valid {
+		hook.DisabledAt = &disabledAt.Time
+	}
+	return nil
+}
+
+var webhookDeliveryFields = fieldColumns{
+	{"id", "id", zeroNumber},
+	{"subscription_id", "subscription_id", zeroNumber},
+	{"event_id", "event_id", zeroNumber},
+	{"event_type", "event_type", zeroText},
+	{"payload", "payload", zeroNull},
+	{"status", "status", zeroText},
+	{"attempts", "attempts", zeroNumber},
+	{"next_attempt_at", "next_attempt_at", zeroTime},
+	{"last_error", "last_error", zeroNull},
+	{"delivered_at", "delivered_at", zeroNull},
+	{"created_at", "created_at", zeroTime},
+}
+
+var webhookDeliveryColumns = webhookDeliveryFields.all()
+
+func scanWebhookDelivery(row rowScanner, delivery *models.WebhookDelivery) error {
+	var payload []byte
+	var lastError sql.NullString
+	var deliveredAt sql.NullTime
+	if err := row.Scan(&delivery.ID, &delivery.SubscriptionID, &delivery.EventID, &delivery.EventType, &payload,
+		&delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt, &lastError, &deliveredAt, &delivery.CreatedAt); err != nil {
+		return err
+	}
+	delivery.Payload = payload
+	delivery.LastError = lastError.String
+	delivery.DeliveredAt = nil
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+	return nil
+}
+
+// validateWebhook checks the endpoint URL and event filter of a subscription.
+func validateWebhook(hook models.WebhookSubscription) string {
+	endpoint, err := url.Parse(hook.URL)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return "url must be an absolute http or https URL"
+	}
+	return validateEventTypes(hook.EventTypes)
+}
+
+// validateEventTypes checks that every entry of an event filter is a known
+// domain event type.
+func validateEventTypes(eventTypes []string) string {
+	for _, eventType := range eventTypes {
+		known := false
+		for _, candidate := range outbox.EventTypes {
+			if eventType == candidate {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return "Unknown event type " + eventType
+		}
+	}
+	return ""
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Webhook handlers
+
+func GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	format, ok := listFormat(w, r)
+	if !ok {
+		return
+	}
+	fields, ok := parseFields(w, r, models.WebhookSubscription{})
+	if !ok {
+		return
+	}
+
+	rows, err := database.DB.Query("SELECT " + webhookFields.selecting(fields) + " FROM webhook_subscriptions ORDER BY id")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	list := newListWriter(w, format, "webhooks", export.Select(models.WebhookSubscription{}, fields))
+	for rows.Next() {
+		var hook models.WebhookSubscription
+		if err := scanWebhook(rows, &hook); err != nil {
+			if logger.APILogger != nil {
+				logger.APILogger.LogError(r.Method, r.URL.Path, "Row scan failed: "+err.Error())
+			}
+			continue
+		}
+		if err := list.Write(export.Select(hook, fields)); err != nil {
+			break
+		}
+	}
+
+	list.Close()
+}
+
+func GetWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid ID"})
+		return
+	}
+	fields, ok := parseFields(w, r, models.WebhookSubscription{})
+	if !ok {
+		return
+	}
+
+	var hook models.WebhookSubscription
+	err = scanWebhook(database.DB.QueryRow("SELECT "+webhookFields.selecting(fields)+" FROM webhook_subscriptions WHERE id = $1", id), &hook)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Webhook not found"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(export.Select(hook, fields))
+}
+
+// CreateWebhook registers a subscription. When no secret is given one is
+// generated; the secret is only returned in this response.
+func CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var hook models.WebhookSubscription
+	if err := json.NewDecoder(r.Body).Decode(&hook); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+		return
+	}
+	if msg := validateWebhook(hook); msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": msg})
+		return
+	}
+	if hook.EventTypes == nil {
+		hook.EventTypes = []string{}
+	}
+	if hook.Secret == "" {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create webhook"})
+			return
+		}
+		hook.Secret = secret
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	hook.Active = true
+	err = tx.QueryRow(`
+		INSERT INTO webhook_subscriptions (url, secret, event_types, bank_id)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, hook.URL, hook.Secret, pq.Array(hook.EventTypes), hook.BankID).Scan(&hook.ID, &hook.CreatedAt)
+	if err == nil {
+		err = recordChange(tx, r, "webhook", hook.ID, audit.OpCreate, nil, hook)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		if logger.APILogger != nil {
+			logger.APILogger.LogError(r.Method, r.URL.Path, "Failed to create webhook: "+err.Error())
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create webhook"})
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(hook)
+}
+
+// UpdateWebhook replaces the URL and filters of a subscription. A non-empty
+// secret rotates it, and setting active re-enables an auto-disabled
+// subscription with a clean failure count.
+func UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	var hook models.WebhookSubscription
+	if err := json.NewDecoder(r.Body).Decode(&hook); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+		return
+	}
+	if msg := validateWebhook(hook); msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": msg})
+		return
+	}
+	if hook.EventTypes == nil {
+		hook.EventTypes = []string{}
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	var before models.WebhookSubscription
+	err = scanWebhook(tx.QueryRow("SELECT "+webhookColumns+" FROM webhook_subscriptions WHERE id = $1 FOR UPDATE", id), &before)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Webhook not found"})
+		return
+	}
+
+	var updated models.WebhookSubscription
+	if err == nil {
+		err = scanWebhook(tx.QueryRow(`
+			UPDATE webhook_subscriptions
+			SET url = $1, event_types = $2, bank_id = $3, active = $4,
+			    secret = COALESCE(NULLIF($5, ''), secret),
+			    consecutive_failures = CASE WHEN $4 THEN 0 ELSE consecutive_failures END,
+			    disabled_at = CASE WHEN $4 THEN NULL ELSE disabled_at END
+			WHERE id = $6
+			RETURNING `+webhookColumns, hook.URL, pq.Array(hook.EventTypes), hook.BankID, hook.Active, hook.Secret, id), &updated)
+	}
+	if err == nil {
+		err = recordChange(tx, r, "webhook", id, audit.OpUpdate, before, updated)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update webhook"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteWebhook removes a subscription together with its delivery log.
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	var hook models.WebhookSubscription
+	err = scanWebhook(tx.QueryRow("DELETE FROM webhook_subscriptions WHERE id = $1 RETURNING "+webhookColumns, id), &hook)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Webhook not found"})
+		return
+	}
+	if err == nil {
+		err = recordChange(tx, r, "webhook", id, audit.OpDelete, hook, nil)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to delete webhook"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"message": "Webhook deleted successfully"})
+}
+
+// GetWebhookDeliveries lists a subscription's deliveries, newest first.
+// Pass ?status= to filter by PENDING, SUCCEEDED or FAILED.
+func GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	format, ok := listFormat(w, r)
+	if !ok {
+		return
+	}
+	fields, ok := parseFields(w, r, models.WebhookDelivery{})
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	rows, err := database.DB.Query(`
+		SELECT `+webhookDeliveryFields.selecting(fields)+`
+		FROM webhook_deliveries
+		WHERE subscription_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY id DESC
+		LIMIT 100
+	`, id, status)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	list := newListWriter(w, format, "webhook-deliveries", export.Select(models.WebhookDelivery{}, fields))
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		if err := scanWebhookDelivery(rows, &delivery); err != nil {
+			continue
+		}
+		if err := list.Write(export.Select(delivery, fields)); err != nil {
+			break
+		}
+	}
+
+	list.Close()
+}
+
+var webhookAttemptFields = fieldColumns{
+	{"id", "a.id", zeroNumber},
+	{"delivery_id", "a.delivery_id", zeroNumber},
+	{"status_code", "a.status_code", zeroNull},
+	{"error", "a.error", zeroNull},
+	{"duration_ms", "a.duration_ms", zeroNumber},
+	{"attempted_at", "a.attempted_at", zeroTime},
+}
+
+// GetWebhookDeliveryAttempts lists every HTTP call made for a delivery.
+func GetWebhookDeliveryAttempts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	format, ok := listFormat(w, r)
+	if !ok {
+		return
+	}
+	fields, ok := parseFields(w, r, models.WebhookDeliveryAttempt{})
+	if !ok {
+		return
+	}
+	params := mux.Vars(r)
+	id, err1 := strconv.Atoi(params["id"])
+	deliveryID, err2 := strconv.ParseInt(params["deliveryId"], 10, 64)
+	if err1 != nil || err2 != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT `+webhookAttemptFields.selecting(fields)+`
+		FROM webhook_delivery_attempts a
+		JOIN webhook_deliveries d ON d.id = a.delivery_id
+		WHERE d.id = $1 AND d.subscription_id = $2
+		ORDER BY a.id
+	`, deliveryID, id)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	list := newListWriter(w, format, "webhook-delivery-attempts", export.Select(models.WebhookDeliveryAttempt{}, fields))
+	for rows.Next() {
+		var attempt models.WebhookDeliveryAttempt
+		var statusCode sql.NullInt64
+		var errorText sql.NullString
+		if err := rows.Scan(&attempt.ID, &attempt.DeliveryID, &statusCode, &errorText,
+			&attempt.DurationMs, &attempt.AttemptedAt); err != nil {
+			continue
+		}
+		if statusCode.Valid {
+			code := int(statusCode.Int64)
+			attempt.StatusCode = &code
+		}
+		attempt.Error = errorText.String
+		if err := list.Write(export.Select(attempt, fields)); err != nil {
+			break
+		}
+	}
+
+	list.Close()
+}
+
+// RedeliverWebhook queues a delivery to be sent again right away with a fresh
+// attempt budget, whatever its current status. Deliveries of inactive
+// subscriptions are refused, since the dispatcher would never send them.
+func RedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	params := mux.Vars(r)
+	id, err1 := strconv.Atoi(params["id"])
+	deliveryID, err2 := strconv.ParseInt(params["deliveryId"], 10, 64)
+	if err1 != nil || err2 != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid ID"})
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to queue redelivery"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Hold the subscription so it cannot be deactivated before the commit
+	var active bool
+	err = tx.QueryRow(`
+		SELECT ws.active FROM webhook_deliveries wd
+		JOIN webhook_subscriptions ws ON ws.id = wd.subscription_id
+		WHERE wd.id = $1 AND wd.subscription_id = $2
+		FOR SHARE OF ws
+	`, deliveryID, id).Scan(&active)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Delivery not found"})
+		return
+	}
+	if err == nil && !active {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Webhook is inactive; reactivate it before redelivering"})
+		return
+	}
+
+	var delivery models.WebhookDelivery
+	if err == nil {
+		err = scanWebhookDelivery(tx.QueryRow(`
+			UPDATE webhook_deliveries
+			SET status = 'PENDING', attempts = 0, next_attempt_at = CURRENT_TIMESTAMP
+			WHERE id = $1
+			RETURNING `+webhookDeliveryColumns, deliveryID), &delivery)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to queue redelivery"})
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(delivery)
+}
@@ -0,0 +1,453 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Header carries the client chosen key identifying a logical request.
+const Header = "Idempotency-Key"
+
+// ReplayedHeader is set on responses served from a stored result.
+const ReplayedHeader = "Idempotent-Replayed"
+
+const maxKeyLength = 255
+
+// memoryBodySize is how much of a request body is held in memory while it is
+// fingerprinted; the rest is spooled to a temporary file.
+const memoryBodySize = 1 << 20
+
+// DefaultMaxBodySize is the MaxBodySize of New.
+const DefaultMaxBodySize = 32 << 20
+
+var errBodyTooLarge = errors.New("request body too large")
+
+// Middleware makes POST requests carrying an Idempotency-Key safe to retry.
+// The first response for a key is stored with a fingerprint of the request;
+// retries get the stored response, requests reusing the key for a different
+// request are rejected with 422, and concurrent duplicates wait for the first
+// to finish or get 409 with Retry-After. Server errors are not stored so they
+// can be retried. Handlers can Tag a response with the subjects it carries data about, or
+// Discard it.
+type Middleware struct {
+	DB *sql.DB
+	// TTL is how long responses are kept.
+	TTL time.Duration
+	// Wait is how long a duplicate waits for an in-flight request.
+	Wait time.Duration
+	// LockTimeout is after how long an in-flight request is presumed dead and
+	// its key taken over.
+	LockTimeout time.Duration
+	// Scope returns the caller a request comes from. Keys are scoped to their
+	// caller, so two callers choosing the same key never share a response.
+	// nil puts every request in the same scope.
+	Scope func(r *http.Request) string
+	// Cipher encrypts stored response bodies. nil stores them as sent.
+	Cipher Cipher
+	// MaxBodySize bounds the bodies of keyed requests; larger ones are
+	// rejected with 413. It should admit whatever the handlers accept.
+	MaxBodySize int64
+}
+
+// Cipher encrypts and decrypts stored response bodies.
+type Cipher interface {
+	Encrypt(plaintext string) (string, error)
+	Decrypt(ciphertext string) (string, error)
+}
+
+// outcome is what a handler tells the middleware about its response.
+type outcome struct {
+	mu       sync.Mutex
+	subjects []string
+	discard  bool
+}
+
+type outcomeKey struct{}
+
+func requestOutcome(r *http.Request) *outcome {
+	o, _ := r.Context().Value(outcomeKey{}).(*outcome)
+	return o
+}
+
+// Tag marks the response to r as carrying data about subject, such as
+// "client:12", so that DeleteSubject can remove it. It does nothing for
+// requests the middleware does not store.
+func Tag(r *http.Request, subject string) {
+	if o := requestOutcome(r); o != nil {
+		o.mu.Lock()
+		o.subjects = append(o.subjects, subject)
+		o.mu.Unlock()
+	}
+}
+
+// Discard keeps the response to r from being stored, as for server errors.
+// Read-only POSTs use it so their results are not kept.
+func Discard(r *http.Request) {
+	if o := requestOutcome(r); o != nil {
+		o.mu.Lock()
+		o.discard = true
+		o.mu.Unlock()
+	}
+}
+
+// Execer runs a statement, as *sql.DB and *sql.Tx do.
+type Execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// DeleteSubject removes every stored response tagged with subject, e.g. when
+// the data it names is erased.
+func DeleteSubject(db Execer, subject string) error {
+	_, err := db.Exec("DELETE FROM idempotency_keys WHERE subjects @> ARRAY[$1]::TEXT[]", subject)
+	return err
+}
+
+// New returns a middleware keeping responses for 24 hours.
+func New(db *sql.DB) *Middleware {
+	return &Middleware{
+		DB: db, TTL: 24 * time.Hour, Wait: 5 * time.Second, LockTimeout: time.Minute,
+		MaxBodySize: DefaultMaxBodySize,
+	}
+}
+
+// Fingerprint identifies a request by method, path, query string and body.
+func Fingerprint(method, path, rawQuery string, body []byte) string {
+	h := fingerprintHash(method, path, rawQuery)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// fingerprintHash starts a Fingerprint; the body is written to it.
+func fingerprintHash(method, path, rawQuery string) hash.Hash {
+	h := sha256.New()
+	for _, part := range []string{method, path, rawQuery} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return h
+}
+
+// readBody fingerprints r while reading its body, and returns a reader that
+// serves the body again. Bodies past memoryBodySize are spooled to a
+// temporary file, removed on Close, so large uploads are not held in memory.
+func (m *Middleware) readBody(r *http.Request) (string, io.ReadCloser, error) {
+	if r.ContentLength > m.MaxBodySize {
+		return "", nil, errBodyTooLarge
+	}
+	h := fingerprintHash(r.Method, r.URL.Path, r.URL.RawQuery)
+	body := io.TeeReader(r.Body, h)
+	data, err := io.ReadAll(io.LimitReader(body, memoryBodySize+1))
+	if err != nil {
+		return "", nil, err
+	}
+	if len(data) <= memoryBodySize {
+		return hex.EncodeToString(h.Sum(nil)), io.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	file, err := os.CreateTemp("", "idempotent-body-*")
+	if err != nil {
+		return "", nil, err
+	}
+	spooled := &spooledBody{file}
+	n, err := io.Copy(file, io.MultiReader(bytes.NewReader(data), io.LimitReader(body, m.MaxBodySize+1-int64(len(data)))))
+	if err == nil && n > m.MaxBodySize {
+		err = errBodyTooLarge
+	}
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		spooled.Close()
+		return "", nil, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), spooled, nil
+}
+
+// spooledBody is a request body read back from a temporary file.
+type spooledBody struct {
+	*os.File
+}
+
+func (b *spooledBody) Close() error {
+	err := b.File.Close()
+	os.Remove(b.File.Name())
+	return err
+}
+
+// scopedKey is the key a request is stored under: a hash of its caller's
+// scope and the key the caller chose.
+func (m *Middleware) scopedKey(r *http.Request, key string) string {
+	scope := ""
+	if m.Scope != nil {
+		scope = m.Scope(r)
+	}
+	h := sha256.New()
+	h.Write([]byte(scope))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type storedResponse struct {
+	fingerprint string
+	completed   bool
+	status      int
+	headers     http.Header
+	body        []byte
+}
+
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		if key == "" || r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxKeyLength {
+			writeError(w, http.StatusBadRequest, "Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		fingerprint, body, err := m.readBody(r)
+		if err == errBodyTooLarge {
+			writeError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Unable to read request body")
+			return
+		}
+		defer body.Close()
+		r.Body = body
+		key = m.scopedKey(r, key)
+
+		acquired, err := m.acquire(r.Context(), key, fingerprint)
+		if err != nil {
+			log.Printf("Idempotency key lookup failed: %v", err)
+			writeError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if !acquired {
+			m.replay(w, r, key, fingerprint)
+			return
+		}
+
+		rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+		o := &outcome{}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), outcomeKey{}, o)))
+
+		// Use a fresh context: the result must be stored even if the client
+		// has gone away
+		ctx := context.Background()
+		o.mu.Lock()
+		discard, subjects := o.discard, o.subjects
+		o.mu.Unlock()
+		if rec.status >= 500 || discard {
+			_, err = m.DB.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE key = $1", key)
+		} else {
+			err = m.store(ctx, key, rec, subjects)
+		}
+		if err != nil {
+			log.Printf("Failed to store idempotent response: %v", err)
+		}
+	})
+}
+
+// store saves the recorded response for key, encrypting the body when a
+// Cipher is set.
+func (m *Middleware) store(ctx context.Context, key string, rec *recorder, subjects []string) error {
+	headers, _ := json.Marshal(rec.Header())
+	body := rec.body.Bytes()
+	encrypted := m.Cipher != nil
+	if encrypted {
+		sealed, err := m.Cipher.Encrypt(string(body))
+		if err != nil {
+			// Release the key rather than keep the body in plaintext
+			m.DB.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE key = $1", key)
+			return err
+		}
+		body = []byte(sealed)
+	}
+	if subjects == nil {
+		subjects = []string{}
+	}
+	_, err := m.DB.ExecContext(ctx, `
+		UPDATE idempotency_keys
+		SET completed = TRUE, response_status = $1, response_headers = $2, response_body = $3,
+		    body_encrypted = $4, subjects = $5
+		WHERE key = $6
+	`, rec.status, string(headers), body, encrypted, pq.Array(subjects), key)
+	return err
+}
+
+// acquire claims key for this request. It returns false when another request
+// holds or has completed it.
+func (m *Middleware) acquire(ctx context.Context, key, fingerprint string) (bool, error) {
+	result, err := m.DB.ExecContext(ctx, `
+		INSERT INTO idempotency_keys (key, fingerprint, locked_until, expires_at)
+		VALUES ($1, $2, CURRENT_TIMESTAMP + $3 * INTERVAL '1 millisecond', CURRENT_TIMESTAMP + $4 * INTERVAL '1 millisecond')
+		ON CONFLICT (key) DO UPDATE
+		SET fingerprint = EXCLUDED.fingerprint, completed = FALSE, locked_until = EXCLUDED.locked_until,
+		    expires_at = EXCLUDED.expires_at, response_status = NULL, response_headers = NULL, response_body = NULL,
+		    body_encrypted = FALSE, subjects = '{}'
+		WHERE idempotency_keys.expires_at < CURRENT_TIMESTAMP
+		   OR (NOT idempotency_keys.completed AND idempotency_keys.locked_until < CURRENT_TIMESTAMP
+		       AND idempotency_keys.fingerprint = EXCLUDED.fingerprint)
+	`, key, fingerprint, m.LockTimeout.Milliseconds(), m.TTL.Milliseconds())
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+func (m *Middleware) load(ctx context.Context, key string) (*storedResponse, error) {
+	var stored storedResponse
+	var status sql.NullInt64
+	var headers []byte
+	var encrypted bool
+	err := m.DB.QueryRowContext(ctx, `
+		SELECT fingerprint, completed, response_status, response_headers, response_body, body_encrypted
+		FROM idempotency_keys WHERE key = $1
+	`, key).Scan(&stored.fingerprint, &stored.completed, &status, &headers, &stored.body, &encrypted)
+	if err != nil {
+		return nil, err
+	}
+	if encrypted {
+		if m.Cipher == nil {
+			return nil, errors.New("stored response is encrypted but no cipher is configured")
+		}
+		body, err := m.Cipher.Decrypt(string(stored.body))
+		if err != nil {
+			return nil, err
+		}
+		stored.body = []byte(body)
+	}
+	stored.status = int(status.Int64)
+	if headers != nil {
+		if err := json.Unmarshal(headers, &stored.headers); err != nil {
+			return nil, err
+		}
+	}
+	return &stored, nil
+}
+
+// replay answers a request whose key is already taken, waiting up to Wait for
+// an in-flight original to complete.
+func (m *Middleware) replay(w http.ResponseWriter, r *http.Request, key, fingerprint string) {
+	deadline := time.Now().Add(m.Wait)
+	for {
+		stored, err := m.load(r.Context(), key)
+		if err == sql.ErrNoRows {
+			// The original failed with a server error and released the key
+			w.Header().Set("Retry-After", "0")
+			writeError(w, http.StatusConflict, "The original request failed; retry to run it again")
+			return
+		}
+		if err != nil {
+			log.Printf("Idempotency key lookup failed: %v", err)
+			writeError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if stored.fingerprint != fingerprint {
+			writeError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+			return
+		}
+		if stored.completed {
+			for name, values := range stored.headers {
+				if name == "Date" || name == "Content-Length" {
+					continue
+				}
+				w.Header()[name] = values
+			}
+			w.Header().Set(ReplayedHeader, "true")
+			w.WriteHeader(stored.status)
+			w.Write(stored.body)
+			return
+		}
+		if time.Now().After(deadline) {
+			w.Header().Set("Retry-After", "1")
+			writeError(w, http.StatusConflict, "A request with this Idempotency-Key is still in progress")
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// DeleteExpired removes stored responses past their TTL.
+func (m *Middleware) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := m.DB.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE expires_at < CURRENT_TIMESTAMP")
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// StartCleanup runs DeleteExpired every interval until ctx is cancelled. The
+// returned channel is closed once it has stopped.
+func (m *Middleware) StartCleanup(ctx context.Context, interval time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if _, err := m.DeleteExpired(ctx); err != nil {
+				log.Printf("Idempotency key cleanup failed: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// recorder passes the response through while keeping a copy.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *recorder) WriteHeader(code int) {
+	rec.status = code
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying connection.
+func (rec *recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
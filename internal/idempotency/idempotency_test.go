@@ -0,0 +1,138 @@
+package idempotency
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	base := Fingerprint("POST", "/api/banks", "", []byte(`{"name":"A"}`))
+	if base != Fingerprint("POST", "/api/banks", "", []byte(`{"name":"A"}`)) {
+		t.Error("Expected identical requests to share a fingerprint")
+	}
+	if base == Fingerprint("POST", "/api/banks", "", []byte(`{"name":"B"}`)) {
+		t.Error("Expected a different body to change the fingerprint")
+	}
+	if base == Fingerprint("POST", "/api/clients", "", []byte(`{"name":"A"}`)) {
+		t.Error("Expected a different path to change the fingerprint")
+	}
+	if Fingerprint("POST", "/api/credits:batch", "mode=atomic", []byte(`[]`)) ==
+		Fingerprint("POST", "/api/credits:batch", "mode=best_effort", []byte(`[]`)) {
+		t.Error("Expected a different query string to change the fingerprint")
+	}
+}
+
+func TestKeysAreScopedToTheCaller(t *testing.T) {
+	m := New(nil)
+	m.Scope = func(r *http.Request) string { return r.Header.Get("X-Actor") }
+	alice, _ := http.NewRequest("POST", "/api/banks", nil)
+	alice.Header.Set("X-Actor", "alice")
+	bob, _ := http.NewRequest("POST", "/api/banks", nil)
+	bob.Header.Set("X-Actor", "bob")
+
+	if m.scopedKey(alice, "key") == m.scopedKey(bob, "key") {
+		t.Error("Expected callers to have separate keys")
+	}
+	if m.scopedKey(alice, "key") != m.scopedKey(alice, "key") {
+		t.Error("Expected a caller's key to be stable")
+	}
+}
+
+func TestRequestsWithoutKeyPassThrough(t *testing.T) {
+	called := 0
+	handler := New(nil).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called++
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	for _, method := range []string{"POST", "GET"} {
+		req, _ := http.NewRequest(method, "/api/banks", nil)
+		if method == "GET" {
+			req.Header.Set(Header, "key")
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusCreated {
+			t.Errorf("Expected the handler response for %s, got %v", method, rr.Code)
+		}
+	}
+	if called != 2 {
+		t.Errorf("Expected the handler to run twice, ran %d times", called)
+	}
+}
+
+func TestOverlongKeyIsRejected(t *testing.T) {
+	handler := New(nil).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Handler should not run")
+	}))
+
+	req, _ := http.NewRequest("POST", "/api/banks", nil)
+	req.Header.Set(Header, strings.Repeat("k", maxKeyLength+1))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %v", rr.Code)
+	}
+}
+
+func TestTagAndDiscardReachTheMiddleware(t *testing.T) {
+	req, _ := http.NewRequest("POST", "/api/clients", nil)
+	// Requests the middleware does not store are left alone
+	Tag(req, "client:1")
+	Discard(req)
+
+	o := &outcome{}
+	req = req.WithContext(context.WithValue(req.Context(), outcomeKey{}, o))
+	Tag(req, "client:1")
+	Tag(req, "client:2")
+	if strings.Join(o.subjects, ",") != "client:1,client:2" || o.discard {
+		t.Errorf("Expected both subjects and no discard, got %v %v", o.subjects, o.discard)
+	}
+	Discard(req)
+	if !o.discard {
+		t.Error("Expected the response to be discarded")
+	}
+}
+
+func TestLargeBodiesAreSpooled(t *testing.T) {
+	m := New(nil)
+	m.MaxBodySize = 3 * memoryBodySize
+	body := strings.Repeat("b", 2*memoryBodySize)
+	req := httptest.NewRequest("POST", "/api/imports", strings.NewReader(body))
+
+	fingerprint, replay, err := m.readBody(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replay.Close()
+	if _, ok := replay.(*spooledBody); !ok {
+		t.Errorf("Expected a body past %d bytes to be spooled to a file", memoryBodySize)
+	}
+	read, _ := io.ReadAll(replay)
+	if string(read) != body {
+		t.Errorf("Expected the whole body back, got %d bytes", len(read))
+	}
+	if fingerprint != Fingerprint("POST", "/api/imports", "", []byte(body)) {
+		t.Error("Expected the streamed fingerprint to match Fingerprint")
+	}
+
+	// Bodies past MaxBodySize are refused, with or without a Content-Length
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Handler should not run")
+	}))
+	for _, length := range []int64{-1, 4 * memoryBodySize} {
+		req := httptest.NewRequest("POST", "/api/imports", strings.NewReader(strings.Repeat("b", 4*memoryBodySize)))
+		req.ContentLength = length
+		req.Header.Set(Header, "key")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected 413 with Content-Length %d, got %v", length, rr.Code)
+		}
+	}
+}
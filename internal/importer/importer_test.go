@@ -0,0 +1,231 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/internal/models"
+)
+
+func readAll(t *testing.T, r RowReader) [][]string {
+	t.Helper()
+	var rows [][]string
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			return rows
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+func TestCSVReader(t *testing.T) {
+	input := "\xef\xbb\xbfName,Email\nAna,ana@example.com\n\"Doe, John\",john@example.com,extra\n"
+	rows := readAll(t, NewCSVReader(strings.NewReader(input)))
+
+	expected := [][]string{
+		{"Name", "Email"},
+		{"Ana", "ana@example.com"},
+		{"Doe, John", "john@example.com", "extra"},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("Expected %v, got %v", expected, rows)
+	}
+}
+
+func buildXLSX(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestXLSXReader(t *testing.T) {
+	data := buildXLSX(t, map[string]string{
+		workbookPath: `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+			xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+			<sheets><sheet name="Clients" sheetId="1" r:id="rId7"/></sheets></workbook>`,
+		workbookRelsPath: `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+			<Relationship Id="rId7" Type="worksheet" Target="worksheets/clients.xml"/></Relationships>`,
+		sharedStringsPath: `<sst><si><t>name</t></si><si><t>birth</t></si><si><r><t>Ana </t></r><r><t>Lopez</t></r></si></sst>`,
+		"xl/worksheets/clients.xml": `<worksheet><sheetData>
+			<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="inlineStr"><is><t>active</t></is></c></row>
+			<row r="2"><c r="A2" t="s"><v>2</v></c><c r="C2" t="b"><v>1</v></c></row>
+			<row r="3"><c r="B3"><v>32874</v></c></row>
+		</sheetData></worksheet>`,
+	})
+
+	r, err := NewXLSXReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := readAll(t, r)
+
+	expected := [][]string{
+		{"name", "birth", "active"},
+		{"Ana Lopez", "", "TRUE"},
+		{"", "32874"},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("Expected %v, got %v", expected, rows)
+	}
+}
+
+func TestXLSXReaderInvalidFile(t *testing.T) {
+	data := []byte("not a zip file")
+	if _, err := NewXLSXReader(bytes.NewReader(data), int64(len(data))); err == nil {
+		t.Error("Expected an error for a file that is not a workbook")
+	}
+}
+
+func TestCountRows(t *testing.T) {
+	n, err := CountRows(NewCSVReader(strings.NewReader("a,b\n1,2\n3,4\n")))
+	if err != nil || n != 2 {
+		t.Errorf("Expected 2 rows, got %d (%v)", n, err)
+	}
+	n, err = CountRows(NewCSVReader(strings.NewReader("")))
+	if err != nil || n != 0 {
+		t.Errorf("Expected 0 rows for an empty file, got %d (%v)", n, err)
+	}
+}
+
+func TestMappingResolve(t *testing.T) {
+	header := []string{"Name", "E-mail", "Birth Date", "country", "Notes"}
+	mapping := Mapping{"full_name": "name", "email": "E-MAIL", "birth_date": "Birth Date"}
+
+	columns, err := mapping.Resolve(ResourceClients, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Columns{"full_name": 0, "email": 1, "birth_date": 2, "country": 3}
+	if !reflect.DeepEqual(columns, expected) {
+		t.Errorf("Expected %v, got %v", expected, columns)
+	}
+
+	record := columns.Record([]string{" Ana ", "ana@example.com"})
+	if record["full_name"] != "Ana" || record["country"] != "" {
+		t.Errorf("Unexpected record %v", record)
+	}
+
+	for name, tc := range map[string]struct {
+		resource string
+		mapping  Mapping
+		header   []string
+	}{
+		"unknown resource":      {"banks", nil, header},
+		"unknown field":         {ResourceClients, Mapping{"nickname": "Name"}, header},
+		"missing column":        {ResourceClients, Mapping{"full_name": "Full Name"}, header},
+		"missing required":      {ResourceClients, nil, header},
+		"credit with no client": {ResourceCredits, nil, []string{"bank_id", "min_payment", "max_payment", "term_months", "credit_type"}},
+	} {
+		if _, err := tc.mapping.Resolve(tc.resource, tc.header); err == nil {
+			t.Errorf("Expected an error for %s", name)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	expected := time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, value := range []string{"1990-01-01", "32874", "32874.5"} {
+		d, err := ParseDate(value)
+		if err != nil || !d.Equal(expected) {
+			t.Errorf("Expected %v for %q, got %v (%v)", expected, value, d, err)
+		}
+	}
+	if _, err := ParseDate("01/01/1990"); err == nil {
+		t.Error("Expected an error for an unsupported date format")
+	}
+}
+
+func TestParseClient(t *testing.T) {
+	client, err := ParseClient(Record{"full_name": "Ana", "email": "ana@example.com", "birth_date": "1990-01-01", "country": "USA"})
+	if err != nil || client.Email != "ana@example.com" || client.BirthDate.Year() != 1990 {
+		t.Errorf("Unexpected client %+v (%v)", client, err)
+	}
+	if _, err := ParseClient(Record{"full_name": "Ana", "email": "", "birth_date": "1990-01-01", "country": "USA"}); err == nil {
+		t.Error("Expected an error for a missing email")
+	}
+}
+
+func TestParseCredit(t *testing.T) {
+	record := Record{
+		"client_email": "ana@example.com", "bank_id": "3", "min_payment": "100",
+		"max_payment": "250.5", "term_months": "12", "credit_type": "auto", "status": "",
+	}
+	row, err := ParseCredit(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row.ClientEmail != "ana@example.com" || row.Credit.BankID != 3 || row.Credit.MaxPayment != 250.5 ||
+		row.Credit.CreditType != models.CreditTypeAuto || row.Credit.Status != "" {
+		t.Errorf("Unexpected credit row %+v", row)
+	}
+
+	record["client_email"] = ""
+	if _, err := ParseCredit(record); err == nil {
+		t.Error("Expected an error without client_email or client_id")
+	}
+	record["client_id"] = "7"
+	record["term_months"] = "twelve"
+	if _, err := ParseCredit(record); err == nil {
+		t.Error("Expected an error for a non-numeric term")
+	}
+}
+
+func TestXLSXReaderLimits(t *testing.T) {
+	defer func(size int64, count int) { maxPartSize, maxSharedStrings = size, count }(maxPartSize, maxSharedStrings)
+	maxPartSize, maxSharedStrings = 200, 2
+
+	workbook := map[string]string{
+		workbookPath: `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+			<sheets><sheet r:id="rId1"/></sheets></workbook>`,
+		workbookRelsPath:           `<Relationships><Relationship Id="rId1" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData></sheetData></worksheet>`,
+	}
+	open := func(extra map[string]string) error {
+		files := map[string]string{}
+		for name, content := range workbook {
+			files[name] = content
+		}
+		for name, content := range extra {
+			files[name] = content
+		}
+		data := buildXLSX(t, files)
+		_, err := NewXLSXReader(bytes.NewReader(data), int64(len(data)))
+		return err
+	}
+
+	if err := open(nil); err != nil {
+		t.Fatalf("Expected a small workbook to open, got %v", err)
+	}
+	if err := open(map[string]string{sharedStringsPath: `<sst><si><t>a</t></si><si><t>b</t></si><si><t>c</t></si></sst>`}); err == nil {
+		t.Error("Expected too many shared strings to be rejected")
+	}
+	if err := open(map[string]string{sharedStringsPath: `<sst><si><t>` + strings.Repeat("a", 300) + `</t></si></sst>`}); err == nil {
+		t.Error("Expected an oversized part to be rejected")
+	}
+	if _, err := columnIndex("ZZZZZZZZZZZZ1"); err == nil {
+		t.Error("Expected a column beyond XFD to be rejected")
+	}
+	if index, err := columnIndex("XFD1"); err != nil || index != maxColumns-1 {
+		t.Errorf("Expected XFD to be the last column, got %d %v", index, err)
+	}
+}
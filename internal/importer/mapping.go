@@ -0,0 +1,186 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/internal/models"
+)
+
+// Import resources.
+const (
+	ResourceClients = "clients"
+	ResourceCredits = "credits"
+)
+
+// Fields lists the fields each resource reads from a row.
+var Fields = map[string][]string{
+	ResourceClients: {"full_name", "email", "birth_date", "country"},
+	ResourceCredits: {"client_email", "client_id", "bank_id", "min_payment", "max_payment", "term_months", "credit_type", "status"},
+}
+
+// requiredFields lists, per resource, the fields that must have a column.
+// Credits additionally need client_email or client_id.
+var requiredFields = map[string][]string{
+	ResourceClients: {"full_name", "email", "birth_date", "country"},
+	ResourceCredits: {"bank_id", "min_payment", "max_payment", "term_months", "credit_type"},
+}
+
+// Mapping maps import fields to column headers. Fields without an entry are
+// read from the column whose header equals the field name, ignoring case.
+type Mapping map[string]string
+
+// Columns holds the column index of every field present in a file.
+type Columns map[string]int
+
+// Record is one row keyed by field name, with surrounding spaces trimmed.
+type Record map[string]string
+
+// Resolve matches the header row against the mapping for resource and
+// returns the column of every field it finds.
+func (m Mapping) Resolve(resource string, header []string) (Columns, error) {
+	fields, ok := Fields[resource]
+	if !ok {
+		return nil, fmt.Errorf("unknown resource %q", resource)
+	}
+	known := map[string]bool{}
+	for _, field := range fields {
+		known[field] = true
+	}
+	for field := range m {
+		if !known[field] {
+			return nil, fmt.Errorf("unknown field %q in mapping", field)
+		}
+	}
+
+	positions := map[string]int{}
+	for i, name := range header {
+		key := strings.ToLower(strings.TrimSpace(name))
+		if _, seen := positions[key]; !seen {
+			positions[key] = i
+		}
+	}
+
+	columns := Columns{}
+	for _, field := range fields {
+		name, mapped := m[field]
+		if !mapped {
+			name = field
+		}
+		i, ok := positions[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			if mapped {
+				return nil, fmt.Errorf("column %q mapped to %s not found", name, field)
+			}
+			continue
+		}
+		columns[field] = i
+	}
+
+	for _, field := range requiredFields[resource] {
+		if _, ok := columns[field]; !ok {
+			return nil, fmt.Errorf("no column for required field %s", field)
+		}
+	}
+	if resource == ResourceCredits {
+		_, byEmail := columns["client_email"]
+		_, byID := columns["client_id"]
+		if !byEmail && !byID {
+			return nil, errors.New("no column for client_email or client_id")
+		}
+	}
+	return columns, nil
+}
+
+// Record picks the mapped fields out of row. Short rows yield empty values.
+func (c Columns) Record(row []string) Record {
+	record := Record{}
+	for field, i := range c {
+		if i < len(row) {
+			record[field] = strings.TrimSpace(row[i])
+		} else {
+			record[field] = ""
+		}
+	}
+	return record
+}
+
+// excelEpoch is day zero of Excel serial dates, accounting for the 1900 leap
+// year bug.
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// ParseDate accepts YYYY-MM-DD or an Excel serial date, which is how XLSX
+// files store date cells.
+func ParseDate(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	serial, err := strconv.ParseFloat(value, 64)
+	if err != nil || serial < 1 {
+		return time.Time{}, fmt.Errorf("invalid date %q. Use YYYY-MM-DD", value)
+	}
+	return excelEpoch.AddDate(0, 0, int(math.Floor(serial))), nil
+}
+
+// ParseClient builds a client from a row.
+func ParseClient(record Record) (models.Client, error) {
+	client := models.Client{
+		FullName: record["full_name"],
+		Email:    record["email"],
+		Country:  record["country"],
+	}
+	for _, field := range []string{"full_name", "email", "country"} {
+		if record[field] == "" {
+			return client, fmt.Errorf("%s is required", field)
+		}
+	}
+	birthDate, err := ParseDate(record["birth_date"])
+	if err != nil {
+		return client, fmt.Errorf("birth_date: %v", err)
+	}
+	client.BirthDate = birthDate
+	return client, nil
+}
+
+// CreditRow is a credit read from a row together with the email of its
+// client, which is resolved to ClientID when set.
+type CreditRow struct {
+	Credit      models.Credit
+	ClientEmail string
+}
+
+// ParseCredit builds a credit from a row. Field values are checked the same
+// way as for single credits once the client is known.
+func ParseCredit(record Record) (CreditRow, error) {
+	row := CreditRow{ClientEmail: record["client_email"]}
+	credit := &row.Credit
+
+	if row.ClientEmail == "" {
+		id, err := strconv.Atoi(record["client_id"])
+		if err != nil {
+			return row, errors.New("client_email or a numeric client_id is required")
+		}
+		credit.ClientID = id
+	}
+
+	var err error
+	if credit.BankID, err = strconv.Atoi(record["bank_id"]); err != nil {
+		return row, errors.New("bank_id must be a number")
+	}
+	if credit.MinPayment, err = strconv.ParseFloat(record["min_payment"], 64); err != nil {
+		return row, errors.New("min_payment must be a number")
+	}
+	if credit.MaxPayment, err = strconv.ParseFloat(record["max_payment"], 64); err != nil {
+		return row, errors.New("max_payment must be a number")
+	}
+	if credit.TermMonths, err = strconv.Atoi(record["term_months"]); err != nil {
+		return row, errors.New("term_months must be a whole number")
+	}
+	credit.CreditType = models.CreditType(strings.ToUpper(record["credit_type"]))
+	credit.Status = models.CreditStatus(strings.ToUpper(record["status"]))
+	return row, nil
+}
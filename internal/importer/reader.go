@@ -0,0 +1,86 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Spreadsheet formats accepted for import.
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// ErrUnsupportedFormat is returned for formats other than CSV and XLSX.
+var ErrUnsupportedFormat = errors.New("unsupported format")
+
+// RowReader yields the rows of a spreadsheet, header first, and returns
+// io.EOF after the last row.
+type RowReader interface {
+	Read() ([]string, error)
+}
+
+// Open returns a RowReader for the file in the given format.
+func Open(format string, r io.ReaderAt, size int64) (RowReader, error) {
+	switch format {
+	case FormatCSV:
+		return NewCSVReader(io.NewSectionReader(r, 0, size)), nil
+	case FormatXLSX:
+		return NewXLSXReader(r, size)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+// FormatFromName guesses the format from a file name extension.
+func FormatFromName(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".csv"):
+		return FormatCSV
+	case strings.HasSuffix(lower, ".xlsx"):
+		return FormatXLSX
+	default:
+		return ""
+	}
+}
+
+type csvReader struct {
+	r *csv.Reader
+}
+
+// NewCSVReader reads comma-separated rows. Rows may have differing lengths
+// and a leading UTF-8 byte order mark is dropped.
+func NewCSVReader(r io.Reader) RowReader {
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(3); err == nil && string(bom) == "\xef\xbb\xbf" {
+		br.Discard(3)
+	}
+	cr := csv.NewReader(br)
+	cr.FieldsPerRecord = -1
+	return &csvReader{r: cr}
+}
+
+func (c *csvReader) Read() ([]string, error) {
+	return c.r.Read()
+}
+
+// CountRows returns the number of rows after the header.
+func CountRows(r RowReader) (int, error) {
+	n := -1
+	for {
+		if _, err := r.Read(); err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, err
+		}
+		n++
+	}
+	if n < 0 {
+		return 0, nil
+	}
+	return n, nil
+}
@@ -0,0 +1,338 @@
+package importer
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+const (
+	workbookPath      = "xl/workbook.xml"
+	workbookRelsPath  = "xl/_rels/workbook.xml.rels"
+	sharedStringsPath = "xl/sharedStrings.xml"
+)
+
+// Limits on what a workbook may expand to. The upload size only bounds the
+// compressed archive, so each part is also bounded once decompressed.
+var (
+	maxPartSize      int64 = 128 << 20
+	maxSharedStrings       = 1 << 20
+	// maxColumns is the widest sheet Excel allows (column XFD).
+	maxColumns = 16384
+)
+
+type xlsxReader struct {
+	closer  io.Closer
+	decoder *xml.Decoder
+	strings []string
+	done    bool
+}
+
+// NewXLSXReader reads the first worksheet of an XLSX workbook. Cells are read
+// as their stored values, so formulas yield their last computed result and
+// dates yield Excel serial numbers (see ParseDate).
+func NewXLSXReader(r io.ReaderAt, size int64) (RowReader, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid xlsx file: %w", err)
+	}
+	files := map[string]*zip.File{}
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	sheetPath, err := firstSheetPath(files)
+	if err != nil {
+		return nil, err
+	}
+	sheet, ok := files[sheetPath]
+	if !ok {
+		return nil, fmt.Errorf("invalid xlsx file: missing %s", sheetPath)
+	}
+
+	var shared []string
+	if f, ok := files[sharedStringsPath]; ok {
+		if shared, err = readSharedStrings(f); err != nil {
+			return nil, err
+		}
+	}
+
+	rc, err := openPart(sheet)
+	if err != nil {
+		return nil, err
+	}
+	return &xlsxReader{closer: rc, decoder: xml.NewDecoder(rc), strings: shared}, nil
+}
+
+// firstSheetPath follows the workbook relationships to the first sheet.
+func firstSheetPath(files map[string]*zip.File) (string, error) {
+	var workbook struct {
+		Sheets []struct {
+			RID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := decodeXMLFile(files, workbookPath, &workbook); err != nil {
+		return "", err
+	}
+	if len(workbook.Sheets) == 0 {
+		return "", errors.New("invalid xlsx file: workbook has no sheets")
+	}
+
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := decodeXMLFile(files, workbookRelsPath, &rels); err != nil {
+		return "", err
+	}
+	for _, rel := range rels.Relationships {
+		if rel.ID != workbook.Sheets[0].RID {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/"), nil
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+	return "", errors.New("invalid xlsx file: first sheet not found")
+}
+
+func decodeXMLFile(files map[string]*zip.File, name string, v interface{}) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("invalid xlsx file: missing %s", name)
+	}
+	rc, err := openPart(f)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("invalid xlsx file: %s: %w", name, err)
+	}
+	return nil
+}
+
+// openPart opens a file of the archive, failing once it decompresses to more
+// than maxPartSize bytes, whatever size its header declares.
+func openPart(f *zip.File) (io.ReadCloser, error) {
+	if f.UncompressedSize64 > uint64(maxPartSize) {
+		return nil, fmt.Errorf("invalid xlsx file: %s is larger than %d bytes", f.Name, maxPartSize)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	return &partReader{ReadCloser: rc, name: f.Name, remaining: maxPartSize}, nil
+}
+
+type partReader struct {
+	io.ReadCloser
+	name      string
+	remaining int64
+}
+
+func (p *partReader) Read(b []byte) (int, error) {
+	if p.remaining <= 0 {
+		// Only an error if the part really goes on
+		var probe [1]byte
+		if n, _ := p.ReadCloser.Read(probe[:]); n > 0 {
+			return 0, fmt.Errorf("invalid xlsx file: %s is larger than %d bytes", p.name, maxPartSize)
+		}
+		return 0, io.EOF
+	}
+	if int64(len(b)) > p.remaining {
+		b = b[:p.remaining]
+	}
+	n, err := p.ReadCloser.Read(b)
+	p.remaining -= int64(n)
+	return n, err
+}
+
+type sharedString struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+// readSharedStrings returns the shared string table, joining the runs of
+// rich text entries. The table is read an entry at a time so that its size
+// can be checked as it goes.
+func readSharedStrings(f *zip.File) ([]string, error) {
+	rc, err := openPart(f)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	values := []string{}
+	decoder := xml.NewDecoder(rc)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid xlsx file: %s: %w", sharedStringsPath, err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "si" {
+			continue
+		}
+		if len(values) == maxSharedStrings {
+			return nil, fmt.Errorf("invalid xlsx file: more than %d shared strings", maxSharedStrings)
+		}
+		var item sharedString
+		if err := decoder.DecodeElement(&item, &start); err != nil {
+			return nil, fmt.Errorf("invalid xlsx file: %s: %w", sharedStringsPath, err)
+		}
+		if len(item.Runs) == 0 {
+			values = append(values, item.Text)
+			continue
+		}
+		var b strings.Builder
+		for _, run := range item.Runs {
+			b.WriteString(run.Text)
+		}
+		values = append(values, b.String())
+	}
+}
+
+type xlsxCell struct {
+	Ref    string `xml:"r,attr"`
+	Type   string `xml:"t,attr"`
+	Value  string `xml:"v"`
+	Inline struct {
+		Text string `xml:"t"`
+		Runs []struct {
+			Text string `xml:"t"`
+		} `xml:"r"`
+	} `xml:"is"`
+}
+
+// Read returns the next non-empty row. Cells missing from the sheet, which
+// XLSX writers omit when blank, are returned as empty strings.
+func (x *xlsxReader) Read() ([]string, error) {
+	if x.done {
+		return nil, io.EOF
+	}
+	var row []string
+	inRow := false
+	for {
+		token, err := x.decoder.Token()
+		if err == io.EOF {
+			x.finish()
+			return nil, io.EOF
+		}
+		if err != nil {
+			x.finish()
+			return nil, fmt.Errorf("invalid xlsx file: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "row":
+				inRow, row = true, []string{}
+			case "c":
+				if !inRow {
+					continue
+				}
+				var cell xlsxCell
+				if err := x.decoder.DecodeElement(&cell, &t); err != nil {
+					x.finish()
+					return nil, fmt.Errorf("invalid xlsx file: %w", err)
+				}
+				value, err := x.cellValue(cell)
+				if err != nil {
+					x.finish()
+					return nil, err
+				}
+				column := len(row)
+				if cell.Ref != "" {
+					if column, err = columnIndex(cell.Ref); err != nil {
+						x.finish()
+						return nil, err
+					}
+				}
+				for len(row) < column {
+					row = append(row, "")
+				}
+				if column < len(row) {
+					row[column] = value
+				} else {
+					row = append(row, value)
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local == "row" && inRow {
+				return row, nil
+			}
+			if t.Name.Local == "sheetData" {
+				x.finish()
+				return nil, io.EOF
+			}
+		}
+	}
+}
+
+func (x *xlsxReader) finish() {
+	x.done = true
+	x.closer.Close()
+}
+
+func (x *xlsxReader) cellValue(cell xlsxCell) (string, error) {
+	switch cell.Type {
+	case "s":
+		var index int
+		if _, err := fmt.Sscan(cell.Value, &index); err != nil || index < 0 || index >= len(x.strings) {
+			return "", fmt.Errorf("invalid xlsx file: bad shared string reference in cell %s", cell.Ref)
+		}
+		return x.strings[index], nil
+	case "inlineStr":
+		if len(cell.Inline.Runs) == 0 {
+			return cell.Inline.Text, nil
+		}
+		var b strings.Builder
+		for _, run := range cell.Inline.Runs {
+			b.WriteString(run.Text)
+		}
+		return b.String(), nil
+	case "b":
+		if cell.Value == "1" {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	default:
+		return cell.Value, nil
+	}
+}
+
+// columnIndex converts the letters of a cell reference such as "AB12" to a
+// zero-based column index.
+func columnIndex(ref string) (int, error) {
+	index := 0
+	letters := 0
+	for _, ch := range ref {
+		if ch >= 'A' && ch <= 'Z' {
+			index = index*26 + int(ch-'A'+1)
+			letters++
+			if index > maxColumns {
+				return 0, fmt.Errorf("invalid xlsx file: cell reference %q is beyond column XFD", ref)
+			}
+			continue
+		}
+		break
+	}
+	if letters == 0 {
+		return 0, fmt.Errorf("invalid xlsx file: bad cell reference %q", ref)
+	}
+	return index - 1, nil
+}
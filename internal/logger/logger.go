@@ -51,9 +51,13 @@ func Initialize() error {
 	return nil
 }
 
-// Close closes the log file
+// Close flushes the log file to disk and closes it
 func Close() error {
 	if APILogger != nil && APILogger.file != nil {
+		if err := APILogger.file.Sync(); err != nil {
+			APILogger.file.Close()
+			return err
+		}
 		return APILogger.file.Close()
 	}
 	return nil
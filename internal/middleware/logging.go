@@ -13,7 +13,7 @@ import (
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
-	body       *bytes.Buffer
+	size       int
 }
 
 func (rw *responseWriter) WriteHeader(code int) {
@@ -22,10 +22,22 @@ func (rw *responseWriter) WriteHeader(code int) {
 }
 
 func (rw *responseWriter) Write(b []byte) (int, error) {
-	if rw.body != nil {
-		rw.body.Write(b)
+	n, err := rw.ResponseWriter.Write(b)
+	rw.size += n
+	return n, err
+}
+
+// Flush lets streaming handlers push data through the wrapper.
+func (rw *responseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
 	}
-	return rw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the connection, for streams
+// lifting the server's deadlines.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
 }
 
 // LoggingMiddleware logs all HTTP requests and responses
@@ -48,7 +60,6 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 		rw := &responseWriter{
 			ResponseWriter: w,
 			statusCode:     http.StatusOK, // Default status
-			body:           &bytes.Buffer{},
 		}
 
 		// Call the next handler
@@ -67,7 +78,8 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 			UserAgent:    r.UserAgent(),
 			RemoteAddr:   r.RemoteAddr,
 			RequestBody:  requestBody,
-			ResponseSize: rw.body.Len(),
+			ResponseSize: rw.size,
+			RequestID:    RequestID(r.Context()),
 		}
 
 		// Log the request
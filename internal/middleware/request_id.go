@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+type contextKey string
+
+const requestIDKey contextKey = "request_id"
+
+// RequestIDHeader carries the request id in both directions. A client supplied
+// value is kept so ids can be correlated across services.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDMiddleware assigns every request an id, stores it in the request
+// context and echoes it in the response headers.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, id := WithRequestID(r.Context(), r.Header.Get(RequestIDHeader))
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// WithRequestID stores id in ctx as the request id, or a new id when id is
+// empty or too long, for requests that do not pass RequestIDMiddleware.
+func WithRequestID(ctx context.Context, id string) (context.Context, string) {
+	if id == "" || len(id) > 64 {
+		id = newRequestID()
+	}
+	return context.WithValue(ctx, requestIDKey, id), id
+}
+
+// RequestID returns the id assigned to the request, or "" outside of
+// RequestIDMiddleware.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
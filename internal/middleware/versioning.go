@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// MediaTypeV2 in the Accept header asks for version 2 of an endpoint on its
+// unversioned /api path.
+const MediaTypeV2 = "application/vnd.backend.v2+json"
+
+// APIVersionHeader reports which version of the API served a request.
+const APIVersionHeader = "API-Version"
+
+// Versioning chooses the version of the API serving each request. Version 1
+// lives under /api and version 2 under /api/v2, side by side in the same
+// router. A request to a v1 path accepting MediaTypeV2 is served by the v2
+// route of the same path and method when there is one. Responses of v1
+// routes that have a v2 successor carry Deprecation (RFC 9745), Sunset
+// (RFC 8594) and a successor-version Link.
+type Versioning struct {
+	// Router holds the routes of both versions
+	Router *mux.Router
+	// DeprecatedAt and SunsetAt date the v1 routes with a successor; a zero
+	// time leaves the header out, and without DeprecatedAt neither is sent.
+	DeprecatedAt time.Time
+	SunsetAt     time.Time
+}
+
+// Handler wraps the router. It has to run before routing, so it cannot be
+// added with Router.Use.
+func (v *Versioning) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest, ok := strings.CutPrefix(r.URL.Path, "/api/")
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if strings.HasPrefix(rest, "v2/") {
+			w.Header().Set(APIVersionHeader, "2")
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Accept")
+		successor := v.successor(r, rest)
+		if successor == nil {
+			w.Header().Set(APIVersionHeader, "1")
+			next.ServeHTTP(w, r)
+			return
+		}
+		if acceptsV2(r.Header.Get("Accept")) {
+			w.Header().Set(APIVersionHeader, "2")
+			next.ServeHTTP(w, successor)
+			return
+		}
+
+		w.Header().Set(APIVersionHeader, "1")
+		if !v.DeprecatedAt.IsZero() {
+			w.Header().Set("Deprecation", "@"+strconv.FormatInt(v.DeprecatedAt.Unix(), 10))
+			if !v.SunsetAt.IsZero() {
+				w.Header().Set("Sunset", v.SunsetAt.UTC().Format(http.TimeFormat))
+			}
+		}
+		w.Header().Add("Link", "<"+successor.URL.Path+`>; rel="successor-version"`)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// successor returns r moved to /api/v2, or nil when no v2 route matches it.
+// rest is the path after /api/.
+func (v *Versioning) successor(r *http.Request, rest string) *http.Request {
+	url := *r.URL
+	url.Path = "/api/v2/" + rest
+	if url.RawPath != "" {
+		url.RawPath = "/api/v2/" + strings.TrimPrefix(url.RawPath, "/api/")
+	}
+	moved := r.WithContext(r.Context())
+	moved.URL = &url
+
+	var match mux.RouteMatch
+	if !v.Router.Match(moved, &match) || match.MatchErr != nil {
+		return nil
+	}
+	return moved
+}
+
+// acceptsV2 reports whether an Accept header lists MediaTypeV2.
+func acceptsV2(accept string) bool {
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, _, _ := strings.Cut(mediaRange, ";")
+		if strings.EqualFold(strings.TrimSpace(mediaType), MediaTypeV2) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func versionedRouter() http.Handler {
+	return versionedRouterAt(time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC))
+}
+
+// versionedRouterAt serves v1 and v2 routes with v1 deprecated at
+// deprecatedAt and sunset on 2027-04-18.
+func versionedRouterAt(deprecatedAt time.Time) http.Handler {
+	r := mux.NewRouter()
+	reply := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(body)) }
+	}
+	r.HandleFunc("/api/credits/{id}", reply("v1")).Methods("GET", "DELETE")
+	r.HandleFunc("/api/banks", reply("banks v1")).Methods("GET")
+	r.HandleFunc("/api/v2/credits/{id}", reply("v2")).Methods("GET")
+
+	v := &Versioning{
+		Router:       r,
+		DeprecatedAt: deprecatedAt,
+		SunsetAt:     time.Date(2027, time.April, 18, 0, 0, 0, 0, time.UTC),
+	}
+	return v.Handler(r)
+}
+
+func TestVersioning(t *testing.T) {
+	handler := versionedRouter()
+	for name, tc := range map[string]struct {
+		method, path, accept string
+		body, version        string
+		deprecated           bool
+	}{
+		"v1 path":               {"GET", "/api/credits/7", "application/json", "v1", "1", true},
+		"v2 path":               {"GET", "/api/v2/credits/7", "", "v2", "2", false},
+		"accept v2":             {"GET", "/api/credits/7", "text/html, Application/VND.backend.v2+json;q=0.9", "v2", "2", false},
+		"no v2 route":           {"GET", "/api/banks", MediaTypeV2, "banks v1", "1", false},
+		"no v2 route of method": {"DELETE", "/api/credits/7", MediaTypeV2, "v1", "1", false},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		req.Header.Set("Accept", tc.accept)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Body.String() != tc.body || rr.Header().Get(APIVersionHeader) != tc.version {
+			t.Errorf("%s: expected %q from version %s, got %q from %q", name, tc.body, tc.version, rr.Body.String(), rr.Header().Get(APIVersionHeader))
+		}
+		if deprecated := rr.Header().Get("Deprecation") != ""; deprecated != tc.deprecated {
+			t.Errorf("%s: expected deprecated %v, got headers %v", name, tc.deprecated, rr.Header())
+		}
+	}
+}
+
+func TestVersioningDeprecationHeaders(t *testing.T) {
+	handler := versionedRouter()
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/credits/7", nil))
+
+	for name, expected := range map[string]string{
+		"Deprecation": "@1792281600",
+		"Sunset":      "Sun, 18 Apr 2027 00:00:00 GMT",
+		"Link":        `</api/v2/credits/7>; rel="successor-version"`,
+		"Vary":        "Accept",
+	} {
+		if got := rr.Header().Get(name); got != expected {
+			t.Errorf("Expected %s: %s, got %q", name, expected, got)
+		}
+	}
+}
+
+func TestVersioningNotDeprecated(t *testing.T) {
+	handler := versionedRouterAt(time.Time{})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/credits/7", nil))
+
+	if rr.Header().Get("Deprecation") != "" || rr.Header().Get("Sunset") != "" {
+		t.Errorf("Expected no deprecation headers before a deprecation date is set, got %v", rr.Header())
+	}
+	if rr.Header().Get("Link") == "" {
+		t.Error("Expected the successor link regardless")
+	}
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEvent records a single change to a stored resource.
+type AuditEvent struct {
+	ID           int64           `json:"id"`
+	Actor        string          `json:"actor"`
+	ResourceType string          `json:"resource_type"`
+	ResourceID   int             `json:"resource_id"`
+	Operation    string          `json:"operation"`
+	Before       json.RawMessage `json:"before,omitempty"`
+	After        json.RawMessage `json:"after,omitempty"`
+	Changes      json.RawMessage `json:"changes,omitempty"`
+	RequestID    string          `json:"request_id,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
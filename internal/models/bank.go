@@ -10,8 +10,10 @@ const (
 )
 
 type Bank struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name"`
-	Type      BankType  `json:"type"`
-	CreatedAt time.Time `json:"created_at"`
+	ID        int        `json:"id"`
+	Name      string     `json:"name"`
+	Type      BankType   `json:"type"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Version   int        `json:"version"`
+	CreatedAt time.Time  `json:"created_at"`
 }
\ No newline at end of file
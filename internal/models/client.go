@@ -3,10 +3,25 @@ package models
 import "time"
 
 type Client struct {
-	ID        int       `json:"id"`
-	FullName  string    `json:"full_name"`
-	Email     string    `json:"email"`
-	BirthDate time.Time `json:"birth_date"`
-	Country   string    `json:"country"`
-	CreatedAt time.Time `json:"created_at"`
-}
\ No newline at end of file
+	ID        int        `json:"id"`
+	FullName  string     `json:"full_name"`
+	Email     string     `json:"email"`
+	BirthDate time.Time  `json:"birth_date"`
+	Country   string     `json:"country"`
+	ErasedAt  *time.Time `json:"erased_at,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Version   int        `json:"version"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// ClientExport is the complete archive of data stored about a client.
+type ClientExport struct {
+	ExportedAt        time.Time          `json:"exported_at"`
+	Client            Client             `json:"client"`
+	FinancialProfiles []FinancialProfile `json:"financial_profiles"`
+	Documents         []ClientDocument   `json:"documents"`
+	Credits           []Credit           `json:"credits"`
+	ImportRejections  []ImportRejection  `json:"import_rejections"`
+	Merges            []ClientMerge      `json:"merges"`
+	AuditEvents       []AuditEvent       `json:"audit_events"`
+}
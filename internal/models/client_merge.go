@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// ClientMerge records that a duplicate client was folded into a surviving
+// client, along with the records that were moved.
+type ClientMerge struct {
+	ID                   int       `json:"id"`
+	SurvivorID           int       `json:"survivor_id"`
+	DuplicateID          int       `json:"duplicate_id"`
+	MovedCreditIDs       []int64   `json:"moved_credit_ids"`
+	MovedDocumentIDs     []int64   `json:"moved_document_ids"`
+	MovedProfileVersions int       `json:"moved_profile_versions"`
+	CreatedAt            time.Time `json:"created_at"`
+}
@@ -18,13 +18,17 @@ const (
 )
 
 type Credit struct {
-	ID         int          `json:"id"`
-	ClientID   int          `json:"client_id"`
-	BankID     int          `json:"bank_id"`
-	MinPayment float64      `json:"min_payment"`
-	MaxPayment float64      `json:"max_payment"`
-	TermMonths int          `json:"term_months"`
-	CreditType CreditType   `json:"credit_type"`
-	Status     CreditStatus `json:"status"`
-	CreatedAt  time.Time    `json:"created_at"`
+	ID           int          `json:"id"`
+	ClientID     int          `json:"client_id"`
+	BankID       int          `json:"bank_id"`
+	MinPayment   float64      `json:"min_payment"`
+	MaxPayment   float64      `json:"max_payment"`
+	TermMonths   int          `json:"term_months"`
+	CreditType   CreditType   `json:"credit_type"`
+	Status       CreditStatus `json:"status"`
+	DebtToIncome *float64     `json:"debt_to_income,omitempty"`
+	DTIFlagged   bool         `json:"dti_flagged"`
+	DeletedAt    *time.Time   `json:"deleted_at,omitempty"`
+	Version      int          `json:"version"`
+	CreatedAt    time.Time    `json:"created_at"`
 }
\ No newline at end of file
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+type DocumentType string
+type DocumentStatus string
+
+const (
+	DocumentTypeIDDocument     DocumentType = "ID_DOCUMENT"
+	DocumentTypeProofOfAddress DocumentType = "PROOF_OF_ADDRESS"
+)
+
+const (
+	DocumentStatusPending  DocumentStatus = "PENDING"
+	DocumentStatusVerified DocumentStatus = "VERIFIED"
+	DocumentStatusRejected DocumentStatus = "REJECTED"
+)
+
+// RequiredDocumentTypes must be verified and unexpired before a client's
+// credit can be approved.
+var RequiredDocumentTypes = []DocumentType{DocumentTypeIDDocument, DocumentTypeProofOfAddress}
+
+// ClientDocument describes an uploaded KYC document. The file itself lives in
+// blob storage under StorageKey.
+type ClientDocument struct {
+	ID          int            `json:"id"`
+	ClientID    int            `json:"client_id"`
+	Type        DocumentType   `json:"type"`
+	FileName    string         `json:"file_name"`
+	ContentType string         `json:"content_type"`
+	SizeBytes   int64          `json:"size_bytes"`
+	SHA256      string         `json:"sha256"`
+	StorageKey  string         `json:"-"`
+	ExpiryDate  *time.Time     `json:"expiry_date,omitempty"`
+	Status      DocumentStatus `json:"status"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+type EmploymentType string
+
+const (
+	EmploymentTypeEmployed     EmploymentType = "EMPLOYED"
+	EmploymentTypeSelfEmployed EmploymentType = "SELF_EMPLOYED"
+	EmploymentTypeUnemployed   EmploymentType = "UNEMPLOYED"
+	EmploymentTypeRetired      EmploymentType = "RETIRED"
+)
+
+// FinancialProfile is one version of a client's declared finances. Every
+// update inserts a new version, so the history of declarations is kept.
+type FinancialProfile struct {
+	ID             int            `json:"id"`
+	ClientID       int            `json:"client_id"`
+	Version        int            `json:"version"`
+	MonthlyIncome  float64        `json:"monthly_income"`
+	EmploymentType EmploymentType `json:"employment_type"`
+	ExternalDebts  float64        `json:"external_debts"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
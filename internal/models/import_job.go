@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+type ImportJobStatus string
+
+const (
+	ImportJobPending   ImportJobStatus = "PENDING"
+	ImportJobRunning   ImportJobStatus = "RUNNING"
+	ImportJobCompleted ImportJobStatus = "COMPLETED"
+	ImportJobFailed    ImportJobStatus = "FAILED"
+)
+
+// ImportJob tracks one spreadsheet import. A dry run validates every row and
+// reports rejections without keeping anything.
+type ImportJob struct {
+	ID            int               `json:"id"`
+	Resource      string            `json:"resource"`
+	Format        string            `json:"format"`
+	FileName      string            `json:"file_name"`
+	DryRun        bool              `json:"dry_run"`
+	Mapping       map[string]string `json:"mapping"`
+	Status        ImportJobStatus   `json:"status"`
+	TotalRows     int               `json:"total_rows"`
+	ProcessedRows int               `json:"processed_rows"`
+	ImportedRows  int               `json:"imported_rows"`
+	RejectedRows  int               `json:"rejected_rows"`
+	Error         string            `json:"error,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	StartedAt     *time.Time        `json:"started_at,omitempty"`
+	FinishedAt    *time.Time        `json:"finished_at,omitempty"`
+}
+
+// ImportRejection is a row an import skipped, with the reason and the row as
+// it appeared in the file under its column headers.
+type ImportRejection struct {
+	JobID     int      `json:"job_id"`
+	RowNumber int      `json:"row_number"`
+	Error     string   `json:"error"`
+	Columns   []string `json:"columns"`
+	Data      []string `json:"data"`
+}
@@ -0,0 +1,231 @@
+// Package openapi describes the HTTP API as an OpenAPI 3.1 document. Request
+// and response schemas are derived from the models structs, and the document
+// is served at runtime together with a small documentation page.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"backend/internal/middleware"
+)
+
+// Document is the subset of an OpenAPI 3.1 document this service uses.
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// PathItem maps a lower-case HTTP method to its operation.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary"`
+	Tags        []string            `json:"tags,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+	Deprecated  bool                `json:"deprecated,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is the subset of JSON Schema 2020-12 used by the document. Type is
+// a string, or a list of strings for nullable values.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 interface{}        `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	ReadOnly             bool               `json:"readOnly,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+}
+
+// Operation returns the operation for method and path template, or nil.
+func (d *Document) Operation(method, path string) *Operation {
+	return d.Paths[path][strings.ToLower(method)]
+}
+
+// Resolve follows a component $ref, returning s itself when it is not a
+// reference.
+func (d *Document) Resolve(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		s = d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+// Build assembles the document from the route table.
+func Build() *Document {
+	doc := &Document{
+		OpenAPI: "3.1.0",
+		Info: Info{
+			Title:   "Backend API",
+			Version: "1.0.0",
+			Description: "Clients, banks and credits, with their documents, audit log, events and imports. " +
+				"Version 2 of an endpoint is served under /api/v2, or on its /api path to requests accepting " +
+				middleware.MediaTypeV2 + "; deprecated v1 endpoints answer with Deprecation and Sunset headers.",
+		},
+		Paths:      map[string]PathItem{},
+		Components: Components{Schemas: map[string]*Schema{}},
+	}
+	g := &generator{schemas: doc.Components.Schemas}
+
+	for _, rt := range routes(g) {
+		op := &Operation{
+			OperationID: rt.id,
+			Summary:     rt.summary,
+			Tags:        []string{rt.tag},
+			Parameters:  append(pathParameters(rt.path), rt.params...),
+			RequestBody: rt.body,
+			Responses:   map[string]Response{},
+		}
+		status := rt.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		op.Responses[strconv.Itoa(status)] = Response{Description: http.StatusText(status), Content: rt.response}
+		errors := rt.errors
+		for _, param := range rt.params {
+			// Only admins may see soft-deleted rows
+			if param.Name == includeDeletedParam.Name {
+				errors = append(errors[:len(errors):len(errors)], http.StatusForbidden)
+			}
+		}
+		for _, code := range errors {
+			op.Responses[strconv.Itoa(code)] = Response{
+				Description: http.StatusText(code),
+				Content:     jsonContent(g.ref(errorResponse{})),
+			}
+		}
+		for code, content := range rt.also {
+			op.Responses[strconv.Itoa(code)] = Response{Description: http.StatusText(code), Content: content}
+		}
+		if doc.Paths[rt.path] == nil {
+			doc.Paths[rt.path] = PathItem{}
+		}
+		doc.Paths[rt.path][strings.ToLower(rt.method)] = op
+	}
+
+	// v1 operations with a v2 successor are deprecated
+	for path, item := range doc.Paths {
+		rest, ok := strings.CutPrefix(path, "/api/")
+		if !ok || strings.HasPrefix(rest, "v2/") {
+			continue
+		}
+		for method, op := range item {
+			if doc.Paths["/api/v2/"+rest][method] != nil {
+				op.Deprecated = true
+			}
+		}
+	}
+	return doc
+}
+
+// pathParameters declares every {name} of a path template as an integer path
+// parameter; all path parameters of this API are IDs.
+func pathParameters(path string) []Parameter {
+	var params []Parameter
+	for _, part := range strings.Split(path, "/") {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			params = append(params, Parameter{
+				Name:     strings.Trim(part, "{}"),
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "integer", Minimum: float(1)},
+			})
+		}
+	}
+	return params
+}
+
+var (
+	specOnce sync.Once
+	spec     *Document
+	specJSON []byte
+)
+
+// Spec returns the document, built once.
+func Spec() *Document {
+	specOnce.Do(func() {
+		spec = Build()
+		specJSON, _ = json.MarshalIndent(spec, "", "  ")
+	})
+	return spec
+}
+
+// Methods lists the path templates and methods the document describes, as
+// "METHOD /path" in sorted order.
+func (d *Document) Methods() []string {
+	var methods []string
+	for path, item := range d.Paths {
+		for method := range item {
+			methods = append(methods, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+//go:embed docs.html
+var docsPage []byte
+
+// ServeSpec serves the document as JSON.
+func ServeSpec(w http.ResponseWriter, r *http.Request) {
+	Spec()
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(specJSON)
+}
+
+// ServeDocs serves a page that renders the document from /openapi.json.
+func ServeDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(docsPage)
+}
@@ -0,0 +1,125 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReferencesResolve(t *testing.T) {
+	doc := Build()
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var check func(v interface{})
+	check = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if ref, ok := v["$ref"].(string); ok {
+				if doc.Resolve(&Schema{Ref: ref}) == nil {
+					t.Errorf("Unresolved reference %s", ref)
+				}
+			}
+			for _, child := range v {
+				check(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				check(child)
+			}
+		}
+	}
+	var raw interface{}
+	json.Unmarshal(data, &raw)
+	check(raw)
+
+	ids := map[string]bool{}
+	for _, item := range doc.Paths {
+		for _, op := range item {
+			if ids[op.OperationID] {
+				t.Errorf("Duplicate operationId %s", op.OperationID)
+			}
+			ids[op.OperationID] = true
+		}
+	}
+}
+
+func TestDeprecatedOperations(t *testing.T) {
+	doc := Build()
+	for route, deprecated := range map[string]bool{
+		"GET /api/credits":                true,
+		"PUT /api/credits/{id}":           true,
+		"GET /api/banks/{bankId}/credits": true,
+		"DELETE /api/credits/{id}":        false,
+		"GET /api/v2/credits":             false,
+		"GET /api/banks":                  false,
+	} {
+		parts := strings.SplitN(route, " ", 2)
+		if op := doc.Operation(parts[0], parts[1]); op == nil || op.Deprecated != deprecated {
+			t.Errorf("Expected %s to be deprecated: %v", route, deprecated)
+		}
+	}
+
+	credit := doc.Components.Schemas["CreditV2"]
+	if credit == nil || credit.Properties["min_payment"].Type != "string" {
+		t.Errorf("Expected v2 amounts to be strings, got %+v", credit)
+	}
+}
+
+func TestModelSchemas(t *testing.T) {
+	doc := Build()
+
+	credit := doc.Components.Schemas["Credit"]
+	if credit == nil {
+		t.Fatal("Expected a Credit schema")
+	}
+	status := credit.Properties["status"]
+	if status.Type != "string" || strings.Join(status.Enum, ",") != "PENDING,APPROVED,REJECTED" {
+		t.Errorf("Expected the credit status enum, got %+v", status)
+	}
+	if dti := credit.Properties["debt_to_income"]; !dti.ReadOnly || strings.Join(dti.Type.([]string), ",") != "number,null" {
+		t.Errorf("Expected a nullable read-only debt_to_income, got %+v", dti)
+	}
+
+	bankType := doc.Components.Schemas["Bank"].Properties["type"]
+	if strings.Join(bankType.Enum, ",") != "PRIVATE,GOVERNMENT" {
+		t.Errorf("Expected the bank type enum, got %+v", bankType)
+	}
+
+	op := doc.Operation("POST", "/api/credits")
+	body := op.RequestBody.Content["application/json"].Schema
+	if body.Ref != "#/components/schemas/Credit" || len(body.Required) == 0 {
+		t.Errorf("Expected a Credit body with required fields, got %+v", body)
+	}
+	if _, ok := op.Responses["201"]; !ok {
+		t.Errorf("Expected a 201 response, got %v", op.Responses)
+	}
+
+	params := doc.Operation("GET", "/api/banks/{bankId}/credits").Parameters
+	if params[0].Name != "bankId" || params[0].In != "path" || !params[0].Required {
+		t.Errorf("Expected the bankId path parameter first, got %+v", params[0])
+	}
+}
+
+func TestServeSpec(t *testing.T) {
+	rr := httptest.NewRecorder()
+	ServeSpec(rr, httptest.NewRequest("GET", "/openapi.json", nil))
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["openapi"] != "3.1.0" || rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected an OpenAPI 3.1 JSON document, got %v", doc["openapi"])
+	}
+
+	rr = httptest.NewRecorder()
+	ServeDocs(rr, httptest.NewRequest("GET", "/docs", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "/openapi.json") {
+		t.Errorf("Expected the docs page to load /openapi.json")
+	}
+}
@@ -0,0 +1,404 @@
+package openapi
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"backend/internal/dedupe"
+	"backend/internal/export"
+	"backend/internal/graphql"
+	"backend/internal/handlers"
+	"backend/internal/importer"
+	"backend/internal/models"
+	"backend/internal/outbox"
+)
+
+// route documents one endpoint of the server package.
+type route struct {
+	method   string
+	path     string
+	id       string
+	summary  string
+	tag      string
+	params   []Parameter
+	body     *RequestBody
+	status   int
+	response map[string]MediaType
+	errors   []int
+	// also documents further responses, replacing the error response of a
+	// status listed in errors
+	also map[int]map[string]MediaType
+}
+
+// Bodies and responses that are not models.
+
+type errorResponse struct {
+	Error   string      `json:"error"`
+	Details []Violation `json:"details,omitempty"`
+}
+
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
+type healthResponse struct {
+	Status string `json:"status"`
+}
+
+type batchResult struct {
+	Index   int         `json:"index"`
+	Status  int         `json:"status"`
+	ID      int         `json:"id,omitempty"`
+	Skipped bool        `json:"skipped,omitempty"`
+	Error   string      `json:"error,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+type batchResponse struct {
+	Mode      string        `json:"mode"`
+	Succeeded int           `json:"succeeded"`
+	Skipped   int           `json:"skipped"`
+	Failed    int           `json:"failed"`
+	Results   []batchResult `json:"results"`
+}
+
+type bulkStatusFilter struct {
+	IDs        []int                `json:"ids"`
+	ClientID   *int                 `json:"client_id"`
+	BankID     *int                 `json:"bank_id"`
+	Status     *models.CreditStatus `json:"status"`
+	CreditType *models.CreditType   `json:"credit_type"`
+}
+
+type bulkStatusChange struct {
+	Status models.CreditStatus `json:"status"`
+	Filter bulkStatusFilter    `json:"filter"`
+}
+
+type mergeRequest struct {
+	DuplicateID int `json:"duplicate_id"`
+}
+
+type documentStatusChange struct {
+	Status models.DocumentStatus `json:"status"`
+}
+
+func query(name, description string, schema *Schema) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+func header(name, description string) Parameter {
+	return Parameter{Name: name, In: "header", Description: description, Schema: &Schema{Type: "string"}}
+}
+
+var (
+	formatParam = query("format", "List format; wins over the Accept header.",
+		&Schema{Type: "string", Enum: []string{export.FormatJSON, export.FormatCSV, export.FormatNDJSON}})
+	includeDeletedParam = query("include_deleted", "Include soft-deleted rows. Only for callers whose X-Actor is an admin.",
+		&Schema{Type: "boolean"})
+	batchModeParam = query("mode", "atomic rolls back every item when one fails; best_effort keeps the ones that succeed.",
+		&Schema{Type: "string", Enum: []string{handlers.BatchModeAtomic, handlers.BatchModeBestEffort}})
+	statusParam = query("status", "Only credits with this status.",
+		&Schema{Type: "string", Enum: []string{string(models.CreditStatusPending), string(models.CreditStatusApproved), string(models.CreditStatusRejected)}})
+	creditTypeParam = query("credit_type", "Only credits of this type.",
+		&Schema{Type: "string", Enum: []string{string(models.CreditTypeAuto), string(models.CreditTypeMortgage), string(models.CreditTypeCommercial)}})
+	ifMatchParam     = header("If-Match", "Apply the change only at this ETag.")
+	ifNoneMatchParam = header("If-None-Match", "Answer 304 while the ETag is unchanged.")
+)
+
+// pageV2Params select a page of a v2 list.
+var pageV2Params = []Parameter{
+	query("limit", "Items per page, "+strconv.Itoa(handlers.V2DefaultPageSize)+" by default.",
+		&Schema{Type: "integer", Minimum: float(1), Maximum: float(handlers.V2MaxPageSize)}),
+	query("cursor", "next_cursor of the previous page.", &Schema{Type: "string"}),
+}
+
+func fieldsParam(model interface{}) Parameter {
+	return query("fields", "Comma-separated fields to return, from: "+strings.Join(export.FieldNames(model), ", ")+".",
+		&Schema{Type: "string"})
+}
+
+func expandParam(relations ...string) Parameter {
+	return query("expand", "Comma-separated related objects to embed, from: "+strings.Join(relations, ", ")+".",
+		&Schema{Type: "string"})
+}
+
+// jsonBody is a required JSON request body of schema, with the listed
+// properties required.
+func jsonBody(schema *Schema, required ...string) *RequestBody {
+	if len(required) > 0 {
+		schema = &Schema{Ref: schema.Ref, Required: required}
+	}
+	return &RequestBody{Required: true, Content: jsonContent(schema)}
+}
+
+func multipartBody(schema *Schema) *RequestBody {
+	return &RequestBody{Required: true, Content: map[string]MediaType{"multipart/form-data": {Schema: schema}}}
+}
+
+// listContent offers a list as a JSON array, CSV or NDJSON.
+func listContent(schema *Schema) map[string]MediaType {
+	text := &Schema{Type: "string"}
+	return map[string]MediaType{
+		"application/json":     {Schema: schema},
+		"text/csv":             {Schema: text},
+		"application/x-ndjson": {Schema: text},
+	}
+}
+
+func textContent(contentType string, schema *Schema) map[string]MediaType {
+	return map[string]MediaType{contentType: {Schema: schema}}
+}
+
+func routes(g *generator) []route {
+	var (
+		client   = g.ref(models.Client{})
+		bank     = g.ref(models.Bank{})
+		credit   = g.ref(models.Credit{})
+		creditV2 = g.ref(handlers.CreditV2{})
+		item     = g.ref(models.Item{})
+		profile  = g.ref(models.FinancialProfile{})
+		document = g.ref(models.ClientDocument{})
+		webhook  = g.ref(models.WebhookSubscription{})
+		delivery = g.ref(models.WebhookDelivery{})
+		job      = g.ref(models.ImportJob{})
+		message  = jsonContent(g.ref(messageResponse{}))
+		batch    = jsonContent(g.ref(batchResponse{}))
+		// v2 lists are paged
+		creditPageV2 = g.ref(handlers.CreditPageV2{})
+		// Batches answer 207 when some items failed in best-effort mode and
+		// 422 with every result when an atomic batch is rolled back
+		batchOutcomes = map[int]map[string]MediaType{
+			http.StatusMultiStatus: batch,
+			http.StatusUnprocessableEntity: jsonContent(&Schema{
+				AnyOf: []*Schema{g.ref(batchResponse{}), g.ref(errorResponse{})},
+			}),
+		}
+	)
+	batchItems := func(description string) *RequestBody {
+		return jsonBody(&Schema{
+			Type:        "array",
+			Description: description,
+			Items:       &Schema{Type: "object"},
+			MinItems:    count(1),
+			MaxItems:    count(handlers.MaxBatchSize),
+		})
+	}
+	g.require(errorResponse{}, "error")
+	clientFields := []string{"full_name", "email", "birth_date", "country"}
+	bankFields := []string{"name", "type"}
+	creditFields := []string{"client_id", "bank_id", "min_payment", "max_payment", "term_months", "credit_type"}
+	notFound := []int{http.StatusBadRequest, http.StatusNotFound}
+	conflict := []int{http.StatusBadRequest, http.StatusNotFound, http.StatusPreconditionFailed, http.StatusPreconditionRequired}
+
+	return []route{
+		{method: "GET", path: "/health", id: "healthCheck", summary: "Report that the service is up", tag: "Service",
+			response: jsonContent(g.ref(healthResponse{}))},
+		{method: "GET", path: "/openapi.json", id: "getOpenAPI", summary: "This OpenAPI document", tag: "Service",
+			response: jsonContent(&Schema{Type: "object"})},
+		{method: "GET", path: "/docs", id: "getDocs", summary: "API documentation page", tag: "Service",
+			response: textContent("text/html", &Schema{Type: "string"})},
+
+		// Items
+		{method: "GET", path: "/api/items", id: "listItems", summary: "List items", tag: "Items",
+			params:   []Parameter{formatParam, fieldsParam(models.Item{})},
+			response: listContent(g.list(models.Item{})), errors: []int{http.StatusBadRequest}},
+		{method: "POST", path: "/api/items", id: "createItem", summary: "Create an item", tag: "Items",
+			body: jsonBody(item), status: http.StatusCreated, response: jsonContent(item), errors: []int{http.StatusBadRequest}},
+		{method: "GET", path: "/api/items/{id}", id: "getItem", summary: "Get an item", tag: "Items",
+			params: []Parameter{fieldsParam(models.Item{})}, response: jsonContent(item), errors: notFound},
+
+		// Clients
+		{method: "POST", path: "/api/clients", id: "createClient", summary: "Create a client", tag: "Clients",
+			body: jsonBody(client, clientFields...), status: http.StatusCreated, response: jsonContent(client),
+			errors: []int{http.StatusBadRequest, http.StatusConflict}},
+		{method: "POST", path: "/api/clients:batch", id: "createClientsBatch", summary: "Create several clients", tag: "Clients",
+			params: []Parameter{batchModeParam}, body: batchItems("Clients to create."), status: http.StatusCreated, response: batch, also: batchOutcomes,
+			errors: []int{http.StatusBadRequest, http.StatusUnprocessableEntity}},
+		{method: "GET", path: "/api/clients/duplicates", id: "listDuplicateClients", summary: "List likely duplicate clients", tag: "Clients",
+			params: []Parameter{formatParam, fieldsParam(dedupe.Candidate{}),
+				query("min_score", "Lowest score to report (default 0.8).", &Schema{Type: "number", Minimum: float(0), Maximum: float(1)})},
+			response: listContent(g.list(dedupe.Candidate{})), errors: []int{http.StatusBadRequest}},
+		{method: "GET", path: "/api/clients/{id}", id: "getClient", summary: "Get a client", tag: "Clients",
+			params:   []Parameter{includeDeletedParam, fieldsParam(models.Client{}), expandParam("credits"), ifNoneMatchParam},
+			response: jsonContent(client), errors: notFound},
+		{method: "PUT", path: "/api/clients/{id}", id: "updateClient", summary: "Update a client", tag: "Clients",
+			params: []Parameter{ifMatchParam}, body: jsonBody(client, clientFields...), response: jsonContent(client),
+			errors: append(conflict, http.StatusConflict)},
+		{method: "DELETE", path: "/api/clients/{id}", id: "deleteClient", summary: "Soft-delete a client", tag: "Clients",
+			params: []Parameter{ifMatchParam}, response: message, errors: conflict},
+		{method: "GET", path: "/api/clients/{id}/export", id: "exportClient", summary: "Export everything stored about a client", tag: "Clients",
+			response: jsonContent(g.ref(models.ClientExport{})), errors: notFound},
+		{method: "POST", path: "/api/clients/{id}/erase", id: "eraseClient", summary: "Erase a client's personal data", tag: "Clients",
+			response: jsonContent(client), errors: notFound},
+		{method: "POST", path: "/api/clients/{id}/merge", id: "mergeClient", summary: "Merge a duplicate into this client", tag: "Clients",
+			body: jsonBody(g.ref(mergeRequest{}), "duplicate_id"), response: jsonContent(g.ref(models.ClientMerge{})), errors: notFound},
+		{method: "POST", path: "/api/clients/{id}/restore", id: "restoreClient", summary: "Restore a soft-deleted client", tag: "Clients",
+			params: []Parameter{ifMatchParam}, response: message, errors: conflict},
+		{method: "GET", path: "/api/clients/{clientId}/financial-profile", id: "getFinancialProfile", summary: "Get a client's current financial profile", tag: "Financial profiles",
+			params: []Parameter{fieldsParam(models.FinancialProfile{})}, response: jsonContent(profile), errors: notFound},
+		{method: "PUT", path: "/api/clients/{clientId}/financial-profile", id: "updateFinancialProfile", summary: "Declare a new financial profile version", tag: "Financial profiles",
+			body: jsonBody(profile, "monthly_income", "employment_type"), response: jsonContent(profile), errors: notFound},
+		{method: "GET", path: "/api/clients/{clientId}/financial-profile/history", id: "listFinancialProfiles", summary: "List a client's financial profile versions", tag: "Financial profiles",
+			params: []Parameter{formatParam, fieldsParam(models.FinancialProfile{})}, response: listContent(g.list(models.FinancialProfile{})), errors: notFound},
+		{method: "POST", path: "/api/clients/{clientId}/documents", id: "uploadClientDocument", summary: "Upload a KYC document", tag: "Documents",
+			body: multipartBody(&Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"file":        {Type: "string", Format: "binary"},
+					"type":        g.schemaOf(typeOf(models.DocumentTypeIDDocument)),
+					"expiry_date": {Type: "string", Format: "date"},
+				},
+				Required: []string{"file", "type"},
+			}),
+			status: http.StatusCreated, response: jsonContent(document), errors: notFound},
+		{method: "GET", path: "/api/clients/{clientId}/documents", id: "listClientDocuments", summary: "List a client's documents", tag: "Documents",
+			params: []Parameter{formatParam, fieldsParam(models.ClientDocument{})}, response: listContent(g.list(models.ClientDocument{})), errors: notFound},
+		{method: "GET", path: "/api/clients/{clientId}/documents/{id}/content", id: "downloadClientDocument", summary: "Download a document's file", tag: "Documents",
+			response: textContent("*/*", &Schema{Type: "string", Format: "binary", Description: "The file, with the content type it was uploaded with."}), errors: notFound},
+		{method: "PUT", path: "/api/clients/{clientId}/documents/{id}/status", id: "updateClientDocumentStatus", summary: "Verify or reject a document", tag: "Documents",
+			body: jsonBody(g.ref(documentStatusChange{}), "status"), response: jsonContent(document), errors: notFound},
+
+		// Banks
+		{method: "GET", path: "/api/banks", id: "listBanks", summary: "List banks", tag: "Banks",
+			params:   []Parameter{formatParam, includeDeletedParam, fieldsParam(models.Bank{}), expandParam("credits")},
+			response: listContent(g.list(models.Bank{})), errors: []int{http.StatusBadRequest}},
+		{method: "POST", path: "/api/banks", id: "createBank", summary: "Create a bank", tag: "Banks",
+			body: jsonBody(bank, bankFields...), status: http.StatusCreated, response: jsonContent(bank), errors: []int{http.StatusBadRequest}},
+		{method: "GET", path: "/api/banks/{id}", id: "getBank", summary: "Get a bank", tag: "Banks",
+			params:   []Parameter{includeDeletedParam, fieldsParam(models.Bank{}), expandParam("credits"), ifNoneMatchParam},
+			response: jsonContent(bank), errors: notFound},
+		{method: "PUT", path: "/api/banks/{id}", id: "updateBank", summary: "Update a bank", tag: "Banks",
+			params: []Parameter{ifMatchParam}, body: jsonBody(bank, bankFields...), response: jsonContent(bank), errors: conflict},
+		{method: "DELETE", path: "/api/banks/{id}", id: "deleteBank", summary: "Soft-delete a bank", tag: "Banks",
+			params: []Parameter{ifMatchParam}, response: message, errors: conflict},
+		{method: "POST", path: "/api/banks/{id}/restore", id: "restoreBank", summary: "Restore a soft-deleted bank", tag: "Banks",
+			params: []Parameter{ifMatchParam}, response: message, errors: conflict},
+
+		// Credits
+		{method: "GET", path: "/api/credits", id: "listCredits", summary: "List credits", tag: "Credits",
+			params:   []Parameter{formatParam, includeDeletedParam, fieldsParam(models.Credit{}), expandParam("client", "bank")},
+			response: listContent(g.list(models.Credit{})), errors: []int{http.StatusBadRequest}},
+		{method: "POST", path: "/api/credits", id: "createCredit", summary: "Create a credit", tag: "Credits",
+			body: jsonBody(credit, creditFields...), status: http.StatusCreated, response: jsonContent(credit),
+			errors: []int{http.StatusBadRequest, http.StatusUnprocessableEntity}},
+		{method: "POST", path: "/api/credits:batch", id: "createCreditsBatch", summary: "Create several credits", tag: "Credits",
+			params: []Parameter{batchModeParam}, body: batchItems("Credits to create."), status: http.StatusCreated, response: batch, also: batchOutcomes,
+			errors: []int{http.StatusBadRequest, http.StatusUnprocessableEntity}},
+		{method: "POST", path: "/api/credits:batch-status", id: "updateCreditsStatus", summary: "Change the status of every credit matching a filter", tag: "Credits",
+			params: []Parameter{batchModeParam}, body: jsonBody(g.ref(bulkStatusChange{}), "status", "filter"), response: batch, also: batchOutcomes,
+			errors: []int{http.StatusBadRequest, http.StatusUnprocessableEntity}},
+		{method: "GET", path: "/api/credits/{id}", id: "getCredit", summary: "Get a credit", tag: "Credits",
+			params:   []Parameter{includeDeletedParam, fieldsParam(models.Credit{}), expandParam("client", "bank"), ifNoneMatchParam},
+			response: jsonContent(credit), errors: notFound},
+		{method: "PUT", path: "/api/credits/{id}", id: "updateCredit", summary: "Update a credit", tag: "Credits",
+			params: []Parameter{ifMatchParam}, body: jsonBody(credit, creditFields...), response: jsonContent(credit),
+			errors: append(conflict, http.StatusUnprocessableEntity)},
+		{method: "DELETE", path: "/api/credits/{id}", id: "deleteCredit", summary: "Soft-delete a credit", tag: "Credits",
+			params: []Parameter{ifMatchParam}, response: message, errors: conflict},
+		{method: "POST", path: "/api/credits/{id}/restore", id: "restoreCredit", summary: "Restore a soft-deleted credit", tag: "Credits",
+			params: []Parameter{ifMatchParam}, response: message, errors: conflict},
+		{method: "GET", path: "/api/clients/{clientId}/credits", id: "listClientCredits", summary: "List a client's credits", tag: "Credits",
+			params:   []Parameter{formatParam, includeDeletedParam, fieldsParam(models.Credit{}), expandParam("client", "bank")},
+			response: listContent(g.list(models.Credit{})), errors: []int{http.StatusBadRequest}},
+		{method: "GET", path: "/api/banks/{bankId}/credits", id: "listBankCredits", summary: "List a bank's credits", tag: "Credits",
+			params:   []Parameter{formatParam, includeDeletedParam, fieldsParam(models.Credit{}), expandParam("client", "bank")},
+			response: listContent(g.list(models.Credit{})), errors: []int{http.StatusBadRequest}},
+
+		// Credits, version 2
+		{method: "GET", path: "/api/v2/credits", id: "listCreditsV2", summary: "List credits a page at a time, in id order", tag: "Credits v2",
+			params:   append(pageV2Params, includeDeletedParam, statusParam, creditTypeParam),
+			response: jsonContent(creditPageV2), errors: []int{http.StatusBadRequest}},
+		{method: "POST", path: "/api/v2/credits", id: "createCreditV2", summary: "Create a credit", tag: "Credits v2",
+			body: jsonBody(creditV2, creditFields...), status: http.StatusCreated, response: jsonContent(creditV2),
+			errors: []int{http.StatusBadRequest, http.StatusUnprocessableEntity}},
+		{method: "GET", path: "/api/v2/credits/{id}", id: "getCreditV2", summary: "Get a credit", tag: "Credits v2",
+			params:   []Parameter{includeDeletedParam, ifNoneMatchParam},
+			response: jsonContent(creditV2), errors: notFound},
+		{method: "PUT", path: "/api/v2/credits/{id}", id: "updateCreditV2", summary: "Update a credit", tag: "Credits v2",
+			params: []Parameter{ifMatchParam}, body: jsonBody(creditV2, creditFields...), response: jsonContent(creditV2),
+			errors: append(conflict, http.StatusUnprocessableEntity)},
+		{method: "GET", path: "/api/v2/clients/{clientId}/credits", id: "listClientCreditsV2", summary: "List a client's credits a page at a time", tag: "Credits v2",
+			params:   append(pageV2Params, includeDeletedParam, statusParam, creditTypeParam),
+			response: jsonContent(creditPageV2), errors: []int{http.StatusBadRequest}},
+		{method: "GET", path: "/api/v2/banks/{bankId}/credits", id: "listBankCreditsV2", summary: "List a bank's credits a page at a time", tag: "Credits v2",
+			params:   append(pageV2Params, includeDeletedParam, statusParam, creditTypeParam),
+			response: jsonContent(creditPageV2), errors: []int{http.StatusBadRequest}},
+
+		// Admin
+		{method: "POST", path: "/api/admin/pii/reencrypt", id: "reencryptClients", summary: "Re-encrypt client PII with the current key", tag: "Admin",
+			status: http.StatusAccepted, response: message, errors: []int{http.StatusConflict, http.StatusInternalServerError, http.StatusServiceUnavailable}},
+
+		// Audit and events
+		{method: "GET", path: "/api/audit", id: "listAuditEvents", summary: "List audit events, newest first", tag: "Audit",
+			params: []Parameter{formatParam, fieldsParam(models.AuditEvent{}),
+				query("resource_type", "Only events about this resource type.", &Schema{Type: "string"}),
+				query("resource_id", "Only events about this resource.", &Schema{Type: "integer"}),
+				query("actor", "Only events by this actor.", &Schema{Type: "string"}),
+				query("from", "Only events at or after this time.", &Schema{Type: "string", Format: "date-time"}),
+				query("to", "Only events before this time.", &Schema{Type: "string", Format: "date-time"}),
+				query("limit", "Number of events to return.", &Schema{Type: "integer", Minimum: float(1)})},
+			response: listContent(g.list(models.AuditEvent{})), errors: []int{http.StatusBadRequest}},
+		{method: "GET", path: "/api/events/stream", id: "streamEvents", summary: "Stream domain events as Server-Sent Events", tag: "Events",
+			params: []Parameter{
+				query("types", "Comma-separated event types, from: "+strings.Join(outbox.EventTypes, ", ")+".", &Schema{Type: "string"}),
+				query("bank_id", "Only events about this bank and its credits.", &Schema{Type: "integer"}),
+				query("last_event_id", "Resume after this event; the Last-Event-ID header wins.", &Schema{Type: "integer"}),
+				header("Last-Event-ID", "Resume after this event.")},
+			response: textContent("text/event-stream", &Schema{Type: "string"}),
+			errors:   []int{http.StatusBadRequest, http.StatusServiceUnavailable}},
+
+		// Webhooks
+		{method: "GET", path: "/api/webhooks", id: "listWebhooks", summary: "List webhook subscriptions", tag: "Webhooks",
+			params:   []Parameter{formatParam, fieldsParam(models.WebhookSubscription{})},
+			response: listContent(g.list(models.WebhookSubscription{})), errors: []int{http.StatusBadRequest}},
+		{method: "POST", path: "/api/webhooks", id: "createWebhook", summary: "Subscribe an endpoint to events", tag: "Webhooks",
+			body: jsonBody(webhook, "url"), status: http.StatusCreated, response: jsonContent(webhook), errors: []int{http.StatusBadRequest}},
+		{method: "GET", path: "/api/webhooks/{id}", id: "getWebhook", summary: "Get a webhook subscription", tag: "Webhooks",
+			params: []Parameter{fieldsParam(models.WebhookSubscription{})}, response: jsonContent(webhook), errors: notFound},
+		{method: "PUT", path: "/api/webhooks/{id}", id: "updateWebhook", summary: "Update a webhook subscription", tag: "Webhooks",
+			body: jsonBody(webhook, "url"), response: jsonContent(webhook), errors: notFound},
+		{method: "DELETE", path: "/api/webhooks/{id}", id: "deleteWebhook", summary: "Delete a webhook subscription", tag: "Webhooks",
+			response: message, errors: notFound},
+		{method: "GET", path: "/api/webhooks/{id}/deliveries", id: "listWebhookDeliveries", summary: "List a subscription's deliveries", tag: "Webhooks",
+			params: []Parameter{formatParam, fieldsParam(models.WebhookDelivery{}),
+				query("status", "Only deliveries in this status.", g.schemaOf(typeOf(models.WebhookDeliveryPending)))},
+			response: listContent(g.list(models.WebhookDelivery{})), errors: notFound},
+		{method: "GET", path: "/api/webhooks/{id}/deliveries/{deliveryId}/attempts", id: "listWebhookDeliveryAttempts", summary: "List the HTTP calls made for a delivery", tag: "Webhooks",
+			params:   []Parameter{formatParam, fieldsParam(models.WebhookDeliveryAttempt{})},
+			response: listContent(g.list(models.WebhookDeliveryAttempt{})), errors: notFound},
+		{method: "POST", path: "/api/webhooks/{id}/deliveries/{deliveryId}/redeliver", id: "redeliverWebhook", summary: "Queue a delivery again", tag: "Webhooks",
+			status: http.StatusAccepted, response: jsonContent(delivery), errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+
+		// Imports
+		{method: "GET", path: "/api/imports", id: "listImports", summary: "List the latest imports", tag: "Imports",
+			params:   []Parameter{formatParam, fieldsParam(models.ImportJob{})},
+			response: listContent(g.list(models.ImportJob{})), errors: []int{http.StatusBadRequest}},
+		{method: "POST", path: "/api/imports", id: "createImport", summary: "Import clients or credits from a CSV or XLSX file", tag: "Imports",
+			body: multipartBody(&Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"file":     {Type: "string", Format: "binary"},
+					"resource": {Type: "string", Enum: []string{importer.ResourceClients, importer.ResourceCredits}},
+					"format":   {Type: "string", Enum: []string{importer.FormatCSV, importer.FormatXLSX}},
+					"mapping":  {Type: "string", Description: "JSON object mapping fields to column headers."},
+					"dry_run":  {Type: "string", Enum: []string{"true", "false"}},
+				},
+				Required: []string{"file", "resource"},
+			}),
+			status: http.StatusCreated, response: jsonContent(job), errors: []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusServiceUnavailable},
+			also: map[int]map[string]MediaType{http.StatusAccepted: jsonContent(job)}},
+		{method: "GET", path: "/api/imports/{id}", id: "getImport", summary: "Get an import's progress and counts", tag: "Imports",
+			params: []Parameter{fieldsParam(models.ImportJob{})}, response: jsonContent(job), errors: notFound},
+		{method: "GET", path: "/api/imports/{id}/errors", id: "getImportErrors", summary: "Download the rejected rows of an import", tag: "Imports",
+			response: textContent("text/csv", &Schema{Type: "string"}), errors: notFound},
+
+		// GraphQL
+		{method: "POST", path: "/graphql", id: "graphql", summary: "Run a GraphQL query or mutation over clients, banks and credits", tag: "GraphQL",
+			body: jsonBody(g.ref(graphql.Request{}), "query"), response: jsonContent(g.ref(graphql.Response{})),
+			errors: []int{http.StatusBadRequest}},
+		{method: "GET", path: "/graphql/schema", id: "getGraphQLSchema", summary: "The GraphQL schema in SDL", tag: "GraphQL",
+			response: textContent("text/plain", &Schema{Type: "string"})},
+	}
+}
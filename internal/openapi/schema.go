@@ -0,0 +1,182 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"backend/internal/models"
+)
+
+// enums lists the values of the models' string enum types, which Go cannot
+// enumerate on its own.
+var enums = map[reflect.Type][]string{
+	reflect.TypeOf(models.BankType("")): {
+		string(models.BankTypePrivate), string(models.BankTypeGovernment),
+	},
+	reflect.TypeOf(models.CreditType("")): {
+		string(models.CreditTypeAuto), string(models.CreditTypeMortgage), string(models.CreditTypeCommercial),
+	},
+	reflect.TypeOf(models.CreditStatus("")): {
+		string(models.CreditStatusPending), string(models.CreditStatusApproved), string(models.CreditStatusRejected),
+	},
+	reflect.TypeOf(models.DocumentType("")): {
+		string(models.DocumentTypeIDDocument), string(models.DocumentTypeProofOfAddress),
+	},
+	reflect.TypeOf(models.DocumentStatus("")): {
+		string(models.DocumentStatusPending), string(models.DocumentStatusVerified), string(models.DocumentStatusRejected),
+	},
+	reflect.TypeOf(models.EmploymentType("")): {
+		string(models.EmploymentTypeEmployed), string(models.EmploymentTypeSelfEmployed),
+		string(models.EmploymentTypeUnemployed), string(models.EmploymentTypeRetired),
+	},
+	reflect.TypeOf(models.WebhookDeliveryStatus("")): {
+		string(models.WebhookDeliveryPending), string(models.WebhookDeliverySucceeded), string(models.WebhookDeliveryFailed),
+	},
+	reflect.TypeOf(models.ImportJobStatus("")): {
+		string(models.ImportJobPending), string(models.ImportJobRunning),
+		string(models.ImportJobCompleted), string(models.ImportJobFailed),
+	},
+}
+
+// readOnly names the fields the server sets. They are documented on the
+// models but ignored when sent in a request.
+var readOnly = map[string]bool{
+	"id":                   true,
+	"version":              true,
+	"created_at":           true,
+	"deleted_at":           true,
+	"erased_at":            true,
+	"debt_to_income":       true,
+	"dti_flagged":          true,
+	"consecutive_failures": true,
+	"disabled_at":          true,
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage{})
+)
+
+// generator derives schemas from Go types, registering each struct once as
+// a component named after its type.
+type generator struct {
+	schemas map[string]*Schema
+}
+
+// ref returns a reference to the component schema of v's struct type.
+func (g *generator) ref(v interface{}) *Schema {
+	return g.schemaOf(reflect.TypeOf(v))
+}
+
+// list returns an array schema of v's struct type.
+func (g *generator) list(v interface{}) *Schema {
+	return &Schema{Type: "array", Items: g.ref(v)}
+}
+
+// require marks fields of v's component schema as always present, for
+// bodies that are never trimmed by a field set.
+func (g *generator) require(v interface{}, fields ...string) {
+	g.ref(v)
+	g.schemas[componentName(reflect.TypeOf(v))].Required = fields
+}
+
+func componentName(t reflect.Type) string {
+	name := []rune(t.Name())
+	name[0] = unicode.ToUpper(name[0])
+	return string(name)
+}
+
+func (g *generator) schemaOf(t reflect.Type) *Schema {
+	if values, ok := enums[t]; ok {
+		return &Schema{Type: "string", Enum: values}
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		inner := g.schemaOf(t.Elem())
+		if inner.Ref != "" {
+			return &Schema{AnyOf: []*Schema{inner, {Type: "null"}}}
+		}
+		if typeName, ok := inner.Type.(string); ok {
+			inner.Type = []string{typeName, "null"}
+		}
+		return inner
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		name := componentName(t)
+		if _, ok := g.schemas[name]; !ok {
+			// Registered before the fields so recursive types terminate
+			object := &Schema{Type: "object", Properties: map[string]*Schema{}}
+			g.schemas[name] = object
+			g.addFields(object, t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	// interface{} and anything else accepts any value
+	return &Schema{}
+}
+
+// addFields adds the JSON fields of t to object, including the fields of
+// embedded structs.
+func (g *generator) addFields(object *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" || (field.PkgPath != "" && !field.Anonymous) {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			g.addFields(object, field.Type)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		property := g.schemaOf(field.Type)
+		if kind := field.Type.Kind(); (kind == reflect.Slice || kind == reflect.Map) && field.Type != rawType {
+			// A nil slice or map is encoded as null
+			property.Type = []string{property.Type.(string), "null"}
+		}
+		if readOnly[name] && property.Ref == "" {
+			property.ReadOnly = true
+		}
+		object.Properties[name] = property
+	}
+}
+
+func typeOf(v interface{}) reflect.Type {
+	return reflect.TypeOf(v)
+}
+
+func jsonContent(s *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: s}}
+}
+
+func float(v float64) *float64 {
+	return &v
+}
+
+func count(v int) *int {
+	return &v
+}
@@ -0,0 +1,488 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// maxValidatedBody bounds the JSON bodies the validator reads; larger bodies
+// are left to the handlers.
+const maxValidatedBody = 10 << 20
+
+// Violation is one way a request or response breaks the document. In is
+// path, query, header, content-type, body or status, and Name locates the
+// value, such as a parameter name or filter.ids[2] within a body.
+type Violation struct {
+	In      string `json:"in"`
+	Name    string `json:"name,omitempty"`
+	Message string `json:"message"`
+}
+
+// Validator checks requests against the document before they reach the
+// handlers and answers 400 listing every violation. With ValidateResponses
+// set, it also buffers each response and checks it, replacing one that
+// breaks the document with a 500, so tests catch handlers drifting from the
+// contract.
+type Validator struct {
+	Doc               *Document
+	ValidateResponses bool
+}
+
+func NewValidator(doc *Document) *Validator {
+	return &Validator{Doc: doc}
+}
+
+// Handler is mux middleware; it relies on the matched route's path template
+// to find the operation. Requests to routes the document does not describe
+// pass through unchecked.
+func (v *Validator) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := mux.CurrentRoute(r)
+		if route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		path, err := route.GetPathTemplate()
+		op := v.Doc.Operation(r.Method, path)
+		if err != nil || op == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if violations := v.checkRequest(op, r); len(violations) > 0 {
+			writeViolations(w, http.StatusBadRequest, "Invalid request", violations)
+			return
+		}
+		if !v.ValidateResponses || streams(op) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if violations := v.checkResponse(op, rec); len(violations) > 0 {
+			log.Printf("%s %s answered %d outside the API contract: %+v", r.Method, path, rec.status, violations)
+			writeViolations(w, http.StatusInternalServerError, "Response does not match the API contract", violations)
+			return
+		}
+		for name, values := range rec.header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(rec.status)
+		w.Write(rec.body.Bytes())
+	})
+}
+
+func writeViolations(w http.ResponseWriter, status int, message string, violations []Violation) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Error: message, Details: violations})
+}
+
+// streams reports whether op answers with an event stream, which cannot be
+// buffered.
+func streams(op *Operation) bool {
+	for _, response := range op.Responses {
+		if _, ok := response.Content["text/event-stream"]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *Validator) checkRequest(op *Operation, r *http.Request) []Violation {
+	var violations []Violation
+	vars := mux.Vars(r)
+	for _, param := range op.Parameters {
+		var values []string
+		switch param.In {
+		case "path":
+			values = []string{vars[param.Name]}
+		case "query":
+			values = r.URL.Query()[param.Name]
+		case "header":
+			values = r.Header.Values(param.Name)
+		}
+		// Handlers read an empty parameter as an absent one
+		present := false
+		for _, value := range values {
+			if value == "" {
+				continue
+			}
+			present = true
+			violations = append(violations, v.checkParameter(param, value)...)
+		}
+		if param.Required && !present {
+			violations = append(violations, Violation{In: param.In, Name: param.Name, Message: "is required"})
+		}
+	}
+
+	if op.RequestBody != nil {
+		violations = append(violations, v.checkBody(op.RequestBody, r)...)
+	}
+	return violations
+}
+
+// checkParameter converts a parameter to the type of its schema and checks
+// the result.
+func (v *Validator) checkParameter(param Parameter, raw string) []Violation {
+	var value interface{} = raw
+	switch primaryType(param.Schema) {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return []Violation{{In: param.In, Name: param.Name, Message: "must be " + describeTypes([]string{primaryType(param.Schema)})}}
+		}
+		value = json.Number(raw)
+	case "boolean":
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil || (raw != "true" && raw != "false") {
+			return []Violation{{In: param.In, Name: param.Name, Message: "must be true or false"}}
+		}
+		value = parsed
+	}
+	var violations []Violation
+	v.Doc.check(param.Schema, value, param.Name, true, func(name, message string) {
+		violations = append(violations, Violation{In: param.In, Name: name, Message: message})
+	})
+	return violations
+}
+
+func (v *Validator) checkBody(body *RequestBody, r *http.Request) []Violation {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		if body.Required && r.ContentLength == 0 {
+			return []Violation{{In: "body", Message: "is required"}}
+		}
+		return []Violation{{In: "content-type", Message: "must be one of: " + strings.Join(mediaTypes(body.Content), ", ")}}
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	media, ok := body.Content[mediaType]
+	if err != nil || !ok {
+		return []Violation{{In: "content-type", Message: "must be one of: " + strings.Join(mediaTypes(body.Content), ", ")}}
+	}
+	if mediaType != "application/json" {
+		// Multipart forms are parsed and checked by their handlers
+		return nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxValidatedBody+1))
+	if err != nil {
+		return []Violation{{In: "body", Message: "could not be read"}}
+	}
+	if len(data) > maxValidatedBody {
+		// Pass the whole body on unvalidated, not just the part read
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), r.Body))
+		return nil
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	if len(bytes.TrimSpace(data)) == 0 {
+		if body.Required {
+			return []Violation{{In: "body", Message: "is required"}}
+		}
+		return nil
+	}
+
+	value, err := decodeJSON(data)
+	if err != nil {
+		return []Violation{{In: "body", Message: "is not valid JSON: " + err.Error()}}
+	}
+	var violations []Violation
+	v.Doc.check(media.Schema, value, "", true, func(name, message string) {
+		violations = append(violations, Violation{In: "body", Name: name, Message: message})
+	})
+	return violations
+}
+
+func (v *Validator) checkResponse(op *Operation, rec *bufferedResponse) []Violation {
+	if rec.status == http.StatusNotModified || rec.status >= 500 {
+		return nil
+	}
+	status := strconv.Itoa(rec.status)
+	response, ok := op.Responses[status]
+	if !ok {
+		if rec.status < 400 {
+			return []Violation{{In: "status", Name: status, Message: "is not documented"}}
+		}
+		// Errors from middleware, such as idempotency conflicts, share the
+		// error body of the documented ones
+		response = Response{Content: jsonContent(&Schema{Ref: "#/components/schemas/ErrorResponse"})}
+	}
+
+	mediaType, _, err := mime.ParseMediaType(rec.header.Get("Content-Type"))
+	media, ok := matchMediaType(response.Content, mediaType)
+	if err != nil || !ok {
+		return []Violation{{In: "content-type", Name: status, Message: "must be one of: " + strings.Join(mediaTypes(response.Content), ", ")}}
+	}
+	if mediaType != "application/json" {
+		return nil
+	}
+	value, err := decodeJSON(rec.body.Bytes())
+	if err != nil {
+		return []Violation{{In: "body", Name: status, Message: "is not valid JSON: " + err.Error()}}
+	}
+	var violations []Violation
+	v.Doc.check(media.Schema, value, "", false, func(name, message string) {
+		violations = append(violations, Violation{In: "body", Name: name, Message: message})
+	})
+	return violations
+}
+
+// decodeJSON decodes a single JSON value, keeping numbers as json.Number so
+// integers can be told apart.
+func decodeJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after the top-level value")
+	}
+	return value, nil
+}
+
+// matchMediaType finds the content of mediaType, falling back to a type/*
+// or */* range.
+func matchMediaType(content map[string]MediaType, mediaType string) (MediaType, bool) {
+	if media, ok := content[mediaType]; ok {
+		return media, true
+	}
+	if media, ok := content[strings.SplitN(mediaType, "/", 2)[0]+"/*"]; ok {
+		return media, true
+	}
+	media, ok := content["*/*"]
+	return media, ok
+}
+
+func mediaTypes(content map[string]MediaType) []string {
+	var types []string
+	for mediaType := range content {
+		types = append(types, mediaType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// check validates value against s, reporting each violation with the
+// location of the offending value. In requests, read-only properties are
+// ignored, and null or "" for an optional property counts as leaving it out,
+// since handlers decode bodies into structs that cannot tell them apart.
+func (d *Document) check(s *Schema, value interface{}, at string, request bool, report func(name, message string)) {
+	if s == nil {
+		return
+	}
+	if s.Ref != "" {
+		d.check(d.Resolve(s), value, at, request, report)
+	}
+	if len(s.AnyOf) > 0 {
+		var first []string
+		for i, alternative := range s.AnyOf {
+			var failures []string
+			d.check(alternative, value, at, request, func(name, message string) {
+				failures = append(failures, name+"\x00"+message)
+			})
+			if len(failures) == 0 {
+				first = nil
+				break
+			}
+			if i == 0 {
+				first = failures
+			}
+		}
+		for _, failure := range first {
+			parts := strings.SplitN(failure, "\x00", 2)
+			report(parts[0], parts[1])
+		}
+	}
+
+	if types := schemaTypes(s); len(types) > 0 {
+		matched := false
+		for _, t := range types {
+			matched = matched || hasType(value, t)
+		}
+		if !matched {
+			report(at, "must be "+describeTypes(types))
+			return
+		}
+	}
+
+	switch value := value.(type) {
+	case string:
+		if len(s.Enum) > 0 && !contains(s.Enum, value) {
+			report(at, "must be one of: "+strings.Join(s.Enum, ", "))
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, value); err != nil {
+				report(at, "must be an RFC 3339 date-time")
+			}
+		}
+		if s.Format == "date" {
+			if _, err := time.Parse("2006-01-02", value); err != nil {
+				report(at, "must be a date (YYYY-MM-DD)")
+			}
+		}
+	case json.Number:
+		n, _ := value.Float64()
+		if s.Minimum != nil && n < *s.Minimum {
+			report(at, "must be at least "+formatNumber(*s.Minimum))
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			report(at, "must be at most "+formatNumber(*s.Maximum))
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(value) < *s.MinItems {
+			report(at, "must have at least "+strconv.Itoa(*s.MinItems)+" items")
+		}
+		if s.MaxItems != nil && len(value) > *s.MaxItems {
+			report(at, "must have at most "+strconv.Itoa(*s.MaxItems)+" items")
+		}
+		if s.Items != nil {
+			for i, item := range value {
+				d.check(s.Items, item, at+"["+strconv.Itoa(i)+"]", request, report)
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := value[name]; !ok {
+				report(join(at, name), "is required")
+			}
+		}
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property := value[name]
+			schema, declared := s.Properties[name]
+			if !declared {
+				schema = s.AdditionalProperties
+			}
+			if schema == nil {
+				continue
+			}
+			if request {
+				if schema.ReadOnly {
+					continue
+				}
+				if (property == nil || property == "") && !contains(s.Required, name) {
+					continue
+				}
+			}
+			d.check(schema, property, join(at, name), request, report)
+		}
+	}
+}
+
+func join(at, name string) string {
+	if at == "" {
+		return name
+	}
+	return at + "." + name
+}
+
+func schemaTypes(s *Schema) []string {
+	switch t := s.Type.(type) {
+	case string:
+		return []string{t}
+	case []string:
+		return t
+	}
+	return nil
+}
+
+// primaryType is the non-null type of a schema.
+func primaryType(s *Schema) string {
+	for _, t := range schemaTypes(s) {
+		if t != "null" {
+			return t
+		}
+	}
+	return ""
+}
+
+func hasType(value interface{}, t string) bool {
+	switch value := value.(type) {
+	case nil:
+		return t == "null"
+	case bool:
+		return t == "boolean"
+	case string:
+		return t == "string"
+	case json.Number:
+		if t == "number" {
+			return true
+		}
+		n, err := value.Float64()
+		return t == "integer" && err == nil && n == math.Trunc(n)
+	case []interface{}:
+		return t == "array"
+	case map[string]interface{}:
+		return t == "object"
+	}
+	return false
+}
+
+func describeTypes(types []string) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		switch t {
+		case "null":
+			names[i] = "null"
+		case "integer", "array", "object":
+			names[i] = "an " + t
+		default:
+			names[i] = "a " + t
+		}
+	}
+	return strings.Join(names, " or ")
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferedResponse holds a response until it has been checked.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+	wrote  bool
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if !b.wrote {
+		b.status, b.wrote = status, true
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.wrote = true
+	return b.body.Write(p)
+}
@@ -0,0 +1,175 @@
+package openapi
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// validated routes path through a validator to handler, which records the
+// body it was given.
+func validated(method, path string, handler http.HandlerFunc, validateResponses bool) (*mux.Router, *string) {
+	var received string
+	v := NewValidator(Spec())
+	v.ValidateResponses = validateResponses
+	r := mux.NewRouter()
+	r.Use(v.Handler)
+	r.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+		handler(w, r)
+	}).Methods(method)
+	return r, &received
+}
+
+func ok(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"message":"ok"}`))
+}
+
+func violations(t *testing.T, rr *httptest.ResponseRecorder) map[string]string {
+	var response errorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]string{}
+	for _, v := range response.Details {
+		found[v.In+" "+v.Name] = v.Message
+	}
+	return found
+}
+
+func TestValidateParameters(t *testing.T) {
+	r, _ := validated("GET", "/api/credits/{id}", ok, false)
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/api/credits/abc?include_deleted=yes&fields=", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400, got %d", rr.Code)
+	}
+	found := violations(t, rr)
+	if found["path id"] != "must be an integer" || found["query include_deleted"] != "must be true or false" || len(found) != 2 {
+		t.Errorf("Expected path and query violations, got %v", found)
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/api/credits/0", nil))
+	if found := violations(t, rr); found["path id"] != "must be at least 1" {
+		t.Errorf("Expected a minimum violation, got %v", found)
+	}
+
+	r, _ = validated("GET", "/api/credits", ok, false)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/api/credits?format=xml&unknown=1", nil))
+	if found := violations(t, rr); !strings.HasPrefix(found["query format"], "must be one of: json") || len(found) != 1 {
+		t.Errorf("Expected a format violation, got %v", found)
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/api/credits?format=csv&include_deleted=true", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected a valid request to reach the handler, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestValidateBody(t *testing.T) {
+	r, received := validated("POST", "/api/credits", ok, false)
+	post := func(contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/credits", strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := post("application/json", `{"bank_id": 1, "min_payment": 100, "max_payment": "500", "term_months": 12.5, "credit_type": "BOAT"}`)
+	found := violations(t, rr)
+	expected := map[string]string{
+		"body client_id":   "is required",
+		"body max_payment": "must be a number",
+		"body term_months": "must be an integer",
+		"body credit_type": "must be one of: AUTO, MORTGAGE, COMMERCIAL",
+	}
+	for name, message := range expected {
+		if found[name] != message {
+			t.Errorf("Expected %s %s, got %v", name, message, found)
+		}
+	}
+
+	if found := violations(t, post("text/plain", `{}`)); found["content-type "] != "must be one of: application/json" {
+		t.Errorf("Expected a content type violation, got %v", found)
+	}
+	if found := violations(t, post("application/json", `{"client_id": 1,`)); !strings.HasPrefix(found["body "], "is not valid JSON") {
+		t.Errorf("Expected a JSON violation, got %v", found)
+	}
+
+	// Read-only fields and empty optional values are what a zero-valued
+	// struct encodes to, so they are accepted
+	body := `{"id": 0, "client_id": 1, "bank_id": 1, "min_payment": 100, "max_payment": 500, "term_months": 12,
+		"credit_type": "AUTO", "status": "", "dti_flagged": false, "created_at": "0001-01-01T00:00:00Z"}`
+	rr = post("application/json; charset=utf-8", body)
+	if rr.Code != http.StatusOK || *received != body {
+		t.Errorf("Expected the body to reach the handler, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// A body past the validation limit reaches the handler whole
+	large := `{"client_id": 1, "notes": "` + strings.Repeat("x", maxValidatedBody) + `"}`
+	rr = post("application/json", large)
+	if rr.Code != http.StatusOK || *received != large {
+		t.Errorf("Expected an oversized body to reach the handler complete, got %d and %d bytes", rr.Code, len(*received))
+	}
+
+	r, _ = validated("POST", "/api/clients:batch", ok, false)
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/clients:batch", strings.NewReader(`[]`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(rr, req)
+	if found := violations(t, rr); found["body "] != "must have at least 1 items" {
+		t.Errorf("Expected a batch size violation, got %v", found)
+	}
+}
+
+func TestValidateResponses(t *testing.T) {
+	respond := func(status int, body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			w.Write([]byte(body))
+		}
+	}
+	get := func(handler http.HandlerFunc) *httptest.ResponseRecorder {
+		r, _ := validated("GET", "/api/credits/{id}", handler, true)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", "/api/credits/1", nil))
+		return rr
+	}
+
+	rr := get(respond(http.StatusOK, `{"id": 1, "status": "APPROVED", "debt_to_income": null, "client": {"id": 2}}`))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "APPROVED") {
+		t.Errorf("Expected a valid response to pass, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = get(respond(http.StatusOK, `{"id": "1", "status": "DONE"}`))
+	found := violations(t, rr)
+	if rr.Code != http.StatusInternalServerError || found["body id"] != "must be an integer" ||
+		found["body status"] != "must be one of: PENDING, APPROVED, REJECTED" {
+		t.Errorf("Expected the drifted response to be replaced, got %d: %v", rr.Code, found)
+	}
+
+	if found := violations(t, get(respond(http.StatusCreated, `{}`))); found["status 201"] != "is not documented" {
+		t.Errorf("Expected an undocumented status, got %v", found)
+	}
+	if found := violations(t, get(respond(http.StatusConflict, `{"message": "taken"}`))); found["body error"] != "is required" {
+		t.Errorf("Expected errors to be checked against the error body, got %v", found)
+	}
+	if rr := get(respond(http.StatusNotFound, `{"error": "Credit not found"}`)); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected a documented error to pass, got %d", rr.Code)
+	}
+}
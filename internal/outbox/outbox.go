@@ -0,0 +1,74 @@
+package outbox
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Domain event types written to the outbox.
+const (
+	ClientCreated  = "ClientCreated"
+	ClientUpdated  = "ClientUpdated"
+	ClientDeleted  = "ClientDeleted"
+	ClientRestored = "ClientRestored"
+	ClientErased   = "ClientErased"
+	ClientMerged   = "ClientMerged"
+
+	BankCreated  = "BankCreated"
+	BankUpdated  = "BankUpdated"
+	BankDeleted  = "BankDeleted"
+	BankRestored = "BankRestored"
+
+	CreditCreated       = "CreditCreated"
+	CreditUpdated       = "CreditUpdated"
+	CreditStatusChanged = "CreditStatusChanged"
+	CreditDeleted       = "CreditDeleted"
+	CreditRestored      = "CreditRestored"
+
+	DocumentUploaded        = "DocumentUploaded"
+	DocumentStatusChanged   = "DocumentStatusChanged"
+	DocumentDeleted         = "DocumentDeleted"
+	FinancialProfileUpdated = "FinancialProfileUpdated"
+)
+
+// EventTypes lists every domain event type.
+var EventTypes = []string{
+	ClientCreated, ClientUpdated, ClientDeleted, ClientRestored, ClientErased, ClientMerged,
+	BankCreated, BankUpdated, BankDeleted, BankRestored,
+	CreditCreated, CreditUpdated, CreditStatusChanged, CreditDeleted, CreditRestored,
+	DocumentUploaded, DocumentStatusChanged, DocumentDeleted, FinancialProfileUpdated,
+}
+
+// Message is a domain event as stored in the outbox and handed to publishers.
+// ID is stable across redeliveries so consumers can discard duplicates.
+type Message struct {
+	ID int64 `json:"id"`
+	// Sequence numbers events in commit order. It is only loaded for the
+	// event stream.
+	Sequence      int64           `json:"sequence,omitempty"`
+	Type          string          `json:"type"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   int             `json:"aggregate_id"`
+	Payload       json.RawMessage `json:"payload"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+}
+
+// Execer is satisfied by *sql.DB and *sql.Tx. Callers pass the transaction of
+// the change so the event is stored if and only if the change commits.
+type Execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// Enqueue stores an event for the relay to publish.
+func Enqueue(ex Execer, eventType, aggregateType string, aggregateID int, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = ex.Exec(`
+		INSERT INTO outbox (event_type, aggregate_type, aggregate_id, payload)
+		VALUES ($1, $2, $3, $4)
+	`, eventType, aggregateType, aggregateID, string(data))
+	return err
+}
@@ -0,0 +1,41 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	cases := map[int]time.Duration{
+		1:  time.Second,
+		2:  2 * time.Second,
+		4:  8 * time.Second,
+		20: MaxRetryDelay,
+	}
+	for attempts, expected := range cases {
+		if delay := RetryDelay(attempts); delay != expected {
+			t.Errorf("RetryDelay(%d) = %v, expected %v", attempts, delay, expected)
+		}
+	}
+}
+
+func TestWriterPublisher(t *testing.T) {
+	var buf bytes.Buffer
+	publisher := NewWriterPublisher(&buf)
+
+	msg := Message{ID: 7, Type: CreditCreated, AggregateType: "credit", AggregateID: 3, Payload: json.RawMessage(`{"id":3}`)}
+	if err := publisher.Publish(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded Message
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.ID != 7 || decoded.Type != CreditCreated || string(decoded.Payload) != `{"id":3}` {
+		t.Errorf("Unexpected message %+v", decoded)
+	}
+}
@@ -0,0 +1,61 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// Publisher delivers messages to the outside world. Publish may be called
+// more than once for the same message.
+type Publisher interface {
+	Publish(ctx context.Context, msg Message) error
+}
+
+// WriterPublisher writes each message as a JSON line to an io.Writer.
+type WriterPublisher struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterPublisher publishes to w, for example os.Stdout.
+func NewWriterPublisher(w io.Writer) *WriterPublisher {
+	return &WriterPublisher{w: w}
+}
+
+// NewFilePublisher appends messages to the file at path, creating it if
+// needed.
+func NewFilePublisher(path string) (*WriterPublisher, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriterPublisher(file), nil
+}
+
+func (p *WriterPublisher) Publish(ctx context.Context, msg Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err = p.w.Write(append(data, '\n'))
+	return err
+}
+
+// MultiPublisher publishes each message to every publisher in order and
+// stops at the first error. The whole message is retried, so publishers
+// earlier in the list may see it again.
+type MultiPublisher []Publisher
+
+func (m MultiPublisher) Publish(ctx context.Context, msg Message) error {
+	for _, publisher := range m {
+		if err := publisher.Publish(ctx, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
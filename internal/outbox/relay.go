@@ -0,0 +1,183 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sort"
+	"time"
+)
+
+// Backoff bounds for failed deliveries.
+const (
+	MinRetryDelay = time.Second
+	MaxRetryDelay = 5 * time.Minute
+)
+
+// RetryDelay returns how long to wait before the next delivery of a message
+// that has failed attempts times, doubling from MinRetryDelay up to
+// MaxRetryDelay.
+func RetryDelay(attempts int) time.Duration {
+	delay := MinRetryDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= MaxRetryDelay {
+			return MaxRetryDelay
+		}
+	}
+	return delay
+}
+
+// Relay moves messages from the outbox table to a Publisher. A message is
+// marked published only after Publish succeeds, so delivery is at-least-once.
+// Several relays may run against the same database.
+type Relay struct {
+	DB           *sql.DB
+	Publisher    Publisher
+	BatchSize    int
+	PollInterval time.Duration
+	// Lease is how long a claimed batch is kept from other relays while it is
+	// published. A relay stopping mid-batch leaves the rest to be retried once
+	// the lease has expired.
+	Lease time.Duration
+	// Retention is how long published messages are kept before
+	// DeletePublished removes them.
+	Retention time.Duration
+}
+
+// NewRelay returns a relay with a batch size of 100 polling every second,
+// leasing batches for a minute and keeping published messages for a week.
+func NewRelay(db *sql.DB, publisher Publisher) *Relay {
+	return &Relay{DB: db, Publisher: publisher, BatchSize: 100, PollInterval: time.Second,
+		Lease: time.Minute, Retention: 7 * 24 * time.Hour}
+}
+
+// Start runs the relay in a goroutine until ctx is cancelled. The returned
+// channel is closed once it has stopped.
+func (r *Relay) Start(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(r.PollInterval)
+		defer ticker.Stop()
+		for {
+			// Drain full batches before waiting for the next tick
+			for {
+				n, err := r.ProcessBatch(ctx)
+				if err != nil {
+					log.Printf("Outbox relay failed: %v", err)
+				}
+				if err != nil || n < r.BatchSize {
+					break
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
+}
+
+// ProcessBatch publishes up to BatchSize due messages and returns how many
+// it attempted. The batch is claimed by pushing its next attempt back by
+// Lease, so the messages are published without holding a transaction or row
+// locks.
+func (r *Relay) ProcessBatch(ctx context.Context) (int, error) {
+	// SKIP LOCKED lets concurrent relays claim different messages
+	rows, err := r.DB.QueryContext(ctx, `
+		UPDATE outbox SET next_attempt_at = CURRENT_TIMESTAMP + $2 * INTERVAL '1 millisecond'
+		WHERE id IN (
+			SELECT id FROM outbox
+			WHERE published_at IS NULL AND next_attempt_at <= CURRENT_TIMESTAMP
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, event_type, aggregate_type, aggregate_id, payload, created_at, attempts
+	`, r.BatchSize, r.Lease.Milliseconds())
+	if err != nil {
+		return 0, err
+	}
+	type pending struct {
+		msg      Message
+		attempts int
+	}
+	batch := []pending{}
+	for rows.Next() {
+		var p pending
+		var payload []byte
+		if err := rows.Scan(&p.msg.ID, &p.msg.Type, &p.msg.AggregateType, &p.msg.AggregateID,
+			&payload, &p.msg.OccurredAt, &p.attempts); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		p.msg.Payload = payload
+		batch = append(batch, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	sort.Slice(batch, func(i, j int) bool { return batch[i].msg.ID < batch[j].msg.ID })
+
+	// Outcomes are recorded even when ctx ends after a publish, so a
+	// delivered message is not delivered again
+	record := context.WithoutCancel(ctx)
+	for _, p := range batch {
+		if err := r.Publisher.Publish(ctx, p.msg); err != nil {
+			attempts := p.attempts + 1
+			_, err = r.DB.ExecContext(record, `
+				UPDATE outbox
+				SET attempts = $1, last_error = $2, next_attempt_at = CURRENT_TIMESTAMP + $3 * INTERVAL '1 millisecond'
+				WHERE id = $4
+			`, attempts, err.Error(), RetryDelay(attempts).Milliseconds(), p.msg.ID)
+		} else {
+			_, err = r.DB.ExecContext(record, `
+				UPDATE outbox SET published_at = CURRENT_TIMESTAMP, attempts = attempts + 1, last_error = NULL
+				WHERE id = $1
+			`, p.msg.ID)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(batch), nil
+}
+
+// DeletePublished deletes messages published more than Retention ago and
+// returns how many were removed.
+func (r *Relay) DeletePublished(ctx context.Context) (int64, error) {
+	result, err := r.DB.ExecContext(ctx, `
+		DELETE FROM outbox WHERE published_at < CURRENT_TIMESTAMP - $1 * INTERVAL '1 millisecond'
+	`, r.Retention.Milliseconds())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// StartCleanup runs DeletePublished every interval until ctx is cancelled.
+// The returned channel is closed once it has stopped.
+func (r *Relay) StartCleanup(ctx context.Context, interval time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if _, err := r.DeletePublished(ctx); err != nil {
+				log.Printf("Outbox cleanup failed: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
+}
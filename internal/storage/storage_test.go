@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalStoreRoundTrip(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "clients/1/doc.pdf", strings.NewReader("content")); err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := store.Get(ctx, "clients/1/doc.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "content" {
+		t.Errorf("Expected 'content', got %q", data)
+	}
+
+	if err := store.Delete(ctx, "clients/1/doc.pdf"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, "clients/1/doc.pdf"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestLocalStoreRejectsEscapingKeys(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Put(context.Background(), "../outside", strings.NewReader("x")); err == nil {
+		t.Error("Expected an error for a key escaping the root directory")
+	}
+}
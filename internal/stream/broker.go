@@ -0,0 +1,143 @@
+package stream
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"backend/internal/outbox"
+
+	"github.com/lib/pq"
+)
+
+// Channel is the Postgres notification channel carrying new outbox ids.
+const Channel = "outbox_events"
+
+// subscriberBuffer is how many events a subscriber may fall behind before it
+// is dropped. Dropped subscribers are expected to reconnect and resume.
+const subscriberBuffer = 256
+
+// Loader loads the outbox message with id. It returns false for a message
+// that is not streamed.
+type Loader func(ctx context.Context, id int64) (outbox.Message, bool, error)
+
+// Broker fans outbox notifications out to in-process subscribers. Every app
+// instance runs its own broker, so a change made through any instance reaches
+// the subscribers of all of them.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[chan outbox.Message]struct{}
+	closed      bool
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: map[chan outbox.Message]struct{}{}}
+}
+
+// Subscribe returns a channel receiving every new outbox event. The channel
+// is closed when cancel is called, the subscriber falls too far behind or the
+// broker is closed.
+func (b *Broker) Subscribe() (<-chan outbox.Message, func()) {
+	ch := make(chan outbox.Message, subscriberBuffer)
+	b.mu.Lock()
+	if b.closed {
+		close(ch)
+	} else {
+		b.subscribers[ch] = struct{}{}
+	}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Publish hands msg to every subscriber without blocking.
+func (b *Broker) Publish(msg outbox.Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- msg:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Close ends every subscription and refuses new ones, so that event stream
+// clients reconnect to another instance while this one shuts down.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	b.dropAll()
+}
+
+// dropAll ends every subscription, so the subscribers resume from their last
+// event.
+func (b *Broker) dropAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// Listen subscribes to Channel on a dedicated connection until ctx is
+// cancelled. Each notified event is loaded once with load and published to
+// every subscriber.
+func (b *Broker) Listen(ctx context.Context, databaseURL string, load Loader) error {
+	listener := pq.NewListener(databaseURL, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Event stream listener: %v", err)
+		}
+	})
+	if err := listener.Listen(Channel); err != nil {
+		listener.Close()
+		return err
+	}
+
+	go func() {
+		defer listener.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case n := <-listener.Notify:
+				// A nil notification follows a reconnect. Events sent while
+				// disconnected were lost, so subscribers resume from the
+				// outbox with their last event id
+				if n == nil {
+					b.dropAll()
+					continue
+				}
+				id, err := strconv.ParseInt(n.Extra, 10, 64)
+				if err != nil {
+					continue
+				}
+				msg, ok, err := load(ctx, id)
+				if err != nil {
+					// Subscribers would miss the event, so they resume
+					// from the outbox instead
+					log.Printf("Event stream listener: loading event %d: %v", id, err)
+					b.dropAll()
+				} else if ok {
+					b.Publish(msg)
+				}
+			case <-time.After(90 * time.Second):
+				go listener.Ping()
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,67 @@
+package stream
+
+import (
+	"testing"
+
+	"backend/internal/outbox"
+)
+
+func TestBrokerFansOut(t *testing.T) {
+	b := NewBroker()
+	first, cancelFirst := b.Subscribe()
+	defer cancelFirst()
+	second, cancelSecond := b.Subscribe()
+
+	b.Publish(outbox.Message{ID: 1})
+	if msg := <-first; msg.ID != 1 {
+		t.Errorf("Expected 1, got %d", msg.ID)
+	}
+	if msg := <-second; msg.ID != 1 {
+		t.Errorf("Expected 1, got %d", msg.ID)
+	}
+
+	cancelSecond()
+	cancelSecond()
+	b.Publish(outbox.Message{ID: 2})
+	if _, ok := <-second; ok {
+		t.Error("Expected a cancelled subscription to be closed")
+	}
+	if msg := <-first; msg.ID != 2 {
+		t.Errorf("Expected 2, got %d", msg.ID)
+	}
+}
+
+func TestBrokerDropsSlowSubscribers(t *testing.T) {
+	b := NewBroker()
+	ch, cancel := b.Subscribe()
+	defer cancel()
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		b.Publish(outbox.Message{ID: int64(i)})
+	}
+
+	count := 0
+	for range ch {
+		count++
+	}
+	if count != subscriberBuffer {
+		t.Errorf("Expected %d buffered events before the channel closed, got %d", subscriberBuffer, count)
+	}
+}
+
+func TestBrokerClose(t *testing.T) {
+	b := NewBroker()
+	before, cancel := b.Subscribe()
+
+	b.Close()
+	cancel()
+	if _, ok := <-before; ok {
+		t.Error("Expected subscriptions to be closed with the broker")
+	}
+	after, cancel := b.Subscribe()
+	defer cancel()
+	b.Publish(outbox.Message{ID: 1})
+	if _, ok := <-after; ok {
+		t.Error("Expected a subscription to a closed broker to be closed")
+	}
+}
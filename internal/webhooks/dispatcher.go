@@ -0,0 +1,243 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Retry bounds for failed deliveries.
+const (
+	MinRetryDelay = 30 * time.Second
+	MaxRetryDelay = time.Hour
+)
+
+// RetryDelay returns how long to wait after the given number of failed
+// attempts, doubling from MinRetryDelay up to MaxRetryDelay.
+func RetryDelay(attempts int) time.Duration {
+	delay := MinRetryDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= MaxRetryDelay {
+			return MaxRetryDelay
+		}
+	}
+	return delay
+}
+
+// Dispatcher sends queued webhook deliveries. A delivery is retried until it
+// succeeds or reaches MaxAttempts; a subscription is disabled after
+// DisableAfter consecutive failed attempts.
+type Dispatcher struct {
+	DB           *sql.DB
+	Client       *http.Client
+	BatchSize    int
+	PollInterval time.Duration
+	MaxAttempts  int
+	DisableAfter int
+	// Lease is how long a claimed delivery stays hidden from other
+	// dispatchers while it is being sent.
+	Lease time.Duration
+}
+
+// NewDispatcher returns a dispatcher with a 10 second request timeout.
+func NewDispatcher(db *sql.DB) *Dispatcher {
+	return &Dispatcher{
+		DB:           db,
+		Client:       &http.Client{Timeout: 10 * time.Second},
+		BatchSize:    20,
+		PollInterval: time.Second,
+		MaxAttempts:  8,
+		DisableAfter: 15,
+		Lease:        time.Minute,
+	}
+}
+
+// Start runs the dispatcher in a goroutine until ctx is cancelled. The
+// returned channel is closed once it has stopped.
+func (d *Dispatcher) Start(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(d.PollInterval)
+		defer ticker.Stop()
+		for {
+			for {
+				n, err := d.ProcessBatch(ctx)
+				if err != nil {
+					log.Printf("Webhook dispatch failed: %v", err)
+				}
+				if err != nil || n < d.BatchSize {
+					break
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
+}
+
+type job struct {
+	id        int64
+	eventType string
+	payload   []byte
+	attempts  int
+	url       string
+	secret    string
+}
+
+// ProcessBatch claims and sends up to BatchSize due deliveries of active
+// subscriptions and returns how many it sent.
+func (d *Dispatcher) ProcessBatch(ctx context.Context) (int, error) {
+	// Claiming pushes next_attempt_at past the lease so requests are made
+	// without holding row locks
+	rows, err := d.DB.QueryContext(ctx, `
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = CURRENT_TIMESTAMP + $2 * INTERVAL '1 millisecond'
+		FROM webhook_subscriptions s
+		WHERE s.id = d.subscription_id AND d.id IN (
+			SELECT wd.id FROM webhook_deliveries wd
+			JOIN webhook_subscriptions ws ON ws.id = wd.subscription_id
+			WHERE wd.status = 'PENDING' AND wd.next_attempt_at <= CURRENT_TIMESTAMP AND ws.active
+			ORDER BY wd.id
+			LIMIT $1
+			FOR UPDATE OF wd SKIP LOCKED
+		)
+		RETURNING d.id, d.event_type, d.payload, d.attempts, s.url, s.secret
+	`, d.BatchSize, d.Lease.Milliseconds())
+	if err != nil {
+		return 0, err
+	}
+	jobs := []job{}
+	for rows.Next() {
+		var j job
+		if err := rows.Scan(&j.id, &j.eventType, &j.payload, &j.attempts, &j.url, &j.secret); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		jobs = append(jobs, j)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// Attempts are recorded even when ctx ends after a send, so a delivery
+	// that went out is not sent again. Deliveries not sent by then, or cut
+	// off by ctx, are retried once their claim expires.
+	record := context.WithoutCancel(ctx)
+	sent := 0
+	for _, j := range jobs {
+		if ctx.Err() != nil {
+			break
+		}
+		start := time.Now()
+		statusCode, sendErr := d.send(ctx, j)
+		if sendErr != nil && ctx.Err() != nil {
+			break
+		}
+		if err := d.record(record, j, statusCode, sendErr, time.Since(start)); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// send POSTs the delivery and returns the response status. Any status outside
+// 2xx is reported as an error.
+func (d *Dispatcher) send(ctx context.Context, j job) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, j.url, bytes.NewReader(j.payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, j.eventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(j.id, 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(j.secret, timestamp, j.payload))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// record logs the attempt and updates the delivery and its subscription.
+func (d *Dispatcher) record(ctx context.Context, j job, statusCode int, sendErr error, duration time.Duration) error {
+	tx, err := d.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	errorText := sql.NullString{}
+	if sendErr != nil {
+		errorText = sql.NullString{String: sendErr.Error(), Valid: true}
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO webhook_delivery_attempts (delivery_id, status_code, error, duration_ms)
+		VALUES ($1, $2, $3, $4)
+	`, j.id, sql.NullInt64{Int64: int64(statusCode), Valid: statusCode != 0}, errorText, duration.Milliseconds())
+	if err != nil {
+		return err
+	}
+
+	attempts := j.attempts + 1
+	if sendErr == nil {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE webhook_deliveries
+			SET status = 'SUCCEEDED', attempts = $1, last_error = NULL, delivered_at = CURRENT_TIMESTAMP
+			WHERE id = $2
+		`, attempts, j.id)
+		if err == nil {
+			_, err = tx.ExecContext(ctx, `
+				UPDATE webhook_subscriptions SET consecutive_failures = 0
+				WHERE id = (SELECT subscription_id FROM webhook_deliveries WHERE id = $1)
+			`, j.id)
+		}
+	} else {
+		status := "PENDING"
+		if attempts >= d.MaxAttempts {
+			status = "FAILED"
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE webhook_deliveries
+			SET status = $1, attempts = $2, last_error = $3,
+			    next_attempt_at = CURRENT_TIMESTAMP + $4 * INTERVAL '1 millisecond'
+			WHERE id = $5
+		`, status, attempts, sendErr.Error(), RetryDelay(attempts).Milliseconds(), j.id)
+		if err == nil {
+			_, err = tx.ExecContext(ctx, `
+				UPDATE webhook_subscriptions
+				SET consecutive_failures = consecutive_failures + 1,
+				    active = active AND consecutive_failures + 1 < $1,
+				    disabled_at = CASE WHEN active AND consecutive_failures + 1 >= $1 THEN CURRENT_TIMESTAMP ELSE disabled_at END
+				WHERE id = (SELECT subscription_id FROM webhook_deliveries WHERE id = $2)
+			`, d.DisableAfter, j.id)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
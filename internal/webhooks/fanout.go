@@ -0,0 +1,91 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"backend/internal/outbox"
+	"github.com/lib/pq"
+)
+
+// FanOut is an outbox.Publisher that queues a delivery for every active
+// subscription matching the message. Queuing is idempotent per subscription
+// and event, so redelivery by the relay does not duplicate webhooks.
+type FanOut struct {
+	DB *sql.DB
+}
+
+func (f *FanOut) Publish(ctx context.Context, msg outbox.Message) error {
+	rows, err := f.DB.QueryContext(ctx, "SELECT id, event_types, bank_id FROM webhook_subscriptions WHERE active")
+	if err != nil {
+		return err
+	}
+	matching := []int{}
+	for rows.Next() {
+		var id int
+		var eventTypes []string
+		var bankID sql.NullInt64
+		if err := rows.Scan(&id, pq.Array(&eventTypes), &bankID); err != nil {
+			rows.Close()
+			return err
+		}
+		var bank *int
+		if bankID.Valid {
+			value := int(bankID.Int64)
+			bank = &value
+		}
+		if Matches(eventTypes, bank, msg) {
+			matching = append(matching, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(matching) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = f.DB.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload)
+		SELECT unnest($1::int[]), $2, $3, $4
+		ON CONFLICT (subscription_id, event_id) DO NOTHING
+	`, pq.Array(matching), msg.ID, msg.Type, string(body))
+	return err
+}
+
+// Matches reports whether a subscription with the given filters receives msg.
+// A bank filter matches events about that bank and about credits granted by it.
+func Matches(eventTypes []string, bankID *int, msg outbox.Message) bool {
+	if len(eventTypes) > 0 {
+		found := false
+		for _, eventType := range eventTypes {
+			if eventType == msg.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if bankID == nil {
+		return true
+	}
+	switch msg.AggregateType {
+	case "bank":
+		return msg.AggregateID == *bankID
+	case "credit":
+		var credit struct {
+			BankID int `json:"bank_id"`
+		}
+		return json.Unmarshal(msg.Payload, &credit) == nil && credit.BankID == *bankID
+	}
+	return false
+}
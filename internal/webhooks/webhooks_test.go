@@ -0,0 +1,110 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"backend/internal/outbox"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"id":1}`)
+	signature := Sign("secret", now.Unix(), body)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	if err := Verify("secret", timestamp, body, signature, 5*time.Minute, now); err != nil {
+		t.Errorf("Expected a valid signature, got %v", err)
+	}
+	if err := Verify("other", timestamp, body, signature, 5*time.Minute, now); err != ErrInvalidSignature {
+		t.Errorf("Expected ErrInvalidSignature for a wrong secret, got %v", err)
+	}
+	if err := Verify("secret", timestamp, []byte(`{"id":2}`), signature, 5*time.Minute, now); err != ErrInvalidSignature {
+		t.Errorf("Expected ErrInvalidSignature for a modified body, got %v", err)
+	}
+	if err := Verify("secret", timestamp, body, signature, 5*time.Minute, now.Add(time.Hour)); err != ErrStaleTimestamp {
+		t.Errorf("Expected ErrStaleTimestamp for an old delivery, got %v", err)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	bankID := 7
+	credit := outbox.Message{Type: outbox.CreditStatusChanged, AggregateType: "credit", AggregateID: 1,
+		Payload: json.RawMessage(`{"id":1,"bank_id":7}`)}
+	client := outbox.Message{Type: outbox.ClientCreated, AggregateType: "client", AggregateID: 1,
+		Payload: json.RawMessage(`{"id":1}`)}
+
+	if !Matches(nil, nil, client) {
+		t.Error("Expected an unfiltered subscription to match every event")
+	}
+	if !Matches([]string{outbox.CreditStatusChanged}, &bankID, credit) {
+		t.Error("Expected the bank's credit status change to match")
+	}
+	if Matches([]string{outbox.CreditCreated}, nil, credit) {
+		t.Error("Expected an event type filter to exclude other events")
+	}
+	other := 8
+	if Matches(nil, &other, credit) {
+		t.Error("Expected a bank filter to exclude other banks' credits")
+	}
+	if Matches(nil, &bankID, client) {
+		t.Error("Expected a bank filter to exclude client events")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	if delay := RetryDelay(1); delay != MinRetryDelay {
+		t.Errorf("Expected %v, got %v", MinRetryDelay, delay)
+	}
+	if delay := RetryDelay(3); delay != 4*MinRetryDelay {
+		t.Errorf("Expected %v, got %v", 4*MinRetryDelay, delay)
+	}
+	if delay := RetryDelay(30); delay != MaxRetryDelay {
+		t.Errorf("Expected %v, got %v", MaxRetryDelay, delay)
+	}
+}
+
+func TestSendSignsDeliveries(t *testing.T) {
+	var received *http.Request
+	var receivedBody []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		receivedBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	d := NewDispatcher(nil)
+	j := job{id: 42, eventType: outbox.CreditCreated, payload: []byte(`{"id":1}`), url: receiver.URL, secret: "secret"}
+	if status, err := d.send(context.Background(), j); err != nil || status != http.StatusNoContent {
+		t.Fatalf("Expected 204 without error, got %d, %v", status, err)
+	}
+
+	if received.Header.Get(EventHeader) != outbox.CreditCreated || received.Header.Get(DeliveryHeader) != "42" {
+		t.Errorf("Unexpected headers %v", received.Header)
+	}
+	err := Verify("secret", received.Header.Get(TimestampHeader), receivedBody,
+		received.Header.Get(SignatureHeader), time.Minute, time.Now())
+	if err != nil {
+		t.Errorf("Expected a verifiable signature, got %v", err)
+	}
+}
+
+func TestSendReportsErrorStatus(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer receiver.Close()
+
+	d := NewDispatcher(nil)
+	status, err := d.send(context.Background(), job{id: 1, payload: []byte(`{}`), url: receiver.URL})
+	if err == nil || status != http.StatusBadGateway {
+		t.Errorf("Expected a 502 error, got %d, %v", status, err)
+	}
+}
@@ -1,15 +1,23 @@
 package main
 
 import (
+	"context"
 	"log"
-	"net/http"
+	"net"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"backend/internal/database"
+	"backend/internal/grpcserver"
 	"backend/internal/handlers"
 	"backend/internal/logger"
-	"backend/internal/middleware"
-	"github.com/gorilla/mux"
+	"backend/internal/outbox"
+	"backend/internal/webhooks"
+	"backend/pkg/server"
 	"github.com/joho/godotenv"
 )
 
@@ -20,7 +28,6 @@ func main() {
 	if err := logger.Initialize(); err != nil {
 		log.Fatal("Failed to initialize logger:", err)
 	}
-	defer logger.Close()
 
 	databaseURL := os.Getenv("DATABASE_URL")
 	if databaseURL == "" {
@@ -30,49 +37,219 @@ func main() {
 	if err := database.Connect(databaseURL); err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
-	defer database.Close()
 
 	if err := database.InitSchema(); err != nil {
 		log.Fatal("Failed to initialize schema:", err)
 	}
 
-	r := mux.NewRouter()
-	
-	// Add logging middleware to all routes
-	r.Use(middleware.LoggingMiddleware)
-	
-	r.HandleFunc("/health", handlers.HealthCheck).Methods("GET")
-	r.HandleFunc("/api/items", handlers.GetItems).Methods("GET")
-	r.HandleFunc("/api/items", handlers.CreateItem).Methods("POST")
-	r.HandleFunc("/api/items/{id}", handlers.GetItem).Methods("GET")
-	
-	// Client routes
-	r.HandleFunc("/api/clients", handlers.CreateClient).Methods("POST")
-	r.HandleFunc("/api/clients/{id}", handlers.GetClient).Methods("GET")
-	r.HandleFunc("/api/clients/{id}", handlers.UpdateClient).Methods("PUT")
-	r.HandleFunc("/api/clients/{id}", handlers.DeleteClient).Methods("DELETE")
-
-	// Bank routes
-	r.HandleFunc("/api/banks", handlers.GetBanks).Methods("GET")
-	r.HandleFunc("/api/banks", handlers.CreateBank).Methods("POST")
-	r.HandleFunc("/api/banks/{id}", handlers.GetBank).Methods("GET")
-	r.HandleFunc("/api/banks/{id}", handlers.UpdateBank).Methods("PUT")
-	r.HandleFunc("/api/banks/{id}", handlers.DeleteBank).Methods("DELETE")
-
-	// Credit routes
-	r.HandleFunc("/api/credits", handlers.GetCredits).Methods("GET")
-	r.HandleFunc("/api/credits", handlers.CreateCredit).Methods("POST")
-	r.HandleFunc("/api/credits/{id}", handlers.GetCredit).Methods("GET")
-	r.HandleFunc("/api/credits/{id}", handlers.UpdateCredit).Methods("PUT")
-	r.HandleFunc("/api/credits/{id}", handlers.DeleteCredit).Methods("DELETE")
-	r.HandleFunc("/api/clients/{clientId}/credits", handlers.GetCreditsByClient).Methods("GET")
-	r.HandleFunc("/api/banks/{bankId}/credits", handlers.GetCreditsByBank).Methods("GET")
+	// Imports still pending or running were cut short by a crash or restart
+	if failed, err := handlers.FailInterruptedImports(); err != nil {
+		log.Fatal("Failed to mark interrupted imports as failed:", err)
+	} else if failed > 0 {
+		log.Printf("Marked %d interrupted imports as failed\n", failed)
+	}
+
+	cfg := server.Config{
+		DB:                 database.DB,
+		DatabaseURL:        databaseURL,
+		DocumentStorageDir: os.Getenv("DOCUMENT_STORAGE_DIR"),
+		PIIKeyFile:         os.Getenv("PII_KEY_FILE"),
+		DTIReject:          os.Getenv("DTI_MODE") == "reject",
+		RequireIfMatch:     os.Getenv("REQUIRE_IF_MATCH") == "true",
+	}
+
+	// Callers, named in X-Actor, that may see soft-deleted rows, as a
+	// comma-separated list
+	for _, actor := range strings.Split(os.Getenv("ADMIN_ACTORS"), ",") {
+		if actor = strings.TrimSpace(actor); actor != "" {
+			cfg.AdminActors = append(cfg.AdminActors, actor)
+		}
+	}
+
+	// Debt-to-income policy for new credits
+	if threshold := os.Getenv("DTI_THRESHOLD"); threshold != "" {
+		value, err := strconv.ParseFloat(threshold, 64)
+		if err != nil || value <= 0 {
+			log.Fatal("DTI_THRESHOLD must be a positive number")
+		}
+		cfg.DTIThreshold = value
+	}
+
+	// Once deprecated, v1 routes with a v2 successor announce since when and,
+	// by default six months later, when they go away, as dates like 2026-10-18
+	if value := os.Getenv("API_V1_DEPRECATED_AT"); value != "" {
+		date, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			log.Fatal("API_V1_DEPRECATED_AT must be a date such as 2026-10-18")
+		}
+		cfg.APIV1DeprecatedAt = date
+		cfg.APIV1SunsetAt = date.AddDate(0, 6, 0)
+	}
+	if value := os.Getenv("API_V1_SUNSET"); value != "" {
+		if cfg.APIV1DeprecatedAt.IsZero() {
+			log.Fatal("API_V1_SUNSET requires API_V1_DEPRECATED_AT")
+		}
+		date, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			log.Fatal("API_V1_SUNSET must be a date such as 2027-04-18")
+		}
+		cfg.APIV1SunsetAt = date
+	}
+
+	// Connection timeouts of the HTTP server, and how long a shutdown waits
+	// for requests in flight, as durations such as 30s
+	cfg.ShutdownTimeout = server.DefaultShutdownTimeout
+	for name, timeout := range map[string]*time.Duration{
+		"HTTP_READ_HEADER_TIMEOUT": &cfg.ReadHeaderTimeout,
+		"HTTP_READ_TIMEOUT":        &cfg.ReadTimeout,
+		"HTTP_WRITE_TIMEOUT":       &cfg.WriteTimeout,
+		"HTTP_IDLE_TIMEOUT":        &cfg.IdleTimeout,
+		"SHUTDOWN_TIMEOUT":         &cfg.ShutdownTimeout,
+	} {
+		if value := os.Getenv(name); value != "" {
+			duration, err := time.ParseDuration(value)
+			if err != nil || duration <= 0 {
+				log.Fatal(name + " must be a positive duration such as 30s")
+			}
+			*timeout = duration
+		}
+	}
+
+	// SIGTERM or SIGINT shuts the servers down gracefully
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Background workers run until the servers have drained, and report on
+	// these channels once they have stopped
+	workers, stopWorkers := context.WithCancel(context.Background())
+	var workersDone []<-chan struct{}
+
+	// Routes, middleware and the dependencies of the handlers: document
+	// storage, PII encryption and the live event stream fed by Postgres
+	// notifications on new outbox rows
+	srv, err := server.New(workers, cfg)
+	if err != nil {
+		log.Fatal("Failed to set up the server:", err)
+	}
+	workersDone = append(workersDone, srv.Idempotency.StartCleanup(workers, time.Hour))
+
+	// Imports and re-encryption runs started through the API are stopped
+	// and waited for like the other workers
+	workersDone = append(workersDone, handlers.TrackJobs(workers))
+
+	// Existing client PII is encrypted or re-wrapped in the background
+	if cfg.PIIKeyFile != "" {
+		workersDone = append(workersDone, handlers.StartClientReencryption(workers, handlers.ReencryptBatchSize))
+	}
+
+	// Hard-delete soft-deleted rows once the retention period has passed
+	retention := 30 * 24 * time.Hour
+	if value := os.Getenv("SOFT_DELETE_RETENTION"); value != "" {
+		retention, err = time.ParseDuration(value)
+		if err != nil || retention <= 0 {
+			log.Fatal("SOFT_DELETE_RETENTION must be a positive duration such as 720h")
+		}
+	}
+	workersDone = append(workersDone, handlers.StartPurgeJob(workers, retention, time.Hour))
+
+	// Domain events are relayed from the outbox to webhook subscriptions and,
+	// optionally, to OUTBOX_PUBLISHER=stdout or OUTBOX_PUBLISHER=file:<path>
+	publishers := outbox.MultiPublisher{&webhooks.FanOut{DB: database.DB}}
+	if target := os.Getenv("OUTBOX_PUBLISHER"); target != "" {
+		switch {
+		case target == "stdout":
+			publishers = append(publishers, outbox.NewWriterPublisher(os.Stdout))
+		case strings.HasPrefix(target, "file:"):
+			publisher, err := outbox.NewFilePublisher(strings.TrimPrefix(target, "file:"))
+			if err != nil {
+				log.Fatal("Failed to open outbox file:", err)
+			}
+			publishers = append(publishers, publisher)
+		default:
+			log.Fatal("OUTBOX_PUBLISHER must be stdout or file:<path>")
+		}
+	}
+	relay := outbox.NewRelay(database.DB, publishers)
+
+	// Published events are kept for OUTBOX_RETENTION (default 168h) so event
+	// stream clients can replay them
+	if value := os.Getenv("OUTBOX_RETENTION"); value != "" {
+		relay.Retention, err = time.ParseDuration(value)
+		if err != nil || relay.Retention <= 0 {
+			log.Fatal("OUTBOX_RETENTION must be a positive duration such as 168h")
+		}
+	}
+	workersDone = append(workersDone,
+		relay.Start(workers),
+		relay.StartCleanup(workers, time.Hour),
+		webhooks.NewDispatcher(database.DB).Start(workers))
+
+	// gRPC API on its own port. It reports not serving to health checks and
+	// drains alongside the HTTP server.
+	grpcPort := os.Getenv("GRPC_PORT")
+	if grpcPort == "" {
+		grpcPort = "9090"
+	}
+	grpcListener, err := net.Listen("tcp", ":"+grpcPort)
+	if err != nil {
+		log.Fatal("Failed to listen for gRPC:", err)
+	}
+	grpcServer := grpcserver.New()
+	go func() {
+		log.Printf("gRPC server starting on :%s\n", grpcPort)
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			log.Println("gRPC server failed:", err)
+			stop()
+		}
+	}()
+	grpcDone := make(chan struct{})
+	go func() {
+		defer close(grpcDone)
+		<-ctx.Done()
+		grpcServer.Health.Shutdown()
+		grpcServer.GracefulStop()
+	}()
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
-	
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		log.Fatal("Failed to listen:", err)
+	}
+
 	log.Printf("Server starting on :%s\n", port)
-	log.Fatal(http.ListenAndServe(":"+port, r))
+	if err := srv.Serve(ctx, listener); err != nil {
+		log.Println("HTTP server failed:", err)
+	}
+	stop()
+	log.Println("Shutting down")
+
+	// gRPC calls and then the background workers get another
+	// SHUTDOWN_TIMEOUT to finish before the logger and database go away
+	shutdown, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	select {
+	case <-grpcDone:
+	case <-shutdown.Done():
+		log.Println("gRPC calls still running, closing them")
+		grpcServer.Stop()
+		<-grpcDone
+	}
+	stopWorkers()
+	for _, done := range workersDone {
+		select {
+		case <-done:
+		case <-shutdown.Done():
+		}
+	}
+	if shutdown.Err() != nil {
+		log.Println("Background workers still running at shutdown")
+	}
+
+	if err := logger.Close(); err != nil {
+		log.Println("Failed to flush the API log:", err)
+	}
+	database.Close()
 }
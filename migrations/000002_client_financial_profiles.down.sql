@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS client_financial_profiles;
+ALTER TABLE credits DROP COLUMN IF EXISTS dti_flagged;
+ALTER TABLE credits DROP COLUMN IF EXISTS debt_to_income;
\ No newline at end of file
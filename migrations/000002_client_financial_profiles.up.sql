@@ -0,0 +1,14 @@
+ALTER TABLE credits ADD COLUMN IF NOT EXISTS debt_to_income NUMERIC(12,4);
+ALTER TABLE credits ADD COLUMN IF NOT EXISTS dti_flagged BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS client_financial_profiles (
+    id SERIAL PRIMARY KEY,
+    client_id INTEGER NOT NULL REFERENCES clients(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    monthly_income DECIMAL(15,2) NOT NULL,
+    employment_type VARCHAR(20) NOT NULL
+        CHECK (employment_type IN ('EMPLOYED', 'SELF_EMPLOYED', 'UNEMPLOYED', 'RETIRED')),
+    external_debts DECIMAL(15,2) NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (client_id, version)
+);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS client_documents;
\ No newline at end of file
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS client_documents (
+    id SERIAL PRIMARY KEY,
+    client_id INTEGER NOT NULL REFERENCES clients(id) ON DELETE CASCADE,
+    type VARCHAR(30) NOT NULL CHECK (type IN ('ID_DOCUMENT', 'PROOF_OF_ADDRESS')),
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    sha256 CHAR(64) NOT NULL,
+    storage_key VARCHAR(500) NOT NULL,
+    expiry_date DATE,
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING'
+        CHECK (status IN ('PENDING', 'VERIFIED', 'REJECTED')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
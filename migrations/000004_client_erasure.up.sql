@@ -0,0 +1 @@
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS erased_at TIMESTAMP;
@@ -0,0 +1,11 @@
+-- Encrypted rows must be decrypted back into the plaintext columns before
+-- running this migration.
+ALTER TABLE clients ALTER COLUMN birth_date SET NOT NULL;
+ALTER TABLE clients ALTER COLUMN email SET NOT NULL;
+ALTER TABLE clients ALTER COLUMN full_name SET NOT NULL;
+
+ALTER TABLE clients DROP COLUMN IF EXISTS pii_key_id;
+ALTER TABLE clients DROP COLUMN IF EXISTS email_index;
+ALTER TABLE clients DROP COLUMN IF EXISTS birth_date_enc;
+ALTER TABLE clients DROP COLUMN IF EXISTS email_enc;
+ALTER TABLE clients DROP COLUMN IF EXISTS full_name_enc;
@@ -0,0 +1,9 @@
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS full_name_enc TEXT;
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS email_enc TEXT;
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS birth_date_enc TEXT;
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS email_index VARCHAR(64) UNIQUE;
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS pii_key_id VARCHAR(64);
+
+ALTER TABLE clients ALTER COLUMN full_name DROP NOT NULL;
+ALTER TABLE clients ALTER COLUMN email DROP NOT NULL;
+ALTER TABLE clients ALTER COLUMN birth_date DROP NOT NULL;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS client_merges;
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS client_merges (
+    id SERIAL PRIMARY KEY,
+    survivor_id INTEGER NOT NULL,
+    duplicate_id INTEGER NOT NULL,
+    moved_credit_ids INTEGER[] NOT NULL DEFAULT '{}',
+    moved_document_ids INTEGER[] NOT NULL DEFAULT '{}',
+    moved_profile_versions INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
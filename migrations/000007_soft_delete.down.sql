@@ -0,0 +1,3 @@
+ALTER TABLE credits DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE banks DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE clients DROP COLUMN IF EXISTS deleted_at;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE banks ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE credits ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
@@ -0,0 +1,3 @@
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE banks ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE credits ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
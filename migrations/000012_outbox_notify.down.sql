@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS outbox_notify ON outbox;
+DROP FUNCTION IF EXISTS notify_outbox_event();
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS import_rejections;
+DROP TABLE IF EXISTS import_jobs;
\ No newline at end of file
@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS import_jobs (
+    id SERIAL PRIMARY KEY,
+    resource VARCHAR(20) NOT NULL,
+    format VARCHAR(10) NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+    mapping JSONB NOT NULL DEFAULT '{}',
+    columns TEXT[] NOT NULL DEFAULT '{}',
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING' CHECK (status IN ('PENDING', 'RUNNING', 'COMPLETED', 'FAILED')),
+    total_rows INTEGER NOT NULL DEFAULT 0,
+    processed_rows INTEGER NOT NULL DEFAULT 0,
+    imported_rows INTEGER NOT NULL DEFAULT 0,
+    rejected_rows INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS import_rejections (
+    id BIGSERIAL PRIMARY KEY,
+    job_id INTEGER NOT NULL REFERENCES import_jobs(id) ON DELETE CASCADE,
+    row_number INTEGER NOT NULL,
+    error TEXT NOT NULL,
+    data TEXT[] NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_import_rejections_job ON import_rejections (job_id, row_number);
@@ -0,0 +1,4 @@
+-- Rejected rows stored encrypted keep only their row number and reason.
+DROP INDEX IF EXISTS idx_import_rejections_email;
+ALTER TABLE import_rejections DROP COLUMN IF EXISTS email_index;
+ALTER TABLE import_rejections DROP COLUMN IF EXISTS data_enc;
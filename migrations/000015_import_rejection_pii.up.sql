@@ -0,0 +1,4 @@
+ALTER TABLE import_rejections ADD COLUMN IF NOT EXISTS data_enc TEXT;
+ALTER TABLE import_rejections ADD COLUMN IF NOT EXISTS email_index VARCHAR(64);
+
+CREATE INDEX IF NOT EXISTS idx_import_rejections_email ON import_rejections (email_index);
@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS outbox_sequence ON outbox;
+DROP FUNCTION IF EXISTS sequence_outbox_event();
+DROP INDEX IF EXISTS idx_outbox_stream_seq;
+ALTER TABLE outbox DROP COLUMN IF EXISTS stream_seq;
+DROP SEQUENCE IF EXISTS outbox_stream_seq;
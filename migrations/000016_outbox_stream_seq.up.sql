@@ -0,0 +1,31 @@
+-- Number outbox rows in commit order, so an event stream resuming after
+-- number N never misses a row that commits later with a lower number. Ids
+-- are taken at insert and can commit out of order, so the number is taken
+-- by a deferred trigger as the transaction commits.
+--
+-- The advisory lock is held from that trigger until the commit completes,
+-- so transactions writing events commit one at a time. Without it two
+-- commits could draw their numbers in one order and become visible in the
+-- other, and a reader between them would skip an event for good. The lock
+-- covers only the end of the commit of transactions that insert outbox
+-- rows; reads and the body of every transaction still run concurrently.
+-- Rows from before the column existed keep their id as number.
+CREATE SEQUENCE IF NOT EXISTS outbox_stream_seq;
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS stream_seq BIGINT;
+UPDATE outbox SET stream_seq = id WHERE stream_seq IS NULL;
+SELECT setval('outbox_stream_seq', GREATEST(
+    (SELECT COALESCE(MAX(stream_seq), 0) FROM outbox),
+    (SELECT last_value FROM outbox_stream_seq)));
+CREATE UNIQUE INDEX IF NOT EXISTS idx_outbox_stream_seq ON outbox (stream_seq);
+
+CREATE OR REPLACE FUNCTION sequence_outbox_event() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtext('outbox_stream_seq'));
+    UPDATE outbox SET stream_seq = nextval('outbox_stream_seq') WHERE id = NEW.id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS outbox_sequence ON outbox;
+CREATE CONSTRAINT TRIGGER outbox_sequence AFTER INSERT ON outbox
+    DEFERRABLE INITIALLY DEFERRED FOR EACH ROW EXECUTE FUNCTION sequence_outbox_event();
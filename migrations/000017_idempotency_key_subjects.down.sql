@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_idempotency_keys_subjects;
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS subjects;
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS body_encrypted;
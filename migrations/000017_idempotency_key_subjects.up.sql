@@ -0,0 +1,6 @@
+-- Stored responses are encrypted when a PII key is configured, and list the
+-- clients they concern so that erasing a client deletes them.
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS body_encrypted BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS subjects TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_subjects ON idempotency_keys USING GIN (subjects);
@@ -0,0 +1,254 @@
+// Package client is a typed Go client for the API. It covers clients, banks,
+// credits and items, retries idempotent calls with backoff and maps error
+// responses to *Error values that match the sentinel errors below.
+//
+//	c := client.New("http://localhost:8080")
+//	c.Actor = "billing-service"
+//	credit, err := c.GetCredit(ctx, 42)
+//	if errors.Is(err, client.ErrNotFound) {
+//		...
+//	}
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// API calls the API at BaseURL. The zero value is not usable; create one
+// with New and adjust the fields before the first call.
+type API struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	// Token is sent as a bearer token when set.
+	Token string
+	// Actor is recorded as the author of changes in the audit log.
+	Actor string
+	// MaxRetries bounds the retries of a call that failed with a network
+	// error or a 429, 502, 503 or 504 response. Zero disables retries.
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry; it doubles with each
+	// further retry, with random jitter, up to MaxRetryBackoff.
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+}
+
+func New(baseURL string) *API {
+	return &API{
+		BaseURL:         strings.TrimRight(baseURL, "/"),
+		HTTPClient:      &http.Client{Timeout: 30 * time.Second},
+		MaxRetries:      3,
+		RetryBackoff:    200 * time.Millisecond,
+		MaxRetryBackoff: 5 * time.Second,
+	}
+}
+
+// Sentinel errors matched by *Error through errors.Is.
+var (
+	ErrInvalid            = errors.New("invalid request")
+	ErrNotFound           = errors.New("not found")
+	ErrConflict           = errors.New("conflict")
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrUnprocessable      = errors.New("unprocessable")
+)
+
+var statusErrors = map[int]error{
+	http.StatusBadRequest:           ErrInvalid,
+	http.StatusNotFound:             ErrNotFound,
+	http.StatusConflict:             ErrConflict,
+	http.StatusPreconditionFailed:   ErrPreconditionFailed,
+	http.StatusPreconditionRequired: ErrPreconditionFailed,
+	http.StatusUnprocessableEntity:  ErrUnprocessable,
+}
+
+// Violation is one problem the API found with a request.
+type Violation struct {
+	In      string `json:"in"`
+	Name    string `json:"name,omitempty"`
+	Message string `json:"message"`
+}
+
+// Error is an error response from the API.
+type Error struct {
+	StatusCode int
+	Message    string
+	Details    []Violation
+	RequestID  string
+}
+
+func (e *Error) Error() string {
+	message := fmt.Sprintf("api: %d %s", e.StatusCode, e.Message)
+	for _, v := range e.Details {
+		message += fmt.Sprintf("; %s %s %s", v.In, v.Name, v.Message)
+	}
+	return message
+}
+
+// Is matches the sentinel error of the response's status code.
+func (e *Error) Is(target error) bool {
+	return statusErrors[e.StatusCode] == target
+}
+
+// retryable reports whether a response is worth retrying. A 409 is when it
+// carries Retry-After: the API sends one while the original request for an
+// Idempotency-Key is still running or after it failed and released the key.
+func retryable(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	case http.StatusConflict:
+		return resp.Header.Get("Retry-After") != ""
+	}
+	return false
+}
+
+// request describes one call. Every call is safe to retry: GET, PUT and
+// DELETE are idempotent, and POSTs carry an Idempotency-Key so the API
+// replays the first result instead of repeating the change.
+type request struct {
+	method  string
+	path    string
+	query   url.Values
+	body    interface{}
+	headers map[string]string
+	accept  string
+}
+
+// send performs r, retrying as configured, and returns the successful
+// response. The caller closes its body.
+func (c *API) send(ctx context.Context, r request) (*http.Response, error) {
+	var body []byte
+	if r.body != nil {
+		var err error
+		if body, err = json.Marshal(r.body); err != nil {
+			return nil, err
+		}
+	}
+	target := c.BaseURL + r.path
+	if len(r.query) > 0 {
+		target += "?" + r.query.Encode()
+	}
+	var idempotencyKey string
+	if r.method == http.MethodPost {
+		idempotencyKey = newKey()
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, r.method, target, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		req.Header.Set("Accept", "application/json")
+		if r.accept != "" {
+			req.Header.Set("Accept", r.accept)
+		}
+		if c.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.Token)
+		}
+		if c.Actor != "" {
+			req.Header.Set("X-Actor", c.Actor)
+		}
+		if idempotencyKey != "" {
+			req.Header.Set("Idempotency-Key", idempotencyKey)
+		}
+		for name, value := range r.headers {
+			req.Header.Set(name, value)
+		}
+
+		resp, err := c.HTTPClient.Do(req)
+		var retryAfter time.Duration
+		if err == nil {
+			if resp.StatusCode < 400 {
+				return resp, nil
+			}
+			err = decodeError(resp)
+			if !retryable(resp) {
+				return nil, err
+			}
+			if seconds, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil {
+				retryAfter = time.Duration(seconds) * time.Second
+			}
+		}
+		if attempt >= c.MaxRetries || ctx.Err() != nil {
+			return nil, err
+		}
+
+		wait := c.backoff(attempt)
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff is the wait before retry number attempt+1: the doubled base
+// backoff, capped, less up to half of it at random.
+func (c *API) backoff(attempt int) time.Duration {
+	wait := c.RetryBackoff << attempt
+	if wait <= 0 || (c.MaxRetryBackoff > 0 && wait > c.MaxRetryBackoff) {
+		wait = c.MaxRetryBackoff
+	}
+	if half := int64(wait / 2); half > 0 {
+		jitter, err := rand.Int(rand.Reader, big.NewInt(half))
+		if err == nil {
+			wait -= time.Duration(jitter.Int64())
+		}
+	}
+	return wait
+}
+
+// do performs r and decodes the JSON response into out, when given.
+func (c *API) do(ctx context.Context, r request, out interface{}) error {
+	resp, err := c.send(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func decodeError(resp *http.Response) error {
+	defer resp.Body.Close()
+	apiErr := &Error{StatusCode: resp.StatusCode, RequestID: resp.Header.Get("X-Request-ID")}
+	var body struct {
+		Error   string      `json:"error"`
+		Details []Violation `json:"details"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err == nil && body.Error != "" {
+		apiErr.Message, apiErr.Details = body.Error, body.Details
+	} else {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
+
+func newKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
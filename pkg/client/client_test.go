@@ -0,0 +1,183 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"backend/internal/openapi"
+
+	"github.com/gorilla/mux"
+)
+
+// contractServer serves routes behind the OpenAPI validator, so every
+// request the client sends and every canned response must match the
+// document.
+func contractServer(t *testing.T, routes map[string]http.HandlerFunc) *API {
+	validator := openapi.NewValidator(openapi.Spec())
+	validator.ValidateResponses = true
+	r := mux.NewRouter()
+	r.Use(validator.Handler)
+	for route, handler := range routes {
+		var method, path string
+		fmt.Sscan(route, &method, &path)
+		r.HandleFunc(path, handler).Methods(method)
+	}
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+
+	api := New(server.URL)
+	api.RetryBackoff = time.Millisecond
+	return api
+}
+
+func respond(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestCreateAndUpdate(t *testing.T) {
+	var headers http.Header
+	api := contractServer(t, map[string]http.HandlerFunc{
+		"POST /api/credits": func(w http.ResponseWriter, r *http.Request) {
+			headers = r.Header
+			var credit Credit
+			json.NewDecoder(r.Body).Decode(&credit)
+			credit.ID, credit.Status, credit.Version, credit.CreatedAt = 7, CreditStatusPending, 1, time.Now()
+			respond(w, http.StatusCreated, credit)
+		},
+		"PUT /api/banks/{id}": func(w http.ResponseWriter, r *http.Request) {
+			headers = r.Header
+			respond(w, http.StatusPreconditionFailed, map[string]string{"error": "If-Match does not match the current version"})
+		},
+	})
+	api.Token, api.Actor = "secret", "billing"
+
+	credit, err := api.CreateCredit(context.Background(), Credit{
+		ClientID: 1, BankID: 2, MinPayment: 100, MaxPayment: 500, TermMonths: 12, CreditType: CreditTypeAuto,
+	})
+	if err != nil || credit.ID != 7 || credit.Status != CreditStatusPending {
+		t.Fatalf("Expected the created credit, got %+v, %v", credit, err)
+	}
+	if headers.Get("Authorization") != "Bearer secret" || headers.Get("X-Actor") != "billing" || len(headers.Get("Idempotency-Key")) != 32 {
+		t.Errorf("Expected auth, actor and idempotency headers, got %v", headers)
+	}
+
+	_, err = api.UpdateBank(context.Background(), Bank{ID: 3, Name: "Renamed", Type: BankTypePrivate, Version: 4})
+	if !errors.Is(err, ErrPreconditionFailed) || headers.Get("If-Match") != `"4"` {
+		t.Errorf("Expected a precondition failure guarded by If-Match, got %v (%s)", err, headers.Get("If-Match"))
+	}
+}
+
+func TestErrors(t *testing.T) {
+	api := contractServer(t, map[string]http.HandlerFunc{
+		"GET /api/credits/{id}": func(w http.ResponseWriter, r *http.Request) {
+			respond(w, http.StatusNotFound, map[string]string{"error": "Credit not found"})
+		},
+		"POST /api/banks": func(w http.ResponseWriter, r *http.Request) {
+			t.Error("Expected the invalid bank to be rejected before the handler")
+		},
+	})
+
+	_, err := api.GetCredit(context.Background(), 1)
+	var apiErr *Error
+	if !errors.Is(err, ErrNotFound) || !errors.As(err, &apiErr) || apiErr.Message != "Credit not found" {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+
+	_, err = api.CreateBank(context.Background(), Bank{Name: "Bank", Type: "MUTUAL"})
+	if !errors.Is(err, ErrInvalid) || !errors.As(err, &apiErr) || len(apiErr.Details) != 1 || apiErr.Details[0].Name != "type" {
+		t.Errorf("Expected a validation error on type, got %v", err)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	attempts := 0
+	var keys []string
+	api := contractServer(t, map[string]http.HandlerFunc{
+		"POST /api/banks/{id}/restore": func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			keys = append(keys, r.Header.Get("Idempotency-Key"))
+			if attempts < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			respond(w, http.StatusOK, map[string]string{"message": "Bank restored successfully"})
+		},
+	})
+
+	if err := api.RestoreBank(context.Background(), 1); err != nil || attempts != 3 {
+		t.Fatalf("Expected success on the third attempt, got %v after %d", err, attempts)
+	}
+	if keys[0] == "" || keys[0] != keys[1] || keys[1] != keys[2] {
+		t.Errorf("Expected every attempt to reuse the idempotency key, got %v", keys)
+	}
+
+	attempts = 0
+	api.MaxRetries = 1
+	err := api.RestoreBank(context.Background(), 1)
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable || attempts != 2 {
+		t.Errorf("Expected to give up after one retry, got %v after %d", err, attempts)
+	}
+}
+
+func TestRetriesIdempotencyConflicts(t *testing.T) {
+	attempts := 0
+	retryAfter := "0"
+	api := contractServer(t, map[string]http.HandlerFunc{
+		"POST /api/banks/{id}/restore": func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			if attempts == 1 {
+				// The original is still running, or failed and released the key
+				if retryAfter != "" {
+					w.Header().Set("Retry-After", retryAfter)
+				}
+				respond(w, http.StatusConflict, map[string]string{"error": "A request with this Idempotency-Key is still in progress"})
+				return
+			}
+			respond(w, http.StatusOK, map[string]string{"message": "Bank restored successfully"})
+		},
+	})
+
+	if err := api.RestoreBank(context.Background(), 1); err != nil || attempts != 2 {
+		t.Fatalf("Expected a 409 with Retry-After to be retried, got %v after %d", err, attempts)
+	}
+
+	attempts, retryAfter = 0, ""
+	if err := api.RestoreBank(context.Background(), 1); !errors.Is(err, ErrConflict) || attempts != 1 {
+		t.Errorf("Expected a plain 409 to fail at once, got %v after %d", err, attempts)
+	}
+}
+
+func TestIterator(t *testing.T) {
+	var query string
+	api := contractServer(t, map[string]http.HandlerFunc{
+		"GET /api/banks/{bankId}/credits": func(w http.ResponseWriter, r *http.Request) {
+			query = r.URL.RawQuery
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			for id := 1; id <= 3; id++ {
+				json.NewEncoder(w).Encode(Credit{ID: id, BankID: 5, Status: CreditStatusApproved})
+			}
+		},
+	})
+
+	credits, err := api.ListBankCredits(context.Background(), 5, &ListOptions{IncludeDeleted: true}).All()
+	if err != nil || len(credits) != 3 || credits[2].ID != 3 {
+		t.Fatalf("Expected 3 credits, got %+v, %v", credits, err)
+	}
+	if query != "format=ndjson&include_deleted=true" {
+		t.Errorf("Expected NDJSON with deleted rows, got %s", query)
+	}
+
+	it := api.ListBankCredits(context.Background(), 0, nil)
+	if it.Next() || !errors.Is(it.Err(), ErrInvalid) {
+		t.Errorf("Expected the invalid bank ID to end the iteration, got %v", it.Err())
+	}
+}
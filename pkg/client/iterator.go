@@ -0,0 +1,121 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+)
+
+// maxLine bounds one NDJSON record.
+const maxLine = 1 << 20
+
+// ListOptions narrow list calls. A nil *ListOptions lists live rows.
+type ListOptions struct {
+	// IncludeDeleted adds soft-deleted rows.
+	IncludeDeleted bool
+}
+
+func (o *ListOptions) query() url.Values {
+	q := url.Values{"format": {"ndjson"}}
+	if o != nil && o.IncludeDeleted {
+		q.Set("include_deleted", "true")
+	}
+	return q
+}
+
+// Iterator walks a list one value at a time. The API returns whole lists
+// rather than pages, so the iterator streams the list as NDJSON and decodes
+// each value as it arrives instead of holding the list in memory. The
+// request is sent on the first call to Next.
+//
+//	it := c.ListCredits(ctx, nil)
+//	defer it.Close()
+//	for it.Next() {
+//		credit := it.Value()
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type Iterator[T any] struct {
+	ctx     context.Context
+	client  *API
+	request request
+	body    io.ReadCloser
+	lines   *bufio.Scanner
+	value   T
+	err     error
+	done    bool
+}
+
+func newIterator[T any](ctx context.Context, c *API, r request) *Iterator[T] {
+	r.accept = "application/x-ndjson"
+	return &Iterator[T]{ctx: ctx, client: c, request: r}
+}
+
+// Next advances to the next value, reporting false at the end of the list or
+// on error.
+func (it *Iterator[T]) Next() bool {
+	if it.done {
+		return false
+	}
+	if it.lines == nil {
+		resp, err := it.client.send(it.ctx, it.request)
+		if err != nil {
+			return it.fail(err)
+		}
+		it.body = resp.Body
+		it.lines = bufio.NewScanner(resp.Body)
+		it.lines.Buffer(make([]byte, 64*1024), maxLine)
+	}
+	for it.lines.Scan() {
+		if len(it.lines.Bytes()) == 0 {
+			continue
+		}
+		var value T
+		if err := json.Unmarshal(it.lines.Bytes(), &value); err != nil {
+			return it.fail(err)
+		}
+		it.value = value
+		return true
+	}
+	return it.fail(it.lines.Err())
+}
+
+func (it *Iterator[T]) fail(err error) bool {
+	it.err = err
+	it.Close()
+	return false
+}
+
+// Value is the value Next advanced to.
+func (it *Iterator[T]) Value() T {
+	return it.value
+}
+
+// Err is the error that ended the iteration, if any.
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// Close releases the response; it is safe to call more than once.
+func (it *Iterator[T]) Close() error {
+	it.done = true
+	if it.body == nil {
+		return nil
+	}
+	err := it.body.Close()
+	it.body = nil
+	return err
+}
+
+// All collects the remaining values.
+func (it *Iterator[T]) All() ([]T, error) {
+	defer it.Close()
+	values := []T{}
+	for it.Next() {
+		values = append(values, it.Value())
+	}
+	return values, it.Err()
+}
@@ -0,0 +1,188 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"backend/internal/models"
+)
+
+// The API's resources. These are the server's own types, so the client
+// decodes exactly what the API encodes.
+type (
+	Client       = models.Client
+	Bank         = models.Bank
+	BankType     = models.BankType
+	Credit       = models.Credit
+	CreditType   = models.CreditType
+	CreditStatus = models.CreditStatus
+	Item         = models.Item
+)
+
+const (
+	BankTypePrivate    = models.BankTypePrivate
+	BankTypeGovernment = models.BankTypeGovernment
+
+	CreditTypeAuto       = models.CreditTypeAuto
+	CreditTypeMortgage   = models.CreditTypeMortgage
+	CreditTypeCommercial = models.CreditTypeCommercial
+
+	CreditStatusPending  = models.CreditStatusPending
+	CreditStatusApproved = models.CreditStatusApproved
+	CreditStatusRejected = models.CreditStatusRejected
+)
+
+func path(prefix string, id int, suffix ...string) string {
+	p := prefix + "/" + strconv.Itoa(id)
+	for _, s := range suffix {
+		p += "/" + s
+	}
+	return p
+}
+
+// ifMatch guards an update with the version the caller last read, so a
+// change made in between fails with ErrPreconditionFailed instead of being
+// overwritten. A zero version sends no guard.
+func ifMatch(version int) map[string]string {
+	if version == 0 {
+		return nil
+	}
+	return map[string]string{"If-Match": `"` + strconv.Itoa(version) + `"`}
+}
+
+// Clients
+
+func (c *API) CreateClient(ctx context.Context, client Client) (Client, error) {
+	var created Client
+	err := c.do(ctx, request{method: http.MethodPost, path: "/api/clients", body: client}, &created)
+	return created, err
+}
+
+func (c *API) GetClient(ctx context.Context, id int) (Client, error) {
+	var client Client
+	err := c.do(ctx, request{method: http.MethodGet, path: path("/api/clients", id)}, &client)
+	return client, err
+}
+
+// UpdateClient replaces the client with client.ID, guarded by
+// client.Version.
+func (c *API) UpdateClient(ctx context.Context, client Client) (Client, error) {
+	var updated Client
+	err := c.do(ctx, request{
+		method: http.MethodPut, path: path("/api/clients", client.ID), body: client, headers: ifMatch(client.Version),
+	}, &updated)
+	return updated, err
+}
+
+func (c *API) DeleteClient(ctx context.Context, id int) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: path("/api/clients", id)}, nil)
+}
+
+func (c *API) RestoreClient(ctx context.Context, id int) error {
+	return c.do(ctx, request{method: http.MethodPost, path: path("/api/clients", id, "restore")}, nil)
+}
+
+// ListClientCredits lists the credits of a client.
+func (c *API) ListClientCredits(ctx context.Context, clientID int, opts *ListOptions) *Iterator[Credit] {
+	return newIterator[Credit](ctx, c, request{
+		method: http.MethodGet, path: path("/api/clients", clientID, "credits"), query: opts.query(),
+	})
+}
+
+// Banks
+
+func (c *API) ListBanks(ctx context.Context, opts *ListOptions) *Iterator[Bank] {
+	return newIterator[Bank](ctx, c, request{method: http.MethodGet, path: "/api/banks", query: opts.query()})
+}
+
+func (c *API) CreateBank(ctx context.Context, bank Bank) (Bank, error) {
+	var created Bank
+	err := c.do(ctx, request{method: http.MethodPost, path: "/api/banks", body: bank}, &created)
+	return created, err
+}
+
+func (c *API) GetBank(ctx context.Context, id int) (Bank, error) {
+	var bank Bank
+	err := c.do(ctx, request{method: http.MethodGet, path: path("/api/banks", id)}, &bank)
+	return bank, err
+}
+
+// UpdateBank replaces the bank with bank.ID, guarded by bank.Version.
+func (c *API) UpdateBank(ctx context.Context, bank Bank) (Bank, error) {
+	var updated Bank
+	err := c.do(ctx, request{
+		method: http.MethodPut, path: path("/api/banks", bank.ID), body: bank, headers: ifMatch(bank.Version),
+	}, &updated)
+	return updated, err
+}
+
+func (c *API) DeleteBank(ctx context.Context, id int) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: path("/api/banks", id)}, nil)
+}
+
+func (c *API) RestoreBank(ctx context.Context, id int) error {
+	return c.do(ctx, request{method: http.MethodPost, path: path("/api/banks", id, "restore")}, nil)
+}
+
+// ListBankCredits lists the credits granted by a bank.
+func (c *API) ListBankCredits(ctx context.Context, bankID int, opts *ListOptions) *Iterator[Credit] {
+	return newIterator[Credit](ctx, c, request{
+		method: http.MethodGet, path: path("/api/banks", bankID, "credits"), query: opts.query(),
+	})
+}
+
+// Credits
+
+func (c *API) ListCredits(ctx context.Context, opts *ListOptions) *Iterator[Credit] {
+	return newIterator[Credit](ctx, c, request{method: http.MethodGet, path: "/api/credits", query: opts.query()})
+}
+
+// CreateCredit creates a credit; an empty Status defaults to PENDING.
+func (c *API) CreateCredit(ctx context.Context, credit Credit) (Credit, error) {
+	var created Credit
+	err := c.do(ctx, request{method: http.MethodPost, path: "/api/credits", body: credit}, &created)
+	return created, err
+}
+
+func (c *API) GetCredit(ctx context.Context, id int) (Credit, error) {
+	var credit Credit
+	err := c.do(ctx, request{method: http.MethodGet, path: path("/api/credits", id)}, &credit)
+	return credit, err
+}
+
+// UpdateCredit replaces the credit with credit.ID, guarded by
+// credit.Version.
+func (c *API) UpdateCredit(ctx context.Context, credit Credit) (Credit, error) {
+	var updated Credit
+	err := c.do(ctx, request{
+		method: http.MethodPut, path: path("/api/credits", credit.ID), body: credit, headers: ifMatch(credit.Version),
+	}, &updated)
+	return updated, err
+}
+
+func (c *API) DeleteCredit(ctx context.Context, id int) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: path("/api/credits", id)}, nil)
+}
+
+func (c *API) RestoreCredit(ctx context.Context, id int) error {
+	return c.do(ctx, request{method: http.MethodPost, path: path("/api/credits", id, "restore")}, nil)
+}
+
+// Items
+
+func (c *API) ListItems(ctx context.Context) *Iterator[Item] {
+	return newIterator[Item](ctx, c, request{method: http.MethodGet, path: "/api/items", query: (*ListOptions)(nil).query()})
+}
+
+func (c *API) CreateItem(ctx context.Context, item Item) (Item, error) {
+	var created Item
+	err := c.do(ctx, request{method: http.MethodPost, path: "/api/items", body: item}, &created)
+	return created, err
+}
+
+func (c *API) GetItem(ctx context.Context, id int) (Item, error) {
+	var item Item
+	err := c.do(ctx, request{method: http.MethodGet, path: path("/api/items", id)}, &item)
+	return item, err
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"net/http"
+
+	"backend/internal/handlers"
+	"backend/internal/openapi"
+)
+
+// Route is an endpoint of the API. Name is its operation id in the OpenAPI
+// document, a stable label for docs and metrics; within a request it is
+// mux.CurrentRoute(r).GetName().
+type Route struct {
+	Method  string
+	Path    string
+	Name    string
+	Handler http.HandlerFunc
+}
+
+// Routes returns every route, in the order they are matched.
+func Routes() []Route {
+	return append([]Route(nil), routes...)
+}
+
+var routes = []Route{
+	{"GET", "/health", "healthCheck", handlers.HealthCheck},
+	{"GET", "/openapi.json", "getOpenAPI", openapi.ServeSpec},
+	{"GET", "/docs", "getDocs", openapi.ServeDocs},
+	{"GET", "/api/items", "listItems", handlers.GetItems},
+	{"POST", "/api/items", "createItem", handlers.CreateItem},
+	{"GET", "/api/items/{id}", "getItem", handlers.GetItem},
+
+	// Clients
+	{"POST", "/api/clients", "createClient", handlers.CreateClient},
+	{"POST", "/api/clients:batch", "createClientsBatch", handlers.CreateClientsBatch},
+	{"GET", "/api/clients/duplicates", "listDuplicateClients", handlers.GetDuplicateClients},
+	{"GET", "/api/clients/{id}", "getClient", handlers.GetClient},
+	{"PUT", "/api/clients/{id}", "updateClient", handlers.UpdateClient},
+	{"DELETE", "/api/clients/{id}", "deleteClient", handlers.DeleteClient},
+	{"GET", "/api/clients/{id}/export", "exportClient", handlers.ExportClient},
+	{"POST", "/api/clients/{id}/erase", "eraseClient", handlers.EraseClient},
+	{"POST", "/api/clients/{id}/merge", "mergeClient", handlers.MergeClient},
+	{"POST", "/api/clients/{id}/restore", "restoreClient", handlers.RestoreClient},
+	{"GET", "/api/clients/{clientId}/financial-profile", "getFinancialProfile", handlers.GetFinancialProfile},
+	{"PUT", "/api/clients/{clientId}/financial-profile", "updateFinancialProfile", handlers.UpdateFinancialProfile},
+	{"GET", "/api/clients/{clientId}/financial-profile/history", "listFinancialProfiles", handlers.GetFinancialProfileHistory},
+	{"POST", "/api/clients/{clientId}/documents", "uploadClientDocument", handlers.UploadClientDocument},
+	{"GET", "/api/clients/{clientId}/documents", "listClientDocuments", handlers.GetClientDocuments},
+	{"GET", "/api/clients/{clientId}/documents/{id}/content", "downloadClientDocument", handlers.DownloadClientDocument},
+	{"PUT", "/api/clients/{clientId}/documents/{id}/status", "updateClientDocumentStatus", handlers.UpdateClientDocumentStatus},
+
+	// Banks
+	{"GET", "/api/banks", "listBanks", handlers.GetBanks},
+	{"POST", "/api/banks", "createBank", handlers.CreateBank},
+	{"GET", "/api/banks/{id}", "getBank", handlers.GetBank},
+	{"PUT", "/api/banks/{id}", "updateBank", handlers.UpdateBank},
+	{"DELETE", "/api/banks/{id}", "deleteBank", handlers.DeleteBank},
+	{"POST", "/api/banks/{id}/restore", "restoreBank", handlers.RestoreBank},
+
+	// Credits
+	{"GET", "/api/credits", "listCredits", handlers.GetCredits},
+	{"POST", "/api/credits", "createCredit", handlers.CreateCredit},
+	{"POST", "/api/credits:batch", "createCreditsBatch", handlers.CreateCreditsBatch},
+	{"POST", "/api/credits:batch-status", "updateCreditsStatus", handlers.UpdateCreditsStatus},
+	{"GET", "/api/credits/{id}", "getCredit", handlers.GetCredit},
+	{"PUT", "/api/credits/{id}", "updateCredit", handlers.UpdateCredit},
+	{"DELETE", "/api/credits/{id}", "deleteCredit", handlers.DeleteCredit},
+	{"POST", "/api/credits/{id}/restore", "restoreCredit", handlers.RestoreCredit},
+	{"GET", "/api/clients/{clientId}/credits", "listClientCredits", handlers.GetCreditsByClient},
+	{"GET", "/api/banks/{bankId}/credits", "listBankCredits", handlers.GetCreditsByBank},
+
+	// Credits, version 2
+	{"GET", "/api/v2/credits", "listCreditsV2", handlers.GetCreditsV2},
+	{"POST", "/api/v2/credits", "createCreditV2", handlers.CreateCreditV2},
+	{"GET", "/api/v2/credits/{id}", "getCreditV2", handlers.GetCreditV2},
+	{"PUT", "/api/v2/credits/{id}", "updateCreditV2", handlers.UpdateCreditV2},
+	{"GET", "/api/v2/clients/{clientId}/credits", "listClientCreditsV2", handlers.GetCreditsByClientV2},
+	{"GET", "/api/v2/banks/{bankId}/credits", "listBankCreditsV2", handlers.GetCreditsByBankV2},
+
+	// Admin
+	{"POST", "/api/admin/pii/reencrypt", "reencryptClients", handlers.ReencryptClientsHandler},
+
+	// Audit
+	{"GET", "/api/audit", "listAuditEvents", handlers.GetAuditEvents},
+
+	// Event stream
+	{"GET", "/api/events/stream", "streamEvents", handlers.StreamEvents},
+
+	// Webhooks
+	{"GET", "/api/webhooks", "listWebhooks", handlers.GetWebhooks},
+	{"POST", "/api/webhooks", "createWebhook", handlers.CreateWebhook},
+	{"GET", "/api/webhooks/{id}", "getWebhook", handlers.GetWebhook},
+	{"PUT", "/api/webhooks/{id}", "updateWebhook", handlers.UpdateWebhook},
+	{"DELETE", "/api/webhooks/{id}", "deleteWebhook", handlers.DeleteWebhook},
+	{"GET", "/api/webhooks/{id}/deliveries", "listWebhookDeliveries", handlers.GetWebhookDeliveries},
+	{"GET", "/api/webhooks/{id}/deliveries/{deliveryId}/attempts", "listWebhookDeliveryAttempts", handlers.GetWebhookDeliveryAttempts},
+	{"POST", "/api/webhooks/{id}/deliveries/{deliveryId}/redeliver", "redeliverWebhook", handlers.RedeliverWebhook},
+
+	// Imports
+	{"GET", "/api/imports", "listImports", handlers.GetImports},
+	{"POST", "/api/imports", "createImport", handlers.CreateImport},
+	{"GET", "/api/imports/{id}", "getImport", handlers.GetImport},
+	{"GET", "/api/imports/{id}/errors", "getImportErrors", handlers.GetImportErrors},
+
+	// GraphQL
+	{"POST", "/graphql", "graphql", handlers.GraphQL},
+	{"GET", "/graphql/schema", "getGraphQLSchema", handlers.GraphQLSchema},
+}
@@ -0,0 +1,238 @@
+// Package server builds the complete HTTP API from a Config: every route, the
+// middleware chain and the dependencies of the handlers. main serves it, the
+// integration tests run it under httptest and other programs can embed it.
+//
+//	srv, err := server.New(ctx, server.Config{DB: db, DatabaseURL: url})
+//	if err != nil {
+//		...
+//	}
+//	listener, _ := net.Listen("tcp", ":8080")
+//	err = srv.Serve(ctx, listener) // shuts down gracefully once ctx is done
+//
+// The handlers keep their dependencies in package variables, so a process
+// runs one server at a time.
+package server
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"backend/internal/database"
+	"backend/internal/encryption"
+	"backend/internal/handlers"
+	"backend/internal/idempotency"
+	"backend/internal/middleware"
+	"backend/internal/openapi"
+	"backend/internal/storage"
+	"backend/internal/stream"
+
+	"github.com/gorilla/mux"
+)
+
+// Config configures the API. A zero field keeps the default documented on it.
+type Config struct {
+	// DB is the database of the handlers; nil keeps database.DB.
+	DB *sql.DB
+	// DatabaseURL, when set, is listened on for new events to push to event
+	// stream clients; without it the stream answers 503.
+	DatabaseURL string
+	// DocumentStorageDir holds uploaded KYC documents; default data/documents.
+	DocumentStorageDir string
+	// PIIKeyFile holds the keys encrypting client PII; empty leaves new PII
+	// unencrypted.
+	PIIKeyFile string
+	// DTIThreshold is the debt-to-income ratio above which new credits are
+	// flagged; default 0.43.
+	DTIThreshold float64
+	// DTIReject rejects credits above DTIThreshold instead of flagging them.
+	DTIReject bool
+	// RequireIfMatch rejects updates and deletes without an If-Match header.
+	RequireIfMatch bool
+	// AdminActors are the X-Actor values allowed to see soft-deleted rows.
+	AdminActors []string
+	// ValidateResponses checks responses against the OpenAPI document too, so
+	// a handler drifting from it answers 500. Meant for tests.
+	ValidateResponses bool
+	// APIV1DeprecatedAt and APIV1SunsetAt date the v1 routes that have a v2
+	// successor; a zero time leaves its header out, and a zero
+	// APIV1DeprecatedAt leaves both out.
+	APIV1DeprecatedAt time.Time
+	APIV1SunsetAt     time.Time
+
+	// ReadHeaderTimeout, ReadTimeout, WriteTimeout and IdleTimeout bound the
+	// connections of Serve, as in http.Server; event streams are exempt from
+	// the read and write timeouts, and list exports and imports run within
+	// the request get WriteTimeout per write instead of for the whole
+	// response. Zero takes the matching Default constant.
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// ShutdownTimeout is how long Serve waits for requests in flight once
+	// its context is done; default DefaultShutdownTimeout.
+	ShutdownTimeout time.Duration
+}
+
+// Defaults of the Config timeouts. Reads allow for document and import
+// uploads, and writes for large list exports.
+const (
+	DefaultReadHeaderTimeout = 5 * time.Second
+	DefaultReadTimeout       = time.Minute
+	DefaultWriteTimeout      = 2 * time.Minute
+	DefaultIdleTimeout       = 2 * time.Minute
+	DefaultShutdownTimeout   = 30 * time.Second
+)
+
+// Server is the HTTP API, ready to be served as an http.Handler.
+type Server struct {
+	// Router holds the routes, each named after its Route.Name.
+	Router *mux.Router
+	// Idempotency replays retried POSTs. Expired keys are only deleted once
+	// Idempotency.StartCleanup runs.
+	Idempotency *idempotency.Middleware
+
+	cfg     Config
+	broker  *stream.Broker
+	handler http.Handler
+}
+
+// New sets up the dependencies of the handlers from cfg and builds the
+// server. ctx bounds the event listener.
+func New(ctx context.Context, cfg Config) (*Server, error) {
+	if cfg.DB != nil {
+		database.DB = cfg.DB
+	}
+	if cfg.DTIThreshold != 0 {
+		handlers.DTIThreshold = cfg.DTIThreshold
+	}
+	handlers.DTIRejectAboveThreshold = cfg.DTIReject
+	handlers.RequireIfMatch = cfg.RequireIfMatch
+	handlers.AdminActors = map[string]bool{}
+	for _, actor := range cfg.AdminActors {
+		handlers.AdminActors[actor] = true
+	}
+
+	documentsDir := cfg.DocumentStorageDir
+	if documentsDir == "" {
+		documentsDir = "data/documents"
+	}
+	documentStore, err := storage.NewLocalStore(documentsDir)
+	if err != nil {
+		return nil, fmt.Errorf("document storage: %w", err)
+	}
+	handlers.DocumentStore = documentStore
+
+	if cfg.PIIKeyFile != "" {
+		keyProvider, err := encryption.NewLocalKeyProvider(cfg.PIIKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("PII keys: %w", err)
+		}
+		handlers.PIIEncrypter = encryption.NewEncrypter(keyProvider)
+	}
+
+	if cfg.DatabaseURL != "" {
+		broker := stream.NewBroker()
+		if err := broker.Listen(ctx, cfg.DatabaseURL, handlers.LoadStreamedEvent); err != nil {
+			return nil, fmt.Errorf("event listener: %w", err)
+		}
+		handlers.EventBroker = broker
+	}
+
+	for timeout, fallback := range map[*time.Duration]time.Duration{
+		&cfg.ReadHeaderTimeout: DefaultReadHeaderTimeout,
+		&cfg.ReadTimeout:       DefaultReadTimeout,
+		&cfg.WriteTimeout:      DefaultWriteTimeout,
+		&cfg.IdleTimeout:       DefaultIdleTimeout,
+		&cfg.ShutdownTimeout:   DefaultShutdownTimeout,
+	} {
+		if *timeout == 0 {
+			*timeout = fallback
+		}
+	}
+	handlers.StreamedWriteTimeout = cfg.WriteTimeout
+
+	s := &Server{
+		Router:      mux.NewRouter(),
+		Idempotency: idempotency.New(database.DB),
+		cfg:         cfg,
+		broker:      handlers.EventBroker,
+	}
+	s.Router.Use(middleware.RequestIDMiddleware)
+	s.Router.Use(middleware.LoggingMiddleware)
+
+	// Requests that break the OpenAPI document are rejected before the
+	// handlers, and before they can claim an idempotency key
+	validator := openapi.NewValidator(openapi.Spec())
+	validator.ValidateResponses = cfg.ValidateResponses
+	s.Router.Use(validator.Handler)
+	s.Router.Use(handlers.GuardDeleted)
+
+	// Retried POSTs carrying an Idempotency-Key replay the first response.
+	// Keys belong to the caller named in X-Actor.
+	s.Idempotency.Scope = func(r *http.Request) string {
+		return strings.TrimSpace(r.Header.Get(handlers.ActorHeader))
+	}
+	// Stored responses can carry client PII and webhook secrets, so they are
+	// encrypted like the clients table
+	if handlers.PIIEncrypter != nil {
+		s.Idempotency.Cipher = handlers.PIIEncrypter
+	}
+	// Imports are the largest bodies a handler accepts
+	s.Idempotency.MaxBodySize = handlers.MaxImportRequestSize
+	s.Router.Use(s.Idempotency.Handler)
+
+	for _, route := range routes {
+		s.Router.HandleFunc(route.Path, route.Handler).Methods(route.Method).Name(route.Name)
+	}
+
+	// The API version is chosen before routing
+	versions := &middleware.Versioning{
+		Router:       s.Router,
+		DeprecatedAt: cfg.APIV1DeprecatedAt,
+		SunsetAt:     cfg.APIV1SunsetAt,
+	}
+	s.handler = versions.Handler(s.Router)
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// Serve serves the API on l until ctx is done, then shuts down gracefully: it
+// stops accepting connections, ends event streams so their clients reconnect
+// elsewhere, and waits up to ShutdownTimeout for requests in flight before
+// closing the connections left. It returns nil after a graceful shutdown.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	httpServer := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: s.cfg.ReadHeaderTimeout,
+		ReadTimeout:       s.cfg.ReadTimeout,
+		WriteTimeout:      s.cfg.WriteTimeout,
+		IdleTimeout:       s.cfg.IdleTimeout,
+	}
+	if s.broker != nil {
+		httpServer.RegisterOnShutdown(s.broker.Close)
+	}
+
+	failed := make(chan error, 1)
+	go func() { failed <- httpServer.Serve(l) }()
+	select {
+	case err := <-failed:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdown, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdown); err != nil {
+		httpServer.Close()
+		return fmt.Errorf("requests still running after %s: %w", s.cfg.ShutdownTimeout, err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/internal/handlers"
+	"backend/internal/middleware"
+	"backend/internal/openapi"
+	"backend/internal/stream"
+
+	"github.com/gorilla/mux"
+)
+
+func TestEveryRouteIsDocumented(t *testing.T) {
+	doc := openapi.Spec()
+	registered := map[string]bool{}
+	for _, route := range Routes() {
+		registered[route.Method+" "+route.Path] = true
+		op := doc.Operation(route.Method, route.Path)
+		if op == nil {
+			t.Errorf("%s %s is registered but missing from the OpenAPI document", route.Method, route.Path)
+		} else if op.OperationID != route.Name {
+			t.Errorf("%s %s is named %s but documented as %s", route.Method, route.Path, route.Name, op.OperationID)
+		}
+	}
+	for _, documented := range doc.Methods() {
+		if !registered[documented] {
+			t.Errorf("%s is documented but not registered", documented)
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	defer func(threshold float64) { handlers.DTIThreshold = threshold }(handlers.DTIThreshold)
+	srv, err := New(context.Background(), Config{
+		DocumentStorageDir: t.TempDir(),
+		DTIThreshold:       0.5,
+		APIV1DeprecatedAt:  time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if handlers.DTIThreshold != 0.5 || handlers.DocumentStore == nil {
+		t.Errorf("Expected the handlers to be configured")
+	}
+
+	names := map[string]bool{}
+	srv.Router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		names[route.GetName()] = true
+		return nil
+	})
+	if len(names) != len(Routes()) {
+		t.Errorf("Expected %d named routes, got %d", len(Routes()), len(names))
+	}
+
+	// Rejected by the request validator, after the request id and version
+	// headers are set
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest("GET", "/api/credits/abc", nil))
+	if rr.Code != http.StatusBadRequest || rr.Header().Get(middleware.RequestIDHeader) == "" ||
+		rr.Header().Get(middleware.APIVersionHeader) != "1" || rr.Header().Get("Deprecation") == "" {
+		t.Errorf("Expected a deprecated v1 400 with a request id, got %d %v", rr.Code, rr.Header())
+	}
+
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest("GET", "/health", nil))
+	if rr.Code != http.StatusOK || rr.Header().Get(middleware.APIVersionHeader) != "" {
+		t.Errorf("Expected the health check outside the versioned API, got %d %v", rr.Code, rr.Header())
+	}
+}
+
+func TestServeShutsDownGracefully(t *testing.T) {
+	defer func(interval time.Duration) { handlers.StreamHeartbeatInterval = interval }(handlers.StreamHeartbeatInterval)
+	defer func() { handlers.EventBroker = nil }()
+	handlers.StreamHeartbeatInterval = 20 * time.Millisecond
+	handlers.EventBroker = stream.NewBroker()
+
+	srv, err := New(context.Background(), Config{
+		DocumentStorageDir: t.TempDir(),
+		ReadTimeout:        50 * time.Millisecond,
+		WriteTimeout:       50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ctx, listener) }()
+
+	resp, err := http.Get("http://" + listener.Addr().String() + "/api/events/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// Heartbeats keep coming after the read and write timeouts
+	lines := bufio.NewScanner(resp.Body)
+	start := time.Now()
+	for time.Since(start) < 150*time.Millisecond {
+		if !lines.Scan() {
+			t.Fatalf("Expected the stream to outlive the timeouts, it ended after %s: %v", time.Since(start), lines.Err())
+		}
+	}
+
+	// Shutting down ends the stream and returns once it is done
+	cancel()
+	for lines.Scan() {
+		if strings.HasPrefix(lines.Text(), "data:") {
+			t.Errorf("Expected no events, got %q", lines.Text())
+		}
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Expected a graceful shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Serve to return after the stream ended")
+	}
+	if _, err := http.Get("http://" + listener.Addr().String() + "/health"); err == nil {
+		t.Error("Expected new connections to be refused after shutdown")
+	}
+}